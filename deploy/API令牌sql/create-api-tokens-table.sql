@@ -0,0 +1,19 @@
+-- 创建API令牌表（个人访问令牌，供脚本调用导入、导出接口）
+CREATE TABLE IF NOT EXISTS `api_tokens` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `user_id` int(11) NOT NULL COMMENT '所属用户ID，关联users表',
+  `name` varchar(50) NOT NULL COMMENT '令牌名称',
+  `token_hash` char(64) NOT NULL COMMENT '令牌SHA-256哈希（不保存原始令牌）',
+  `token_prefix` varchar(16) NOT NULL COMMENT '令牌前几位，用于辨认',
+  `scopes` varchar(100) NOT NULL COMMENT '权限范围，逗号分隔：read=只读，import=导入，export=导出',
+  `created_by` varchar(50) NOT NULL COMMENT '创建人',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `last_used_at` datetime DEFAULT NULL COMMENT '最后使用时间',
+  `last_used_ip` varchar(50) DEFAULT NULL COMMENT '最后使用IP',
+  `expires_at` datetime DEFAULT NULL COMMENT '过期时间（NULL表示永不过期）',
+  `revoked_at` datetime DEFAULT NULL COMMENT '吊销时间（NULL表示未吊销）',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_token_hash` (`token_hash`),
+  KEY `idx_user_id` (`user_id`),
+  CONSTRAINT `fk_api_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='API令牌表';
@@ -49,11 +49,14 @@ if %errorlevel% neq 0 (
 echo ✓ 数据库创建成功
 echo.
 
-REM 执行建表SQL
+REM 执行数据库迁移建表（需先在 config\config.json 中配置数据库连接）
 echo [2/3] 创建数据库表...
-mysql -u %DB_USER% -p%DB_PASS% %DB_NAME% < "%~dp0create-all-tables.sql"
-if %errorlevel% neq 0 (
-    echo 错误: 创建表失败，请检查SQL文件是否存在
+pushd "%~dp0.."
+ops-web.exe migrate up
+set MIGRATE_RESULT=%errorlevel%
+popd
+if %MIGRATE_RESULT% neq 0 (
+    echo 错误: 创建表失败，请确认已编译 ops-web.exe 并配置 config\config.json
     pause
     exit /b 1
 )
@@ -35,13 +35,12 @@ mysql -u "$DB_USER" -p"$DB_PASS" -e "CREATE DATABASE IF NOT EXISTS \`$DB_NAME\`
 echo "✓ 数据库创建成功"
 echo ""
 
-# 执行建表SQL
+# 执行数据库迁移建表（需先在 config/config.json 中配置数据库连接）
 echo "[2/3] 创建数据库表..."
-mysql -u "$DB_USER" -p"$DB_PASS" "$DB_NAME" < "$SCRIPT_DIR/create-all-tables.sql"
-if [ $? -eq 0 ]; then
+if (cd "$SCRIPT_DIR/.." && ./ops-web migrate up); then
     echo "✓ 数据库表创建成功"
 else
-    echo "✗ 数据库表创建失败"
+    echo "✗ 数据库表创建失败（请确认已编译 ops-web 并配置 config/config.json）"
     exit 1
 fi
 echo ""
@@ -0,0 +1,10 @@
+-- 用户会话表增加登录IP、浏览器标识、最后活跃时间字段
+-- 会话ID改为保存令牌的SHA-256哈希，旧格式会话全部失效，需先清空
+DELETE FROM `user_sessions`;
+
+ALTER TABLE `user_sessions`
+  MODIFY COLUMN `session_id` char(64) NOT NULL COMMENT '会话ID（会话令牌的SHA-256哈希，不保存原始令牌）',
+  ADD COLUMN `ip` varchar(50) DEFAULT NULL COMMENT '登录IP' AFTER `role_code`,
+  ADD COLUMN `user_agent` varchar(512) DEFAULT NULL COMMENT '浏览器标识' AFTER `ip`,
+  ADD COLUMN `last_seen_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '最后活跃时间' AFTER `user_agent`,
+  MODIFY COLUMN `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '登录时间';
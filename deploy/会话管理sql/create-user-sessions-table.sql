@@ -0,0 +1,14 @@
+-- 创建用户会话表（会话持久化，服务重启后无需重新登录）
+CREATE TABLE IF NOT EXISTS `user_sessions` (
+  `session_id` varchar(128) NOT NULL COMMENT '会话ID',
+  `user_id` int(11) NOT NULL COMMENT '用户ID，关联users表',
+  `username` varchar(50) NOT NULL COMMENT '用户名',
+  `role_id` int(11) NOT NULL COMMENT '角色ID',
+  `role_code` tinyint(4) NOT NULL COMMENT '角色代码：0=管理员，1=普通用户',
+  `expire_at` datetime NOT NULL COMMENT '过期时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`session_id`),
+  KEY `idx_user_id` (`user_id`),
+  KEY `idx_expire_at` (`expire_at`),
+  CONSTRAINT `fk_user_sessions_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户会话表';
@@ -0,0 +1,25 @@
+-- 用户表增加密码修改时间字段（用于密码有效期，已有用户从执行本脚本时开始计算）
+ALTER TABLE `users`
+  ADD COLUMN `password_changed_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '密码最后修改时间' AFTER `password`;
+
+-- 创建历史密码表（用于禁止重复使用最近的密码）
+CREATE TABLE IF NOT EXISTS `password_history` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `user_id` int(11) NOT NULL COMMENT '用户ID，关联users表',
+  `password_hash` varchar(255) NOT NULL COMMENT '历史密码（bcrypt加密）',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '设置时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_user_id` (`user_id`),
+  CONSTRAINT `fk_password_history_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='历史密码表';
+
+-- 插入默认密码策略（已存在时不覆盖）
+INSERT INTO `system_settings` (`param_key`, `param_value`) VALUES
+('password_min_length', '8'),
+('password_require_upper', '0'),
+('password_require_lower', '1'),
+('password_require_digit', '1'),
+('password_require_symbol', '0'),
+('password_history_count', '3'),
+('password_max_age_days', '0')
+ON DUPLICATE KEY UPDATE `param_key` = `param_key`;
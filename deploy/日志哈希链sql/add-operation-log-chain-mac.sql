@@ -0,0 +1,6 @@
+-- 链头和归档检查点校验值（已执行 create-operation-log-chain.sql 的库升级时执行）
+ALTER TABLE `operation_log_chain`
+  ADD COLUMN `mac` char(64) NOT NULL DEFAULT '' COMMENT '链头校验值 HMAC-SHA256(last_id, last_hash)' AFTER `last_hash`;
+
+ALTER TABLE `operation_log_checkpoints`
+  ADD COLUMN `mac` char(64) NOT NULL DEFAULT '' COMMENT '检查点校验值 HMAC-SHA256(检查点各字段)' AFTER `created_by`;
@@ -0,0 +1,20 @@
+-- 用户机构关系表（一个用户可关联多个机构，只能查看这些机构的档案）
+CREATE TABLE IF NOT EXISTS `user_organizations` (
+  `user_id` int(11) NOT NULL COMMENT '用户ID，关联users表',
+  `organization_id` int(11) NOT NULL COMMENT '机构ID，关联organizations表',
+  PRIMARY KEY (`user_id`, `organization_id`),
+  KEY `idx_organization_id` (`organization_id`),
+  CONSTRAINT `fk_user_organizations_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_user_organizations_org` FOREIGN KEY (`organization_id`) REFERENCES `organizations` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户机构关系表';
+
+-- 已导入档案中的机构写入机构字典
+INSERT IGNORE INTO `organizations` (`name`)
+SELECT DISTINCT `organization` FROM `audit_tasks` WHERE `organization` <> '';
+
+INSERT IGNORE INTO `organizations` (`name`)
+SELECT DISTINCT `organization` FROM `checkpoint_tasks` WHERE `organization` <> '';
+
+-- 升级后非管理员角色暂时保留查看全部机构的权限，为用户分配机构后再在权限设置页面取消
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+SELECT `id`, 'all_organizations' FROM `user_role` WHERE `role_code` <> 0;
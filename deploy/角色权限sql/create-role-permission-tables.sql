@@ -0,0 +1,60 @@
+-- 用户角色关系表（一个用户可拥有多个角色）
+CREATE TABLE IF NOT EXISTS `user_role_members` (
+  `user_id` int(11) NOT NULL COMMENT '用户ID，关联users表',
+  `role_id` int(11) NOT NULL COMMENT '角色ID，关联user_role表',
+  PRIMARY KEY (`user_id`, `role_id`),
+  KEY `idx_role_id` (`role_id`),
+  CONSTRAINT `fk_role_members_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_role_members_role` FOREIGN KEY (`role_id`) REFERENCES `user_role` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户角色关系表';
+
+-- 角色权限表（管理员角色固定拥有全部权限，不在此表中保存）
+CREATE TABLE IF NOT EXISTS `role_permissions` (
+  `role_id` int(11) NOT NULL COMMENT '角色ID，关联user_role表',
+  `permission` varchar(50) NOT NULL COMMENT '权限代码',
+  PRIMARY KEY (`role_id`, `permission`),
+  CONSTRAINT `fk_role_permissions_role` FOREIGN KEY (`role_id`) REFERENCES `user_role` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='角色权限表';
+
+-- 现有用户的角色写入角色关系表
+INSERT IGNORE INTO `user_role_members` (`user_id`, `role_id`)
+SELECT `id`, `role_id` FROM `users`;
+
+-- 非管理员角色授予原来普通用户即可使用的功能，保持升级前的行为
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+SELECT r.`id`, p.`permission`
+FROM `user_role` r
+JOIN (
+  SELECT 'audit_edit' AS `permission`
+  UNION ALL SELECT 'audit_sample'
+  UNION ALL SELECT 'reminder_manage'
+  UNION ALL SELECT 'data_export'
+  UNION ALL SELECT 'attachment_upload'
+  UNION ALL SELECT 'attachment_download'
+  UNION ALL SELECT 'device_password_view'
+) p
+WHERE r.`role_code` <> 0;
+
+-- 原“允许普通用户导入/删除”参数转换为角色权限
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+SELECT r.`id`, 'device_import' FROM `user_role` r
+WHERE r.`role_code` <> 0
+  AND EXISTS (SELECT 1 FROM `system_settings` WHERE `param_key` = 'allow_device_audit_import' AND `param_value` IN ('1', 'true', 'True', 'TRUE'));
+
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+SELECT r.`id`, 'checkpoint_import' FROM `user_role` r
+WHERE r.`role_code` <> 0
+  AND EXISTS (SELECT 1 FROM `system_settings` WHERE `param_key` = 'allow_checkpoint_audit_import' AND `param_value` IN ('1', 'true', 'True', 'TRUE'));
+
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+SELECT r.`id`, 'archive_delete' FROM `user_role` r
+WHERE r.`role_code` <> 0
+  AND EXISTS (SELECT 1 FROM `system_settings` WHERE `param_key` IN ('allow_device_audit_delete', 'allow_checkpoint_audit_delete') AND `param_value` IN ('1', 'true', 'True', 'TRUE'));
+
+-- 删除已不再使用的参数
+DELETE FROM `system_settings` WHERE `param_key` IN (
+  'allow_device_audit_import',
+  'allow_device_audit_delete',
+  'allow_checkpoint_audit_import',
+  'allow_checkpoint_audit_delete'
+);
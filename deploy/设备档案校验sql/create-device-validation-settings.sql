@@ -0,0 +1,15 @@
+-- 设备档案字段校验：经纬度允许范围参数
+-- 默认为我国陆地和近海的外接矩形，可按本辖区修改（如只允许本市范围内的坐标）
+
+INSERT INTO `system_settings` (`param_key`, `param_value`) VALUES
+('geo_min_longitude', '73.5'),
+('geo_max_longitude', '135.1'),
+('geo_min_latitude', '3.8'),
+('geo_max_latitude', '53.6')
+ON DUPLICATE KEY UPDATE `param_key` = `param_key`;
+
+-- 按辖区收窄范围示例（杭州市）：
+-- UPDATE `system_settings` SET `param_value` = '118.3' WHERE `param_key` = 'geo_min_longitude';
+-- UPDATE `system_settings` SET `param_value` = '120.8' WHERE `param_key` = 'geo_max_longitude';
+-- UPDATE `system_settings` SET `param_value` = '29.2'  WHERE `param_key` = 'geo_min_latitude';
+-- UPDATE `system_settings` SET `param_value` = '30.6'  WHERE `param_key` = 'geo_max_latitude';
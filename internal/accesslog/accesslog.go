@@ -0,0 +1,129 @@
+package accesslog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader 请求ID响应头；反向代理已设置该请求头时沿用其值，便于与代理日志对应
+const RequestIDHeader = "X-Request-ID"
+
+// quietPaths 页面定时轮询和监控探测的地址，访问日志只在 debug 级别输出
+var quietPaths = map[string]bool{
+	"/session/status":     true,
+	"/session/timeout.js": true,
+	"/healthz":            true,
+	"/readyz":             true,
+}
+
+// requestInfo 本次请求的信息，认证中间件解析出用户后回填用户名
+type requestInfo struct {
+	id       string
+	username string
+}
+
+type requestInfoKey struct{}
+
+// Middleware 为每个请求分配请求ID（写入响应头和处理期间的全部日志），请求结束后记录访问日志
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{id: requestID(r)}
+		unbind := logger.BindRequestID(info.id)
+		defer unbind()
+
+		w.Header().Set(RequestIDHeader, info.id)
+		rec := &statusRecorder{ResponseWriter: w}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+
+		defer func() {
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			username := info.username
+			if username == "" {
+				username = "-"
+			}
+			logf := logger.Infof
+			switch {
+			case rec.status >= http.StatusInternalServerError:
+				logf = logger.Warnf
+			case quietPaths[r.URL.Path]:
+				logf = logger.Debugf
+			}
+			logf("访问日志 method=%s path=%q status=%d bytes=%d latency=%dms user=%s ip=%s",
+				r.Method, r.URL.RequestURI(), rec.status, rec.bytes, time.Since(start).Milliseconds(),
+				username, operationlog.ClientIP(r))
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// SetUsername 记录本次请求的当前用户（由认证中间件调用，写入访问日志）
+func SetUsername(r *http.Request, username string) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.username = username
+	}
+}
+
+// RequestID 本次请求的请求ID，未经过 Middleware 时返回空字符串
+func RequestID(r *http.Request) string {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// requestID 沿用代理传入的请求ID（只接受字母、数字和 -_.，最长64位），否则随机生成
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); id != "" && len(id) <= 64 && strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.") == "" {
+		return id
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
+
+// statusRecorder 记录响应状态码和字节数
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush 支持分块输出
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问原始 ResponseWriter
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,291 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"ops-web/internal/auth"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strings"
+)
+
+// PageData 我的账号页面数据
+type PageData struct {
+	Title       string
+	ActiveMenu  string
+	SubMenu     string
+	Message     string
+	MessageType string // success, error
+	CurrentUser *auth.User
+	CSRFToken   string
+	// 修改密码
+	CanChangePassword bool   // 本地账号可修改密码，目录账号的密码由目录管理
+	PasswordPolicy    string // 密码策略说明
+	PasswordExpireAt  string // 密码过期时间，未启用有效期时为空
+	// 双因素认证
+	TwoFactorEnabled  bool
+	TwoFactorRequired bool         // 当前用户是否被要求启用（管理员且开启了强制要求）
+	NewSecret         string       // 未启用时生成的待绑定密钥
+	QRCode            template.URL // 待绑定密钥的二维码
+	RecoveryCodes     []string     // 启用成功后一次性展示的恢复码
+}
+
+// Handler 我的账号页面
+func Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	data, err := buildPageData(r, currentUser)
+	if err != nil {
+		logger.Errorf("我的账号-查询账号信息失败: %v, 用户名: %s", err, currentUser.Username)
+		http.Error(w, "查询账号信息失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data.Message = r.URL.Query().Get("message")
+	data.MessageType = r.URL.Query().Get("type")
+
+	renderTemplate(w, data)
+}
+
+// EnableTwoFactorHandler 绑定身份验证器并启用双因素认证
+func EnableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/account", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	secret := strings.TrimSpace(r.FormValue("secret"))
+	code := strings.TrimSpace(r.FormValue("code"))
+	if secret == "" || code == "" {
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("请输入验证码")+"&type=error", http.StatusFound)
+		return
+	}
+	step, ok := auth.VerifyTOTPCode(secret, code)
+	if !ok {
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("验证码错误，请确认手机时间准确后重试")+"&type=error", http.StatusFound)
+		return
+	}
+
+	codes, err := auth.EnableTwoFactor(currentUser.ID, secret, step)
+	if err != nil {
+		logger.Errorf("我的账号-启用双因素认证失败: %v, 用户名: %s", err, currentUser.Username)
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("启用双因素认证失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionEnable2FA,
+		EntityType: operationlog.EntityUser,
+		EntityID:   operationlog.ID(currentUser.ID),
+		Message:    "启用双因素认证",
+	})
+
+	// 恢复码只展示这一次，直接渲染页面而不是重定向
+	data, err := buildPageData(r, currentUser)
+	if err != nil {
+		logger.Errorf("我的账号-查询账号信息失败: %v, 用户名: %s", err, currentUser.Username)
+		http.Error(w, "查询账号信息失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data.Message = "双因素认证已启用"
+	data.MessageType = "success"
+	data.RecoveryCodes = codes
+	renderTemplate(w, data)
+}
+
+// DisableTwoFactorHandler 关闭双因素认证（需输入当前验证码确认）
+func DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/account", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	if currentUser.IsAdmin() && auth.RequireAdminTwoFactor() {
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("系统要求管理员必须启用双因素认证，不能关闭")+"&type=error", http.StatusFound)
+		return
+	}
+
+	code := strings.TrimSpace(r.FormValue("code"))
+	ok, err := auth.VerifyUserTwoFactor(currentUser.ID, code)
+	if err != nil {
+		logger.Errorf("我的账号-校验验证码失败: %v, 用户名: %s", err, currentUser.Username)
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("校验验证码失败")+"&type=error", http.StatusFound)
+		return
+	}
+	if !ok {
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("验证码错误")+"&type=error", http.StatusFound)
+		return
+	}
+
+	if err := auth.ResetTwoFactor(currentUser.ID); err != nil {
+		logger.Errorf("我的账号-关闭双因素认证失败: %v, 用户名: %s", err, currentUser.Username)
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("关闭双因素认证失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionDisable2FA,
+		EntityType: operationlog.EntityUser,
+		EntityID:   operationlog.ID(currentUser.ID),
+		Message:    "关闭双因素认证",
+	})
+
+	http.Redirect(w, r, "/account?message="+url.QueryEscape("双因素认证已关闭")+"&type=success", http.StatusFound)
+}
+
+// ChangePasswordHandler 修改自己的密码（需输入原密码）
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/account", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	if currentUser.AuthSource != auth.AuthSourceLocal {
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("目录账号的密码请在目录系统中修改")+"&type=error", http.StatusFound)
+		return
+	}
+
+	oldPassword := r.FormValue("old_password")
+	newPassword := r.FormValue("new_password")
+	if oldPassword == "" || newPassword == "" {
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("原密码和新密码不能为空")+"&type=error", http.StatusFound)
+		return
+	}
+	if newPassword != r.FormValue("confirm_password") {
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("两次输入的新密码不一致")+"&type=error", http.StatusFound)
+		return
+	}
+
+	ok, err := auth.VerifyPassword(currentUser.ID, oldPassword)
+	if err != nil {
+		logger.Errorf("我的账号-校验原密码失败: %v, 用户名: %s", err, currentUser.Username)
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("校验原密码失败")+"&type=error", http.StatusFound)
+		return
+	}
+	if !ok {
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionChangePassword,
+			EntityType: operationlog.EntityUser,
+			EntityID:   operationlog.ID(currentUser.ID),
+			Outcome:    operationlog.OutcomeFailure,
+			Message:    "修改密码失败（原密码错误）",
+		})
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("原密码错误")+"&type=error", http.StatusFound)
+		return
+	}
+
+	if err := auth.ChangePassword(currentUser.ID, newPassword); err != nil {
+		var policyErr *auth.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			http.Redirect(w, r, "/account?message="+url.QueryEscape(policyErr.Msg)+"&type=error", http.StatusFound)
+			return
+		}
+		logger.Errorf("我的账号-修改密码失败: %v, 用户名: %s", err, currentUser.Username)
+		http.Redirect(w, r, "/account?message="+url.QueryEscape("修改密码失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	// 修改密码后注销该账号在其他设备上的会话
+	revoked, err := auth.RevokeOtherSessions(r, currentUser.ID)
+	if err != nil {
+		logger.Errorf("我的账号-注销其他会话失败: %v, 用户名: %s", err, currentUser.Username)
+	}
+
+	action := "修改密码"
+	if revoked > 0 {
+		action = fmt.Sprintf("修改密码（注销其他会话 %d 个）", revoked)
+	}
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionChangePassword,
+		EntityType: operationlog.EntityUser,
+		EntityID:   operationlog.ID(currentUser.ID),
+		Message:    action,
+	})
+
+	http.Redirect(w, r, "/account?message="+url.QueryEscape("密码修改成功")+"&type=success", http.StatusFound)
+}
+
+// buildPageData 构造页面数据（未启用双因素认证时生成新的待绑定密钥）
+func buildPageData(r *http.Request, currentUser *auth.User) (PageData, error) {
+	data := PageData{
+		Title:       "我的账号",
+		ActiveMenu:  "settings",
+		SubMenu:     "account",
+		CurrentUser: currentUser,
+		CSRFToken:   auth.CSRFToken(r),
+	}
+
+	data.CanChangePassword = currentUser.AuthSource == auth.AuthSourceLocal
+	if data.CanChangePassword {
+		data.PasswordPolicy = auth.LoadPasswordPolicy().Description()
+		expireAt, err := auth.PasswordExpireAt(currentUser)
+		if err != nil {
+			return data, err
+		}
+		if !expireAt.IsZero() {
+			data.PasswordExpireAt = expireAt.Format("2006-01-02 15:04")
+		}
+	}
+
+	twoFactor, err := auth.GetTwoFactor(currentUser.ID)
+	if err != nil {
+		return data, err
+	}
+	data.TwoFactorEnabled = twoFactor.Enabled
+	data.TwoFactorRequired = currentUser.IsAdmin() && auth.RequireAdminTwoFactor()
+
+	if !twoFactor.Enabled {
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			return data, err
+		}
+		qr, err := auth.TOTPQRCode(auth.TOTPProvisioningURL(currentUser.Username, secret))
+		if err != nil {
+			return data, err
+		}
+		data.NewSecret = secret
+		data.QRCode = qr
+	}
+	return data, nil
+}
+
+// renderTemplate 渲染模板
+func renderTemplate(w http.ResponseWriter, data PageData) {
+	tmpl, err := template.ParseFiles("templates/account.html")
+	if err != nil {
+		logger.Errorf("我的账号-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		logger.Errorf("我的账号-模板渲染失败: %v", err)
+		http.Error(w, "模板渲染失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package auditprogress
+
+import (
+	"fmt"
+	"ops-web/internal/filelist"
+	"strings"
+	"unicode"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// importFields 导入 audit_details 的字段，顺序与导入模板一致（不含 task_id 和序号列）
+var importFields = []string{
+	"device_code", "original_device_code", "device_name", "division_code", "monitor_point_type",
+	"pickup", "parent_device", "construction_unit", "construction_unit_code", "management_unit",
+	"camera_dept", "admin_name", "admin_contact", "contractor", "maintain_unit", "device_vendor",
+	"device_model", "camera_type", "access_method", "camera_function_type", "video_encoding_format",
+	"image_resolution", "camera_light_property", "backend_structure", "lens_type", "installation_type",
+	"height_type", "jurisdiction_police", "installation_address", "surrounding_landmark", "longitude",
+	"latitude", "installation_location", "monitoring_direction", "pole_number", "scene_picture",
+	"networking_property", "access_network", "ipv4_address", "ipv6_address", "mac_address",
+	"access_port", "associated_encoder", "device_username", "device_password", "channel_number",
+	"connection_protocol", "enabled_time", "scrapped_time", "device_status", "inspection_status",
+	"video_loss", "color_distortion", "video_blur", "brightness_exception", "video_interference",
+	"video_lag", "video_occlusion", "scene_change", "online_duration", "offline_duration",
+	"signaling_delay", "video_stream_delay", "key_frame_delay", "recording_retention_days",
+	"storage_device_code", "storage_channel_number", "storage_type", "cache_settings", "notes",
+	"collection_area_type",
+}
+
+// importHeaderFields 规范化后的表头 -> 字段名
+var importHeaderFields = func() map[string]string {
+	m := make(map[string]string, len(importFields))
+	for _, field := range importFields {
+		m[normalizeHeader(filelist.ExportHeaders[field])] = field
+	}
+	return m
+}()
+
+// serialHeader 序号列，导入时忽略
+var serialHeader = normalizeHeader(filelist.ExportHeaders["id"])
+
+// importColumns 导入文件中各字段所在的列（从0开始）
+type importColumns map[string]int
+
+// value 取行中字段的值，行比表头短时返回空字符串
+func (c importColumns) value(row []string, field string) string {
+	idx, ok := c[field]
+	if !ok {
+		return ""
+	}
+	return getRowValue(row, idx)
+}
+
+// fieldLabel 字段在导入模板中的表头，如“设备编码（*）”
+func fieldLabel(field string) string {
+	return filelist.ExportHeaders[field]
+}
+
+// isRequiredField 表头带（*）的字段为必填
+func isRequiredField(field string) bool {
+	return strings.Contains(fieldLabel(field), "（*）")
+}
+
+// normalizeHeader 规范化表头以便匹配：去掉空白和必填标记（*），半角括号转为全角，英文转小写
+func normalizeHeader(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		switch r {
+		case '(':
+			return '（'
+		case ')':
+			return '）'
+		case '＊':
+			return '*'
+		}
+		return unicode.ToLower(r)
+	}, s)
+	s = strings.ReplaceAll(s, "（*）", "")
+	return strings.ReplaceAll(s, "*", "")
+}
+
+// mapImportHeader 按表头识别各字段所在的列，列顺序可以与模板不同；
+// 返回无法识别、重复和缺少的列，有问题时不应导入任何数据
+func mapImportHeader(header []string) (importColumns, []string) {
+	columns := importColumns{}
+	var problems []string
+
+	for idx, cell := range header {
+		label := strings.TrimSpace(cell)
+		if label == "" {
+			continue
+		}
+		key := normalizeHeader(label)
+		if key == serialHeader {
+			continue
+		}
+
+		colName, _ := excelize.ColumnNumberToName(idx + 1)
+		field, ok := importHeaderFields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("第 %s 列“%s”无法识别，请与导入模板的表头保持一致", colName, label))
+			continue
+		}
+		if prev, dup := columns[field]; dup {
+			prevName, _ := excelize.ColumnNumberToName(prev + 1)
+			problems = append(problems, fmt.Sprintf("第 %s 列“%s”与第 %s 列重复", colName, label, prevName))
+			continue
+		}
+		columns[field] = idx
+	}
+
+	for _, field := range importFields {
+		if _, ok := columns[field]; !ok {
+			problems = append(problems, fmt.Sprintf("缺少“%s”列", fieldLabel(field)))
+		}
+	}
+	return columns, problems
+}
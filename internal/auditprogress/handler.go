@@ -1,6 +1,7 @@
 package auditprogress
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -9,10 +10,11 @@ import (
 	"net/http"
 	"ops-web/internal/auth"
 	"ops-web/internal/db"
+	"ops-web/internal/importcheck"
+	"ops-web/internal/lifecycle"
 	"ops-web/internal/filelist"
 	"ops-web/internal/logger"
 	"ops-web/internal/operationlog"
-	"ops-web/internal/permission"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -73,8 +75,14 @@ type PageData struct {
 	ImportCount   int
 	HighlightTaskID int // 需要高亮的任务ID（用于从提醒页面跳转过来时定位）
 	// 权限信息
-	CanImport bool // 是否可以导入
-	CanDelete bool // 是否可以删除
+	CanImport   bool // 是否可以导入
+	CanDelete   bool // 是否可以删除
+	CanEdit     bool // 是否可以编辑审核意见
+	CanSample   bool // 是否可以抽检
+	CanUpload   bool // 是否可以上传附件
+	CanDownload bool // 是否可以下载附件
+	Organizations []string // 导入时可选的机构
+	CSRFToken string
 }
 
 // Handler: 审核进度列表页 (GET)
@@ -102,6 +110,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	whereSQL := " WHERE 1=1"
 	args := []interface{}{}
 
+	// 只显示当前用户可见机构的档案
+	currentUser := auth.CurrentUser(r)
+	orgSQL, orgArgs := auth.OrganizationFilter(currentUser, "organization")
+	whereSQL += orgSQL
+	args = append(args, orgArgs...)
+
 	if searchName != "" {
 		whereSQL += " AND file_name LIKE ?"
 		args = append(args, "%"+searchName+"%")
@@ -206,7 +220,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		)
 
 		if err != nil {
-			fmt.Printf("❌ Database scan error: %v\n", err)
+			logger.Errorf("设备审核进度-数据扫描失败: %v", err)
 			continue
 		}
 
@@ -293,7 +307,6 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	query := strings.Join(queryParams, "&")
 
 	// 记录查询操作日志（如果有查询条件）
-	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil && (searchName != "" || auditStatus != "" || archiveType != "") {
 		action := "查询审核进度"
 		hasCondition := false
@@ -320,17 +333,16 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		if hasCondition {
 			action += "）"
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionQuery,
+			EntityType: operationlog.EntityAuditTask,
+			Message:    action,
+		})
 	}
 
-	// 检查权限
-	canImport := true
-	canDelete := true
-	if currentUser != nil && currentUser.RoleCode != 0 {
-		// 普通用户需要检查权限设置
-		canImport = permission.CheckPermission(currentUser, "allow_device_audit_import")
-		canDelete = permission.CheckPermission(currentUser, "allow_device_audit_delete")
-	}
+	// 检查权限（控制页面上各操作按钮是否显示）
+	canImport := currentUser.Can(auth.PermDeviceImport)
+	canDelete := currentUser.Can(auth.PermArchiveDelete)
 
 	// 计算当前页记录范围
 	startRecord := (page-1)*pageSize + 1
@@ -398,6 +410,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// 导入表单的机构候选项
+	organizations, err := auth.VisibleOrganizations(currentUser)
+	if err != nil {
+		logger.Errorf("审核进度-查询机构失败: %v", err)
+	}
+
 	data := PageData{
 		Title:         "审核进度",
 		ActiveMenu:    "audit",
@@ -424,6 +442,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		HighlightTaskID: highlightTaskID, // 需要高亮的任务ID
 		CanImport:     canImport,
 		CanDelete:     canDelete,
+		CanEdit:       currentUser.Can(auth.PermAuditEdit),
+		CanSample:     currentUser.Can(auth.PermAuditSample),
+		CanUpload:     currentUser.Can(auth.PermAttachmentUpload),
+		CanDownload:   currentUser.Can(auth.PermAttachmentDownload),
+		Organizations: organizations,
+		CSRFToken:     auth.CSRFToken(r),
 	}
 
 	tmpl, err := template.ParseFiles("templates/auditprogress.html")
@@ -441,7 +465,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// ImportHandler: 导入 XLSX 档案
+// ImportHandler: 导入 XLSX 档案（先校验全部数据行，有错误时显示校验结果，不导入任何数据）
 func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Redirect(w, r, "/audit/progress", http.StatusSeeOther)
@@ -449,20 +473,18 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查权限
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Error(w, "未登录", http.StatusUnauthorized)
 		return
 	}
 	
-	// 如果不是管理员，检查权限设置
-	if currentUser.RoleCode != 0 {
-		if !permission.CheckPermission(currentUser, "allow_device_audit_import") {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte(`{"error": "权限不足，请联系管理员开通设备审核进度档案导入权限"}`))
-			return
-		}
+	// 检查权限
+	if !currentUser.Can(auth.PermDeviceImport) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "权限不足，请联系管理员开通设备审核进度档案导入权限"}`))
+		return
 	}
 
 	// 解析表单数据
@@ -479,6 +501,10 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "机构名称不能为空", http.StatusBadRequest)
 		return
 	}
+	if !currentUser.CanAccessOrganization(organization) {
+		http.Error(w, "无权导入机构“"+organization+"”的档案", http.StatusForbidden)
+		return
+	}
 
 	// 获取上传的文件
 	file, fileHeader, err := r.FormFile("upload_file")
@@ -489,29 +515,11 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// 获取文件名（不含路径）
-	fileName := fileHeader.Filename
-	// 去除扩展名，只保留文件名部分作为档案名称，并去除前后空格
-	fileNameWithoutExt := strings.TrimSpace(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
-
-	// 解析Excel文件
-	f, err := excelize.OpenReader(file)
-	if err != nil {
-		logger.Errorf("审核进度-Excel解析失败: %v, 文件名: %s", err, fileHeader.Filename)
-		http.Error(w, "Excel解析失败: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	sheetName := f.GetSheetName(0)
-
-	rows, err := f.GetRows(sheetName)
+	// 读取文件内容（校验未通过时暂存，供下载错误标注文件和仅导入有效行使用）
+	data, err := io.ReadAll(file)
 	if err != nil {
-		logger.Errorf("审核进度-数据读取失败: %v, 文件名: %s", err, fileHeader.Filename)
-		http.Error(w, "数据读取失败: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if len(rows) < 2 {
-		http.Error(w, "Excel文件至少需要包含表头和数据行", http.StatusBadRequest)
+		logger.Errorf("审核进度-文件读取失败: %v, 文件名: %s", err, fileHeader.Filename)
+		http.Error(w, "文件读取失败", http.StatusBadRequest)
 		return
 	}
 
@@ -537,8 +545,190 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	importArchive(w, r, currentUser, importRequest{
+		FileName:        fileHeader.Filename,
+		Data:            data,
+		Organization:    organization,
+		IsSingleSoldier: isSingleSoldier,
+		ArchiveType:     archiveType,
+	}, "")
+}
+
+// ImportConfirmHandler: 仅导入校验通过的行（校验结果页面提交）
+func ImportConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/audit/progress", http.StatusSeeOther)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "未登录", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.FormValue("token")
+	pending, ok := importcheck.Load(token, currentUser.Username)
+	if !ok {
+		http.Error(w, "导入文件已过期，请重新上传", http.StatusGone)
+		return
+	}
+
+	req := importRequest{
+		FileName:     pending.FileName,
+		Data:         pending.Data,
+		Organization: pending.Form["organization"],
+		ArchiveType:  pending.Form["archive_type"],
+	}
+	req.IsSingleSoldier, _ = strconv.Atoi(pending.Form["is_single_soldier"])
+	if !currentUser.CanAccessOrganization(req.Organization) {
+		http.Error(w, "无权导入机构“"+req.Organization+"”的档案", http.StatusForbidden)
+		return
+	}
+
+	importArchive(w, r, currentUser, req, token)
+}
+
+// ImportErrorFileHandler: 下载错误标注文件（上传文件的副本，出错的单元格标红并加批注）
+func ImportErrorFileHandler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "未登录", http.StatusUnauthorized)
+		return
+	}
+
+	pending, ok := importcheck.Load(r.URL.Query().Get("token"), currentUser.Username)
+	if !ok {
+		http.Error(w, "导入文件已过期，请重新上传", http.StatusGone)
+		return
+	}
+
+	f, err := importcheck.ErrorWorkbook(pending.Data, pending.Problems)
+	if err != nil {
+		logger.Errorf("审核进度-生成错误标注文件失败: %v, 文件名: %s", err, pending.FileName)
+		http.Error(w, "生成错误标注文件失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionDownload,
+		EntityType: operationlog.EntityAuditTask,
+		Message:    fmt.Sprintf("下载审核档案导入错误标注文件（%s，%d 处错误）", pending.FileName, len(pending.Problems)),
+	})
+
+	fileName := strings.TrimSuffix(pending.FileName, filepath.Ext(pending.FileName))
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_错误标注.xlsx\"", fileName))
+	f.Write(w)
+}
+
+// importRequest 一次导入的文件和表单
+type importRequest struct {
+	FileName        string
+	Data            []byte
+	Organization    string
+	IsSingleSoldier int
+	ArchiveType     string
+}
+
+// importArchive 校验并导入档案。token 为空时有任何错误都不导入，显示校验结果；
+// token 非空表示用户在校验结果页面选择了仅导入有效行，跳过有错误的行
+func importArchive(w http.ResponseWriter, r *http.Request, currentUser *auth.User, req importRequest, token string) {
+	validOnly := token != ""
+
+	// 去除扩展名，只保留文件名部分作为档案名称，并去除前后空格
+	fileNameWithoutExt := strings.TrimSpace(strings.TrimSuffix(req.FileName, filepath.Ext(req.FileName)))
+	organization := req.Organization
+
+	// 解析Excel文件
+	f, err := excelize.OpenReader(bytes.NewReader(req.Data))
+	if err != nil {
+		logger.Errorf("审核进度-Excel解析失败: %v, 文件名: %s", err, req.FileName)
+		http.Error(w, "Excel解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sheetName := f.GetSheetName(0)
+
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		logger.Errorf("审核进度-数据读取失败: %v, 文件名: %s", err, req.FileName)
+		http.Error(w, "数据读取失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(rows) < 2 {
+		http.Error(w, "Excel文件至少需要包含表头和数据行", http.StatusBadRequest)
+		return
+	}
+
+	// 按表头识别各列，表头与模板不一致时不导入
+	columns, headerProblems := mapImportHeader(rows[0])
+	if len(headerProblems) > 0 {
+		logger.Warnf("审核进度-导入表头与模板不一致: %s, 文件名: %s", strings.Join(headerProblems, "；"), req.FileName)
+		http.Error(w, "导入文件的表头与导入模板不一致，未导入任何数据：\n"+strings.Join(headerProblems, "\n"), http.StatusBadRequest)
+		return
+	}
+
+	// 校验全部数据行，有错误时不写入任何数据
+	result, err := validateImportRows(rows, columns)
+	if err != nil {
+		logger.Errorf("审核进度-导入校验失败: %v, 文件名: %s", err, req.FileName)
+		http.Error(w, "导入校验失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !result.OK() && !validOnly {
+		token, err := importcheck.Save(&importcheck.Pending{
+			Owner:    currentUser.Username,
+			FileName: req.FileName,
+			Data:     req.Data,
+			Form: map[string]string{
+				"organization":      req.Organization,
+				"is_single_soldier": strconv.Itoa(req.IsSingleSoldier),
+				"archive_type":      req.ArchiveType,
+			},
+			Problems: result.Problems,
+		})
+		if err != nil {
+			logger.Errorf("审核进度-暂存导入文件失败: %v, 文件名: %s", err, req.FileName)
+			http.Error(w, "暂存导入文件失败", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Warnf("审核进度-导入校验未通过: %d 行中 %d 行有错误（共 %d 处）, 文件名: %s", result.TotalRows, result.InvalidRows(), len(result.Problems), req.FileName)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionImport,
+			EntityType: operationlog.EntityAuditTask,
+			Outcome:    operationlog.OutcomeFailure,
+			Message:    fmt.Sprintf("导入 Excel（%s）校验未通过：%d 行中 %d 行有错误，未保存任何数据", req.FileName, result.TotalRows, result.InvalidRows()),
+		})
+
+		page := importcheck.NewReportPage(result)
+		page.Title = "设备档案导入校验结果"
+		page.ActiveMenu = "audit"
+		page.SubMenu = "audit_progress"
+		page.FileName = req.FileName
+		page.Organization = organization
+		page.Token = token
+		page.ErrorFileURL = "/audit/progress/import/errors?token=" + token
+		page.ConfirmURL = "/audit/progress/import/confirm"
+		page.BackURL = "/audit/progress"
+		page.CSRFToken = auth.CSRFToken(r)
+		page.API = auth.HasBearerToken(r)
+		importcheck.RenderReport(w, page)
+		return
+	}
+	if result.ValidRows() == 0 {
+		http.Error(w, "没有校验通过的数据行，未导入任何数据", http.StatusBadRequest)
+		return
+	}
+	skippedCount := result.InvalidRows()
+
+	// 服务停止时等待导入完成，超时后中断并回滚
+	ctx, done := lifecycle.Track("设备审核进度导入")
+	defer done()
+
 	// 开始事务
-	tx, err := db.DBInstance.Begin()
+	tx, err := db.DBInstance.BeginTx(ctx, nil)
 	if err != nil {
 		http.Error(w, "事务开始失败", http.StatusInternalServerError)
 		return
@@ -546,7 +736,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 1. 创建审核任务记录
 	insertTaskSQL := `INSERT INTO audit_tasks (file_name, organization, import_time, audit_status, is_single_soldier, archive_type) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := tx.Exec(insertTaskSQL, fileNameWithoutExt, organization, time.Now(), "未审核", isSingleSoldier, archiveType)
+	insertResult, err := tx.Exec(insertTaskSQL, fileNameWithoutExt, organization, time.Now(), "未审核", req.IsSingleSoldier, req.ArchiveType)
 	if err != nil {
 		tx.Rollback()
 		logger.Errorf("审核进度-创建审核任务失败: %v, SQL: %s", err, insertTaskSQL)
@@ -555,7 +745,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取插入的任务ID
-	taskID, err := result.LastInsertId()
+	taskID, err := insertResult.LastInsertId()
 	if err != nil {
 		tx.Rollback()
 		logger.Errorf("审核进度-获取任务ID失败: %v", err)
@@ -563,26 +753,8 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 2. 导入Excel数据到audit_details表
-	// 这里需要根据audit_details表结构来插入数据
-	// 参考filelist的导入逻辑，但字段映射需要根据audit_details表结构调整
-	insertDetailFields := []string{
-		"task_id", "device_code", "original_device_code", "device_name", "division_code", "monitor_point_type",
-		"pickup", "parent_device", "construction_unit", "construction_unit_code", "management_unit",
-		"camera_dept", "admin_name", "admin_contact", "contractor", "maintain_unit", "device_vendor",
-		"device_model", "camera_type", "access_method", "camera_function_type", "video_encoding_format",
-		"image_resolution", "camera_light_property", "backend_structure", "lens_type", "installation_type",
-		"height_type", "jurisdiction_police", "installation_address", "surrounding_landmark", "longitude",
-		"latitude", "installation_location", "monitoring_direction", "pole_number", "scene_picture",
-		"networking_property", "access_network", "ipv4_address", "ipv6_address", "mac_address",
-		"access_port", "associated_encoder", "device_username", "device_password", "channel_number",
-		"connection_protocol", "enabled_time", "scrapped_time", "device_status", "inspection_status",
-		"video_loss", "color_distortion", "video_blur", "brightness_exception", "video_interference",
-		"video_lag", "video_occlusion", "scene_change", "online_duration", "offline_duration",
-		"signaling_delay", "video_stream_delay", "key_frame_delay", "recording_retention_days",
-		"storage_device_code", "storage_channel_number", "storage_type", "cache_settings", "notes",
-		"collection_area_type",
-	}
+	// 2. 导入Excel数据到audit_details表（各字段所在的列由表头确定）
+	insertDetailFields := append([]string{"task_id"}, importFields...)
 
 	insertDetailSQL := fmt.Sprintf("INSERT INTO audit_details (%s) VALUES (%s)",
 		strings.Join(insertDetailFields, ", "),
@@ -598,67 +770,49 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	defer stmt.Close()
 
 	importedCount := 0
-	const expectedCols = 73 // Excel总共73列
 
-	// 跳过表头，从第2行开始
+	// 跳过表头，从第2行开始；跳过空行和校验未通过的行（仅导入有效行时）
 	for i, row := range rows {
-		if i == 0 {
-			continue // 跳过表头
+		if i == 0 || importcheck.IsBlankRow(row) || result.Invalid(i+1) {
+			continue
 		}
 
-		// 强制对齐到73列
-		for len(row) < expectedCols {
-			row = append(row, "")
+		if ctx.Err() != nil {
+			tx.Rollback()
+			logger.Warnf("审核进度-服务停止，导入已中断并回滚（已处理 %d 行）, 文件名: %s", importedCount, req.FileName)
+			operationlog.Record(r, currentUser.Username, operationlog.Entry{
+				Action:     operationlog.ActionImport,
+				EntityType: operationlog.EntityAuditTask,
+				Outcome:    operationlog.OutcomeFailure,
+				Message:    fmt.Sprintf("服务停止，导入 Excel（%s）已中断并回滚，未保存任何数据", req.FileName),
+			})
+			http.Error(w, "服务正在停止，导入已中断，数据未保存，请稍后重新导入", http.StatusServiceUnavailable)
+			return
 		}
 
-		// 数据类型转换（Excel列：A列是序号，B列开始是数据）
-		// filelist中row[31]对应longitude，row[32]对应latitude，row[64]对应recording_retention_days
-		lon, _ := strconv.ParseFloat(getRowValue(row, 31), 64)  // longitude列 (AF列)
-		lat, _ := strconv.ParseFloat(getRowValue(row, 32), 64)  // latitude列 (AG列)
-		recDays, _ := strconv.Atoi(getRowValue(row, 64))        // recording_retention_days列 (BS列)
+		// 数据类型转换
+		lon, _ := strconv.ParseFloat(columns.value(row, "longitude"), 64)
+		lat, _ := strconv.ParseFloat(columns.value(row, "latitude"), 64)
+		recDays, _ := strconv.Atoi(columns.value(row, "recording_retention_days"))
 
 		// 准备参数（task_id + 71个字段）
 		params := make([]interface{}, len(insertDetailFields))
 		params[0] = taskID // task_id
 
-		// 映射Excel列到数据库字段
-		// Excel结构: A列(id序号), B列(device_code), C列(original_device_code)...
-		// row[0]=A列, row[1]=B列, row[31]=AF列(longitude), row[32]=AG列(latitude)...
-		// params[0]=task_id, params[1]=device_code(B列row[1]), params[2]=original_device_code(C列row[2])...
-		// 所以params[j]对应row[j]，j从1开始（因为params[0]是task_id）
 		for j := 1; j < len(insertDetailFields); j++ {
-			excelIdx := j // Excel列索引（B列=row[1], C列=row[2]...，与filelist一致）
-			
-			switch excelIdx {
-			case 31: // longitude (Excel列32，row[31])
+			field := insertDetailFields[j]
+
+			switch field {
+			case "longitude":
 				params[j] = lon
-			case 32: // latitude (Excel列33，row[32])
+			case "latitude":
 				params[j] = lat
-			case 64: // recording_retention_days (Excel列65，row[64])
+			case "recording_retention_days":
 				params[j] = recDays
-			case 48: // enabled_time (Excel列49，row[48])
-				timeStr := strings.TrimSpace(getRowValue(row, excelIdx))
-				if timeStr == "" {
-					params[j] = nil
-				} else {
-					parsedTime, err := time.Parse(time.RFC3339, timeStr)
-					if err == nil {
-						params[j] = parsedTime.Format("2006-01-02 15:04:05")
-					} else {
-						params[j] = timeStr
-					}
-				}
+			case "enabled_time", "scrapped_time":
+				params[j] = importDateValue(columns.value(row, field))
 			default:
-				// 必填项列表（对应Excel列号，从B列开始，即row索引）
-				requiredCols := []int{1, 3, 4, 5, 8, 9, 10, 11, 12, 13, 14, 15, 16, 18, 20, 21, 22, 27, 28, 29, 30, 33, 34, 35, 38, 39, 41, 50, 71}
-				isRequired := false
-				for _, col := range requiredCols {
-					if excelIdx == col {
-						isRequired = true
-						break
-					}
-				}
-				params[j] = toDBValue(getRowValue(row, excelIdx), isRequired)
+				params[j] = toDBValue(columns.value(row, field), isRequiredField(field))
 			}
 		}
 
@@ -669,7 +823,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 			// 检查是否是唯一约束错误（device_code字段）
 			isUniqueErr, fieldName, fieldValue := checkUniqueConstraintError(execErr, "device_code")
 			if isUniqueErr {
-				logger.Errorf("审核进度-导入失败，第%d行数据违反唯一约束: device_code=%s, 文件名: %s", i+1, fieldValue, fileHeader.Filename)
+				logger.Errorf("审核进度-导入失败，第%d行数据违反唯一约束: device_code=%s, 文件名: %s", i+1, fieldValue, req.FileName)
 				
 				// 返回JSON格式的错误信息，前端可以弹窗显示
 				w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -685,7 +839,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			
-			logger.Errorf("审核进度-导入失败，第%d行数据错误: %v, 文件名: %s", i+1, execErr, fileHeader.Filename)
+			logger.Errorf("审核进度-导入失败，第%d行数据错误: %v, 文件名: %s", i+1, execErr, req.FileName)
 			errMsg := fmt.Sprintf("导入失败：第 %d 行数据错误。详细信息: %v", i+1, execErr)
 			http.Error(w, errMsg, http.StatusInternalServerError)
 			return
@@ -705,15 +859,33 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 提交事务
 	if err = tx.Commit(); err != nil {
-		logger.Errorf("审核进度-数据库提交失败: %v, 文件名: %s", err, fileHeader.Filename)
+		logger.Errorf("审核进度-数据库提交失败: %v, 文件名: %s", err, req.FileName)
 		http.Error(w, "数据库提交失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// 新机构加入机构字典，便于在用户管理中分配
+	if err := auth.EnsureOrganization(organization); err != nil {
+		logger.Errorf("审核进度-写入机构字典失败: %v, 机构: %s", err, organization)
+	}
+
+	// 仅导入有效行时删除暂存的文件
+	if validOnly {
+		importcheck.Delete(token)
+	}
+
 	// 记录导入操作日志
-	if currentUser := auth.GetCurrentUser(r); currentUser != nil {
-		action := fmt.Sprintf("导入审核档案 Excel（档案名称：%s，机构：%s，是否单兵设备：%d，档案类型：%s，共 %d 条数据）", fileNameWithoutExt, organization, isSingleSoldier, archiveType, importedCount)
-		operationlog.Record(r, currentUser.Username, action)
+	if currentUser := auth.CurrentUser(r); currentUser != nil {
+		action := fmt.Sprintf("导入审核档案 Excel（档案名称：%s，机构：%s，是否单兵设备：%d，档案类型：%s，共 %d 条数据）", fileNameWithoutExt, organization, req.IsSingleSoldier, req.ArchiveType, importedCount)
+		if skippedCount > 0 {
+			action += fmt.Sprintf("，跳过 %d 行校验未通过的数据", skippedCount)
+		}
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionImport,
+			EntityType: operationlog.EntityAuditTask,
+			EntityID:   strconv.FormatInt(taskID, 10),
+			Message:    action,
+		})
 	}
 
 	http.Redirect(w, r, "/audit/progress?message=ImportSuccess&count="+strconv.Itoa(importedCount), http.StatusSeeOther)
@@ -756,6 +928,7 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 			ActiveMenu string
 			SubMenu    string
 			Task       AuditTask
+			CSRFToken  string
 		}
 
 		data := EditPageData{
@@ -763,6 +936,7 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 			ActiveMenu: "audit",
 			SubMenu:    "audit_progress",
 			Task:       task,
+			CSRFToken:  auth.CSRFToken(r),
 		}
 
 		tmpl, err := template.ParseFiles("templates/auditedit.html")
@@ -815,8 +989,24 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// 修改前的审核意见和状态（写入操作日志）
+		var oldComment sql.NullString
+		var oldStatus string
+		err = tx.QueryRow("SELECT audit_comment, audit_status FROM audit_tasks WHERE id = ?", taskID).Scan(&oldComment, &oldStatus)
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			http.Error(w, "档案不存在", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			logger.Errorf("查询审核意见失败: %v, taskID: %d", err, taskID)
+			http.Error(w, "查询审核意见失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// 保存审核意见历史记录（如果内容有变化）
-		currentUser := auth.GetCurrentUser(r)
+		currentUser := auth.CurrentUser(r)
 		err = SaveAuditHistory(tx, taskID, auditComment, auditStatus, currentUser)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -872,7 +1062,7 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 		if auditComment != "" {
 			earliestDate, requiredDays, found := ParseVideoDaysIssue(auditComment)
 			if found {
-				logger.Errorf("解析到录像天数不足信息: taskID=%d, earliestDate=%s, requiredDays=%d", 
+				logger.Infof("解析到录像天数不足信息: taskID=%d, earliestDate=%s, requiredDays=%d", 
 					taskID, earliestDate.Format("2006-01-02"), requiredDays)
 				// 获取当前时间作为审核日期
 				auditDate := time.Now()
@@ -884,7 +1074,7 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 			} else {
 				// 如果审核意见包含相关关键词但未解析成功，记录日志用于调试
 				if strings.Contains(auditComment, "录像") && strings.Contains(auditComment, "不足") {
-					logger.Errorf("审核意见包含录像相关关键词但解析失败: taskID=%d, comment=%s", taskID, auditComment)
+					logger.Warnf("审核意见包含录像相关关键词但解析失败: taskID=%d, comment=%s", taskID, auditComment)
 				}
 			}
 		}
@@ -897,9 +1087,16 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 记录操作日志
-		if currentUser := auth.GetCurrentUser(r); currentUser != nil {
+		if currentUser := auth.CurrentUser(r); currentUser != nil {
 			action := fmt.Sprintf("编辑审核意见（档案ID：%d，状态：%s）", taskID, auditStatus)
-			operationlog.Record(r, currentUser.Username, action)
+			operationlog.Record(r, currentUser.Username, operationlog.Entry{
+				Action:     operationlog.ActionEditComment,
+				EntityType: operationlog.EntityAuditTask,
+				EntityID:   operationlog.ID(taskID),
+				Message:    action,
+				Before:     map[string]string{"audit_comment": oldComment.String, "audit_status": oldStatus},
+				After:      map[string]string{"audit_comment": auditComment, "audit_status": auditStatus},
+			})
 		}
 
 		http.Redirect(w, r, "/audit/progress?message=EditSuccess", http.StatusSeeOther)
@@ -1277,7 +1474,7 @@ func DetailExportHandler(w http.ResponseWriter, r *http.Request) {
 			item.ScenePicture.String, item.NetworkingProperty.String, item.AccessNetwork,
 			item.IPv4Address, item.IPv6Address.String, item.MACAddress,
 			item.AccessPort.String, item.AssociatedEncoder.String, item.DeviceUsername.String,
-			item.DevicePassword.String, item.ChannelNumber.String, item.ConnectionProtocol.String,
+			auth.MaskDevicePassword(auth.CurrentUser(r), item.DevicePassword.String), item.ChannelNumber.String, item.ConnectionProtocol.String,
 			item.EnabledTime.String, item.ScrappedTime.String, item.DeviceStatus,
 			item.InspectionStatus.String, item.VideoLoss.Int64, item.ColorDistortion.Int64,
 			item.VideoBlur.Int64, item.BrightnessException.Int64, item.VideoInterference.Int64,
@@ -1294,10 +1491,15 @@ func DetailExportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 记录导出操作日志
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser != nil {
 		action := fmt.Sprintf("导出设备审核档案明细 Excel（档案名称：%s）", task.FileName)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionExport,
+			EntityType: operationlog.EntityAuditTask,
+			EntityID:   operationlog.ID(taskID),
+			Message:    action,
+		})
 	}
 
 	// 输出文件（使用档案名称作为文件名）
@@ -1342,10 +1544,14 @@ func DownloadTemplateHandler(w http.ResponseWriter, r *http.Request) {
 	f.SetSheetRow(sheetName, "A1", &TemplateHeaders)
 
 	// 记录操作日志
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser != nil {
 		action := "下载审核档案导入模板"
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDownload,
+			EntityType: operationlog.EntityAuditTask,
+			Message:    action,
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
@@ -1362,20 +1568,18 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查权限
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Error(w, "未登录", http.StatusUnauthorized)
 		return
 	}
 	
-	// 如果不是管理员，检查权限设置
-	if currentUser.RoleCode != 0 {
-		if !permission.CheckPermission(currentUser, "allow_device_audit_delete") {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte(`{"error": "权限不足，请联系管理员开通设备审核进度档案删除权限"}`))
-			return
-		}
+	// 检查权限
+	if !currentUser.Can(auth.PermArchiveDelete) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "权限不足，请联系管理员开通设备审核进度档案删除权限"}`))
+		return
 	}
 
 	// 获取要删除的任务ID
@@ -1480,11 +1684,14 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 记录删除操作日志
-	currentUser2 := auth.GetCurrentUser(r)
-	if currentUser2 != nil {
-		action := fmt.Sprintf("删除审核档案（档案名称：%s，机构：%s，包含 %d 条明细）", task.FileName, task.Organization, detailCount)
-		operationlog.Record(r, currentUser2.Username, action)
-	}
+	action := fmt.Sprintf("删除审核档案（档案名称：%s，机构：%s，包含 %d 条明细）", task.FileName, task.Organization, detailCount)
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionDelete,
+		EntityType: operationlog.EntityAuditTask,
+		EntityID:   operationlog.ID(taskID),
+		Message:    action,
+		Before:     map[string]interface{}{"file_name": task.FileName, "organization": task.Organization, "detail_count": detailCount},
+	})
 
 	// 重定向回列表页（保留查询参数）
 	searchName := r.FormValue("file_name")
@@ -1513,7 +1720,7 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
@@ -1715,7 +1922,17 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 			action += fmt.Sprintf("，失败：%d", len(failedFiles))
 		}
 	}
-	operationlog.Record(r, currentUser.Username, action)
+	outcome := operationlog.OutcomeSuccess
+	if len(failedFiles) > 0 {
+		outcome = operationlog.OutcomeFailure
+	}
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionUpload,
+		EntityType: operationlog.EntityAuditTask,
+		EntityID:   operationlog.ID(taskID),
+		Outcome:    outcome,
+		Message:    action,
+	})
 
 	// 返回响应
 	w.WriteHeader(http.StatusOK)
@@ -1851,10 +2068,15 @@ func DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// 记录操作日志
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser != nil {
 		action := fmt.Sprintf("下载附件（档案：%s，文件名：%s）", archiveFileName, fileName)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDownload,
+			EntityType: operationlog.EntityAuditTask,
+			EntityID:   operationlog.ID(taskID),
+			Message:    action,
+		})
 	}
 }
 
@@ -1923,7 +2145,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 获取当前用户
-		currentUser := auth.GetCurrentUser(r)
+		currentUser := auth.CurrentUser(r)
 		if currentUser == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
@@ -1935,6 +2157,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 			SubMenu    string
 			Task       AuditTask
 			SampledBy  string
+			CSRFToken  string
 		}
 
 		data := SamplePageData{
@@ -1943,6 +2166,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 			SubMenu:    "audit_progress",
 			Task:       task,
 			SampledBy:  currentUser.Username,
+			CSRFToken:  auth.CSRFToken(r),
 		}
 
 		tmpl, err := template.ParseFiles("templates/auditsample.html")
@@ -1995,7 +2219,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 获取当前用户
-		currentUser := auth.GetCurrentUser(r)
+		currentUser := auth.CurrentUser(r)
 		if currentUser == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
@@ -2024,7 +2248,13 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 
 		// 记录操作日志
 		action := fmt.Sprintf("抽检设备审核档案（档案ID：%d，结果：%s）", taskID, sampleResult)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionSample,
+			EntityType: operationlog.EntityAuditTask,
+			EntityID:   operationlog.ID(taskID),
+			Message:    action,
+			After:      map[string]string{"sample_result": sampleResult, "sample_comment": sampleComment},
+		})
 
 		http.Redirect(w, r, "/audit/progress?message=SampleSuccess", http.StatusSeeOther)
 		return
@@ -2125,7 +2355,7 @@ func VideoReminderHandler(w http.ResponseWriter, r *http.Request) {
 	pageSize := 30
 
 	// 查询提醒列表
-	reminders, totalCount, err := GetVideoReminders(status, page, pageSize)
+	reminders, totalCount, err := GetVideoReminders(auth.CurrentUser(r), status, page, pageSize)
 	if err != nil {
 		logger.Errorf("查询录像提醒列表失败: %v", err)
 		http.Error(w, "查询提醒列表失败: "+err.Error(), http.StatusInternalServerError)
@@ -2194,6 +2424,7 @@ func VideoReminderHandler(w http.ResponseWriter, r *http.Request) {
 		FirstPage     int
 		LastPage      int
 		Query         string
+		CSRFToken     string
 	}
 
 	startRecord := (page-1)*pageSize + 1
@@ -2224,6 +2455,7 @@ func VideoReminderHandler(w http.ResponseWriter, r *http.Request) {
 		FirstPage:   1,
 		LastPage:    totalPages,
 		Query:       query,
+		CSRFToken:   auth.CSRFToken(r),
 	}
 
 	tmpl, err := template.ParseFiles("templates/auditvideoreminder.html")
@@ -2241,6 +2473,28 @@ func VideoReminderHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// checkRemindersAccessible 提醒任务所属档案不在当前用户可见机构内时返回403
+func checkRemindersAccessible(w http.ResponseWriter, r *http.Request, currentUser *auth.User, reminderIDs []int) bool {
+	ok, err := RemindersAccessible(currentUser, reminderIDs)
+	if err != nil {
+		logger.Errorf("录像提醒-检查机构权限失败: %v, IDs: %v", err, reminderIDs)
+		http.Error(w, "查询提醒任务失败: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionAccess,
+			EntityType: operationlog.EntityVideoReminder,
+			EntityID:   operationlog.IDs(reminderIDs),
+			Outcome:    operationlog.OutcomeDenied,
+			Message:    fmt.Sprintf("访问被拒绝（录像提醒不属于可见机构，提醒ID：%v）", reminderIDs),
+		})
+		http.Error(w, "权限不足：提醒任务所属档案不在您可见的机构内", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // CompleteReminderHandler: 标记提醒为已完成
 func CompleteReminderHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -2261,12 +2515,16 @@ func CompleteReminderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Error(w, "未登录", http.StatusUnauthorized)
 		return
 	}
 
+	if !checkRemindersAccessible(w, r, currentUser, []int{reminderID}) {
+		return
+	}
+
 	err = CompleteVideoReminder(reminderID, currentUser.Username)
 	if err != nil {
 		logger.Errorf("标记提醒为已完成失败: %v, reminderID: %d", err, reminderID)
@@ -2276,7 +2534,12 @@ func CompleteReminderHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 记录操作日志
 	action := fmt.Sprintf("标记录像提醒为已完成（提醒ID：%d）", reminderID)
-	operationlog.Record(r, currentUser.Username, action)
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionComplete,
+		EntityType: operationlog.EntityVideoReminder,
+		EntityID:   operationlog.ID(reminderID),
+		Message:    action,
+	})
 
 	// 重定向回提醒列表
 	status := r.FormValue("status")
@@ -2299,7 +2562,7 @@ func DeleteReminderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Error(w, "未登录", http.StatusUnauthorized)
 		return
@@ -2333,6 +2596,10 @@ func DeleteReminderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkRemindersAccessible(w, r, currentUser, reminderIDs) {
+		return
+	}
+
 	// 执行删除
 	err = DeleteVideoReminders(reminderIDs)
 	if err != nil {
@@ -2344,10 +2611,20 @@ func DeleteReminderHandler(w http.ResponseWriter, r *http.Request) {
 	// 记录操作日志
 	if len(reminderIDs) == 1 {
 		action := fmt.Sprintf("删除录像提醒任务（提醒ID：%d）", reminderIDs[0])
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDelete,
+			EntityType: operationlog.EntityVideoReminder,
+			EntityID:   operationlog.ID(reminderIDs[0]),
+			Message:    action,
+		})
 	} else {
 		action := fmt.Sprintf("批量删除录像提醒任务（共 %d 条）", len(reminderIDs))
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDelete,
+			EntityType: operationlog.EntityVideoReminder,
+			EntityID:   operationlog.IDs(reminderIDs),
+			Message:    action,
+		})
 	}
 
 	// 重定向回提醒列表
@@ -2388,6 +2665,7 @@ func ScheduleConfigHandler(w http.ResponseWriter, r *http.Request) {
 			ActiveMenu  string
 			SubMenu     string
 			Config      *ScheduleConfig
+			CSRFToken   string
 		}
 
 		data := ConfigPageData{
@@ -2395,6 +2673,7 @@ func ScheduleConfigHandler(w http.ResponseWriter, r *http.Request) {
 			ActiveMenu: "audit",
 			SubMenu:    "video_reminders",
 			Config:     config,
+			CSRFToken:  auth.CSRFToken(r),
 		}
 
 		tmpl, err := template.ParseFiles("templates/auditvideoreminderschedule.html")
@@ -2446,12 +2725,18 @@ func ScheduleConfigHandler(w http.ResponseWriter, r *http.Request) {
 
 		enabled := enabledStr == "1"
 
-		currentUser := auth.GetCurrentUser(r)
+		currentUser := auth.CurrentUser(r)
 		if currentUser == nil {
 			http.Error(w, "未登录", http.StatusUnauthorized)
 			return
 		}
 
+		// 修改前的配置（写入操作日志）
+		var before map[string]interface{}
+		if old, err := GetScheduleConfig(); err == nil && old != nil {
+			before = scheduleSnapshot(old.Frequency, old.Hour, int(old.DayOfWeek.Int64), old.Enabled)
+		}
+
 		err = SaveScheduleConfig(frequency, hour, dayOfWeek, enabled, currentUser.Username)
 		if err != nil {
 			logger.Errorf("保存定时配置失败: %v", err)
@@ -2461,9 +2746,25 @@ func ScheduleConfigHandler(w http.ResponseWriter, r *http.Request) {
 
 		// 记录操作日志
 		action := fmt.Sprintf("更新录像提醒定时任务配置（频率：%s，时间：%d:00）", frequency, hour)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionSaveSettings,
+			EntityType: operationlog.EntitySettings,
+			EntityID:   "video_reminder_schedule",
+			Message:    action,
+			Before:     before,
+			After:      scheduleSnapshot(frequency, hour, dayOfWeek, enabled),
+		})
 
 		http.Redirect(w, r, "/audit/progress/video-reminders/schedule?message=SaveSuccess", http.StatusSeeOther)
 	}
 }
 
+// scheduleSnapshot 操作日志中记录的录像提醒定时任务配置
+func scheduleSnapshot(frequency string, hour, dayOfWeek int, enabled bool) map[string]interface{} {
+	return map[string]interface{}{
+		"frequency":   frequency,
+		"hour":        hour,
+		"day_of_week": dayOfWeek,
+		"enabled":     enabled,
+	}
+}
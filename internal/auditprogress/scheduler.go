@@ -1,64 +1,140 @@
 package auditprogress
 
 import (
+	"context"
 	"fmt"
+	"ops-web/internal/lifecycle"
 	"ops-web/internal/logger"
+	"sync"
 	"time"
 )
 
+// VideoReminderStatus 录像提醒定时任务状态（诊断页面显示）
+type VideoReminderStatus struct {
+	Running   bool
+	Enabled   bool
+	Schedule  string    // 定时配置描述
+	NextRun   time.Time // 下次执行时间
+	LastRun   time.Time // 最近一次执行时间
+	LastError string    // 最近一次执行失败的原因，成功时为空
+}
+
+var (
+	reminderStatusMutex sync.Mutex
+	reminderStatus      VideoReminderStatus
+)
+
+// VideoReminderSchedulerStatus 返回录像提醒定时任务当前状态
+func VideoReminderSchedulerStatus() VideoReminderStatus {
+	reminderStatusMutex.Lock()
+	defer reminderStatusMutex.Unlock()
+	return reminderStatus
+}
+
+// updateReminderStatus 修改录像提醒定时任务状态
+func updateReminderStatus(update func(status *VideoReminderStatus)) {
+	reminderStatusMutex.Lock()
+	defer reminderStatusMutex.Unlock()
+	update(&reminderStatus)
+}
+
 // StartVideoReminderScheduler 启动录像提醒定时任务
-// 根据数据库配置执行定时任务
+// 根据数据库配置执行定时任务，服务停止时退出
 func StartVideoReminderScheduler() {
-	go func() {
-		for {
-			// 获取定时配置
-			config, err := GetScheduleConfig()
-			if err != nil {
-				logger.Errorf("获取定时配置失败: %v，使用默认配置（每天凌晨1点）", err)
-				config = &ScheduleConfig{
-					Frequency: "daily",
-					Hour:      1,
-					Enabled:   true,
-				}
+	updateReminderStatus(func(status *VideoReminderStatus) { status.Running = true })
+	lifecycle.Go("录像提醒定时任务", runVideoReminderScheduler)
+	logger.Infof("设备审核录像提醒定时任务已启动，将根据配置执行")
+}
+
+// runVideoReminderScheduler 录像提醒定时任务循环，ctx 取消时退出
+func runVideoReminderScheduler(ctx context.Context) {
+	defer func() {
+		updateReminderStatus(func(status *VideoReminderStatus) {
+			status.Running = false
+			status.NextRun = time.Time{}
+		})
+		logger.Infof("设备审核录像提醒定时任务已停止")
+	}()
+
+	for {
+		// 获取定时配置
+		config, err := GetScheduleConfig()
+		if err != nil {
+			logger.Warnf("获取定时配置失败: %v，使用默认配置（每天凌晨1点）", err)
+			config = &ScheduleConfig{
+				Frequency: "daily",
+				Hour:      1,
+				Enabled:   true,
 			}
+		}
 
-			if !config.Enabled {
-				logger.Errorf("定时任务已禁用，等待60秒后重新检查配置")
-				time.Sleep(60 * time.Second)
-				continue
+		if !config.Enabled {
+			updateReminderStatus(func(status *VideoReminderStatus) {
+				status.Enabled = false
+				status.Schedule = getScheduleDescription(config)
+				status.NextRun = time.Time{}
+			})
+			logger.Debugf("定时任务已禁用，等待60秒后重新检查配置")
+			if !lifecycle.Sleep(ctx, 60*time.Second) {
+				return
 			}
+			continue
+		}
 
-			// 计算下次执行时间
-			nextRun := calculateNextRunTime(config)
-			now := time.Now()
-			waitDuration := nextRun.Sub(now)
+		// 计算下次执行时间
+		nextRun := calculateNextRunTime(config)
+		now := time.Now()
+		waitDuration := nextRun.Sub(now)
+		updateReminderStatus(func(status *VideoReminderStatus) {
+			status.Enabled = true
+			status.Schedule = getScheduleDescription(config)
+			status.NextRun = nextRun
+		})
 
-			if waitDuration > 0 {
-				logger.Errorf("定时任务已配置：%s，下次执行时间：%s，等待 %v", 
-					getScheduleDescription(config), nextRun.Format("2006-01-02 15:04:05"), waitDuration)
-				time.Sleep(waitDuration)
+		if waitDuration > 0 {
+			logger.Infof("定时任务已配置：%s，下次执行时间：%s，等待 %v", 
+				getScheduleDescription(config), nextRun.Format("2006-01-02 15:04:05"), waitDuration)
+			if !lifecycle.Sleep(ctx, waitDuration) {
+				return
 			}
+		}
 
-			// 执行任务
-			logger.Errorf("开始执行设备审核录像提醒定时任务（配置：%s）", getScheduleDescription(config))
-			if err := ProcessVideoReminders(); err != nil {
-				logger.Errorf("执行设备审核录像提醒定时任务失败: %v", err)
-			}
+		// 执行任务
+		logger.Infof("开始执行设备审核录像提醒定时任务（配置：%s）", getScheduleDescription(config))
+		jobCtx, done := lifecycle.Track("录像提醒处理")
+		runErr := ProcessVideoReminders(jobCtx)
+		done()
+		if runErr != nil {
+			logger.Errorf("执行设备审核录像提醒定时任务失败: %v", runErr)
+		}
 
-			// 如果是每天执行，等待24小时；如果是每周执行，等待7天
-			if config.Frequency == "daily" {
-				time.Sleep(24 * time.Hour)
-			} else {
-				time.Sleep(7 * 24 * time.Hour)
+		// 如果是每天执行，等待24小时；如果是每周执行，等待7天
+		wait := 7 * 24 * time.Hour
+		if config.Frequency == "daily" {
+			wait = 24 * time.Hour
+		}
+		updateReminderStatus(func(status *VideoReminderStatus) {
+			status.LastRun = time.Now()
+			status.LastError = ""
+			if runErr != nil {
+				status.LastError = runErr.Error()
 			}
+			// 等待结束后按配置重新计算执行时间（配置不变时即为下次执行时间）
+			status.NextRun = nextRunAfter(config, status.LastRun.Add(wait))
+		})
+		if !lifecycle.Sleep(ctx, wait) {
+			return
 		}
-	}()
-	logger.Errorf("设备审核录像提醒定时任务已启动，将根据配置执行")
+	}
 }
 
 // calculateNextRunTime 计算下次执行时间
 func calculateNextRunTime(config *ScheduleConfig) time.Time {
-	now := time.Now()
+	return nextRunAfter(config, time.Now())
+}
+
+// nextRunAfter 计算 now 之后（含 now）的第一个执行时间
+func nextRunAfter(config *ScheduleConfig, now time.Time) time.Time {
 	today := time.Date(now.Year(), now.Month(), now.Day(), config.Hour, 0, 0, 0, now.Location())
 
 	if config.Frequency == "daily" {
@@ -0,0 +1,130 @@
+package auditprogress
+
+import (
+	"fmt"
+	"math"
+	"ops-web/internal/devicevalidate"
+	"ops-web/internal/importcheck"
+	"strings"
+)
+
+// fieldMaxLen audit_details 中长度不是 50 的文本字段
+var fieldMaxLen = map[string]int{
+	"device_code":          20,
+	"original_device_code": 20,
+	"contractor":           100,
+	"maintain_unit":        100,
+	"notes":                255,
+}
+
+// intFields 整数字段（巡检指标）
+var intFields = map[string]bool{
+	"video_loss": true, "color_distortion": true, "video_blur": true, "brightness_exception": true,
+	"video_interference": true, "video_lag": true, "video_occlusion": true, "scene_change": true,
+	"online_duration": true, "offline_duration": true, "signaling_delay": true,
+	"video_stream_delay": true, "key_frame_delay": true,
+}
+
+// dateFields 日期字段
+var dateFields = map[string]bool{
+	"enabled_time":  true,
+	"scrapped_time": true,
+}
+
+// validateImportRows 校验全部数据行（必填、格式、范围、编码标准、设备编码重复），收集所有错误；
+// 空行不计入数据行，导入时同样跳过
+func validateImportRows(rows [][]string, columns importColumns) (*importcheck.Result, error) {
+	result := importcheck.NewResult()
+	validator := devicevalidate.New()
+	codes := importcheck.UniqueChecker{}
+	var codeList []string
+
+	for i, row := range rows {
+		if i == 0 || importcheck.IsBlankRow(row) {
+			continue
+		}
+		result.TotalRows++
+		rowNum := i + 1
+
+		for _, field := range importFields {
+			value := strings.TrimSpace(columns.value(row, field))
+			if msg := checkImportField(validator, field, value); msg != "" {
+				result.Add(rowNum, columns[field], fieldLabel(field), value, msg)
+			}
+		}
+		get := func(field string) string {
+			return strings.TrimSpace(columns.value(row, field))
+		}
+		for _, p := range validator.Record(get) {
+			result.Add(rowNum, columns[p.Field], fieldLabel(p.Field), get(p.Field), p.Message)
+		}
+
+		code := strings.TrimSpace(columns.value(row, "device_code"))
+		if code == "" {
+			continue
+		}
+		if first, dup := codes.Seen(code, rowNum); dup {
+			result.Add(rowNum, columns["device_code"], fieldLabel("device_code"), code, fmt.Sprintf("设备编码与第 %d 行重复", first))
+			continue
+		}
+		codeList = append(codeList, code)
+	}
+
+	// 已导入的档案中存在的设备编码
+	existing, err := importcheck.ExistingValues("audit_details", "device_code", codeList)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codeList {
+		if existing[code] {
+			result.Add(codes[code], columns["device_code"], fieldLabel("device_code"), code, "设备编码已存在，不能重复导入")
+		}
+	}
+
+	result.Sort()
+	return result, nil
+}
+
+// checkImportField 校验单个字段，返回错误说明，通过时返回空字符串；
+// 设备编码、IP地址、经纬度等有标准的字段由 devicevalidate 校验
+func checkImportField(validator *devicevalidate.Validator, field, value string) string {
+	if value == "" {
+		if isRequiredField(field) {
+			return "必填项不能为空"
+		}
+		return ""
+	}
+	if msg := validator.Field(field, value); msg != "" {
+		return msg
+	}
+
+	switch {
+	case field == "recording_retention_days":
+		return importcheck.CheckInt(value, 0, 9999)
+	case intFields[field]:
+		return importcheck.CheckInt(value, math.MinInt32, math.MaxInt32)
+	case dateFields[field]:
+		if _, ok := importcheck.ParseDate(value); !ok {
+			return "不是有效的日期（如 2024-01-31）"
+		}
+		return ""
+	}
+
+	maxLen, ok := fieldMaxLen[field]
+	if !ok {
+		maxLen = 50
+	}
+	return importcheck.CheckLength(value, maxLen)
+}
+
+// importDateValue 日期字段转为数据库格式，空值为 NULL
+func importDateValue(value string) interface{} {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if t, ok := importcheck.ParseDate(value); ok {
+		return t.Format("2006-01-02")
+	}
+	return value
+}
@@ -1,8 +1,10 @@
 package auditprogress
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"ops-web/internal/auth"
 	"ops-web/internal/db"
 	"ops-web/internal/logger"
 	"regexp"
@@ -100,15 +102,15 @@ func ParseVideoDaysIssue(comment string) (earliestDate time.Time, requiredDays i
 				fmt.Sscanf(daysStr, "%d", &days)
 				// 验证天数是否为30、90或180
 				if days == 30 || days == 90 || days == 180 {
-					logger.Errorf("成功解析录像天数不足信息: dateStr=%s, daysStr=%s, parsedDate=%s, days=%d", 
+					logger.Debugf("成功解析录像天数不足信息: dateStr=%s, daysStr=%s, parsedDate=%s, days=%d", 
 						dateStr, daysStr, t.Format("2006-01-02"), days)
 					return t, days, true
 				}
 			}
 		}
-		logger.Errorf("日期或天数解析失败: dateStr=%s, daysStr=%s, comment=%s", dateStr, daysStr, comment)
+		logger.Debugf("日期或天数解析失败: dateStr=%s, daysStr=%s, comment=%s", dateStr, daysStr, comment)
 	} else {
-		logger.Errorf("未找到日期或天数: dateStr=%s, daysStr=%s, comment=%s", dateStr, daysStr, comment)
+		logger.Debugf("未找到日期或天数: dateStr=%s, daysStr=%s, comment=%s", dateStr, daysStr, comment)
 	}
 
 	return time.Time{}, 0, false
@@ -143,7 +145,7 @@ func CreateVideoReminder(tx *sql.Tx, taskID int, earliestDate time.Time, require
 
 	// 如果已存在，不重复创建
 	if exists > 0 {
-		logger.Errorf("录像提醒任务已存在，跳过创建: taskID=%d, earliestDate=%s, requiredDays=%d", 
+		logger.Infof("录像提醒任务已存在，跳过创建: taskID=%d, earliestDate=%s, requiredDays=%d", 
 			taskID, earliestDate.Format("2006-01-02"), requiredDays)
 		return nil
 	}
@@ -159,20 +161,20 @@ func CreateVideoReminder(tx *sql.Tx, taskID int, earliestDate time.Time, require
 		return err
 	}
 
-	logger.Errorf("创建录像提醒任务成功: taskID=%d, earliestDate=%s, requiredDays=%d, reminderDate=%s", 
+	logger.Infof("创建录像提醒任务成功: taskID=%d, earliestDate=%s, requiredDays=%d, reminderDate=%s", 
 		taskID, earliestDate.Format("2006-01-02"), requiredDays, reminderDate.Format("2006-01-02"))
 	
 	return nil
 }
 
 // ProcessVideoReminders 处理到期的提醒任务（定时任务调用）
-func ProcessVideoReminders() error {
+func ProcessVideoReminders(ctx context.Context) error {
 	// 查询到期的提醒任务（reminder_date <= 今天，且状态为pending）
 	today := time.Now().Format("2006-01-02")
 	querySQL := `SELECT id, task_id FROM audit_video_reminders 
 		WHERE reminder_date <= ? AND status = 'pending'`
 	
-	rows, err := db.DBInstance.Query(querySQL, today)
+	rows, err := db.DBInstance.QueryContext(ctx, querySQL, today)
 	if err != nil {
 		logger.Errorf("查询到期提醒任务失败: %v", err)
 		return err
@@ -205,28 +207,32 @@ func ProcessVideoReminders() error {
 		args[i] = id
 	}
 
-	_, err = db.DBInstance.Exec(updateSQL, args...)
+	_, err = db.DBInstance.ExecContext(ctx, updateSQL, args...)
 	if err != nil {
 		logger.Errorf("更新提醒任务状态失败: %v", err)
 		return err
 	}
 
-	logger.Errorf("处理到期提醒任务成功: 共 %d 条", len(reminderIDs))
+	logger.Infof("处理到期提醒任务成功: 共 %d 条", len(reminderIDs))
 	return nil
 }
 
-// GetVideoReminders 获取提醒任务列表
-func GetVideoReminders(status string, page, pageSize int) ([]VideoReminder, int, error) {
+// GetVideoReminders 获取提醒任务列表（只包含用户可见机构的档案）
+func GetVideoReminders(user *auth.User, status string, page, pageSize int) ([]VideoReminder, int, error) {
 	whereSQL := " WHERE 1=1"
 	args := []interface{}{}
 
+	orgSQL, orgArgs := auth.OrganizationFilter(user, "at.organization")
+	whereSQL += orgSQL
+	args = append(args, orgArgs...)
+
 	if status != "" {
 		whereSQL += " AND vr.status = ?"
 		args = append(args, status)
 	}
 
 	// 查询总数
-	countSQL := `SELECT COUNT(*) FROM audit_video_reminders vr` + whereSQL
+	countSQL := `SELECT COUNT(*) FROM audit_video_reminders vr LEFT JOIN audit_tasks at ON vr.task_id = at.id` + whereSQL
 	var totalCount int
 	err := db.DBInstance.QueryRow(countSQL, args...).Scan(&totalCount)
 	if err != nil {
@@ -303,7 +309,7 @@ func GetVideoReminders(status string, page, pageSize int) ([]VideoReminder, int,
 			}
 		}
 		if !parsed {
-			logger.Errorf("解析最早录像日期失败: %s, 错误: %v", earliestDateStr, parseErr)
+			logger.Warnf("解析最早录像日期失败: %s, 错误: %v", earliestDateStr, parseErr)
 			vr.EarliestVideoDate = time.Time{} // 保持零值
 		}
 		
@@ -324,7 +330,7 @@ func GetVideoReminders(status string, page, pageSize int) ([]VideoReminder, int,
 			}
 		}
 		if !parsed {
-			logger.Errorf("解析提醒日期失败: %s, 错误: %v", reminderDateStr, parseErr)
+			logger.Warnf("解析提醒日期失败: %s, 错误: %v", reminderDateStr, parseErr)
 			vr.ReminderDate = time.Time{} // 保持零值
 		}
 		
@@ -355,7 +361,7 @@ func GetVideoReminders(status string, page, pageSize int) ([]VideoReminder, int,
 			}
 		}
 		if !parsed {
-			logger.Errorf("解析创建时间失败: %s, 错误: %v", createdAtStr, parseErr)
+			logger.Warnf("解析创建时间失败: %s, 错误: %v", createdAtStr, parseErr)
 			vr.CreatedAt = time.Time{} // 保持零值
 		}
 		if notifiedAt.Valid {
@@ -374,6 +380,32 @@ func GetVideoReminders(status string, page, pageSize int) ([]VideoReminder, int,
 	return reminders, totalCount, nil
 }
 
+// RemindersAccessible 检查提醒任务所属档案是否都在用户可见机构内
+func RemindersAccessible(user *auth.User, reminderIDs []int) (bool, error) {
+	if user.AllOrganizations() || len(reminderIDs) == 0 {
+		return true, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(reminderIDs)), ",")
+	args := make([]interface{}, len(reminderIDs))
+	for i, id := range reminderIDs {
+		args[i] = id
+	}
+	orgSQL, orgArgs := auth.OrganizationFilter(user, "at.organization")
+
+	var total, visible int
+	err := db.DBInstance.QueryRow(`SELECT COUNT(*) FROM audit_video_reminders WHERE id IN (`+placeholders+`)`, args...).Scan(&total)
+	if err != nil {
+		return false, err
+	}
+	err = db.DBInstance.QueryRow(`SELECT COUNT(*) FROM audit_video_reminders vr
+		LEFT JOIN audit_tasks at ON vr.task_id = at.id
+		WHERE vr.id IN (`+placeholders+`)`+orgSQL, append(args, orgArgs...)...).Scan(&visible)
+	if err != nil {
+		return false, err
+	}
+	return visible == total, nil
+}
+
 // CompleteVideoReminder 标记提醒任务为已完成
 func CompleteVideoReminder(reminderID int, username string) error {
 	updateSQL := `UPDATE audit_video_reminders 
@@ -386,7 +418,7 @@ func CompleteVideoReminder(reminderID int, username string) error {
 		return err
 	}
 
-	logger.Errorf("标记提醒任务为已完成成功: reminderID=%d, username=%s", reminderID, username)
+	logger.Infof("标记提醒任务为已完成成功: reminderID=%d, username=%s", reminderID, username)
 	return nil
 }
 
@@ -512,7 +544,7 @@ func DeleteVideoReminders(reminderIDs []int) error {
 		return err
 	}
 
-	logger.Errorf("批量删除录像提醒任务成功: 共 %d 条, IDs: %v", len(reminderIDs), reminderIDs)
+	logger.Infof("批量删除录像提醒任务成功: 共 %d 条, IDs: %v", len(reminderIDs), reminderIDs)
 	return nil
 }
 
@@ -526,7 +558,7 @@ func DeleteVideoReminder(reminderID int) error {
 		return err
 	}
 
-	logger.Errorf("删除录像提醒任务成功: reminderID=%d", reminderID)
+	logger.Infof("删除录像提醒任务成功: reminderID=%d", reminderID)
 	return nil
 }
 
@@ -96,7 +96,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 查询统计数据
-	stats, summary := getStatistics(whereSQL, args)
+	stats, summary := getStatistics(auth.CurrentUser(r), whereSQL, args)
 
 	// 构建查询参数字符串（用于表单回显）
 	queryParams := []string{}
@@ -123,7 +123,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil && (month != "" || auditStatus != "") {
 		action := fmt.Sprintf("查询月度建档数据（月份：%s，建档状态：%s）", month, auditStatus)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionQuery,
+			EntityType: operationlog.EntityStatistics,
+			Message:    action,
+		})
 	}
 
 	// 渲染模板
@@ -142,8 +146,8 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getStatistics: 获取统计数据
-func getStatistics(whereSQL string, args []interface{}) ([]StatRow, StatRow) {
+// getStatistics: 获取统计数据（只统计用户可见机构的档案）
+func getStatistics(user *auth.User, whereSQL string, args []interface{}) ([]StatRow, StatRow) {
 	// 使用 map 来组织数据，key 是 management_unit
 	statsMap := make(map[string]*StatRow)
 
@@ -156,6 +160,7 @@ func getStatistics(whereSQL string, args []interface{}) ([]StatRow, StatRow) {
 	// 将 whereSQL 中的字段名替换为带表别名 ad. 的版本
 	auditWhereSQL := strings.ReplaceAll(whereSQL, "update_time", "ad.update_time")
 	auditWhereSQL = strings.ReplaceAll(auditWhereSQL, "audit_status", "ad.audit_status")
+	auditOrgSQL, auditOrgArgs := auth.OrganizationFilter(user, "at.organization")
 	
 	query := `
 		SELECT 
@@ -167,10 +172,11 @@ func getStatistics(whereSQL string, args []interface{}) ([]StatRow, StatRow) {
 		INNER JOIN audit_tasks at ON ad.task_id = at.id
 		` + auditWhereSQL + `
 		AND at.archive_type = '新增'
+		` + auditOrgSQL + `
 		ORDER BY ad.management_unit, ad.monitor_point_type
 	`
 
-	rows, err := db.DBInstance.Query(query, args...)
+	rows, err := db.DBInstance.Query(query, append(append([]interface{}{}, args...), auditOrgArgs...)...)
 	if err != nil {
 		logger.Errorf("月度建档数据-数据库查询失败: %v, SQL: %s, Args: %v", err, query, args)
 		return []StatRow{}, StatRow{ManagementUnit: "汇总"}
@@ -322,6 +328,7 @@ func getStatistics(whereSQL string, args []interface{}) ([]StatRow, StatRow) {
 	// 将 whereSQL 中的字段名替换为带表别名 cd. 的版本
 	checkpointWhereSQL := strings.ReplaceAll(whereSQL, "update_time", "cd.update_time")
 	checkpointWhereSQL = strings.ReplaceAll(checkpointWhereSQL, "audit_status", "cd.audit_status")
+	checkpointOrgSQL, checkpointOrgArgs := auth.OrganizationFilter(user, "ct.organization")
 	
 	checkpointQuery := `
 		SELECT 
@@ -331,10 +338,11 @@ func getStatistics(whereSQL string, args []interface{}) ([]StatRow, StatRow) {
 		INNER JOIN checkpoint_tasks ct ON cd.task_id = ct.id
 		` + checkpointWhereSQL + `
 		AND ct.archive_type = '新增'
+		` + checkpointOrgSQL + `
 		ORDER BY cd.management_unit, cd.checkpoint_point_type
 	`
 
-	checkpointRows, err := db.DBInstance.Query(checkpointQuery, args...)
+	checkpointRows, err := db.DBInstance.Query(checkpointQuery, append(append([]interface{}{}, args...), checkpointOrgArgs...)...)
 	if err != nil {
 		logger.Errorf("月度建档数据-卡口数据查询失败: %v, SQL: %s, Args: %v", err, checkpointQuery, args)
 		// 继续处理，不中断
@@ -488,7 +496,7 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取统计数据（应用筛选条件）
-	stats, summary := getStatistics(whereSQL, args)
+	stats, summary := getStatistics(auth.CurrentUser(r), whereSQL, args)
 
 	// 创建Excel文件
 	f := excelize.NewFile()
@@ -592,7 +600,11 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			action += "）"
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionExport,
+			EntityType: operationlog.EntityStatistics,
+			Message:    action,
+		})
 	}
 
 	// 输出文件
@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strings"
+	"time"
+)
+
+// API 令牌权限范围
+const (
+	ScopeRead   = "read"   // 只读：查看页面和查询数据
+	ScopeImport = "import" // 导入：上传导入文件
+	ScopeExport = "export" // 导出：导出统计、明细和下载文件
+)
+
+const (
+	apiTokenPrefix      = "opsk_" // 令牌前缀，便于识别泄露的令牌
+	apiTokenBytes       = 32
+	apiTokenDisplayLen  = 12 // 列表中显示的令牌前几位
+	apiTokenTouchPeriod = time.Minute
+)
+
+// APIScopes 全部权限范围及说明（按页面显示顺序）
+var APIScopes = []struct {
+	Code string
+	Name string
+}{
+	{ScopeRead, "只读"},
+	{ScopeImport, "导入"},
+	{ScopeExport, "导出"},
+}
+
+// APIToken 个人访问令牌（数据库中只保存令牌的SHA-256哈希）
+type APIToken struct {
+	ID         int
+	UserID     int
+	Username   string
+	Name       string
+	Prefix     string // 令牌前几位，用于辨认
+	Scopes     []string
+	CreatedBy  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time // 零值表示从未使用
+	LastUsedIP string
+	ExpiresAt  time.Time // 零值表示永不过期
+	RevokedAt  time.Time // 零值表示未吊销
+}
+
+// HasScope 判断令牌是否具有指定权限范围
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active 令牌是否可用（未吊销且未过期）
+func (t *APIToken) Active() bool {
+	return t.RevokedAt.IsZero() && (t.ExpiresAt.IsZero() || time.Now().Before(t.ExpiresAt))
+}
+
+type apiTokenContextKey struct{}
+
+// ErrInvalidScope 权限范围无效
+var ErrInvalidScope = errors.New("请至少选择一个有效的权限范围")
+
+// normalizeScopes 校验并去重权限范围，按 APIScopes 顺序返回
+func normalizeScopes(scopes []string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		valid := false
+		for _, scope := range APIScopes {
+			if scope.Code == s {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, ErrInvalidScope
+		}
+		selected[s] = true
+	}
+
+	var result []string
+	for _, scope := range APIScopes {
+		if selected[scope.Code] {
+			result = append(result, scope.Code)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrInvalidScope
+	}
+	return result, nil
+}
+
+// CreateAPIToken 为用户创建令牌，返回原始令牌（只在创建时显示一次）
+func CreateAPIToken(userID int, name string, scopes []string, expiresAt time.Time, createdBy string) (string, *APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("令牌名称不能为空")
+	}
+	if len([]rune(name)) > 50 {
+		return "", nil, errors.New("令牌名称不能超过50个字符")
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return "", nil, errors.New("过期时间须晚于当前时间")
+	}
+
+	var username string
+	err = db.DBInstance.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", nil, errors.New("用户不存在")
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("查询用户失败: %v", err)
+	}
+
+	buf := make([]byte, apiTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("生成令牌失败: %v", err)
+	}
+	raw := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	token := &APIToken{
+		UserID:    userID,
+		Username:  username,
+		Name:      name,
+		Prefix:    raw[:apiTokenDisplayLen],
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	var expires interface{}
+	if !expiresAt.IsZero() {
+		expires = expiresAt
+	}
+	result, err := db.DBInstance.Exec(
+		"INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		userID, name, hashSessionToken(raw), token.Prefix, strings.Join(scopes, ","), createdBy, token.CreatedAt, expires)
+	if err != nil {
+		return "", nil, fmt.Errorf("保存令牌失败: %v", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		token.ID = int(id)
+	}
+	return raw, token, nil
+}
+
+const apiTokenColumns = `t.id, t.user_id, u.username, t.name, t.token_prefix, t.scopes, t.created_by, t.created_at,
+	t.last_used_at, t.last_used_ip, t.expires_at, t.revoked_at`
+
+// scanAPIToken 扫描一行令牌记录
+func scanAPIToken(scanner interface{ Scan(...interface{}) error }) (*APIToken, error) {
+	var t APIToken
+	var scopes string
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+	var lastUsedIP sql.NullString
+	if err := scanner.Scan(&t.ID, &t.UserID, &t.Username, &t.Name, &t.Prefix, &scopes, &t.CreatedBy, &t.CreatedAt,
+		&lastUsedAt, &lastUsedIP, &expiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	t.LastUsedAt = lastUsedAt.Time
+	t.LastUsedIP = lastUsedIP.String
+	t.ExpiresAt = expiresAt.Time
+	t.RevokedAt = revokedAt.Time
+	return &t, nil
+}
+
+// ListAPITokens 查询全部令牌（含已吊销和已过期的），最新创建的在前
+func ListAPITokens() ([]*APIToken, error) {
+	rows, err := db.DBInstance.Query(
+		"SELECT " + apiTokenColumns + " FROM api_tokens t JOIN users u ON t.user_id = u.id ORDER BY t.id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetAPIToken 按ID查询令牌，令牌不存在时返回 nil
+func GetAPIToken(id int) (*APIToken, error) {
+	t, err := scanAPIToken(db.DBInstance.QueryRow(
+		"SELECT "+apiTokenColumns+" FROM api_tokens t JOIN users u ON t.user_id = u.id WHERE t.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// RevokeAPIToken 吊销令牌，令牌不存在时返回 nil
+func RevokeAPIToken(id int) (*APIToken, error) {
+	t, err := GetAPIToken(id)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	if _, err := db.DBInstance.Exec(
+		"UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// bearerToken 从 Authorization 请求头取出 Bearer 令牌，未携带时 ok 为 false
+func bearerToken(r *http.Request) (token string, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// HasBearerToken 请求是否携带了 Bearer 令牌（浏览器跨站请求无法附带该请求头，无需 CSRF 校验）
+func HasBearerToken(r *http.Request) bool {
+	_, ok := bearerToken(r)
+	return ok
+}
+
+// lookupAPIToken 根据原始令牌查询可用的令牌，不存在、已吊销或已过期时返回 nil
+func lookupAPIToken(raw string) (*APIToken, error) {
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil, nil
+	}
+	t, err := scanAPIToken(db.DBInstance.QueryRow(
+		"SELECT "+apiTokenColumns+" FROM api_tokens t JOIN users u ON t.user_id = u.id WHERE t.token_hash = ?", hashSessionToken(raw)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !t.Active() {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// apiTokenFromContext 获取已通过认证的 API 令牌（仅经过 RequireAuth/RequireAdmin 的请求）
+func apiTokenFromContext(r *http.Request) *APIToken {
+	t, _ := r.Context().Value(apiTokenContextKey{}).(*APIToken)
+	return t
+}
+
+// requiredAPIScope 访问该请求所需的权限范围，返回空字符串表示不允许通过令牌访问
+func requiredAPIScope(r *http.Request) string {
+	path := r.URL.Path
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if strings.HasSuffix(path, "/export") || strings.HasSuffix(path, "/download") || strings.HasSuffix(path, "/download-template") {
+			return ScopeExport
+		}
+		return ScopeRead
+	case http.MethodPost:
+		// /import/confirm：导入校验未通过时仅导入有效行
+		if strings.HasSuffix(path, "/import") || strings.HasSuffix(path, "/import/confirm") || strings.HasSuffix(path, "/upload") {
+			return ScopeImport
+		}
+	}
+	return ""
+}
+
+// authenticateAPIRequest 校验 Bearer 令牌及其权限范围，permission 不为空时还要求令牌所属用户拥有该权限，
+// 成功时返回附带令牌信息的请求，失败时已写出响应
+func authenticateAPIRequest(w http.ResponseWriter, r *http.Request, raw string, permission string) (*http.Request, bool) {
+	ip := operationlog.ClientIP(r)
+	token, err := lookupAPIToken(raw)
+	if err != nil {
+		logger.Errorf("API令牌-查询令牌失败: %v", err)
+		http.Error(w, "认证服务暂时不可用", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if token == nil {
+		logger.Warnf("API令牌-无效令牌: %s %s, IP: %s", r.Method, r.URL.Path, ip)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ops-web", error="invalid_token"`)
+		http.Error(w, "API令牌无效、已过期或已吊销", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	user := loadUser(token.UserID)
+	if user == nil {
+		http.Error(w, "令牌所属用户不存在", http.StatusUnauthorized)
+		return nil, false
+	}
+	r = r.WithContext(context.WithValue(r.Context(), apiTokenContextKey{}, token))
+	r = withUser(r, user)
+	r = operationlog.WithAPIAccess(r, token.Name)
+
+	scope := requiredAPIScope(r)
+	reason := ""
+	switch {
+	case scope == "":
+		reason = "令牌不支持该操作"
+	case !token.HasScope(scope):
+		reason = "令牌缺少 " + scope + " 权限"
+	case permission != "" && !user.Can(permission):
+		reason = "令牌所属用户缺少“" + PermissionName(permission) + "”权限"
+	}
+	if reason != "" {
+		operationlog.Record(r, user.Username, operationlog.Entry{
+			Action:     operationlog.ActionAPIAccess,
+			EntityType: operationlog.EntityAPIToken,
+			EntityID:   operationlog.ID(token.ID),
+			Outcome:    operationlog.OutcomeDenied,
+			Message:    fmt.Sprintf("API访问被拒绝（%s，%s %s）", reason, r.Method, r.URL.Path),
+		})
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ops-web", error="insufficient_scope"`)
+		http.Error(w, "权限不足："+reason, http.StatusForbidden)
+		return nil, false
+	}
+
+	now := time.Now()
+	if now.Sub(token.LastUsedAt) >= apiTokenTouchPeriod || token.LastUsedIP != ip {
+		if _, err := db.DBInstance.Exec(
+			"UPDATE api_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?", now, ip, token.ID); err != nil {
+			logger.Errorf("API令牌-更新最后使用时间失败: %v", err)
+		}
+	}
+	operationlog.Record(r, user.Username, operationlog.Entry{
+		Action:     operationlog.ActionAPIAccess,
+		EntityType: operationlog.EntityAPIToken,
+		EntityID:   operationlog.ID(token.ID),
+		Message:    fmt.Sprintf("API访问（%s %s）", r.Method, r.URL.Path),
+	})
+	return r, true
+}
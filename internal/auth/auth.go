@@ -2,7 +2,7 @@ package auth
 
 import (
 	"database/sql"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -16,37 +16,53 @@ import (
 
 const (
 	SessionCookieName = "ops_session"
-	SessionMaxAge     = 24 * 60 * 60 // 24小时
 )
 
+// secureCookies 启用 HTTPS 时为 true，会话等 Cookie 带 Secure 标记，只通过 HTTPS 发送
+var secureCookies bool
+
+// SetSecureCookies 设置 Cookie 是否带 Secure 标记（启用 HTTPS 时在main.go中调用）
+func SetSecureCookies(secure bool) {
+	secureCookies = secure
+}
+
 // User 用户信息结构
 type User struct {
-	ID       int
-	Username string
-	RoleID   int
-	RoleCode int    // 0=管理员，1=普通用户
-	RoleName string
+	ID         int
+	Username   string
+	RoleID     int
+	RoleCode   int    // 所有角色中最小的角色代码，0=管理员
+	RoleName   string // 所有角色名称，以顿号分隔
+	AuthSource string // 账号来源：local=本地账号，ldap=目录账号
+	RoleIDs     []int           // 用户拥有的全部角色
+	Permissions map[string]bool // 全部角色权限的并集
+	Organizations []string      // 关联的机构名称（拥有“查看全部机构”权限时不限制）
 }
 
 // Session 会话信息
 type Session struct {
-	UserID   int
-	Username string
-	RoleID   int
-	RoleCode int
-	ExpireAt time.Time
+	ID         string // 会话ID（令牌的SHA-256哈希）
+	UserID     int
+	Username   string
+	RoleID     int
+	RoleCode   int
+	IP         string    // 登录IP
+	UserAgent  string    // 浏览器标识
+	CreatedAt  time.Time // 登录时间
+	LastSeenAt time.Time // 最后活跃时间
+	ExpireAt   time.Time
 }
 
 // LoginHandler 处理登录请求
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		// 如果已登录，重定向到首页
+		// 如果已登录，重定向到要访问的页面或首页
 		if IsAuthenticated(r) {
-			http.Redirect(w, r, "/filelist", http.StatusFound)
+			http.Redirect(w, r, loginNext(r), http.StatusFound)
 			return
 		}
 		// 显示登录页面
-		renderLoginPage(w, "")
+		renderLoginPage(w, r, "")
 		return
 	}
 
@@ -59,63 +75,121 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 
 	if username == "" || password == "" {
-		renderLoginPage(w, "用户名和密码不能为空")
+		renderLoginPage(w, r, "用户名和密码不能为空")
 		return
 	}
 
-	// 查询用户
-	var user User
-	var hashedPassword string
-	query := `
-		SELECT u.id, u.username, u.password, u.role_id, ur.role_code, ur.role_name
-		FROM users u
-		LEFT JOIN user_role ur ON u.role_id = ur.id
-		WHERE u.username = ?
-	`
-	err := db.DBInstance.QueryRow(query, username).Scan(
-		&user.ID, &user.Username, &hashedPassword, &user.RoleID, &user.RoleCode, &user.RoleName,
-	)
+	// 检查用户名或IP是否因连续登录失败被锁定（IP 为对端地址，只有对端是可信代理时才采用 X-Forwarded-For，
+	// 避免伪造请求头绕过IP锁定或让他人的IP被锁定）
+	ip := operationlog.ClientIP(r)
+	if locked, remaining := checkLoginLocked(username, ip); locked {
+		minutes := int(remaining.Minutes()) + 1
+		renderLoginPage(w, r, fmt.Sprintf("登录失败次数过多，账号已被临时锁定，请 %d 分钟后再试", minutes))
+		return
+	}
 
-	if err == sql.ErrNoRows {
-		renderLoginPage(w, "用户名或密码错误")
+	// 依次通过各认证后端校验用户名密码
+	user, err := authenticate(username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		time.Sleep(recordLoginFailure(r, username, ip))
+		renderLoginPage(w, r, "用户名或密码错误")
+		return
+	}
+	if errors.Is(err, ErrAccountNotAllowed) {
+		operationlog.Record(r, username, operationlog.Entry{
+			Action:     operationlog.ActionLogin,
+			EntityType: operationlog.EntityUser,
+			Outcome:    operationlog.OutcomeDenied,
+			Message:    "登录被拒绝（目录账号未被授权访问本系统）",
+		})
+		renderLoginPage(w, r, "该账号未被授权访问本系统，请联系管理员")
 		return
 	}
 	if err != nil {
-		logger.Errorf("登录-数据库查询失败: %v, 用户名: %s", err, username)
-		http.Error(w, "数据库查询失败: "+err.Error(), http.StatusInternalServerError)
+		// 认证服务不可用不计入失败次数，避免目录故障时误锁账号
+		renderLoginPage(w, r, "认证服务暂时不可用，请稍后再试或联系管理员")
 		return
 	}
 
-	// 验证密码
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	action := "登录成功"
+	if user.AuthSource == AuthSourceLDAP {
+		action = "登录成功（LDAP）"
+	}
+
+	// 双因素认证：已启用的用户，或被要求启用但尚未绑定的管理员，进入第二步验证
+	twoFactor, err := GetTwoFactor(user.ID)
 	if err != nil {
-		renderLoginPage(w, "用户名或密码错误")
+		logger.Errorf("登录-查询双因素认证信息失败: %v, 用户名: %s", err, username)
+		http.Error(w, "数据库查询失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if twoFactor.Enabled || (user.IsAdmin() && RequireAdminTwoFactor()) {
+		if err := startTwoFactorChallenge(w, user, action, !twoFactor.Enabled, loginNext(r)); err != nil {
+			logger.Errorf("登录-创建双因素认证挑战失败: %v, 用户名: %s", err, username)
+			http.Error(w, "创建双因素认证失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/login/2fa", http.StatusFound)
+		return
+	}
+
+	// 密码已过期时先修改密码，否则进入超时前访问的页面或首页
+	if next, ok := proceedLogin(w, r, user, action, loginNext(r)); ok {
+		http.Redirect(w, r, next, http.StatusFound)
+	}
+}
+
+// completeLogin 创建会话、设置 Cookie 并记录登录日志，失败时已输出错误响应并返回 false
+func completeLogin(w http.ResponseWriter, r *http.Request, user *User, action string) bool {
+	// 全部验证（密码、双因素认证、修改过期密码）通过后才清除该用户名的失败计数；
+	// 只通过密码验证就清除的话，已知密码者可以反复重新登录来无限次猜测验证码
+	clearLoginFailures(user.Username)
 
 	// 创建会话
-	sessionID := createSession(user.ID, user.Username, user.RoleID, user.RoleCode)
+	sessionToken, err := createSession(r, user.ID, user.Username, user.RoleID, user.RoleCode)
+	if err != nil {
+		logger.Errorf("登录-创建会话失败: %v, 用户名: %s", err, user.Username)
+		http.Error(w, "创建会话失败: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
 
-	// 设置 Cookie
+	// 设置 Cookie（有效期为最长登录时长，空闲超时由服务端判断）
 	cookie := &http.Cookie{
 		Name:     SessionCookieName,
-		Value:    sessionID,
+		Value:    sessionToken,
 		Path:     "/",
-		MaxAge:   SessionMaxAge,
+		MaxAge:   int(LoadSessionPolicy().Lifetime().Seconds()),
 		HttpOnly: true,
+		Secure:   secureCookies,
 		SameSite: http.SameSiteLaxMode,
 	}
 	http.SetCookie(w, cookie)
 
 	// 记录登录日志
-	operationlog.Record(r, user.Username, "登录成功")
-
-	// 重定向到首页
-	http.Redirect(w, r, "/filelist", http.StatusFound)
+	operationlog.Record(r, user.Username, operationlog.Entry{
+		Action:     operationlog.ActionLogin,
+		EntityType: operationlog.EntityUser,
+		EntityID:   operationlog.ID(user.ID),
+		Message:    action,
+	})
+	return true
 }
 
 // LogoutHandler 处理登出请求
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	// 记录登出（需在删除会话前获取用户）
+	if u := GetCurrentUser(r); u != nil {
+		operationlog.Record(r, u.Username, operationlog.Entry{
+			Action:     operationlog.ActionLogout,
+			EntityType: operationlog.EntityUser,
+			EntityID:   operationlog.ID(u.ID),
+			Message:    "退出登录",
+		})
+	}
+
+	// 删除服务端会话，使令牌立即失效
+	destroySession(r)
+
 	// 删除 Cookie
 	cookie := &http.Cookie{
 		Name:     SessionCookieName,
@@ -123,66 +197,57 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: true,
+		Secure:   secureCookies,
 	}
 	http.SetCookie(w, cookie)
 
-	// 记录登出
-	if u := GetCurrentUser(r); u != nil {
-		operationlog.Record(r, u.Username, "退出登录")
-	}
-
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
 // IsAuthenticated 检查用户是否已登录
 func IsAuthenticated(r *http.Request) bool {
-	cookie, err := r.Cookie(SessionCookieName)
-	if err != nil {
-		return false
-	}
-
-	session := getSession(cookie.Value)
-	if session == nil {
-		return false
-	}
-
-	// 检查是否过期
-	if time.Now().After(session.ExpireAt) {
-		return false
-	}
-
-	return true
+	return CurrentUser(r) != nil || apiTokenFromContext(r) != nil || sessionFromRequest(r) != nil
 }
 
-// GetCurrentUser 获取当前登录用户信息
+// GetCurrentUser 获取当前登录用户信息（通过 API 令牌访问时为令牌所属用户）
+// 经过 RequireAuth/RequireAdmin 的请求直接返回上下文中的用户，否则按会话查询数据库
 func GetCurrentUser(r *http.Request) *User {
-	cookie, err := r.Cookie(SessionCookieName)
-	if err != nil {
-		return nil
+	if user := CurrentUser(r); user != nil {
+		return user
 	}
-
-	session := getSession(cookie.Value)
-	if session == nil {
-		return nil
+	if token := apiTokenFromContext(r); token != nil {
+		return loadUser(token.UserID)
 	}
-
-	// 检查是否过期
-	if time.Now().After(session.ExpireAt) {
-		return nil
+	if session := sessionFromRequest(r); session != nil {
+		return loadUser(session.UserID)
 	}
+	return nil
+}
 
-	// 查询用户详细信息
+// loadUser 查询用户详细信息，用户不存在或查询失败时返回 nil
+func loadUser(userID int) *User {
 	var user User
 	query := `
-		SELECT u.id, u.username, u.role_id, ur.role_code, ur.role_name
+		SELECT u.id, u.username, u.role_id, ur.role_code, ur.role_name, u.auth_source
 		FROM users u
 		LEFT JOIN user_role ur ON u.role_id = ur.id
 		WHERE u.id = ?
 	`
-	err = db.DBInstance.QueryRow(query, session.UserID).Scan(
-		&user.ID, &user.Username, &user.RoleID, &user.RoleCode, &user.RoleName,
+	err := db.DBInstance.QueryRow(query, userID).Scan(
+		&user.ID, &user.Username, &user.RoleID, &user.RoleCode, &user.RoleName, &user.AuthSource,
 	)
 	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Errorf("认证-查询用户信息失败: %v, 用户ID: %d", err, userID)
+		}
+		return nil
+	}
+	if err := loadUserRoles(&user); err != nil {
+		logger.Errorf("认证-查询用户角色权限失败: %v, 用户ID: %d", err, userID)
+		return nil
+	}
+	if err := loadUserOrganizations(&user); err != nil {
+		logger.Errorf("认证-查询用户机构失败: %v, 用户ID: %d", err, userID)
 		return nil
 	}
 
@@ -191,11 +256,7 @@ func GetCurrentUser(r *http.Request) *User {
 
 // IsAdmin 检查当前用户是否是管理员
 func IsAdmin(r *http.Request) bool {
-	user := GetCurrentUser(r)
-	if user == nil {
-		return false
-	}
-	return user.RoleCode == 0 // 0=管理员
+	return GetCurrentUser(r).IsAdmin()
 }
 
 // HashPassword 加密密码
@@ -207,40 +268,23 @@ func HashPassword(password string) (string, error) {
 	return string(hash), nil
 }
 
-// 简单的内存 session 存储（生产环境建议使用 Redis）
-var sessions = make(map[string]*Session)
-
-// createSession 创建会话
-func createSession(userID int, username string, roleID int, roleCode int) string {
-	sessionID := generateSessionID()
-	session := &Session{
-		UserID:   userID,
-		Username: username,
-		RoleID:   roleID,
-		RoleCode: roleCode,
-		ExpireAt: time.Now().Add(time.Duration(SessionMaxAge) * time.Second),
-	}
-	sessions[sessionID] = session
-	return sessionID
-}
-
-// getSession 获取会话
-func getSession(sessionID string) *Session {
-	return sessions[sessionID]
-}
-
-// generateSessionID 生成会话ID
-func generateSessionID() string {
-	return base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf("%d-%d", time.Now().UnixNano(), time.Now().Unix())))
-}
-
 // LoginPageData 登录页面数据
 type LoginPageData struct {
-	ErrorMsg string
+	ErrorMsg  string
+	Next      string // 登录后返回的页面
+	CSRFToken string
+}
+
+// loginNext 登录成功后跳转的地址：登录表单或查询参数中的 next，无效时为首页
+func loginNext(r *http.Request) string {
+	if next := safeRedirectPath(r.FormValue("next")); next != "" {
+		return next
+	}
+	return "/filelist"
 }
 
 // renderLoginPage 渲染登录页面
-func renderLoginPage(w http.ResponseWriter, errorMsg string) {
+func renderLoginPage(w http.ResponseWriter, r *http.Request, errorMsg string) {
 	tmpl, err := template.ParseFiles("templates/login.html")
 	if err != nil {
 		logger.Errorf("登录-模板解析失败: %v", err)
@@ -249,7 +293,12 @@ func renderLoginPage(w http.ResponseWriter, errorMsg string) {
 	}
 
 	data := LoginPageData{
-		ErrorMsg: errorMsg,
+		ErrorMsg:  errorMsg,
+		Next:      safeRedirectPath(r.FormValue("next")),
+		CSRFToken: CSRFToken(r),
+	}
+	if errorMsg == "" && r.Method == http.MethodGet && r.URL.Query().Get("timeout") == "1" {
+		data.ErrorMsg = "由于长时间未操作，登录已超时，请重新登录"
 	}
 
 	err = tmpl.Execute(w, data)
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 账号来源（users.auth_source）
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+)
+
+// ErrInvalidCredentials 用户名或密码错误（计入登录失败次数）
+var ErrInvalidCredentials = errors.New("用户名或密码错误")
+
+// ErrAccountNotAllowed 密码正确但账号未被授权访问本系统（如不属于任何已映射的目录组）
+var ErrAccountNotAllowed = errors.New("账号未被授权访问本系统")
+
+// Authenticator 登录认证后端
+// 用户名或密码错误时返回 ErrInvalidCredentials，认证服务本身出错时返回其他错误
+type Authenticator interface {
+	Name() string
+	Authenticate(username, password string) (*User, error)
+}
+
+// authenticators 按顺序尝试的认证后端，默认只有本地账号
+var authenticators = []Authenticator{LocalAuthenticator{}}
+
+// SetAuthenticators 替换认证后端链
+func SetAuthenticators(list ...Authenticator) {
+	authenticators = list
+}
+
+// InitAuthenticators 根据 config.json 中的 ldap 配置初始化认证后端链
+// 启用 LDAP 时先尝试目录认证，未禁用本地登录时再尝试本地账号（保留应急管理员）
+func InitAuthenticators() {
+	cfg := db.AppConfig.LDAP
+	if !cfg.Enabled {
+		return
+	}
+
+	ldapAuth, err := NewLDAPAuthenticator(cfg)
+	if err != nil {
+		logger.Errorf("认证初始化-LDAP配置无效: %v", err)
+		log.Printf("警告: LDAP配置无效，仅使用本地账号登录: %v", err)
+		return
+	}
+
+	list := []Authenticator{ldapAuth}
+	if !cfg.DisableLocalLogin {
+		list = append(list, LocalAuthenticator{})
+	}
+	SetAuthenticators(list...)
+	logger.Infof("认证初始化-LDAP认证已启用: %s", cfg.URL)
+}
+
+// authenticate 依次尝试各认证后端
+// 全部返回 ErrInvalidCredentials 时返回 ErrInvalidCredentials；存在后端出错且无成功时返回最后一个错误
+func authenticate(username, password string) (*User, error) {
+	var lastErr error
+	for _, a := range authenticators {
+		user, err := a.Authenticate(username, password)
+		if err == nil {
+			return user, nil
+		}
+		if errors.Is(err, ErrAccountNotAllowed) {
+			return nil, err
+		}
+		if !errors.Is(err, ErrInvalidCredentials) {
+			logger.Errorf("登录-%s认证出错: %v, 用户名: %s", a.Name(), err, username)
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// LocalAuthenticator 本地账号认证（users 表中的 bcrypt 密码）
+type LocalAuthenticator struct{}
+
+// Name 认证后端名称
+func (LocalAuthenticator) Name() string {
+	return "本地账号"
+}
+
+// Authenticate 校验本地账号密码，目录账号不参与本地认证
+func (LocalAuthenticator) Authenticate(username, password string) (*User, error) {
+	var user User
+	var hashedPassword string
+	query := `
+		SELECT u.id, u.username, u.password, u.role_id, ur.role_code, ur.role_name, u.auth_source
+		FROM users u
+		LEFT JOIN user_role ur ON u.role_id = ur.id
+		WHERE u.username = ? AND u.auth_source = ?
+	`
+	err := db.DBInstance.QueryRow(query, username, AuthSourceLocal).Scan(
+		&user.ID, &user.Username, &hashedPassword, &user.RoleID, &user.RoleCode, &user.RoleName, &user.AuthSource,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询失败: %v", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := loadUserRoles(&user); err != nil {
+		return nil, fmt.Errorf("查询用户角色失败: %v", err)
+	}
+	return &user, nil
+}
+
+// provisionExternalUser 目录认证通过后同步本地用户：不存在则创建，存在则按目录组更新角色
+// 同名的本地账号不会被目录账号接管
+func provisionExternalUser(username, source string, roleCodes []int) (*User, error) {
+	var roleIDs []int
+	for _, code := range roleCodes {
+		var roleID int
+		err := db.DBInstance.QueryRow("SELECT id FROM user_role WHERE role_code = ?", code).Scan(&roleID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("角色代码 %d 不存在", code)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("查询角色失败: %v", err)
+		}
+		roleIDs = append(roleIDs, roleID)
+	}
+	if len(roleIDs) == 0 {
+		return nil, ErrAccountNotAllowed
+	}
+
+	var userID int
+	var currentSource string
+	err := db.DBInstance.QueryRow("SELECT id, auth_source FROM users WHERE username = ?", username).
+		Scan(&userID, &currentSource)
+	switch {
+	case err == sql.ErrNoRows:
+		// 首次登录，创建本地用户（密码留空，只能通过目录认证登录）
+		result, err := db.DBInstance.Exec(
+			"INSERT INTO users (username, password, role_id, auth_source) VALUES (?, '', ?, ?)", username, roleIDs[0], source)
+		if err != nil {
+			return nil, fmt.Errorf("创建用户失败: %v", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("获取用户ID失败: %v", err)
+		}
+		userID = int(id)
+		logger.Infof("目录认证-自动创建用户: %s（来源：%s）", username, source)
+	case err != nil:
+		return nil, fmt.Errorf("查询用户失败: %v", err)
+	case currentSource != source:
+		logger.Warnf("目录认证-用户名已被%s账号占用，拒绝登录: %s", currentSource, username)
+		return nil, ErrInvalidCredentials
+	}
+
+	// 每次登录按目录组同步角色
+	if err := SetUserRoles(userID, roleIDs); err != nil {
+		return nil, fmt.Errorf("更新用户角色失败: %v", err)
+	}
+
+	user := loadUser(userID)
+	if user == nil {
+		return nil, fmt.Errorf("查询用户失败: %s", username)
+	}
+	return user, nil
+}
@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"ops-web/internal/accesslog"
+	"ops-web/internal/operationlog"
+)
+
+type userContextKey struct{}
+
+// withUser 将本次请求已解析的当前用户放入请求上下文（同时告知操作日志、访问日志模块当前用户名）
+func withUser(r *http.Request, user *User) *http.Request {
+	accesslog.SetUsername(r, user.Username)
+	r = operationlog.WithUsername(r, user.Username)
+	return r.WithContext(context.WithValue(r.Context(), userContextKey{}, user))
+}
+
+// WithCurrentUser 将已认证的当前用户放入请求上下文，供不经过认证中间件直接调用处理函数的场景（如测试）使用
+func WithCurrentUser(r *http.Request, user *User) *http.Request {
+	return withUser(r, user)
+}
+
+// CurrentUser 获取 RequireAuth/RequireAdmin 已解析好的当前用户，不再查询数据库；
+// 未经过这两个中间件的请求返回 nil。用户信息每次请求重新读取，修改角色或用户名后下一次请求即生效
+func CurrentUser(r *http.Request) *User {
+	user, _ := r.Context().Value(userContextKey{}).(*User)
+	return user
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+)
+
+const (
+	CSRFFieldName  = "csrf_token"   // 表单字段名
+	CSRFHeaderName = "X-CSRF-Token" // AJAX 请求头
+
+	// csrfCookieName 未登录时（登录页、双因素认证页）使用的临时密钥 Cookie
+	csrfCookieName = "ops_csrf"
+	csrfTokenBytes = 32
+)
+
+type csrfContextKey struct{}
+
+// CSRFToken 当前请求对应的 CSRF 令牌，渲染页面时放入表单隐藏字段和 AJAX 请求头
+// 令牌由会话 Cookie（未登录时为临时 Cookie）经 HMAC 派生，同一会话内不变，服务重启后仍然有效
+func CSRFToken(r *http.Request) string {
+	secret := csrfSecret(r)
+	if secret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("ops-web csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// csrfSecret 派生令牌使用的密钥：优先会话 Cookie，其次临时 Cookie
+func csrfSecret(r *http.Request) string {
+	if cookie, err := r.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if secret, ok := r.Context().Value(csrfContextKey{}).(string); ok {
+		return secret
+	}
+	return ""
+}
+
+// CSRFProtect 校验所有会修改数据的请求（POST/PUT/PATCH/DELETE）携带的 CSRF 令牌，校验失败返回 403 并记录日志
+func CSRFProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			// 尚无会话时下发临时 Cookie，使登录页等也能生成令牌
+			if csrfSecret(r) == "" {
+				buf := make([]byte, csrfTokenBytes)
+				if _, err := rand.Read(buf); err == nil {
+					secret := base64.RawURLEncoding.EncodeToString(buf)
+					http.SetCookie(w, &http.Cookie{
+						Name:     csrfCookieName,
+						Value:    secret,
+						Path:     "/",
+						HttpOnly: true,
+						Secure:   secureCookies,
+						SameSite: http.SameSiteLaxMode,
+					})
+					r = r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, secret))
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 携带 Bearer 令牌的脚本请求不使用 Cookie，由 RequireAuth/RequireAdmin 校验令牌
+		if HasBearerToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		submitted := r.Header.Get(CSRFHeaderName)
+		if submitted == "" {
+			submitted = r.FormValue(CSRFFieldName)
+		}
+		expected := CSRFToken(r)
+		if expected == "" || !hmac.Equal([]byte(submitted), []byte(expected)) {
+			rejectCSRF(w, r, submitted == "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectCSRF 拒绝请求并记录错误日志和操作日志
+func rejectCSRF(w http.ResponseWriter, r *http.Request, missing bool) {
+	reason := "令牌无效"
+	if missing {
+		reason = "缺少令牌"
+	}
+
+	username := "未登录"
+	if session := sessionFromRequest(r); session != nil {
+		username = session.Username
+	}
+
+	ip := operationlog.ClientIP(r)
+	logger.Warnf("CSRF校验失败（%s）: %s %s, 用户: %s, IP: %s, Referer: %s", reason, r.Method, r.URL.Path, username, ip, r.Referer())
+	operationlog.Record(r, username, operationlog.Entry{
+		Action:     operationlog.ActionAccess,
+		EntityType: operationlog.EntityRequest,
+		EntityID:   r.URL.Path,
+		Outcome:    operationlog.OutcomeDenied,
+		Message:    "CSRF校验失败，已拒绝请求（" + reason + "，" + r.Method + " " + r.URL.Path + "）",
+	})
+
+	http.Error(w, "请求校验失败（CSRF令牌"+reason+"），请刷新页面后重试", http.StatusForbidden)
+}
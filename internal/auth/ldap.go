@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"ops-web/internal/db"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPAuthenticator LDAP/Active Directory 认证
+// 流程：服务账号绑定 -> 按用户名查询用户DN和所属组 -> 以用户DN和密码绑定校验密码 -> 按组映射角色并同步本地用户
+type LDAPAuthenticator struct {
+	cfg     db.LDAPConfig
+	timeout time.Duration
+}
+
+// NewLDAPAuthenticator 创建 LDAP 认证后端，补全默认值并校验配置
+func NewLDAPAuthenticator(cfg db.LDAPConfig) (*LDAPAuthenticator, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("未配置 ldap.url")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") {
+		return nil, fmt.Errorf("ldap.url 格式错误: %s", cfg.URL)
+	}
+	if cfg.BaseDN == "" {
+		return nil, errors.New("未配置 ldap.base_dn")
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid=%s)"
+	}
+	if !strings.Contains(cfg.UserFilter, "%s") {
+		return nil, fmt.Errorf("ldap.user_filter 缺少 %%s 占位符: %s", cfg.UserFilter)
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = "(member=%s)"
+	}
+	for dn, code := range cfg.GroupRoleMap {
+		if code < 0 {
+			return nil, fmt.Errorf("ldap.group_role_map 中组 %s 的角色代码无效: %d", dn, code)
+		}
+	}
+
+	if cfg.DefaultRoleCode != nil && *cfg.DefaultRoleCode < 0 {
+		return nil, fmt.Errorf("ldap.default_role_code 无效: %d", *cfg.DefaultRoleCode)
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &LDAPAuthenticator{cfg: cfg, timeout: timeout}, nil
+}
+
+// Name 认证后端名称
+func (a *LDAPAuthenticator) Name() string {
+	return "LDAP"
+}
+
+// Authenticate 通过目录校验用户名密码，成功后按目录组同步本地用户
+func (a *LDAPAuthenticator) Authenticate(username, password string) (*User, error) {
+	// 空密码会被目录当作匿名绑定而"成功"，必须拒绝
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if a.cfg.BindDN != "" {
+		if err := conn.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("服务账号绑定失败: %v", err)
+		}
+	}
+
+	// 查询用户
+	search := ldap.NewSearchRequest(
+		a.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.timeout.Seconds()), false,
+		fmt.Sprintf(a.cfg.UserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn", a.cfg.GroupAttribute},
+		nil,
+	)
+	result, err := conn.Search(search)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("查询用户失败: %v", err)
+	}
+	if len(result.Entries) != 1 {
+		// 不存在或匹配到多个条目都视为认证失败
+		return nil, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	// 以用户身份绑定校验密码
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("用户绑定失败: %v", err)
+	}
+
+	groups := entry.GetAttributeValues(a.cfg.GroupAttribute)
+	if a.cfg.GroupBaseDN != "" {
+		groups, err = a.searchGroups(conn, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	roleCodes, ok := a.mapRoles(groups)
+	if !ok {
+		return nil, fmt.Errorf("%w: 用户 %s 不属于任何已映射的目录组，且未配置默认角色", ErrAccountNotAllowed, username)
+	}
+	return provisionExternalUser(username, AuthSourceLDAP, roleCodes)
+}
+
+// dial 建立连接（按配置使用 ldaps 或 StartTLS）
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(a.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(a.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("连接LDAP服务器失败: %v", err)
+	}
+	conn.SetTimeout(a.timeout)
+
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS失败: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// searchGroups 按组查询用户所属组（用于没有 memberOf 属性的目录）
+func (a *LDAPAuthenticator) searchGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	search := ldap.NewSearchRequest(
+		a.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(a.timeout.Seconds()), false,
+		fmt.Sprintf(a.cfg.GroupFilter, ldap.EscapeFilter(userDN)),
+		[]string{"dn"},
+		nil,
+	)
+	result, err := conn.Search(search)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户所属组失败: %v", err)
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// mapRoles 按组映射角色，属于多个映射组时拥有这些组对应的全部角色
+func (a *LDAPAuthenticator) mapRoles(groups []string) ([]int, bool) {
+	var codes []int
+	seen := make(map[int]bool)
+	for _, group := range groups {
+		for dn, code := range a.cfg.GroupRoleMap {
+			if !sameDN(group, dn) || seen[code] {
+				continue
+			}
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) > 0 {
+		return codes, true
+	}
+	if a.cfg.DefaultRoleCode != nil {
+		return []int{*a.cfg.DefaultRoleCode}, true
+	}
+	return nil, false
+}
+
+// sameDN 比较两个DN（忽略大小写和RDN之间的空格）
+func sameDN(a, b string) bool {
+	da, errA := ldap.ParseDN(a)
+	dbn, errB := ldap.ParseDN(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+	}
+	return da.EqualFold(dbn)
+}
@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"errors"
+	"ops-web/internal/auth/ldaptest"
+	"ops-web/internal/db"
+	"reflect"
+	"testing"
+)
+
+const (
+	testBaseDN      = "dc=example,dc=com"
+	testServiceDN   = "cn=ops-web,ou=services,dc=example,dc=com"
+	testServicePass = "service-secret"
+	testAdminGroup  = "cn=ops-admins,ou=groups,dc=example,dc=com"
+	testUserGroup   = "cn=ops-users,ou=groups,dc=example,dc=com"
+	testOtherGroup  = "cn=finance,ou=groups,dc=example,dc=com"
+)
+
+// 测试用户库中的角色
+var (
+	testAdminRole = fakeRole{ID: 1, Code: 0, Name: "管理员"}
+	testUserRole  = fakeRole{ID: 2, Code: 1, Name: "普通用户"}
+)
+
+// newTestDirectory 启动进程内 LDAP 服务器：服务账号、各组成员和组条目
+func newTestDirectory(t *testing.T) *ldaptest.Server {
+	t.Helper()
+	person := func(uid string, groups ...string) ldaptest.Entry {
+		return ldaptest.Entry{
+			DN:       "uid=" + uid + ",ou=people," + testBaseDN,
+			Password: uid + "-pass",
+			Attributes: map[string][]string{
+				"uid":      {uid},
+				"memberOf": groups,
+			},
+		}
+	}
+	group := func(dn string, members ...string) ldaptest.Entry {
+		var memberDNs []string
+		for _, uid := range members {
+			memberDNs = append(memberDNs, "uid="+uid+",ou=people,"+testBaseDN)
+		}
+		return ldaptest.Entry{DN: dn, Attributes: map[string][]string{"member": memberDNs}}
+	}
+
+	srv, err := ldaptest.NewServer([]ldaptest.Entry{
+		{DN: testServiceDN, Password: testServicePass},
+		person("zhangsan", testAdminGroup),
+		person("lisi", testUserGroup),
+		person("wangwu", testOtherGroup),
+		person("zhaoliu", testUserGroup, testAdminGroup),
+		group(testAdminGroup, "zhangsan", "zhaoliu"),
+		group(testUserGroup, "lisi", "zhaoliu"),
+		group(testOtherGroup, "wangwu"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// newTestLDAP 按测试目录创建认证后端，modify 可调整配置
+func newTestLDAP(t *testing.T, srv *ldaptest.Server, modify func(cfg *db.LDAPConfig)) *LDAPAuthenticator {
+	t.Helper()
+	cfg := db.LDAPConfig{
+		Enabled:      true,
+		URL:          srv.URL(),
+		BindDN:       testServiceDN,
+		BindPassword: testServicePass,
+		BaseDN:       "ou=people," + testBaseDN,
+		GroupRoleMap: map[string]int{
+			testAdminGroup: testAdminRole.Code,
+			testUserGroup:  testUserRole.Code,
+		},
+	}
+	if modify != nil {
+		modify(&cfg)
+	}
+	a, err := NewLDAPAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestLDAPAuthenticateSuccess(t *testing.T) {
+	srv := newTestDirectory(t)
+	useFakeUserDB(t, testAdminRole, testUserRole)
+	a := newTestLDAP(t, srv, nil)
+
+	user, err := a.Authenticate("zhangsan", "zhangsan-pass")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if user.Username != "zhangsan" || user.AuthSource != AuthSourceLDAP {
+		t.Errorf("user = %s（%s），期望 zhangsan（%s）", user.Username, user.AuthSource, AuthSourceLDAP)
+	}
+	if !user.IsAdmin() {
+		t.Errorf("ops-admins 组成员应为管理员，RoleCode = %d", user.RoleCode)
+	}
+	// 服务账号绑定 + 用户绑定
+	if got := srv.BindCount(); got != 2 {
+		t.Errorf("BindCount() = %d, 期望 2", got)
+	}
+}
+
+func TestLDAPAuthenticateInvalidCredentials(t *testing.T) {
+	srv := newTestDirectory(t)
+	store := useFakeUserDB(t, testAdminRole, testUserRole)
+	a := newTestLDAP(t, srv, nil)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"密码错误", "zhangsan", "wrong"},
+		{"用户不存在", "nobody", "nobody-pass"},
+		{"用户名中的过滤条件被转义", "*", "zhangsan-pass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Authenticate(tt.username, tt.password)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Authenticate(%q) error = %v, 期望 ErrInvalidCredentials", tt.username, err)
+			}
+		})
+	}
+	if n := store.userCount(); n != 0 {
+		t.Errorf("认证失败不应创建用户，用户数 = %d", n)
+	}
+}
+
+func TestLDAPAuthenticateEmptyPassword(t *testing.T) {
+	srv := newTestDirectory(t)
+	a := newTestLDAP(t, srv, nil)
+
+	// 空密码会被目录当作未认证绑定，必须在连接目录前拒绝
+	if _, err := a.Authenticate("zhangsan", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, 期望 ErrInvalidCredentials", err)
+	}
+	if got := srv.BindCount(); got != 0 {
+		t.Errorf("BindCount() = %d, 空密码不应连接目录", got)
+	}
+}
+
+func TestLDAPAuthenticateServiceBindFailure(t *testing.T) {
+	srv := newTestDirectory(t)
+	a := newTestLDAP(t, srv, func(cfg *db.LDAPConfig) { cfg.BindPassword = "wrong" })
+
+	// 服务账号配置错误是认证服务故障，不能计为用户密码错误
+	_, err := a.Authenticate("zhangsan", "zhangsan-pass")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, 期望服务账号绑定失败", err)
+	}
+}
+
+func TestLDAPAuthenticateNotInAllowedGroup(t *testing.T) {
+	srv := newTestDirectory(t)
+	store := useFakeUserDB(t, testAdminRole, testUserRole)
+
+	a := newTestLDAP(t, srv, nil)
+	_, err := a.Authenticate("wangwu", "wangwu-pass")
+	if !errors.Is(err, ErrAccountNotAllowed) {
+		t.Fatalf("Authenticate() error = %v, 期望 ErrAccountNotAllowed", err)
+	}
+	if n := store.userCount(); n != 0 {
+		t.Errorf("未授权的目录账号不应创建用户，用户数 = %d", n)
+	}
+
+	// 配置默认角色后，不属于映射组的用户以默认角色登录
+	defaultCode := testUserRole.Code
+	a = newTestLDAP(t, srv, func(cfg *db.LDAPConfig) { cfg.DefaultRoleCode = &defaultCode })
+	user, err := a.Authenticate("wangwu", "wangwu-pass")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if !reflect.DeepEqual(user.RoleIDs, []int{testUserRole.ID}) {
+		t.Errorf("RoleIDs = %v, 期望默认角色 %v", user.RoleIDs, []int{testUserRole.ID})
+	}
+}
+
+func TestLDAPGroupRoleMapping(t *testing.T) {
+	srv := newTestDirectory(t)
+
+	tests := []struct {
+		name     string
+		username string
+		modify   func(cfg *db.LDAPConfig)
+		want     []int
+	}{
+		{"普通用户组", "lisi", nil, []int{testUserRole.ID}},
+		{"属于多个映射组时拥有全部角色", "zhaoliu", nil, []int{testAdminRole.ID, testUserRole.ID}},
+		{"组DN比较忽略大小写和空格", "zhangsan", func(cfg *db.LDAPConfig) {
+			cfg.GroupRoleMap = map[string]int{"CN=Ops-Admins, OU=Groups, DC=Example, DC=Com": testAdminRole.Code}
+		}, []int{testAdminRole.ID}},
+		{"按组查询成员（无 memberOf 的目录）", "zhaoliu", func(cfg *db.LDAPConfig) {
+			cfg.GroupAttribute = "noSuchAttribute"
+			cfg.GroupBaseDN = "ou=groups," + testBaseDN
+		}, []int{testAdminRole.ID, testUserRole.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeUserDB(t, testAdminRole, testUserRole)
+			a := newTestLDAP(t, srv, tt.modify)
+			user, err := a.Authenticate(tt.username, tt.username+"-pass")
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if !reflect.DeepEqual(user.RoleIDs, tt.want) {
+				t.Errorf("RoleIDs = %v, 期望 %v", user.RoleIDs, tt.want)
+			}
+		})
+	}
+}
+
+func TestLDAPProvisionFirstLogin(t *testing.T) {
+	srv := newTestDirectory(t)
+	store := useFakeUserDB(t, testAdminRole, testUserRole)
+	a := newTestLDAP(t, srv, nil)
+
+	// 首次登录创建本地用户（密码为空，只能通过目录登录）
+	user, err := a.Authenticate("lisi", "lisi-pass")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	created := store.userByName("lisi")
+	if created == nil {
+		t.Fatal("首次登录未创建本地用户")
+	}
+	if created.AuthSource != AuthSourceLDAP || created.ID != user.ID || created.RoleID != testUserRole.ID {
+		t.Errorf("创建的用户 = %+v, 期望来源 %s、角色 %d", *created, AuthSourceLDAP, testUserRole.ID)
+	}
+	if got := store.memberRoles(created.ID); !reflect.DeepEqual(got, []int{testUserRole.ID}) {
+		t.Errorf("user_role_members = %v, 期望 %v", got, []int{testUserRole.ID})
+	}
+
+	// 组映射调整后再次登录：不重复创建用户，按目录组同步角色
+	a = newTestLDAP(t, srv, func(cfg *db.LDAPConfig) {
+		cfg.GroupRoleMap = map[string]int{testUserGroup: testAdminRole.Code}
+	})
+	user, err = a.Authenticate("lisi", "lisi-pass")
+	if err != nil {
+		t.Fatalf("再次登录 Authenticate() error = %v", err)
+	}
+	if n := store.userCount(); n != 1 {
+		t.Errorf("再次登录后用户数 = %d, 期望 1", n)
+	}
+	if user.ID != created.ID || !user.IsAdmin() {
+		t.Errorf("再次登录 user = %+v, 期望同一用户且角色更新为管理员", *user)
+	}
+	if got := store.memberRoles(created.ID); !reflect.DeepEqual(got, []int{testAdminRole.ID}) {
+		t.Errorf("同步后 user_role_members = %v, 期望 %v", got, []int{testAdminRole.ID})
+	}
+}
+
+func TestLDAPProvisionDoesNotTakeOverLocalUser(t *testing.T) {
+	srv := newTestDirectory(t)
+	store := useFakeUserDB(t, testAdminRole, testUserRole)
+	store.addUser("zhangsan", AuthSourceLocal, testUserRole.ID)
+	a := newTestLDAP(t, srv, nil)
+
+	// 同名本地账号不会被目录账号接管，也不会因目录组而提升为管理员
+	if _, err := a.Authenticate("zhangsan", "zhangsan-pass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() error = %v, 期望 ErrInvalidCredentials", err)
+	}
+	local := store.userByName("zhangsan")
+	if local.AuthSource != AuthSourceLocal || local.RoleID != testUserRole.ID {
+		t.Errorf("本地账号被修改: %+v", *local)
+	}
+}
@@ -0,0 +1,426 @@
+// Package ldaptest 提供进程内的简易 LDAP 服务器，用于在没有真实目录的环境下验证 LDAP 认证
+//
+// 仅实现认证所需的最小协议子集：简单绑定（Bind）、查询（Search，支持 and/or/not/等值/存在 过滤条件）和解绑（Unbind）。
+//
+// 用法：
+//
+//	srv, err := ldaptest.NewServer([]ldaptest.Entry{
+//		{DN: "uid=zhangsan,ou=people,dc=example,dc=com", Password: "secret",
+//			Attributes: map[string][]string{"uid": {"zhangsan"}, "memberOf": {"cn=ops-admins,ou=groups,dc=example,dc=com"}}},
+//	})
+//	defer srv.Close()
+//	// 将 ldap.url 配置为 srv.URL()
+package ldaptest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAP 协议操作标签（RFC 4511）
+const (
+	appBindRequest      = 0
+	appBindResponse     = 1
+	appUnbindRequest    = 2
+	appSearchRequest    = 3
+	appSearchResultItem = 4
+	appSearchResultDone = 5
+)
+
+// LDAP 结果码
+const (
+	resultSuccess            = 0
+	resultProtocolError      = 2
+	resultNoSuchObject       = 32
+	resultInvalidCredentials = 49
+	resultUnwillingToPerform = 53
+)
+
+// 查询范围
+const (
+	scopeBaseObject   = 0
+	scopeSingleLevel  = 1
+	scopeWholeSubtree = 2
+)
+
+// Entry 目录条目
+type Entry struct {
+	DN         string
+	Password   string // 为空表示该条目不能绑定
+	Attributes map[string][]string
+}
+
+// Server 进程内 LDAP 服务器
+type Server struct {
+	listener net.Listener
+
+	mu      sync.RWMutex
+	entries []Entry
+	binds   int // 绑定请求次数
+
+	wg sync.WaitGroup
+}
+
+// NewServer 在 127.0.0.1 的随机端口上启动服务器
+func NewServer(entries []Entry) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("监听端口失败: %v", err)
+	}
+	s := &Server{listener: listener, entries: entries}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// URL 服务器地址（ldap://127.0.0.1:端口）
+func (s *Server) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// AddEntry 添加目录条目
+func (s *Server) AddEntry(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+}
+
+// BindCount 已处理的绑定请求次数
+func (s *Server) BindCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.binds
+}
+
+// Close 关闭服务器并等待所有连接结束
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// serve 接受连接
+func (s *Server) serve() {
+	defer s.wg.Done()
+	var conns sync.WaitGroup
+	var mu sync.Mutex
+	var open []net.Conn
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			// 监听关闭时同时断开所有连接
+			mu.Lock()
+			for _, c := range open {
+				c.Close()
+			}
+			mu.Unlock()
+			conns.Wait()
+			return
+		}
+		mu.Lock()
+		open = append(open, conn)
+		mu.Unlock()
+
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle 处理单个连接上的请求
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		messageID, ok := packet.Children[0].Value.(int64)
+		if !ok {
+			return
+		}
+		op := packet.Children[1]
+		if op.ClassType != ber.ClassApplication {
+			return
+		}
+
+		switch op.Tag {
+		case appBindRequest:
+			code, msg := s.bind(op)
+			if _, err := conn.Write(result(messageID, appBindResponse, code, msg).Bytes()); err != nil {
+				return
+			}
+		case appUnbindRequest:
+			return
+		case appSearchRequest:
+			entries, code, msg := s.search(op)
+			for _, e := range entries {
+				if _, err := conn.Write(entryPacket(messageID, e).Bytes()); err != nil {
+					return
+				}
+			}
+			if _, err := conn.Write(result(messageID, appSearchResultDone, code, msg).Bytes()); err != nil {
+				return
+			}
+		default:
+			// 其他操作（修改、扩展操作等）不支持
+			if _, err := conn.Write(result(messageID, int(op.Tag)+1, resultUnwillingToPerform, "operation not supported").Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bind 处理简单绑定
+func (s *Server) bind(op *ber.Packet) (int, string) {
+	s.mu.Lock()
+	s.binds++
+	s.mu.Unlock()
+
+	if len(op.Children) < 3 {
+		return resultProtocolError, "malformed bind request"
+	}
+	dn := packetString(op.Children[1])
+	auth := op.Children[2]
+	if auth.ClassType != ber.ClassContext || auth.Tag != 0 {
+		return resultUnwillingToPerform, "only simple bind is supported"
+	}
+	password := packetString(auth)
+
+	// 匿名绑定
+	if dn == "" && password == "" {
+		return resultSuccess, ""
+	}
+	// 未认证绑定（有DN无密码）一律拒绝
+	if password == "" {
+		return resultUnwillingToPerform, "unauthenticated bind not allowed"
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, e := range s.entries {
+		if sameDN(e.DN, dn) && e.Password != "" && e.Password == password {
+			return resultSuccess, ""
+		}
+	}
+	return resultInvalidCredentials, "invalid credentials"
+}
+
+// search 处理查询
+func (s *Server) search(op *ber.Packet) ([]Entry, int, string) {
+	if len(op.Children) < 8 {
+		return nil, resultProtocolError, "malformed search request"
+	}
+	baseDN := packetString(op.Children[0])
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+
+	var attributes []string
+	for _, a := range op.Children[7].Children {
+		attributes = append(attributes, packetString(a))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	baseFound := baseDN == ""
+	var matched []Entry
+	for _, e := range s.entries {
+		// 基准DN本身或其下存在条目即视为存在（测试数据可以省略中间的容器条目）
+		if inScope(e.DN, baseDN, scopeWholeSubtree) {
+			baseFound = true
+		}
+		if !inScope(e.DN, baseDN, int(scope)) || !matchFilter(e, filter) {
+			continue
+		}
+		matched = append(matched, selectAttributes(e, attributes))
+		if sizeLimit > 0 && int64(len(matched)) >= sizeLimit {
+			break
+		}
+	}
+	if !baseFound {
+		return nil, resultNoSuchObject, "no such object"
+	}
+	return matched, resultSuccess, ""
+}
+
+// matchFilter 计算过滤条件
+func matchFilter(e Entry, f *ber.Packet) bool {
+	if f.ClassType != ber.ClassContext {
+		return false
+	}
+	switch f.Tag {
+	case 0: // and
+		for _, c := range f.Children {
+			if !matchFilter(e, c) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, c := range f.Children {
+			if matchFilter(e, c) {
+				return true
+			}
+		}
+		return false
+	case 2: // not
+		return len(f.Children) == 1 && !matchFilter(e, f.Children[0])
+	case 3: // equalityMatch
+		if len(f.Children) != 2 {
+			return false
+		}
+		name, value := packetString(f.Children[0]), packetString(f.Children[1])
+		for _, v := range attributeValues(e, name) {
+			if strings.EqualFold(v, value) || (isDNAttribute(name) && sameDN(v, value)) {
+				return true
+			}
+		}
+		return false
+	case 7: // present
+		name := packetString(f)
+		return strings.EqualFold(name, "objectClass") || len(attributeValues(e, name)) > 0
+	default:
+		return false
+	}
+}
+
+// attributeValues 按属性名（不区分大小写）取值
+func attributeValues(e Entry, name string) []string {
+	for k, v := range e.Attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// selectAttributes 只返回请求的属性（未指定时返回全部）
+func selectAttributes(e Entry, attributes []string) Entry {
+	if len(attributes) == 0 {
+		return e
+	}
+	out := Entry{DN: e.DN, Attributes: map[string][]string{}}
+	for _, name := range attributes {
+		if name == "*" {
+			return e
+		}
+		for k, v := range e.Attributes {
+			if strings.EqualFold(k, name) {
+				out.Attributes[k] = v
+			}
+		}
+	}
+	return out
+}
+
+// inScope 判断条目是否在查询范围内
+func inScope(dn, baseDN string, scope int) bool {
+	dn, baseDN = normalizeDN(dn), normalizeDN(baseDN)
+	switch scope {
+	case scopeBaseObject:
+		return dn == baseDN
+	case scopeSingleLevel:
+		if baseDN == "" {
+			return !strings.Contains(dn, ",")
+		}
+		parent := ""
+		if i := strings.Index(dn, ","); i >= 0 {
+			parent = dn[i+1:]
+		}
+		return parent == baseDN
+	case scopeWholeSubtree:
+		return baseDN == "" || dn == baseDN || strings.HasSuffix(dn, ","+baseDN)
+	default:
+		return false
+	}
+}
+
+// isDNAttribute 值为DN的常用属性，比较时按DN规则忽略大小写和空格
+func isDNAttribute(name string) bool {
+	switch strings.ToLower(name) {
+	case "member", "uniquemember", "memberof", "manager":
+		return true
+	}
+	return false
+}
+
+// sameDN 比较两个DN（忽略大小写和RDN之间的空格）
+func sameDN(a, b string) bool {
+	return normalizeDN(a) == normalizeDN(b)
+}
+
+// normalizeDN 规范化DN（不处理转义字符，测试数据中不应包含转义的逗号）
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, p := range parts {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			p = strings.TrimSpace(kv[0]) + "=" + strings.TrimSpace(kv[1])
+		}
+		parts[i] = strings.ToLower(strings.TrimSpace(p))
+	}
+	return strings.Join(parts, ",")
+}
+
+// packetString 读取字符串值（上下文类型的原始值没有解码到 Value，需从 Data 读取）
+func packetString(p *ber.Packet) string {
+	if v, ok := p.Value.(string); ok {
+		return v
+	}
+	if p.Data != nil {
+		return p.Data.String()
+	}
+	return ""
+}
+
+// envelope 构造 LDAPMessage 外层
+func envelope(messageID int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	return packet
+}
+
+// result 构造 LDAPResult 类型的响应（BindResponse、SearchResultDone 等）
+func result(messageID int64, tag int, code int, message string) *ber.Packet {
+	packet := envelope(messageID)
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(tag), nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "diagnosticMessage"))
+	packet.AppendChild(op)
+	return packet
+}
+
+// entryPacket 构造 SearchResultEntry 响应
+func entryPacket(messageID int64, e Entry) *ber.Packet {
+	packet := envelope(messageID)
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, appSearchResultItem, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "objectName"))
+
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range e.Attributes {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, v := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	packet.AppendChild(op)
+	return packet
+}
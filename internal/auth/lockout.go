@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strconv"
+	"time"
+)
+
+// 登录失败计数维度
+const (
+	LockScopeUsername = "username"
+	LockScopeIP       = "ip"
+)
+
+// 登录防暴力破解参数默认值（可在 system_settings 中覆盖）
+const (
+	defaultLoginDelayAfterFailures = 3  // 连续失败多少次后开始延迟响应
+	defaultLoginMaxDelaySeconds    = 10 // 单次最大延迟秒数
+	defaultLoginLockoutFailures    = 10 // 同一用户名连续失败多少次后锁定
+	defaultLoginIPLockoutFailures  = 30 // 同一IP连续失败多少次后锁定
+	defaultLoginLockoutMinutes     = 15 // 锁定时长（分钟），同时也是失败计数的统计窗口
+)
+
+// LoginLock 登录失败记录
+type LoginLock struct {
+	ID           int
+	Scope        string // username / ip
+	ScopeKey     string // 用户名或IP
+	FailCount    int
+	LastFailedAt time.Time
+	LockedUntil  sql.NullTime
+}
+
+// loginGuardConfig 登录防暴力破解参数
+type loginGuardConfig struct {
+	DelayAfterFailures int
+	MaxDelaySeconds    int
+	LockoutFailures    int
+	IPLockoutFailures  int
+	LockoutMinutes     int
+}
+
+// loadLoginGuardConfig 从 system_settings 读取登录防暴力破解参数
+func loadLoginGuardConfig() loginGuardConfig {
+	return loginGuardConfig{
+		DelayAfterFailures: getSettingInt("login_delay_after_failures", defaultLoginDelayAfterFailures),
+		MaxDelaySeconds:    getSettingInt("login_max_delay_seconds", defaultLoginMaxDelaySeconds),
+		LockoutFailures:    getSettingInt("login_lockout_failures", defaultLoginLockoutFailures),
+		IPLockoutFailures:  getSettingInt("login_ip_lockout_failures", defaultLoginIPLockoutFailures),
+		LockoutMinutes:     getSettingInt("login_lockout_minutes", defaultLoginLockoutMinutes),
+	}
+}
+
+// checkLoginLocked 检查用户名或IP是否处于锁定状态，返回剩余锁定时间
+func checkLoginLocked(username, ip string) (bool, time.Duration) {
+	now := time.Now()
+	var lockedUntil sql.NullTime
+	query := `
+		SELECT MAX(locked_until) FROM login_failures
+		WHERE ((scope = ? AND scope_key = ?) OR (scope = ? AND scope_key = ?)) AND locked_until > ?
+	`
+	err := db.DBInstance.QueryRow(query, LockScopeUsername, lockKey(username), LockScopeIP, lockKey(ip), now).Scan(&lockedUntil)
+	if err != nil {
+		logger.Errorf("登录-查询锁定状态失败: %v, 用户名: %s, IP: %s", err, username, ip)
+		return false, 0
+	}
+	if !lockedUntil.Valid {
+		return false, 0
+	}
+	return true, lockedUntil.Time.Sub(now)
+}
+
+// recordLoginFailure 记录一次登录失败，达到阈值时锁定并写操作日志，返回本次应延迟响应的时长
+func recordLoginFailure(r *http.Request, username, ip string) time.Duration {
+	cfg := loadLoginGuardConfig()
+
+	userCount := incrementLoginFailure(r, LockScopeUsername, username, username, cfg.LockoutFailures, cfg)
+	ipCount := incrementLoginFailure(r, LockScopeIP, ip, username, cfg.IPLockoutFailures, cfg)
+
+	count := userCount
+	if ipCount > count {
+		count = ipCount
+	}
+	return loginFailureDelay(count, cfg)
+}
+
+// incrementLoginFailure 增加某个维度的失败计数，达到阈值时锁定，返回当前失败次数
+func incrementLoginFailure(r *http.Request, scope, key, username string, threshold int, cfg loginGuardConfig) int {
+	if key == "" {
+		return 0
+	}
+	key = lockKey(key)
+	now := time.Now()
+	windowStart := now.Add(-time.Duration(cfg.LockoutMinutes) * time.Minute)
+
+	// 超出统计窗口或上次锁定已过期时重新计数
+	upsertSQL := `
+		INSERT INTO login_failures (scope, scope_key, fail_count, last_failed_at)
+		VALUES (?, ?, 1, ?)
+		ON DUPLICATE KEY UPDATE
+			fail_count = IF(last_failed_at < ? OR (locked_until IS NOT NULL AND locked_until <= ?), 1, fail_count + 1),
+			locked_until = IF(locked_until IS NOT NULL AND locked_until <= ?, NULL, locked_until),
+			last_failed_at = VALUES(last_failed_at)
+	`
+	if _, err := db.DBInstance.Exec(upsertSQL, scope, key, now, windowStart, now, now); err != nil {
+		logger.Errorf("登录-记录失败次数失败: %v, 维度: %s, 值: %s", err, scope, key)
+		return 0
+	}
+
+	var failCount int
+	var lockedUntil sql.NullTime
+	err := db.DBInstance.QueryRow("SELECT fail_count, locked_until FROM login_failures WHERE scope = ? AND scope_key = ?", scope, key).
+		Scan(&failCount, &lockedUntil)
+	if err != nil {
+		logger.Errorf("登录-查询失败次数失败: %v, 维度: %s, 值: %s", err, scope, key)
+		return 0
+	}
+
+	if threshold > 0 && failCount >= threshold && !lockedUntil.Valid {
+		until := now.Add(time.Duration(cfg.LockoutMinutes) * time.Minute)
+		_, err := db.DBInstance.Exec("UPDATE login_failures SET locked_until = ? WHERE scope = ? AND scope_key = ?", until, scope, key)
+		if err != nil {
+			logger.Errorf("登录-锁定失败: %v, 维度: %s, 值: %s", err, scope, key)
+			return failCount
+		}
+
+		var action string
+		if scope == LockScopeIP {
+			action = fmt.Sprintf("登录失败次数过多，IP已锁定（IP：%s，连续失败 %d 次，锁定 %d 分钟）", key, failCount, cfg.LockoutMinutes)
+		} else {
+			action = fmt.Sprintf("登录失败次数过多，账号已锁定（用户名：%s，连续失败 %d 次，锁定 %d 分钟）", key, failCount, cfg.LockoutMinutes)
+		}
+		operationlog.Record(r, lockKey(username), operationlog.Entry{
+			Action:     operationlog.ActionLockout,
+			EntityType: operationlog.EntityLoginLock,
+			EntityID:   key,
+			Outcome:    operationlog.OutcomeDenied,
+			Message:    action,
+		})
+	}
+
+	return failCount
+}
+
+// loginFailureDelay 计算渐进延迟：超过阈值后每多失败一次延迟翻倍，最多 MaxDelaySeconds 秒
+func loginFailureDelay(failCount int, cfg loginGuardConfig) time.Duration {
+	if failCount < cfg.DelayAfterFailures || cfg.MaxDelaySeconds <= 0 {
+		return 0
+	}
+	seconds := 1
+	for i := cfg.DelayAfterFailures; i < failCount && seconds < cfg.MaxDelaySeconds; i++ {
+		seconds *= 2
+	}
+	if seconds > cfg.MaxDelaySeconds {
+		seconds = cfg.MaxDelaySeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// clearLoginFailures 登录成功后清除该用户名的失败计数
+func clearLoginFailures(username string) {
+	_, err := db.DBInstance.Exec("DELETE FROM login_failures WHERE scope = ? AND scope_key = ?", LockScopeUsername, lockKey(username))
+	if err != nil {
+		logger.Errorf("登录-清除失败次数失败: %v, 用户名: %s", err, username)
+	}
+}
+
+// ListLoginLocks 查询当前处于锁定状态的用户名和IP
+func ListLoginLocks() ([]LoginLock, error) {
+	query := `
+		SELECT id, scope, scope_key, fail_count, last_failed_at, locked_until
+		FROM login_failures
+		WHERE locked_until > ?
+		ORDER BY locked_until DESC
+	`
+	rows, err := db.DBInstance.Query(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locks []LoginLock
+	for rows.Next() {
+		var lock LoginLock
+		if err := rows.Scan(&lock.ID, &lock.Scope, &lock.ScopeKey, &lock.FailCount, &lock.LastFailedAt, &lock.LockedUntil); err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	return locks, rows.Err()
+}
+
+// UnlockLogin 解除锁定（同时清零失败次数），返回被解除的记录
+func UnlockLogin(lockID int) (*LoginLock, error) {
+	var lock LoginLock
+	err := db.DBInstance.QueryRow(
+		"SELECT id, scope, scope_key, fail_count, last_failed_at, locked_until FROM login_failures WHERE id = ?", lockID,
+	).Scan(&lock.ID, &lock.Scope, &lock.ScopeKey, &lock.FailCount, &lock.LastFailedAt, &lock.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.DBInstance.Exec("DELETE FROM login_failures WHERE id = ?", lockID); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// lockKey 截断过长的用户名/IP，避免超出字段长度
+func lockKey(key string) string {
+	runes := []rune(key)
+	if len(runes) > 100 {
+		return string(runes[:100])
+	}
+	return key
+}
+
+// getSettingInt 获取整数类型参数值，参数不存在或无效时返回默认值
+func getSettingInt(key string, defaultValue int) int {
+	var value string
+	query := "SELECT param_value FROM system_settings WHERE param_key = ?"
+	err := db.DBInstance.QueryRow(query, key).Scan(&value)
+	if err != nil {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return defaultValue
+	}
+	return n
+}
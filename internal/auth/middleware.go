@@ -4,6 +4,15 @@ import (
 	"net/http"
 )
 
+// redirectToLogin 未登录或会话已超时时跳转到登录页，登录后返回原页面（非 GET 请求返回提交表单所在的页面）
+func redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	next := r.Referer()
+	if r.Method == http.MethodGet {
+		next = r.URL.RequestURI()
+	}
+	http.Redirect(w, r, LoginURL(next), http.StatusFound)
+}
+
 // renderForbidden 在当前响应中输出一个居中的提示小窗
 func renderForbidden(w http.ResponseWriter, message string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -45,29 +54,24 @@ func renderForbidden(w http.ResponseWriter, message string) {
 </html>`))
 }
 
-// RequireAuth 要求用户必须登录的中间件
+// RequireAuth 要求用户必须登录的中间件（也接受 Authorization: Bearer 个人访问令牌），不检查任何权限
+// 当前用户只查询一次并放入请求上下文，处理函数通过 CurrentUser 获取；
+// 注册路由时使用 RequirePermission 声明所需权限，确实不需要权限的页面使用 AllowAnyUser
 func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !IsAuthenticated(r) {
-			http.Redirect(w, r, "/login", http.StatusFound)
-			return
-		}
-		next(w, r)
-	}
-}
-
-// RequireAdmin 要求用户必须是管理员的中间件
-func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if !IsAuthenticated(r) {
-			http.Redirect(w, r, "/login", http.StatusFound)
+		if raw, ok := bearerToken(r); ok {
+			r, ok = authenticateAPIRequest(w, r, raw, "")
+			if !ok {
+				return
+			}
+			next(w, r)
 			return
 		}
-		if !IsAdmin(r) {
-			renderForbidden(w, "需要管理员权限才能访问。")
+		user := GetCurrentUser(r)
+		if user == nil {
+			redirectToLogin(w, r)
 			return
 		}
-		next(w, r)
+		next(w, withUser(r, user))
 	}
 }
-
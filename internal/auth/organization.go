@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"ops-web/internal/db"
+	"ops-web/internal/operationlog"
+	"strconv"
+	"strings"
+)
+
+// Organization 机构
+type Organization struct {
+	ID        int
+	Name      string
+	UserCount int // 关联该机构的用户数
+}
+
+// loadUserOrganizations 查询用户关联的机构名称
+func loadUserOrganizations(user *User) error {
+	rows, err := db.DBInstance.Query(`
+		SELECT o.name
+		FROM user_organizations uo
+		JOIN organizations o ON uo.organization_id = o.id
+		WHERE uo.user_id = ?
+		ORDER BY o.name
+	`, user.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	user.Organizations = nil
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		user.Organizations = append(user.Organizations, name)
+	}
+	return rows.Err()
+}
+
+// AllOrganizations 用户是否可以查看全部机构的数据（管理员或拥有“查看全部机构”权限）
+func (u *User) AllOrganizations() bool {
+	return u.Can(PermAllOrganizations)
+}
+
+// CanAccessOrganization 用户是否可以查看指定机构的数据
+func (u *User) CanAccessOrganization(organization string) bool {
+	if u == nil {
+		return false
+	}
+	if u.AllOrganizations() {
+		return true
+	}
+	for _, name := range u.Organizations {
+		if name == organization {
+			return true
+		}
+	}
+	return false
+}
+
+// OrganizationFilter 生成按用户可见机构过滤的 SQL 条件（以 " AND " 开头），column 为机构名称列或表达式
+// 可查看全部机构时返回空字符串；未关联任何机构时不返回任何数据
+func OrganizationFilter(user *User, column string) (string, []interface{}) {
+	if user.AllOrganizations() {
+		return "", nil
+	}
+	if user == nil || len(user.Organizations) == 0 {
+		return " AND 1=0", nil
+	}
+	args := make([]interface{}, len(user.Organizations))
+	for i, name := range user.Organizations {
+		args[i] = name
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	return " AND " + column + " IN (" + placeholders + ")", args
+}
+
+// RequireTaskOrganization 校验请求参数 task_id 对应的档案是否属于当前用户可见的机构，不属于时返回403
+// table 为档案任务表（audit_tasks 或 checkpoint_tasks）；task_id 缺失、无效或档案不存在时交由处理函数自行处理
+func RequireTaskOrganization(table string, next http.HandlerFunc) http.HandlerFunc {
+	if table != "audit_tasks" && table != "checkpoint_tasks" {
+		panic("RequireTaskOrganization: 不支持的表 " + table)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil || user.AllOrganizations() {
+			next(w, r)
+			return
+		}
+		taskID, err := strconv.Atoi(r.FormValue("task_id"))
+		if err != nil || taskID <= 0 {
+			next(w, r)
+			return
+		}
+
+		var organization string
+		err = db.DBInstance.QueryRow("SELECT organization FROM "+table+" WHERE id = ?", taskID).Scan(&organization)
+		if err == sql.ErrNoRows {
+			next(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "查询档案失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !user.CanAccessOrganization(organization) {
+			entityType := operationlog.EntityAuditTask
+			if table == "checkpoint_tasks" {
+				entityType = operationlog.EntityCheckpointTask
+			}
+			operationlog.Record(r, user.Username, operationlog.Entry{
+				Action:     operationlog.ActionAccess,
+				EntityType: entityType,
+				EntityID:   operationlog.ID(taskID),
+				Outcome:    operationlog.OutcomeDenied,
+				Message:    fmt.Sprintf("访问被拒绝（档案不属于可见机构：%s，%s %s）", organization, r.Method, r.URL.Path),
+			})
+			renderForbidden(w, "该档案属于机构“"+organization+"”，您没有查看该机构数据的权限。")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ListOrganizations 查询全部机构及关联用户数
+func ListOrganizations() ([]Organization, error) {
+	rows, err := db.DBInstance.Query(`
+		SELECT o.id, o.name, COUNT(uo.user_id)
+		FROM organizations o
+		LEFT JOIN user_organizations uo ON uo.organization_id = o.id
+		GROUP BY o.id, o.name
+		ORDER BY o.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []Organization
+	for rows.Next() {
+		var org Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.UserCount); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// VisibleOrganizations 用户可见的机构名称（可查看全部机构时返回机构字典中的全部机构）
+func VisibleOrganizations(user *User) ([]string, error) {
+	if !user.AllOrganizations() {
+		if user == nil {
+			return nil, nil
+		}
+		return user.Organizations, nil
+	}
+	orgs, err := ListOrganizations()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(orgs))
+	for i, org := range orgs {
+		names[i] = org.Name
+	}
+	return names, nil
+}
+
+// EnsureOrganization 机构不存在时加入机构字典（导入档案时调用，使新机构可分配给用户）
+func EnsureOrganization(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	_, err := db.DBInstance.Exec("INSERT IGNORE INTO organizations (name) VALUES (?)", name)
+	return err
+}
+
+// SetUserOrganizations 覆盖设置用户关联的机构
+func SetUserOrganizations(userID int, organizationIDs []int) error {
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_organizations WHERE user_id = ?", userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	seen := make(map[int]bool)
+	for _, id := range organizationIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result, err := tx.Exec("INSERT IGNORE INTO user_organizations (user_id, organization_id) SELECT ?, id FROM organizations WHERE id = ?", userID, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return errors.New("机构不存在")
+		}
+	}
+	return tx.Commit()
+}
+
+// UserOrganizationIDs 查询全部用户关联的机构ID（用户ID -> 机构ID列表）
+func UserOrganizationIDs() (map[int][]int, error) {
+	rows, err := db.DBInstance.Query(`
+		SELECT uo.user_id, uo.organization_id
+		FROM user_organizations uo
+		JOIN organizations o ON uo.organization_id = o.id
+		ORDER BY uo.user_id, o.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int][]int)
+	for rows.Next() {
+		var userID, orgID int
+		if err := rows.Scan(&userID, &orgID); err != nil {
+			return nil, err
+		}
+		result[userID] = append(result[userID], orgID)
+	}
+	return result, rows.Err()
+}
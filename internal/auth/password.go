@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"ops-web/internal/db"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码策略默认值（可在 system_settings 中覆盖）
+const (
+	defaultPasswordMinLength     = 8
+	defaultPasswordRequireUpper  = 0
+	defaultPasswordRequireLower  = 1
+	defaultPasswordRequireDigit  = 1
+	defaultPasswordRequireSymbol = 0
+	defaultPasswordHistoryCount  = 3 // 不能与最近几次使用过的密码相同，0 表示不检查
+	defaultPasswordMaxAgeDays    = 0 // 密码有效期（天），0 表示永不过期
+)
+
+// 密码策略参数上限
+const (
+	passwordMaxLength    = 72   // bcrypt 只使用前72个字节
+	passwordMinLengthMax = 64   // 最小长度设置上限
+	passwordHistoryKeep  = 24   // 每个用户最多保留的历史密码数，也是历史密码检查次数的上限
+	passwordMaxAgeLimit  = 3650 // 有效期设置上限（天）
+)
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	HistoryCount  int
+	MaxAgeDays    int
+}
+
+// PasswordPolicyError 新密码不符合策略（错误信息可直接展示给用户）
+type PasswordPolicyError struct {
+	Msg string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Msg
+}
+
+// LoadPasswordPolicy 从 system_settings 读取密码策略
+func LoadPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     getSettingInt("password_min_length", defaultPasswordMinLength),
+		RequireUpper:  getSettingInt("password_require_upper", defaultPasswordRequireUpper) != 0,
+		RequireLower:  getSettingInt("password_require_lower", defaultPasswordRequireLower) != 0,
+		RequireDigit:  getSettingInt("password_require_digit", defaultPasswordRequireDigit) != 0,
+		RequireSymbol: getSettingInt("password_require_symbol", defaultPasswordRequireSymbol) != 0,
+		HistoryCount:  getSettingInt("password_history_count", defaultPasswordHistoryCount),
+		MaxAgeDays:    getSettingInt("password_max_age_days", defaultPasswordMaxAgeDays),
+	}
+}
+
+// Check 校验策略参数取值范围
+func (p PasswordPolicy) Check() error {
+	if p.MinLength < 1 || p.MinLength > passwordMinLengthMax {
+		return fmt.Errorf("密码最小长度须在 1~%d 之间", passwordMinLengthMax)
+	}
+	if p.HistoryCount < 0 || p.HistoryCount > passwordHistoryKeep {
+		return fmt.Errorf("禁止重复使用的历史密码数须在 0~%d 之间", passwordHistoryKeep)
+	}
+	if p.MaxAgeDays < 0 || p.MaxAgeDays > passwordMaxAgeLimit {
+		return fmt.Errorf("密码有效期须在 0~%d 天之间", passwordMaxAgeLimit)
+	}
+	return nil
+}
+
+// Description 策略说明（显示在修改密码页面）
+func (p PasswordPolicy) Description() string {
+	parts := []string{fmt.Sprintf("至少 %d 位", p.MinLength)}
+	var classes []string
+	if p.RequireUpper {
+		classes = append(classes, "大写字母")
+	}
+	if p.RequireLower {
+		classes = append(classes, "小写字母")
+	}
+	if p.RequireDigit {
+		classes = append(classes, "数字")
+	}
+	if p.RequireSymbol {
+		classes = append(classes, "特殊字符")
+	}
+	if len(classes) > 0 {
+		parts = append(parts, "须包含"+strings.Join(classes, "、"))
+	}
+	if p.HistoryCount > 0 {
+		parts = append(parts, fmt.Sprintf("不能与最近 %d 次使用过的密码相同", p.HistoryCount))
+	}
+	if p.MaxAgeDays > 0 {
+		parts = append(parts, fmt.Sprintf("有效期 %d 天", p.MaxAgeDays))
+	}
+	return strings.Join(parts, "，")
+}
+
+// Validate 校验密码强度（长度与字符类别）
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return &PasswordPolicyError{Msg: fmt.Sprintf("密码长度不能少于 %d 位", p.MinLength)}
+	}
+	if len(password) > passwordMaxLength {
+		return &PasswordPolicyError{Msg: fmt.Sprintf("密码长度不能超过 %d 个字节", passwordMaxLength)}
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		return &PasswordPolicyError{Msg: "密码须包含大写字母"}
+	}
+	if p.RequireLower && !hasLower {
+		return &PasswordPolicyError{Msg: "密码须包含小写字母"}
+	}
+	if p.RequireDigit && !hasDigit {
+		return &PasswordPolicyError{Msg: "密码须包含数字"}
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return &PasswordPolicyError{Msg: "密码须包含特殊字符"}
+	}
+	return nil
+}
+
+// CheckNewPassword 按当前策略校验新密码（强度及是否与最近使用过的密码重复），userID 为 0 时只校验强度
+func CheckNewPassword(userID int, password string) error {
+	policy := LoadPasswordPolicy()
+	if err := policy.Validate(password); err != nil {
+		return err
+	}
+	if userID == 0 || policy.HistoryCount == 0 {
+		return nil
+	}
+
+	// 当前密码也算作最近使用过的密码（启用本功能前设置的密码没有历史记录）
+	same, err := VerifyPassword(userID, password)
+	if err != nil {
+		return fmt.Errorf("查询当前密码失败: %v", err)
+	}
+	if same {
+		return &PasswordPolicyError{Msg: "新密码不能与当前密码相同"}
+	}
+
+	rows, err := db.DBInstance.Query(
+		"SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?", userID, policy.HistoryCount)
+	if err != nil {
+		return fmt.Errorf("查询历史密码失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return fmt.Errorf("查询历史密码失败: %v", err)
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return &PasswordPolicyError{Msg: fmt.Sprintf("新密码不能与最近 %d 次使用过的密码相同", policy.HistoryCount)}
+		}
+	}
+	return rows.Err()
+}
+
+// ChangePassword 校验并修改用户密码
+func ChangePassword(userID int, password string) error {
+	if err := CheckNewPassword(userID, password); err != nil {
+		return err
+	}
+	return SetPassword(userID, password)
+}
+
+// SetPassword 修改用户密码（不做策略校验），同时更新修改时间并记录历史密码
+func SetPassword(userID int, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("密码加密失败: %v", err)
+	}
+	if _, err := db.DBInstance.Exec(
+		"UPDATE users SET password = ?, password_changed_at = ? WHERE id = ?", hash, time.Now(), userID); err != nil {
+		return fmt.Errorf("更新密码失败: %v", err)
+	}
+	return RecordPasswordHistory(userID, hash)
+}
+
+// RecordPasswordHistory 记录历史密码，只保留最近 passwordHistoryKeep 条
+func RecordPasswordHistory(userID int, hash string) error {
+	if _, err := db.DBInstance.Exec(
+		"INSERT INTO password_history (user_id, password_hash) VALUES (?, ?)", userID, hash); err != nil {
+		return fmt.Errorf("记录历史密码失败: %v", err)
+	}
+	trimSQL := `
+		DELETE FROM password_history
+		WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM (
+				SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+			) t
+		)
+	`
+	if _, err := db.DBInstance.Exec(trimSQL, userID, userID, passwordHistoryKeep); err != nil {
+		return fmt.Errorf("清理历史密码失败: %v", err)
+	}
+	return nil
+}
+
+// VerifyPassword 校验本地账号的当前密码
+func VerifyPassword(userID int, password string) (bool, error) {
+	var hash string
+	err := db.DBInstance.QueryRow("SELECT password FROM users WHERE id = ?", userID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+}
+
+// PasswordExpireAt 本地账号的密码过期时间，未启用有效期或非本地账号时返回零值
+func PasswordExpireAt(user *User) (time.Time, error) {
+	if user.AuthSource != AuthSourceLocal {
+		return time.Time{}, nil
+	}
+	policy := LoadPasswordPolicy()
+	if policy.MaxAgeDays <= 0 {
+		return time.Time{}, nil
+	}
+
+	var changedAt time.Time
+	if err := db.DBInstance.QueryRow("SELECT password_changed_at FROM users WHERE id = ?", user.ID).Scan(&changedAt); err != nil {
+		return time.Time{}, err
+	}
+	return changedAt.AddDate(0, 0, policy.MaxAgeDays), nil
+}
+
+// passwordExpired 判断本地账号密码是否已过期
+func passwordExpired(user *User) (bool, error) {
+	expireAt, err := PasswordExpireAt(user)
+	if err != nil || expireAt.IsZero() {
+		return false, err
+	}
+	return time.Now().After(expireAt), nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+)
+
+const passwordChangeCookieName = "ops_pwchange"
+
+// PasswordChangePageData 修改过期密码页面数据
+type PasswordChangePageData struct {
+	ErrorMsg          string
+	Username          string
+	PolicyDescription string
+	CSRFToken         string
+}
+
+// proceedLogin 身份验证全部通过后继续登录：密码已过期时转到修改密码页面，否则创建会话
+// next 为登录完成后要进入的页面；返回下一步跳转的地址，返回 false 时已输出错误响应
+func proceedLogin(w http.ResponseWriter, r *http.Request, user *User, action string, next string) (string, bool) {
+	expired, err := passwordExpired(user)
+	if err != nil {
+		logger.Errorf("登录-查询密码修改时间失败: %v, 用户名: %s", err, user.Username)
+		http.Error(w, "数据库查询失败: "+err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+
+	if expired {
+		if err := savePendingLogin(w, passwordChangeCookieName, &pendingLogin{User: *user, Action: action, Next: next}); err != nil {
+			logger.Errorf("登录-创建修改密码请求失败: %v, 用户名: %s", err, user.Username)
+			http.Error(w, "创建修改密码请求失败: "+err.Error(), http.StatusInternalServerError)
+			return "", false
+		}
+		return "/login/change-password", true
+	}
+
+	if !completeLogin(w, r, user, action) {
+		return "", false
+	}
+	return next, true
+}
+
+// ExpiredPasswordHandler 密码过期后强制修改密码（GET 显示页面，POST 提交新密码），修改成功后完成登录
+func ExpiredPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	token, pending := getPendingLogin(r, passwordChangeCookieName)
+	if pending == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderPasswordChangePage(w, r, pending, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	newPassword := r.FormValue("new_password")
+	if newPassword != r.FormValue("confirm_password") {
+		renderPasswordChangePage(w, r, pending, "两次输入的密码不一致")
+		return
+	}
+
+	user := pending.User
+	if err := ChangePassword(user.ID, newPassword); err != nil {
+		var policyErr *PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			renderPasswordChangePage(w, r, pending, policyErr.Msg)
+			return
+		}
+		logger.Errorf("登录-修改过期密码失败: %v, 用户名: %s", err, user.Username)
+		http.Error(w, "修改密码失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	clearPendingLogin(w, passwordChangeCookieName, token)
+	operationlog.Record(r, user.Username, operationlog.Entry{
+		Action:     operationlog.ActionChangePassword,
+		EntityType: operationlog.EntityUser,
+		EntityID:   operationlog.ID(user.ID),
+		Message:    "修改密码（密码已过期）",
+	})
+
+	if completeLogin(w, r, &user, pending.Action) {
+		http.Redirect(w, r, pending.Next, http.StatusFound)
+	}
+}
+
+// renderPasswordChangePage 渲染修改过期密码页面
+func renderPasswordChangePage(w http.ResponseWriter, r *http.Request, pending *pendingLogin, errorMsg string) {
+	data := PasswordChangePageData{
+		ErrorMsg:          errorMsg,
+		Username:          pending.User.Username,
+		PolicyDescription: LoadPasswordPolicy().Description(),
+		CSRFToken:         CSRFToken(r),
+	}
+
+	tmpl, err := template.ParseFiles("templates/loginpassword.html")
+	if err != nil {
+		logger.Errorf("修改密码-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		logger.Errorf("修改密码-模板渲染失败: %v", err)
+		http.Error(w, "模板渲染失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,453 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"ops-web/internal/db"
+	"ops-web/internal/operationlog"
+	"strings"
+)
+
+// AdminRoleCode 内置管理员角色的角色代码，拥有全部权限且不可修改
+const AdminRoleCode = 0
+
+// 权限代码
+const (
+	PermDeviceView         = "device_view"          // 查看设备建档明细、审核进度、录像提醒
+	PermCheckpointView     = "checkpoint_view"      // 查看卡口建档明细、审核进度
+	PermStatsView          = "stats_view"           // 查看统计信息、审核统计
+	PermDeviceImport       = "device_import"        // 设备审核进度档案导入
+	PermCheckpointImport   = "checkpoint_import"    // 卡口审核进度档案导入
+	PermArchiveDelete      = "archive_delete"       // 删除审核进度档案
+	PermAuditEdit          = "audit_edit"           // 编辑审核意见
+	PermAuditSample        = "audit_sample"         // 抽检
+	PermReminderManage     = "reminder_manage"      // 管理录像天数不足提醒
+	PermDataExport         = "data_export"          // 导出 Excel
+	PermAttachmentUpload   = "attachment_upload"    // 上传档案附件
+	PermAttachmentDownload = "attachment_download"  // 下载档案附件
+	PermDevicePasswordView = "device_password_view" // 查看设备口令（导出时显示明文）
+	PermAllOrganizations   = "all_organizations"    // 查看全部机构的数据
+	PermUserView           = "user_view"            // 查看用户列表
+	PermUserManage         = "user_manage"          // 用户、会话、API令牌管理
+	PermSystemManage       = "system_manage"        // 权限设置、任务配置
+	PermLogView            = "log_view"             // 查看操作日志
+)
+
+// Permission 权限定义
+type Permission struct {
+	Code  string
+	Name  string
+	Group string
+}
+
+// Permissions 全部权限（按权限设置页面显示顺序）
+var Permissions = []Permission{
+	{PermDeviceView, "查看设备档案", "档案审核"},
+	{PermCheckpointView, "查看卡口档案", "档案审核"},
+	{PermDeviceImport, "设备档案导入", "档案审核"},
+	{PermCheckpointImport, "卡口档案导入", "档案审核"},
+	{PermArchiveDelete, "删除档案", "档案审核"},
+	{PermAuditEdit, "编辑审核意见", "档案审核"},
+	{PermAuditSample, "抽检", "档案审核"},
+	{PermReminderManage, "管理录像提醒", "档案审核"},
+	{PermStatsView, "查看统计", "数据"},
+	{PermDataExport, "导出数据", "数据"},
+	{PermAttachmentUpload, "上传附件", "数据"},
+	{PermAttachmentDownload, "下载附件", "数据"},
+	{PermDevicePasswordView, "查看设备口令", "数据"},
+	{PermAllOrganizations, "查看全部机构", "数据"},
+	{PermUserView, "查看用户列表", "系统"},
+	{PermUserManage, "用户管理", "系统"},
+	{PermSystemManage, "系统设置", "系统"},
+	{PermLogView, "查看操作日志", "系统"},
+}
+
+// PermissionName 权限名称，未知权限返回代码本身
+func PermissionName(code string) string {
+	for _, p := range Permissions {
+		if p.Code == code {
+			return p.Name
+		}
+	}
+	return code
+}
+
+// Role 角色
+type Role struct {
+	ID          int
+	Name        string
+	Code        int
+	Permissions map[string]bool
+	UserCount   int // 拥有该角色的用户数
+}
+
+// IsAdmin 是否为内置管理员角色
+func (r Role) IsAdmin() bool {
+	return r.Code == AdminRoleCode
+}
+
+// IsAdmin 当前用户是否拥有管理员角色
+func (u *User) IsAdmin() bool {
+	return u != nil && u.RoleCode == AdminRoleCode
+}
+
+// Can 当前用户是否拥有指定权限（管理员拥有全部权限）
+func (u *User) Can(permission string) bool {
+	if u == nil {
+		return false
+	}
+	return u.IsAdmin() || u.Permissions[permission]
+}
+
+// RequirePermission 要求当前用户拥有指定权限的中间件（也接受 Authorization: Bearer 个人访问令牌）
+func RequirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if raw, ok := bearerToken(r); ok {
+			r, ok = authenticateAPIRequest(w, r, raw, permission)
+			if !ok {
+				return
+			}
+			next(w, r)
+			return
+		}
+		user := GetCurrentUser(r)
+		if user == nil {
+			redirectToLogin(w, r)
+			return
+		}
+		if !user.Can(permission) {
+			operationlog.Record(r, user.Username, operationlog.Entry{
+				Action:     operationlog.ActionAccess,
+				EntityType: operationlog.EntityRequest,
+				EntityID:   r.URL.Path,
+				Outcome:    operationlog.OutcomeDenied,
+				Message:    fmt.Sprintf("访问被拒绝（缺少权限：%s，%s %s）", PermissionName(permission), r.Method, r.URL.Path),
+			})
+			renderForbidden(w, "您没有“"+PermissionName(permission)+"”权限，请联系管理员。")
+			return
+		}
+		next(w, withUser(r, user))
+	}
+}
+
+// AllowAnyUser 声明页面不需要任何权限、登录即可访问（如“我的账号”），与 RequirePermission 对应，
+// 使路由表中每个需要登录的路由都显式说明访问条件
+func AllowAnyUser(next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(next)
+}
+
+// loadUserRoles 查询用户的全部角色及权限，填充 RoleCode（取最小角色代码）、RoleName 和 Permissions
+func loadUserRoles(user *User) error {
+	rows, err := db.DBInstance.Query(`
+		SELECT r.id, r.role_name, r.role_code
+		FROM user_role_members m
+		JOIN user_role r ON m.role_id = r.id
+		WHERE m.user_id = ?
+		ORDER BY r.role_code
+	`, user.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var names []string
+	user.RoleIDs = nil
+	for rows.Next() {
+		var id, code int
+		var name string
+		if err := rows.Scan(&id, &name, &code); err != nil {
+			return err
+		}
+		if len(user.RoleIDs) == 0 {
+			user.RoleCode = code
+		}
+		user.RoleIDs = append(user.RoleIDs, id)
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// 未分配角色表记录时沿用 users.role_id
+	if len(user.RoleIDs) == 0 {
+		user.RoleIDs = []int{user.RoleID}
+	} else {
+		user.RoleName = strings.Join(names, "、")
+	}
+
+	user.Permissions = make(map[string]bool)
+	permRows, err := db.DBInstance.Query(`
+		SELECT DISTINCT p.permission
+		FROM role_permissions p
+		JOIN user_role_members m ON p.role_id = m.role_id
+		WHERE m.user_id = ?
+	`, user.ID)
+	if err != nil {
+		return err
+	}
+	defer permRows.Close()
+	for permRows.Next() {
+		var perm string
+		if err := permRows.Scan(&perm); err != nil {
+			return err
+		}
+		user.Permissions[perm] = true
+	}
+	return permRows.Err()
+}
+
+// ListRoles 查询全部角色及其权限和用户数
+func ListRoles() ([]Role, error) {
+	rows, err := db.DBInstance.Query(`
+		SELECT r.id, r.role_name, r.role_code, COUNT(m.user_id)
+		FROM user_role r
+		LEFT JOIN user_role_members m ON m.role_id = r.id
+		GROUP BY r.id, r.role_name, r.role_code
+		ORDER BY r.role_code
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	index := make(map[int]int)
+	for rows.Next() {
+		role := Role{Permissions: make(map[string]bool)}
+		if err := rows.Scan(&role.ID, &role.Name, &role.Code, &role.UserCount); err != nil {
+			return nil, err
+		}
+		index[role.ID] = len(roles)
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	permRows, err := db.DBInstance.Query("SELECT role_id, permission FROM role_permissions")
+	if err != nil {
+		return nil, err
+	}
+	defer permRows.Close()
+	for permRows.Next() {
+		var roleID int
+		var perm string
+		if err := permRows.Scan(&roleID, &perm); err != nil {
+			return nil, err
+		}
+		if i, ok := index[roleID]; ok {
+			roles[i].Permissions[perm] = true
+		}
+	}
+	return roles, permRows.Err()
+}
+
+// SaveRolePermissions 覆盖保存角色的权限，管理员角色不可修改
+func SaveRolePermissions(roleID int, permissions []string) error {
+	var code int
+	err := db.DBInstance.QueryRow("SELECT role_code FROM user_role WHERE id = ?", roleID).Scan(&code)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("角色不存在: %d", roleID)
+	}
+	if err != nil {
+		return err
+	}
+	if code == AdminRoleCode {
+		return errors.New("管理员角色拥有全部权限，不可修改")
+	}
+
+	valid := make(map[string]bool)
+	for _, p := range Permissions {
+		valid[p.Code] = true
+	}
+
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, perm := range permissions {
+		if !valid[perm] {
+			continue
+		}
+		if _, err := tx.Exec("INSERT IGNORE INTO role_permissions (role_id, permission) VALUES (?, ?)", roleID, perm); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateRole 新建角色（无任何权限），角色代码自动分配
+func CreateRole(name string) (*Role, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("角色名称不能为空")
+	}
+	if len([]rune(name)) > 50 {
+		return nil, errors.New("角色名称不能超过50个字符")
+	}
+
+	var count int
+	if err := db.DBInstance.QueryRow("SELECT COUNT(*) FROM user_role WHERE role_name = ?", name).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("角色名称已存在")
+	}
+
+	var maxCode int
+	if err := db.DBInstance.QueryRow("SELECT COALESCE(MAX(role_code), 0) FROM user_role").Scan(&maxCode); err != nil {
+		return nil, err
+	}
+	if maxCode >= 127 {
+		return nil, errors.New("角色数量已达上限")
+	}
+	role := &Role{Name: name, Code: maxCode + 1, Permissions: make(map[string]bool)}
+	result, err := db.DBInstance.Exec("INSERT INTO user_role (role_name, role_code) VALUES (?, ?)", role.Name, role.Code)
+	if err != nil {
+		return nil, err
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		role.ID = int(id)
+	}
+	return role, nil
+}
+
+// DeleteRole 删除角色，管理员角色和仍有用户使用的角色不可删除
+func DeleteRole(roleID int) (*Role, error) {
+	role := &Role{ID: roleID}
+	err := db.DBInstance.QueryRow("SELECT role_name, role_code FROM user_role WHERE id = ?", roleID).Scan(&role.Name, &role.Code)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("角色不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if role.IsAdmin() {
+		return nil, errors.New("管理员角色不可删除")
+	}
+
+	var count int
+	err = db.DBInstance.QueryRow(`
+		SELECT COUNT(*) FROM users u
+		WHERE u.role_id = ? OR EXISTS (SELECT 1 FROM user_role_members m WHERE m.user_id = u.id AND m.role_id = ?)
+	`, roleID, roleID).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("仍有 %d 个用户使用该角色，请先修改这些用户的角色", count)
+	}
+
+	if _, err := db.DBInstance.Exec("DELETE FROM user_role WHERE id = ?", roleID); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// SetUserRoles 覆盖设置用户的角色，users.role_id 同步为其中角色代码最小的角色
+func SetUserRoles(userID int, roleIDs []int) error {
+	if len(roleIDs) == 0 {
+		return errors.New("请至少选择一个角色")
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(roleIDs)), ",")
+	args := make([]interface{}, len(roleIDs))
+	for i, id := range roleIDs {
+		args[i] = id
+	}
+	var primaryID, found int
+	err := db.DBInstance.QueryRow(
+		"SELECT (SELECT id FROM user_role WHERE id IN ("+placeholders+") ORDER BY role_code LIMIT 1), COUNT(*) FROM user_role WHERE id IN ("+placeholders+")",
+		append(args, args...)...).Scan(&primaryID, &found)
+	if err != nil {
+		return err
+	}
+	if found != len(roleIDs) {
+		return errors.New("角色不存在")
+	}
+
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_role_members WHERE user_id = ?", userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, id := range roleIDs {
+		if _, err := tx.Exec("INSERT INTO user_role_members (user_id, role_id) VALUES (?, ?)", userID, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE users SET role_id = ? WHERE id = ?", primaryID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ContainsAdminRole 角色ID列表中是否包含内置管理员角色
+func ContainsAdminRole(roleIDs []int) (bool, error) {
+	if len(roleIDs) == 0 {
+		return false, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(roleIDs)), ",")
+	args := []interface{}{AdminRoleCode}
+	for _, id := range roleIDs {
+		args = append(args, id)
+	}
+	var count int
+	err := db.DBInstance.QueryRow(
+		"SELECT COUNT(*) FROM user_role WHERE role_code = ? AND id IN ("+placeholders+")", args...).Scan(&count)
+	return count > 0, err
+}
+
+// IsAdminUser 用户是否拥有内置管理员角色（主角色或角色成员中任一为管理员）
+func IsAdminUser(userID int) (bool, error) {
+	var count int
+	err := db.DBInstance.QueryRow(`
+		SELECT COUNT(*) FROM user_role
+		WHERE role_code = ?
+		  AND (id = (SELECT role_id FROM users WHERE id = ?) OR id IN (SELECT role_id FROM user_role_members WHERE user_id = ?))
+	`, AdminRoleCode, userID, userID).Scan(&count)
+	return count > 0, err
+}
+
+// UserRoleIDs 查询全部用户的角色ID（用户ID -> 角色ID列表）
+func UserRoleIDs() (map[int][]int, error) {
+	rows, err := db.DBInstance.Query(`
+		SELECT m.user_id, m.role_id
+		FROM user_role_members m
+		JOIN user_role r ON m.role_id = r.id
+		ORDER BY m.user_id, r.role_code
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int][]int)
+	for rows.Next() {
+		var userID, roleID int
+		if err := rows.Scan(&userID, &roleID); err != nil {
+			return nil, err
+		}
+		result[userID] = append(result[userID], roleID)
+	}
+	return result, rows.Err()
+}
+
+// MaskDevicePassword 没有“查看设备口令”权限时隐藏口令
+func MaskDevicePassword(user *User, password string) string {
+	if password == "" || user.Can(PermDevicePasswordView) {
+		return password
+	}
+	return "******"
+}
@@ -0,0 +1,348 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"ops-web/internal/db"
+	"ops-web/internal/lifecycle"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	sessionTokenBytes    = 32          // 会话令牌长度（256位）
+	sessionTouchInterval = time.Minute // 最后活跃时间的最小更新间隔，避免每个请求都写库
+)
+
+// SessionStore 会话存储接口
+// 生产环境使用 MySQL 实现（重启后会话不丢失），测试时可使用内存实现
+// 存储中的会话ID均为令牌的哈希值，原始令牌只保存在浏览器 Cookie 中
+type SessionStore interface {
+	// Save 保存会话（会话ID已存在时覆盖）
+	Save(sessionID string, session *Session) error
+	// Get 获取会话，会话不存在时返回 nil, nil
+	Get(sessionID string) (*Session, error)
+	// Touch 更新会话最后活跃时间
+	Touch(sessionID string, lastSeenAt time.Time) error
+	// List 查询会话列表，userID 为 0 时返回全部用户的会话
+	List(userID int) ([]*Session, error)
+	// Delete 删除会话
+	Delete(sessionID string) error
+	// DeleteByUser 删除某个用户的全部会话，返回删除数量
+	DeleteByUser(userID int) (int64, error)
+	// DeleteExpired 清理已过期（超过最长登录时长，或最后活跃时间早于 idleSince）的会话，返回清理数量
+	DeleteExpired(now, idleSince time.Time) (int64, error)
+}
+
+// 默认使用内存存储，InitSessionStore 后切换为 MySQL 存储
+var sessionStore SessionStore = NewMemorySessionStore()
+
+// SetSessionStore 替换会话存储（测试或自定义存储时使用）
+func SetSessionStore(store SessionStore) {
+	sessionStore = store
+}
+
+// InitSessionStore 初始化 MySQL 会话存储并启动过期会话清理任务（在main.go中调用）
+func InitSessionStore() {
+	SetSessionStore(NewMySQLSessionStore(db.DBInstance))
+	StartSessionSweeper(10 * time.Minute)
+}
+
+// StartSessionSweeper 启动过期会话清理任务，服务停止时退出
+func StartSessionSweeper(interval time.Duration) {
+	lifecycle.Go("过期会话清理", func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			now := time.Now()
+			count, err := sessionStore.DeleteExpired(now, now.Add(-LoadSessionPolicy().IdleTimeout()))
+			if err != nil {
+				logger.Errorf("会话清理-删除过期会话失败: %v", err)
+				continue
+			}
+			if count > 0 {
+				logger.Infof("会话清理-已删除过期会话 %d 个", count)
+			}
+		}
+	})
+}
+
+// createSession 创建会话，返回写入 Cookie 的原始令牌
+func createSession(r *http.Request, userID int, username string, roleID int, roleCode int) (string, error) {
+	token, err := generateSessionToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	session := &Session{
+		UserID:     userID,
+		Username:   username,
+		RoleID:     roleID,
+		RoleCode:   roleCode,
+		IP:         operationlog.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpireAt:   now.Add(LoadSessionPolicy().Lifetime()),
+	}
+	if err := sessionStore.Save(hashSessionToken(token), session); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// getSession 根据 Cookie 中的令牌获取会话，查询失败时记录日志并视为未登录
+func getSession(token string) *Session {
+	session, err := sessionStore.Get(hashSessionToken(token))
+	if err != nil {
+		logger.Errorf("会话-查询会话失败: %v", err)
+		return nil
+	}
+	return session
+}
+
+// lookupSession 获取当前请求对应的有效会话，不更新最后活跃时间（不存在、空闲超时或超过最长登录时长时返回 nil）
+func lookupSession(r *http.Request) *Session {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	session := getSession(cookie.Value)
+	if session == nil {
+		return nil
+	}
+
+	if !time.Now().Before(LoadSessionPolicy().ExpiresAt(session)) {
+		return nil
+	}
+	return session
+}
+
+// sessionFromRequest 获取当前请求对应的有效会话，并把本次请求计为一次操作（空闲超时重新计时）
+func sessionFromRequest(r *http.Request) *Session {
+	session := lookupSession(r)
+	if session == nil {
+		return nil
+	}
+
+	// 更新最后活跃时间（按间隔节流）
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := sessionStore.Touch(session.ID, now); err != nil {
+			logger.Errorf("会话-更新最后活跃时间失败: %v", err)
+		}
+		session.LastSeenAt = now
+	}
+
+	return session
+}
+
+// destroySession 删除当前请求对应的会话
+func destroySession(r *http.Request) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return
+	}
+	if err := sessionStore.Delete(hashSessionToken(cookie.Value)); err != nil {
+		logger.Errorf("退出登录-删除会话失败: %v", err)
+	}
+}
+
+// CurrentSessionID 获取当前请求的会话ID（令牌哈希），未登录时返回空字符串
+func CurrentSessionID(r *http.Request) string {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	return hashSessionToken(cookie.Value)
+}
+
+// ListSessions 查询未过期的会话，userID 为 0 时返回全部用户的会话
+func ListSessions(userID int) ([]*Session, error) {
+	sessions, err := sessionStore.List(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	policy := LoadSessionPolicy()
+	var active []*Session
+	for _, session := range sessions {
+		if !now.Before(policy.ExpiresAt(session)) {
+			continue
+		}
+		active = append(active, session)
+	}
+	return active, nil
+}
+
+// GetSessionByID 按会话ID查询会话
+func GetSessionByID(sessionID string) (*Session, error) {
+	return sessionStore.Get(sessionID)
+}
+
+// RevokeSession 强制注销指定会话
+func RevokeSession(sessionID string) error {
+	return sessionStore.Delete(sessionID)
+}
+
+// RevokeUserSessions 强制注销某个用户的全部会话，返回注销数量
+func RevokeUserSessions(userID int) (int64, error) {
+	return sessionStore.DeleteByUser(userID)
+}
+
+// RevokeOtherSessions 注销某个用户除当前请求外的其他会话（如修改密码后），返回注销数量
+func RevokeOtherSessions(r *http.Request, userID int) (int64, error) {
+	sessions, err := sessionStore.List(userID)
+	if err != nil {
+		return 0, err
+	}
+	current := CurrentSessionID(r)
+	var count int64
+	for _, session := range sessions {
+		if session.ID == current {
+			continue
+		}
+		if err := sessionStore.Delete(session.ID); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// generateSessionToken 生成256位随机会话令牌
+func generateSessionToken() (string, error) {
+	buf := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成会话令牌失败: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashSessionToken 计算令牌的 SHA-256 哈希，数据库中只保存哈希值
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemorySessionStore 内存会话存储（用于测试，进程重启后会话丢失）
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// NewMemorySessionStore 创建内存会话存储
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Save 保存会话
+func (s *MemorySessionStore) Save(sessionID string, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *session
+	copied.ID = sessionID
+	s.sessions[sessionID] = &copied
+	return nil
+}
+
+// Get 获取会话
+func (s *MemorySessionStore) Get(sessionID string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+// Touch 更新会话最后活跃时间
+func (s *MemorySessionStore) Touch(sessionID string, lastSeenAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[sessionID]; ok {
+		session.LastSeenAt = lastSeenAt
+	}
+	return nil
+}
+
+// List 查询会话列表（按最后活跃时间倒序）
+func (s *MemorySessionStore) List(userID int) ([]*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Session
+	for _, session := range s.sessions {
+		if userID != 0 && session.UserID != userID {
+			continue
+		}
+		copied := *session
+		result = append(result, &copied)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeenAt.After(result[j].LastSeenAt)
+	})
+	return result, nil
+}
+
+// Delete 删除会话
+func (s *MemorySessionStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// DeleteByUser 删除某个用户的全部会话
+func (s *MemorySessionStore) DeleteByUser(userID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DeleteExpired 清理已过期的会话
+func (s *MemorySessionStore) DeleteExpired(now, idleSince time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for id, session := range s.sessions {
+		if now.After(session.ExpireAt) || session.LastSeenAt.Before(idleSince) {
+			delete(s.sessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
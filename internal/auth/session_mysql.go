@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MySQLSessionStore MySQL 会话存储（user_sessions 表）
+type MySQLSessionStore struct {
+	db *sql.DB
+}
+
+// NewMySQLSessionStore 创建 MySQL 会话存储
+func NewMySQLSessionStore(database *sql.DB) *MySQLSessionStore {
+	return &MySQLSessionStore{db: database}
+}
+
+const sessionColumns = "session_id, user_id, username, role_id, role_code, ip, user_agent, created_at, last_seen_at, expire_at"
+
+// scanSession 扫描一行会话数据
+func scanSession(scanner interface{ Scan(...interface{}) error }) (*Session, error) {
+	var session Session
+	var ip, userAgent sql.NullString
+	err := scanner.Scan(
+		&session.ID, &session.UserID, &session.Username, &session.RoleID, &session.RoleCode,
+		&ip, &userAgent, &session.CreatedAt, &session.LastSeenAt, &session.ExpireAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.IP = ip.String
+	session.UserAgent = userAgent.String
+	return &session, nil
+}
+
+// Save 保存会话
+func (s *MySQLSessionStore) Save(sessionID string, session *Session) error {
+	query := `
+		INSERT INTO user_sessions (session_id, user_id, username, role_id, role_code, ip, user_agent, created_at, last_seen_at, expire_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), username = VALUES(username),
+			role_id = VALUES(role_id), role_code = VALUES(role_code), ip = VALUES(ip), user_agent = VALUES(user_agent),
+			last_seen_at = VALUES(last_seen_at), expire_at = VALUES(expire_at)
+	`
+	_, err := s.db.Exec(query, sessionID, session.UserID, session.Username, session.RoleID, session.RoleCode,
+		session.IP, session.UserAgent, session.CreatedAt, session.LastSeenAt, session.ExpireAt)
+	return err
+}
+
+// Get 获取会话
+func (s *MySQLSessionStore) Get(sessionID string) (*Session, error) {
+	query := "SELECT " + sessionColumns + " FROM user_sessions WHERE session_id = ?"
+	session, err := scanSession(s.db.QueryRow(query, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Touch 更新会话最后活跃时间
+func (s *MySQLSessionStore) Touch(sessionID string, lastSeenAt time.Time) error {
+	_, err := s.db.Exec("UPDATE user_sessions SET last_seen_at = ? WHERE session_id = ?", lastSeenAt, sessionID)
+	return err
+}
+
+// List 查询会话列表（按最后活跃时间倒序）
+func (s *MySQLSessionStore) List(userID int) ([]*Session, error) {
+	query := "SELECT " + sessionColumns + " FROM user_sessions"
+	args := []interface{}{}
+	if userID != 0 {
+		query += " WHERE user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY last_seen_at DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Delete 删除会话
+func (s *MySQLSessionStore) Delete(sessionID string) error {
+	_, err := s.db.Exec("DELETE FROM user_sessions WHERE session_id = ?", sessionID)
+	return err
+}
+
+// DeleteByUser 删除某个用户的全部会话
+func (s *MySQLSessionStore) DeleteByUser(userID int) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM user_sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpired 清理已过期的会话
+func (s *MySQLSessionStore) DeleteExpired(now, idleSince time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM user_sessions WHERE expire_at < ? OR last_seen_at < ?", now, idleSince)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
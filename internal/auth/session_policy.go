@@ -0,0 +1,177 @@
+package auth
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"ops-web/internal/logger"
+	"strings"
+	"time"
+)
+
+// 会话超时默认值（可在 system_settings 中覆盖）
+const (
+	defaultSessionIdleMinutes   = 30 // 空闲超时（分钟）
+	defaultSessionLifetimeHours = 24 // 最长登录时长（小时）
+)
+
+// 会话超时参数范围
+const (
+	sessionIdleMinutesMin   = 5
+	sessionIdleMinutesMax   = 24 * 60
+	sessionLifetimeHoursMax = 30 * 24
+
+	// SessionWarningBefore 会话到期前多久在页面上提示
+	SessionWarningBefore = 2 * time.Minute
+)
+
+// SessionPolicy 会话超时策略
+type SessionPolicy struct {
+	IdleMinutes   int // 空闲超时：超过该时间没有操作即需重新登录，每次操作重新计时
+	LifetimeHours int // 最长登录时长：从登录起算，到期后无论是否有操作都需重新登录
+}
+
+// LoadSessionPolicy 从 system_settings 读取会话超时策略
+func LoadSessionPolicy() SessionPolicy {
+	return SessionPolicy{
+		IdleMinutes:   getSettingInt("session_idle_minutes", defaultSessionIdleMinutes),
+		LifetimeHours: getSettingInt("session_lifetime_hours", defaultSessionLifetimeHours),
+	}
+}
+
+// Check 校验策略参数取值范围
+func (p SessionPolicy) Check() error {
+	if p.IdleMinutes < sessionIdleMinutesMin || p.IdleMinutes > sessionIdleMinutesMax {
+		return fmt.Errorf("空闲超时须在 %d~%d 分钟之间", sessionIdleMinutesMin, sessionIdleMinutesMax)
+	}
+	if p.LifetimeHours < 1 || p.LifetimeHours > sessionLifetimeHoursMax {
+		return fmt.Errorf("最长登录时长须在 1~%d 小时之间", sessionLifetimeHoursMax)
+	}
+	if p.IdleMinutes > p.LifetimeHours*60 {
+		return fmt.Errorf("空闲超时不能超过最长登录时长")
+	}
+	return nil
+}
+
+// Description 策略说明（写入操作日志）
+func (p SessionPolicy) Description() string {
+	return fmt.Sprintf("空闲 %d 分钟自动退出，最长登录 %d 小时", p.IdleMinutes, p.LifetimeHours)
+}
+
+// IdleTimeout 空闲超时时长
+func (p SessionPolicy) IdleTimeout() time.Duration {
+	return time.Duration(p.IdleMinutes) * time.Minute
+}
+
+// Lifetime 最长登录时长
+func (p SessionPolicy) Lifetime() time.Duration {
+	return time.Duration(p.LifetimeHours) * time.Hour
+}
+
+// ExpiresAt 会话实际到期时间：空闲到期与最长时长到期中较早者
+func (p SessionPolicy) ExpiresAt(session *Session) time.Time {
+	idleAt := session.LastSeenAt.Add(p.IdleTimeout())
+	if idleAt.Before(session.ExpireAt) {
+		return idleAt
+	}
+	return session.ExpireAt
+}
+
+// sessionStatus 会话状态接口返回的数据
+type sessionStatus struct {
+	Authenticated bool   `json:"authenticated"`
+	Remaining     int    `json:"remaining"`     // 距到期的秒数
+	Warning       int    `json:"warning"`       // 剩余多少秒时提示
+	LifetimeEnds  bool   `json:"lifetime_ends"` // 是否因到达最长登录时长而到期（无法续期）
+	CSRFToken     string `json:"csrf_token"`    // 续期请求使用的 CSRF 令牌
+	LoginURL      string `json:"login_url"`     // 到期后跳转的登录地址
+}
+
+//go:embed session_timeout.js
+var sessionTimeoutScript []byte
+
+// SessionScriptHandler 会话到期提示脚本（由各页面引用）
+func SessionScriptHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Write(sessionTimeoutScript)
+}
+
+// SessionStatusHandler 查询当前会话剩余时间（GET，不算作用户操作，不会延长会话）
+func SessionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeSessionStatus(w, r, lookupSession(r))
+}
+
+// SessionRefreshHandler “保持登录”：刷新最后活跃时间，使空闲超时重新计时（POST）
+func SessionRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := lookupSession(r)
+	if session != nil {
+		now := time.Now()
+		if err := sessionStore.Touch(session.ID, now); err != nil {
+			logger.Errorf("会话-续期失败: %v, 用户名: %s", err, session.Username)
+			http.Error(w, "会话续期失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		session.LastSeenAt = now
+	}
+	writeSessionStatus(w, r, session)
+}
+
+// writeSessionStatus 输出会话状态 JSON
+func writeSessionStatus(w http.ResponseWriter, r *http.Request, session *Session) {
+	status := sessionStatus{
+		Warning:  int(SessionWarningBefore.Seconds()),
+		LoginURL: LoginURL(r.Referer()),
+	}
+	if session != nil {
+		policy := LoadSessionPolicy()
+		expiresAt := policy.ExpiresAt(session)
+		status.Authenticated = true
+		status.Remaining = int(time.Until(expiresAt).Seconds())
+		status.LifetimeEnds = !expiresAt.Before(session.ExpireAt)
+		status.CSRFToken = CSRFToken(r)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(status)
+}
+
+// LoginURL 登录页地址，next 为登录后返回的页面（只接受本站路径）
+func LoginURL(next string) string {
+	next = safeRedirectPath(next)
+	if next == "" || next == "/" {
+		return "/login"
+	}
+	return "/login?next=" + url.QueryEscape(next)
+}
+
+// safeRedirectPath 只保留本站内的路径（含查询参数），防止登录后跳转到外部网站
+// 传入完整 URL（如 Referer）时取其路径部分；无效时返回空字符串
+func safeRedirectPath(next string) string {
+	if next == "" {
+		return ""
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		return ""
+	}
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return ""
+	}
+	// 登录相关页面不作为返回地址
+	if path == "/login" || strings.HasPrefix(path, "/login/") || path == "/logout" {
+		return ""
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
@@ -0,0 +1,114 @@
+// 会话到期提示：到期前显示倒计时，可点击“保持登录”续期；到期后跳转登录页，登录后返回当前页面
+(function () {
+    var POLL_SECONDS = 60;
+
+    var pollTimer = null;
+    var countdownTimer = null;
+    var remaining = 0;
+    var status = null;
+    var banner = null;
+
+    function schedule(seconds) {
+        clearTimeout(pollTimer);
+        pollTimer = setTimeout(checkStatus, Math.max(seconds, 1) * 1000);
+    }
+
+    function checkStatus() {
+        fetch('/session/status', { credentials: 'same-origin', cache: 'no-store' })
+            .then(function (resp) { return resp.json(); })
+            .then(update)
+            .catch(function () { schedule(POLL_SECONDS); });
+    }
+
+    function update(data) {
+        status = data;
+        if (!data.authenticated || data.remaining <= 0) {
+            expire();
+            return;
+        }
+        remaining = data.remaining;
+        if (remaining <= data.warning) {
+            showBanner();
+            // 提示期间仍定期查询，其他页面续期后自动关闭提示
+            schedule(Math.min(POLL_SECONDS, remaining));
+        } else {
+            hideBanner();
+            schedule(Math.min(POLL_SECONDS, remaining - data.warning));
+        }
+    }
+
+    function expire() {
+        var loginURL = (status && status.login_url) || '/login';
+        window.location.href = loginURL + (loginURL.indexOf('?') >= 0 ? '&' : '?') + 'timeout=1';
+    }
+
+    function refresh() {
+        var button = document.getElementById('session-timeout-refresh');
+        button.disabled = true;
+        fetch('/session/refresh', {
+            method: 'POST',
+            credentials: 'same-origin',
+            cache: 'no-store',
+            headers: { 'X-CSRF-Token': status ? status.csrf_token : '' }
+        })
+            .then(function (resp) { return resp.json(); })
+            .then(update)
+            .catch(function () { alert('续期失败，请保存当前工作后重新登录'); })
+            .then(function () { button.disabled = false; });
+    }
+
+    function formatRemaining(seconds) {
+        var m = Math.floor(seconds / 60);
+        var s = seconds % 60;
+        return (m > 0 ? m + ' 分 ' : '') + s + ' 秒';
+    }
+
+    function render() {
+        var text = document.getElementById('session-timeout-text');
+        var button = document.getElementById('session-timeout-refresh');
+        if (status.lifetime_ends) {
+            text.textContent = '已达到最长登录时长，将在 ' + formatRemaining(remaining) + '后退出登录，请及时保存当前工作。';
+            button.style.display = 'none';
+        } else {
+            text.textContent = '由于长时间未操作，将在 ' + formatRemaining(remaining) + '后退出登录。';
+            button.style.display = '';
+        }
+    }
+
+    function showBanner() {
+        if (!banner) {
+            banner = document.createElement('div');
+            banner.id = 'session-timeout-banner';
+            banner.style.cssText = 'position:fixed;top:0;left:0;right:0;z-index:10000;padding:10px 20px;' +
+                'background:#fff3cd;color:#856404;border-bottom:1px solid #ffeeba;text-align:center;font-size:14px;';
+            banner.innerHTML = '<span id="session-timeout-text"></span> ' +
+                '<button type="button" id="session-timeout-refresh" style="margin-left:10px;padding:4px 12px;' +
+                'background:#3498db;color:#fff;border:none;border-radius:4px;cursor:pointer;">保持登录</button>';
+            document.body.appendChild(banner);
+            document.getElementById('session-timeout-refresh').addEventListener('click', refresh);
+        }
+        banner.style.display = '';
+        render();
+
+        clearInterval(countdownTimer);
+        countdownTimer = setInterval(function () {
+            remaining--;
+            if (remaining <= 0) {
+                clearInterval(countdownTimer);
+                // 到期前再确认一次，其他页面可能已续期
+                checkStatus();
+                return;
+            }
+            render();
+        }, 1000);
+    }
+
+    function hideBanner() {
+        clearInterval(countdownTimer);
+        if (banner) {
+            banner.style.display = 'none';
+        }
+    }
+
+    checkStatus();
+})();
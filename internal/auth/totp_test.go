@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 绑定时使用的验证码，按保存的步长登录时应视为重放
+func TestEnrollmentCodeCannotBeReplayed(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code := totpCode(key, uint64(now.Unix()/totpPeriod))
+
+	step, ok := VerifyTOTPCode(secret, code)
+	if !ok {
+		t.Fatal("绑定时的验证码校验失败")
+	}
+	if step != now.Unix()/totpPeriod {
+		t.Errorf("返回的时间步长 = %d，期望 %d", step, now.Unix()/totpPeriod)
+	}
+	if _, ok := verifyTOTP(secret, code, now, step); ok {
+		t.Error("绑定时的验证码可以再次用于登录")
+	}
+	if _, ok := verifyTOTP(secret, code, now, 0); !ok {
+		t.Error("未记录步长时同一验证码应当有效（确认测试本身有效）")
+	}
+}
@@ -0,0 +1,445 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"html/template"
+	"net/http"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	twoFactorCookieName    = "ops_2fa"
+	twoFactorPendingMaxAge = 5 * 60 // 第二步验证须在5分钟内完成
+	twoFactorMaxAttempts   = 5      // 第二步验证最多尝试次数
+	recoveryCodeCount      = 10
+)
+
+// TwoFactorInfo 用户双因素认证信息
+type TwoFactorInfo struct {
+	UserID       int
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64 // 最近一次使用的TOTP时间步长（防重放）
+}
+
+// pendingLogin 已通过密码验证、等待第二步验证（或修改过期密码）的登录
+type pendingLogin struct {
+	User     User
+	Action   string // 完成登录时写入操作日志的内容
+	Next     string // 完成登录后跳转的地址
+	Enroll   bool   // 是否需要先绑定（管理员被要求启用但尚未绑定）
+	Secret   string // 绑定时生成的新密钥
+	Attempts int
+	ExpireAt time.Time
+}
+
+var (
+	pendingLogins   = make(map[string]*pendingLogin)
+	pendingLoginsMu sync.Mutex
+)
+
+// TwoFactorPageData 第二步验证页面数据
+type TwoFactorPageData struct {
+	ErrorMsg      string
+	Username      string
+	Enroll        bool
+	Secret        string
+	QRCode        template.URL
+	RecoveryCodes []string // 绑定成功后一次性展示的恢复码
+	NextURL       string   // 保存恢复码后跳转的地址
+	CSRFToken     string
+}
+
+// TwoFactorHandler 登录第二步：校验动态验证码或恢复码（GET 显示页面，POST 提交验证码）
+func TwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	token, pending := getPendingLogin(r, twoFactorCookieName)
+	if pending == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderTwoFactorPage(w, r, pending, "")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 验证码错误同样计入失败次数，账号或IP已被锁定时不再校验验证码
+	user := pending.User
+	if locked, remaining := checkLoginLocked(user.Username, operationlog.ClientIP(r)); locked {
+		clearPendingLogin(w, twoFactorCookieName, token)
+		minutes := int(remaining.Minutes()) + 1
+		renderLoginPage(w, r, fmt.Sprintf("登录失败次数过多，账号已被临时锁定，请 %d 分钟后再试", minutes))
+		return
+	}
+
+	code := strings.TrimSpace(r.FormValue("code"))
+	if code == "" {
+		renderTwoFactorPage(w, r, pending, "请输入验证码")
+		return
+	}
+
+	var ok bool
+	var method string
+	var step int64
+	var err error
+	if pending.Enroll {
+		step, ok = VerifyTOTPCode(pending.Secret, code)
+		method = "绑定身份验证器"
+	} else {
+		ok, method, err = verifyUserTwoFactor(user.ID, code)
+		if err != nil {
+			logger.Errorf("双因素认证-校验失败: %v, 用户名: %s", err, user.Username)
+			http.Error(w, "双因素认证校验失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !ok {
+		time.Sleep(recordLoginFailure(r, user.Username, operationlog.ClientIP(r)))
+		if !incrementPendingAttempts(token) {
+			clearPendingLogin(w, twoFactorCookieName, token)
+			renderLoginPage(w, r, "验证码错误次数过多，请重新登录")
+			return
+		}
+		renderTwoFactorPage(w, r, pending, "验证码错误")
+		return
+	}
+
+	clearPendingLogin(w, twoFactorCookieName, token)
+
+	if pending.Enroll {
+		codes, err := EnableTwoFactor(user.ID, pending.Secret, step)
+		if err != nil {
+			logger.Errorf("双因素认证-启用失败: %v, 用户名: %s", err, user.Username)
+			http.Error(w, "启用双因素认证失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		next, ok := proceedLogin(w, r, &user, pending.Action+"（首次绑定双因素认证）", pending.Next)
+		if !ok {
+			return
+		}
+		// 恢复码只展示这一次，保存后再进入系统（或修改过期密码）
+		renderTwoFactorTemplate(w, TwoFactorPageData{Username: user.Username, RecoveryCodes: codes, NextURL: next})
+		return
+	}
+
+	if next, ok := proceedLogin(w, r, &user, pending.Action+"（双因素认证："+method+"）", pending.Next); ok {
+		http.Redirect(w, r, next, http.StatusFound)
+	}
+}
+
+// startTwoFactorChallenge 密码验证通过后，记录待验证登录并设置临时 Cookie
+func startTwoFactorChallenge(w http.ResponseWriter, user *User, action string, enroll bool, next string) error {
+	pending := &pendingLogin{
+		User:   *user,
+		Action: action,
+		Next:   next,
+		Enroll: enroll,
+	}
+	if enroll {
+		secret, err := GenerateTOTPSecret()
+		if err != nil {
+			return err
+		}
+		pending.Secret = secret
+	}
+	return savePendingLogin(w, twoFactorCookieName, pending)
+}
+
+// savePendingLogin 保存待完成的登录并设置对应的临时 Cookie
+func savePendingLogin(w http.ResponseWriter, cookieName string, pending *pendingLogin) error {
+	token, err := generateSessionToken()
+	if err != nil {
+		return err
+	}
+	pending.ExpireAt = time.Now().Add(twoFactorPendingMaxAge * time.Second)
+
+	pendingLoginsMu.Lock()
+	now := time.Now()
+	for key, p := range pendingLogins {
+		if now.After(p.ExpireAt) {
+			delete(pendingLogins, key)
+		}
+	}
+	pendingLogins[hashSessionToken(token)] = pending
+	pendingLoginsMu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    token,
+		Path:     "/login",
+		MaxAge:   twoFactorPendingMaxAge,
+		HttpOnly: true,
+		Secure:   secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// getPendingLogin 获取当前请求的待完成登录
+func getPendingLogin(r *http.Request, cookieName string) (string, *pendingLogin) {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || cookie.Value == "" {
+		return "", nil
+	}
+	key := hashSessionToken(cookie.Value)
+
+	pendingLoginsMu.Lock()
+	defer pendingLoginsMu.Unlock()
+
+	pending, ok := pendingLogins[key]
+	if !ok {
+		return "", nil
+	}
+	if time.Now().After(pending.ExpireAt) {
+		delete(pendingLogins, key)
+		return "", nil
+	}
+	copied := *pending
+	return key, &copied
+}
+
+// incrementPendingAttempts 增加第二步验证失败次数，超过上限返回 false
+func incrementPendingAttempts(key string) bool {
+	pendingLoginsMu.Lock()
+	defer pendingLoginsMu.Unlock()
+
+	pending, ok := pendingLogins[key]
+	if !ok {
+		return false
+	}
+	pending.Attempts++
+	return pending.Attempts < twoFactorMaxAttempts
+}
+
+// clearPendingLogin 删除待完成登录及临时 Cookie
+func clearPendingLogin(w http.ResponseWriter, cookieName, key string) {
+	pendingLoginsMu.Lock()
+	delete(pendingLogins, key)
+	pendingLoginsMu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    "",
+		Path:     "/login",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureCookies,
+	})
+}
+
+// GetTwoFactor 查询用户双因素认证信息，未绑定时返回 Enabled=false
+func GetTwoFactor(userID int) (*TwoFactorInfo, error) {
+	info := &TwoFactorInfo{UserID: userID}
+	var enabled int
+	err := db.DBInstance.QueryRow(
+		"SELECT secret, enabled, last_used_step FROM user_totp WHERE user_id = ?", userID,
+	).Scan(&info.Secret, &enabled, &info.LastUsedStep)
+	if err == sql.ErrNoRows {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Enabled = enabled == 1
+	return info, nil
+}
+
+// GetTwoFactorEnabledUsers 查询已启用双因素认证的用户ID集合
+func GetTwoFactorEnabledUsers() (map[int]bool, error) {
+	rows, err := db.DBInstance.Query("SELECT user_id FROM user_totp WHERE enabled = 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int]bool)
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		result[userID] = true
+	}
+	return result, rows.Err()
+}
+
+// EnableTwoFactor 启用双因素认证并生成新的恢复码（旧恢复码全部作废），返回恢复码明文
+// step 为绑定时验证码匹配的时间步长，保存为已使用的步长，绑定用的验证码不能再用于登录
+func EnableTwoFactor(userID int, secret string, step int64) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	upsertSQL := `
+		INSERT INTO user_totp (user_id, secret, enabled, last_used_step, enabled_at)
+		VALUES (?, ?, 1, ?, NOW())
+		ON DUPLICATE KEY UPDATE secret = VALUES(secret), enabled = 1, last_used_step = VALUES(last_used_step), enabled_at = NOW()
+	`
+	if _, err := tx.Exec(upsertSQL, userID, secret, step); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, code := range codes {
+		if _, err := tx.Exec("INSERT INTO user_recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID, hashRecoveryCode(code)); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetTwoFactor 清除用户的双因素认证绑定和恢复码
+func ResetTwoFactor(userID int) error {
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// VerifyUserTwoFactor 校验已登录用户的动态验证码（用于关闭双因素认证等敏感操作确认）
+func VerifyUserTwoFactor(userID int, code string) (bool, error) {
+	ok, _, err := verifyUserTwoFactor(userID, code)
+	return ok, err
+}
+
+// RequireAdminTwoFactor 是否要求所有管理员启用双因素认证
+func RequireAdminTwoFactor() bool {
+	return getSettingInt("require_admin_2fa", 0) == 1
+}
+
+// verifyUserTwoFactor 校验动态验证码或恢复码，返回验证方式
+func verifyUserTwoFactor(userID int, code string) (bool, string, error) {
+	info, err := GetTwoFactor(userID)
+	if err != nil {
+		return false, "", err
+	}
+	if !info.Enabled {
+		return false, "", nil
+	}
+
+	// 6位数字按动态验证码校验
+	if len(code) == totpDigits {
+		step, ok := verifyTOTP(info.Secret, code, time.Now(), info.LastUsedStep)
+		if !ok {
+			return false, "", nil
+		}
+		// 只有成功占用该时间步长的请求才算通过，防止同一验证码被并发重放
+		result, err := db.DBInstance.Exec(
+			"UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?", step, userID, step)
+		if err != nil {
+			return false, "", err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return false, "", err
+		}
+		return affected == 1, "动态验证码", nil
+	}
+
+	// 其余按恢复码校验，每个恢复码只能使用一次
+	result, err := db.DBInstance.Exec(
+		"UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		userID, hashRecoveryCode(code))
+	if err != nil {
+		return false, "", err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, "", err
+	}
+	return affected == 1, "恢复码", nil
+}
+
+// generateRecoveryCode 生成恢复码（格式 XXXX-XXXX）
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成恢复码失败: %v", err)
+	}
+	code := base32.StdEncoding.EncodeToString(buf)
+	return code[:4] + "-" + code[4:], nil
+}
+
+// hashRecoveryCode 计算恢复码哈希（忽略大小写、空格和连字符）
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashSessionToken(normalized)
+}
+
+// renderTwoFactorPage 渲染第二步验证页面
+func renderTwoFactorPage(w http.ResponseWriter, r *http.Request, pending *pendingLogin, errorMsg string) {
+	data := TwoFactorPageData{
+		ErrorMsg:  errorMsg,
+		Username:  pending.User.Username,
+		Enroll:    pending.Enroll,
+		CSRFToken: CSRFToken(r),
+	}
+	if pending.Enroll {
+		qr, err := TOTPQRCode(TOTPProvisioningURL(pending.User.Username, pending.Secret))
+		if err != nil {
+			logger.Errorf("双因素认证-%v", err)
+		}
+		data.Secret = pending.Secret
+		data.QRCode = qr
+	}
+	renderTwoFactorTemplate(w, data)
+}
+
+// renderTwoFactorTemplate 渲染双因素认证模板
+func renderTwoFactorTemplate(w http.ResponseWriter, data TwoFactorPageData) {
+	tmpl, err := template.ParseFiles("templates/login2fa.html")
+	if err != nil {
+		logger.Errorf("双因素认证-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		logger.Errorf("双因素认证-模板渲染失败: %v", err)
+		http.Error(w, "模板渲染失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
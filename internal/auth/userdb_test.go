@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"ops-web/internal/db"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试用的内存用户库：以 database/sql 驱动的形式实现目录用户同步（provisionExternalUser）
+// 及加载用户（loadUser）用到的查询，遇到未实现的查询时返回错误，修改这些查询后需同步更新这里
+
+type fakeRole struct {
+	ID   int
+	Code int
+	Name string
+}
+
+type fakeUser struct {
+	ID         int
+	Username   string
+	RoleID     int
+	AuthSource string
+}
+
+// fakeUserDB 内存中的 user_role、users、user_role_members、role_permissions 表
+type fakeUserDB struct {
+	mu      sync.Mutex
+	roles   []fakeRole
+	users   map[int]*fakeUser
+	members map[int][]int    // user_id -> role_id
+	perms   map[int][]string // role_id -> permission
+	nextID  int
+}
+
+var (
+	fakeUserDBOnce    sync.Once
+	fakeUserDBCurrent *fakeUserDB
+)
+
+// useFakeUserDB 以内存用户库替换 db.DBInstance，测试结束后恢复
+func useFakeUserDB(t *testing.T, roles ...fakeRole) *fakeUserDB {
+	t.Helper()
+	fakeUserDBOnce.Do(func() { sql.Register("authtest", fakeDriver{}) })
+
+	store := &fakeUserDB{roles: roles, users: map[int]*fakeUser{}, members: map[int][]int{}, perms: map[int][]string{}, nextID: 1}
+	fakeUserDBCurrent = store
+	conn, err := sql.Open("authtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.DBInstance
+	db.DBInstance = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db.DBInstance = previous
+	})
+	return store
+}
+
+// addUser 预置用户
+func (s *fakeUserDB) addUser(username, source string, roleID int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.users[id] = &fakeUser{ID: id, Username: username, RoleID: roleID, AuthSource: source}
+	s.members[id] = []int{roleID}
+	return id
+}
+
+// userByName 按用户名查找用户
+func (s *fakeUserDB) userByName(username string) *fakeUser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Username == username {
+			return u
+		}
+	}
+	return nil
+}
+
+// userCount 用户数
+func (s *fakeUserDB) userCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.users)
+}
+
+// memberRoles 用户在 user_role_members 中的角色ID（升序）
+func (s *fakeUserDB) memberRoles(userID int) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := append([]int(nil), s.members[userID]...)
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *fakeUserDB) role(id int) (fakeRole, bool) {
+	for _, r := range s.roles {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return fakeRole{}, false
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// query 执行查询，返回列名和数据行
+func (s *fakeUserDB) query(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(q, "SELECT id FROM user_role WHERE role_code = ?"):
+		var rows [][]driver.Value
+		for _, r := range s.roles {
+			if int64(r.Code) == args[0].(int64) {
+				rows = append(rows, []driver.Value{int64(r.ID)})
+			}
+		}
+		return []string{"id"}, rows, nil
+
+	case strings.HasPrefix(q, "SELECT id, auth_source FROM users WHERE username = ?"):
+		var rows [][]driver.Value
+		for _, u := range s.users {
+			if u.Username == args[0].(string) {
+				rows = append(rows, []driver.Value{int64(u.ID), u.AuthSource})
+			}
+		}
+		return []string{"id", "auth_source"}, rows, nil
+
+	case strings.HasPrefix(q, "SELECT (SELECT id FROM user_role WHERE id IN"):
+		// 参数为两份相同的角色ID列表
+		ids := args[:len(args)/2]
+		var primary *fakeRole
+		count := 0
+		for _, a := range ids {
+			if r, ok := s.role(int(a.(int64))); ok {
+				count++
+				if primary == nil || r.Code < primary.Code {
+					r := r
+					primary = &r
+				}
+			}
+		}
+		var primaryID driver.Value
+		if primary != nil {
+			primaryID = int64(primary.ID)
+		}
+		return []string{"id", "count"}, [][]driver.Value{{primaryID, int64(count)}}, nil
+
+	case strings.HasPrefix(q, "SELECT u.id, u.username, u.role_id, ur.role_code, ur.role_name, u.auth_source FROM users u"):
+		u, ok := s.users[int(args[0].(int64))]
+		if !ok {
+			return nil, nil, nil
+		}
+		r, _ := s.role(u.RoleID)
+		return []string{"id", "username", "role_id", "role_code", "role_name", "auth_source"},
+			[][]driver.Value{{int64(u.ID), u.Username, int64(u.RoleID), int64(r.Code), r.Name, u.AuthSource}}, nil
+
+	case strings.HasPrefix(q, "SELECT r.id, r.role_name, r.role_code FROM user_role_members m"):
+		var roles []fakeRole
+		for _, id := range s.members[int(args[0].(int64))] {
+			if r, ok := s.role(id); ok {
+				roles = append(roles, r)
+			}
+		}
+		sort.Slice(roles, func(i, j int) bool { return roles[i].Code < roles[j].Code })
+		var rows [][]driver.Value
+		for _, r := range roles {
+			rows = append(rows, []driver.Value{int64(r.ID), r.Name, int64(r.Code)})
+		}
+		return []string{"id", "role_name", "role_code"}, rows, nil
+
+	case strings.HasPrefix(q, "SELECT DISTINCT p.permission FROM role_permissions p"):
+		seen := map[string]bool{}
+		var rows [][]driver.Value
+		for _, id := range s.members[int(args[0].(int64))] {
+			for _, p := range s.perms[id] {
+				if !seen[p] {
+					seen[p] = true
+					rows = append(rows, []driver.Value{p})
+				}
+			}
+		}
+		return []string{"permission"}, rows, nil
+
+	case strings.HasPrefix(q, "SELECT o.name FROM user_organizations uo"):
+		return []string{"name"}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("测试用户库未实现的查询: %s", q)
+}
+
+// exec 执行写操作，返回新记录ID
+func (s *fakeUserDB) exec(q string, args []driver.Value) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(q, "INSERT INTO users (username, password, role_id, auth_source) VALUES (?, '', ?, ?)"):
+		id := s.nextID
+		s.nextID++
+		s.users[id] = &fakeUser{ID: id, Username: args[0].(string), RoleID: int(args[1].(int64)), AuthSource: args[2].(string)}
+		return int64(id), nil
+	case strings.HasPrefix(q, "DELETE FROM user_role_members WHERE user_id = ?"):
+		delete(s.members, int(args[0].(int64)))
+		return 0, nil
+	case strings.HasPrefix(q, "INSERT INTO user_role_members (user_id, role_id) VALUES (?, ?)"):
+		userID := int(args[0].(int64))
+		s.members[userID] = append(s.members[userID], int(args[1].(int64)))
+		return 0, nil
+	case strings.HasPrefix(q, "UPDATE users SET role_id = ? WHERE id = ?"):
+		if u, ok := s.users[int(args[1].(int64))]; ok {
+			u.RoleID = int(args[0].(int64))
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("测试用户库未实现的语句: %s", q)
+}
+
+// fakeDriver 把查询转给当前的内存用户库（事务不做隔离和回滚）
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("测试用户库不支持预处理语句")
+}
+func (fakeConn) Close() error              { return nil }
+func (fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (fakeConn) QueryContext(_ context.Context, q string, args []driver.NamedValue) (driver.Rows, error) {
+	columns, rows, err := fakeUserDBCurrent.query(strings.TrimSpace(spaces.ReplaceAllString(q, " ")), values(args))
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func (fakeConn) ExecContext(_ context.Context, q string, args []driver.NamedValue) (driver.Result, error) {
+	id, err := fakeUserDBCurrent.exec(strings.TrimSpace(spaces.ReplaceAllString(q, " ")), values(args))
+	if err != nil {
+		return nil, err
+	}
+	return fakeResult(id), nil
+}
+
+// fakeResult 写操作结果，值为新记录ID
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+func values(args []driver.NamedValue) []driver.Value {
+	out := make([]driver.Value, len(args))
+	for i, a := range args {
+		out[i] = a.Value
+	}
+	return out
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
@@ -171,6 +171,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	whereSQL := " WHERE 1=1"
 	args := []interface{}{}
 
+	// 只显示当前用户可见机构的设备（未关联档案的设备按管理单位判断）
+	currentUser := auth.CurrentUser(r)
+	orgSQL, orgArgs := auth.OrganizationFilter(currentUser, "COALESCE(ct.organization, cd.management_unit)")
+	whereSQL += orgSQL
+	args = append(args, orgArgs...)
+
 	if searchCode != "" {
 		whereSQL += " AND cd.checkpoint_code LIKE ?"
 		args = append(args, "%"+searchCode+"%")
@@ -294,7 +300,6 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	query := strings.Join(queryParams, "&")
 
 	// 记录查询操作日志（如果有查询条件）
-	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil && (searchCode != "" || searchName != "" || month != "" || auditStatus != "") {
 		action := "查询卡口建档明细"
 		if searchCode != "" {
@@ -323,7 +328,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		} else if searchCode != "" || searchName != "" || month != "" {
 			action += "）"
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionQuery,
+			EntityType: operationlog.EntityCheckpointDetail,
+			Message:    action,
+		})
 	}
 
 	// 准备数据并渲染模板
@@ -429,6 +438,12 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 	whereSQL := " WHERE 1=1"
 	args := []interface{}{}
 
+	// 只导出当前用户可见机构的设备
+	currentUser := auth.CurrentUser(r)
+	orgSQL, orgArgs := auth.OrganizationFilter(currentUser, "COALESCE((SELECT t.organization FROM checkpoint_tasks t WHERE t.id = checkpoint_details.task_id), checkpoint_details.management_unit)")
+	whereSQL += orgSQL
+	args = append(args, orgArgs...)
+
 	if searchCode != "" {
 		whereSQL += " AND checkpoint_code LIKE ?"
 		args = append(args, "%"+searchCode+"%")
@@ -566,11 +581,11 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 			item.AlarmReceivingPhone.String, item.InterceptionDepartment.String,
 			item.InterceptionDepartmentCode.String, item.InterceptionDepartmentContact.String,
 			item.TerminalCode.String, item.TerminalIPAddress.String, item.TerminalPort.String,
-			item.TerminalUsername.String, item.TerminalPassword.String, item.TerminalVendor.String,
+			item.TerminalUsername.String, auth.MaskDevicePassword(auth.GetCurrentUser(r), item.TerminalPassword.String), item.TerminalVendor.String,
 			item.CheckpointEnabledTime.String, item.CheckpointRevokedTime.String, item.Notes.String,
 			item.CheckpointDeviceType.String, item.TotalCaptureCameras.String, item.CentralControlCode.String,
 			item.CentralControlIPAddress.String, item.CentralControlPort.String,
-			item.CentralControlUsername.String, item.CentralControlPassword.String,
+			item.CentralControlUsername.String, auth.MaskDevicePassword(auth.GetCurrentUser(r), item.CentralControlPassword.String),
 			item.CentralControlVendor.String, item.CheckpointScrappedTime.String, item.TotalAntennas.String,
 			item.TerminalMACAddress.String, item.CollectionAreaType.String,
 			item.IntegratedCommandPlatformCheckpointCode.String,
@@ -589,7 +604,6 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 记录导出操作日志
-	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil {
 		action := "导出卡口建档明细 Excel"
 		if searchCode != "" || searchName != "" || month != "" || auditStatus != "" {
@@ -610,7 +624,11 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			action += strings.Join(conditions, "，") + "）"
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionExport,
+			EntityType: operationlog.EntityCheckpointDetail,
+			Message:    action,
+		})
 	}
 
 	// 输出文件
@@ -1,6 +1,7 @@
 package checkpointprogress
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,9 +11,10 @@ import (
 	"ops-web/internal/auth"
 	"ops-web/internal/checkpointfilelist"
 	"ops-web/internal/db"
+	"ops-web/internal/importcheck"
+	"ops-web/internal/lifecycle"
 	"ops-web/internal/logger"
 	"ops-web/internal/operationlog"
-	"ops-web/internal/permission"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -69,8 +71,14 @@ type PageData struct {
 	ImportMessage string
 	ImportCount   int
 	// 权限信息
-	CanImport bool // 是否可以导入
-	CanDelete bool // 是否可以删除
+	CanImport   bool // 是否可以导入
+	CanDelete   bool // 是否可以删除
+	CanEdit     bool // 是否可以编辑审核意见
+	CanSample   bool // 是否可以抽检
+	CanUpload   bool // 是否可以上传附件
+	CanDownload bool // 是否可以下载附件
+	Organizations []string // 导入时可选的机构
+	CSRFToken string
 }
 
 // Handler: 卡口审核进度列表页 (GET)
@@ -97,6 +105,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	whereSQL := " WHERE 1=1"
 	args := []interface{}{}
 
+	// 只显示当前用户可见机构的档案
+	currentUser := auth.CurrentUser(r)
+	orgSQL, orgArgs := auth.OrganizationFilter(currentUser, "organization")
+	whereSQL += orgSQL
+	args = append(args, orgArgs...)
+
 	if searchName != "" {
 		whereSQL += " AND file_name LIKE ?"
 		args = append(args, "%"+searchName+"%")
@@ -200,7 +214,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		)
 
 		if err != nil {
-			fmt.Printf("❌ Database scan error: %v\n", err)
+			logger.Errorf("卡口审核进度-数据扫描失败: %v", err)
 			continue
 		}
 
@@ -271,7 +285,6 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	query := strings.Join(queryParams, "&")
 
 	// 记录查询操作日志（如果有查询条件）
-	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil && (searchName != "" || auditStatus != "" || archiveType != "") {
 		action := "查询卡口审核进度"
 		hasCondition := false
@@ -298,7 +311,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		if hasCondition {
 			action += "）"
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionQuery,
+			EntityType: operationlog.EntityCheckpointTask,
+			Message:    action,
+		})
 	}
 
 	// 计算当前页记录范围
@@ -313,13 +330,14 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 4. 准备数据并渲染模板
-	// 检查权限（currentUser已在前面定义）
-	canImport := true
-	canDelete := true
-	if currentUser != nil && currentUser.RoleCode != 0 {
-		// 普通用户需要检查权限设置
-		canImport = permission.CheckPermission(currentUser, "allow_checkpoint_audit_import")
-		canDelete = permission.CheckPermission(currentUser, "allow_checkpoint_audit_delete")
+	// 检查权限（currentUser已在前面定义，控制页面上各操作按钮是否显示）
+	canImport := currentUser.Can(auth.PermCheckpointImport)
+	canDelete := currentUser.Can(auth.PermArchiveDelete)
+
+	// 导入表单的机构候选项
+	organizations, err := auth.VisibleOrganizations(currentUser)
+	if err != nil {
+		logger.Errorf("卡口审核进度-查询机构失败: %v", err)
 	}
 
 	data := PageData{
@@ -345,8 +363,14 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		Query:         query,
 		CanImport:     canImport,
 		CanDelete:     canDelete,
+		CanEdit:       currentUser.Can(auth.PermAuditEdit),
+		CanSample:     currentUser.Can(auth.PermAuditSample),
+		CanUpload:     currentUser.Can(auth.PermAttachmentUpload),
+		CanDownload:   currentUser.Can(auth.PermAttachmentDownload),
+		Organizations: organizations,
 		ImportMessage: importMsg,
 		ImportCount:   importCount,
+		CSRFToken:     auth.CSRFToken(r),
 	}
 
 	tmpl, err := template.ParseFiles("templates/checkpointprogress.html")
@@ -364,7 +388,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// ImportHandler: 导入 XLSX 档案
+// ImportHandler: 导入 XLSX 档案（先校验全部数据行，有错误时显示校验结果，不导入任何数据）
 func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Redirect(w, r, "/checkpoint/progress", http.StatusSeeOther)
@@ -372,20 +396,18 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查权限
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Error(w, "未登录", http.StatusUnauthorized)
 		return
 	}
 	
-	// 如果不是管理员，检查权限设置
-	if currentUser.RoleCode != 0 {
-		if !permission.CheckPermission(currentUser, "allow_checkpoint_audit_import") {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte(`{"error": "权限不足，请联系管理员开通卡口审核进度档案导入权限"}`))
-			return
-		}
+	// 检查权限
+	if !currentUser.Can(auth.PermCheckpointImport) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "权限不足，请联系管理员开通卡口审核进度档案导入权限"}`))
+		return
 	}
 
 	// 解析表单数据
@@ -402,6 +424,10 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "机构名称不能为空", http.StatusBadRequest)
 		return
 	}
+	if !currentUser.CanAccessOrganization(organization) {
+		http.Error(w, "无权导入机构“"+organization+"”的档案", http.StatusForbidden)
+		return
+	}
 
 	// 获取档案类型（必填字段）
 	archiveType := strings.TrimSpace(r.FormValue("archive_type"))
@@ -430,15 +456,110 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// 获取文件名（不含路径）
-	fileName := fileHeader.Filename
+	// 读取文件内容（校验未通过时暂存，供下载错误标注文件和仅导入有效行使用）
+	data, err := io.ReadAll(file)
+	if err != nil {
+		logger.Errorf("卡口审核进度-文件读取失败: %v, 文件名: %s", err, fileHeader.Filename)
+		http.Error(w, "文件读取失败", http.StatusBadRequest)
+		return
+	}
+
+	importArchive(w, r, currentUser, importRequest{
+		FileName:     fileHeader.Filename,
+		Data:         data,
+		Organization: organization,
+		ArchiveType:  archiveType,
+	}, "")
+}
+
+// ImportConfirmHandler: 仅导入校验通过的行（校验结果页面提交）
+func ImportConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/checkpoint/progress", http.StatusSeeOther)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "未登录", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.FormValue("token")
+	pending, ok := importcheck.Load(token, currentUser.Username)
+	if !ok {
+		http.Error(w, "导入文件已过期，请重新上传", http.StatusGone)
+		return
+	}
+
+	req := importRequest{
+		FileName:     pending.FileName,
+		Data:         pending.Data,
+		Organization: pending.Form["organization"],
+		ArchiveType:  pending.Form["archive_type"],
+	}
+	if !currentUser.CanAccessOrganization(req.Organization) {
+		http.Error(w, "无权导入机构“"+req.Organization+"”的档案", http.StatusForbidden)
+		return
+	}
+
+	importArchive(w, r, currentUser, req, token)
+}
+
+// ImportErrorFileHandler: 下载错误标注文件（上传文件的副本，出错的单元格标红并加批注）
+func ImportErrorFileHandler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Error(w, "未登录", http.StatusUnauthorized)
+		return
+	}
+
+	pending, ok := importcheck.Load(r.URL.Query().Get("token"), currentUser.Username)
+	if !ok {
+		http.Error(w, "导入文件已过期，请重新上传", http.StatusGone)
+		return
+	}
+
+	f, err := importcheck.ErrorWorkbook(pending.Data, pending.Problems)
+	if err != nil {
+		logger.Errorf("卡口审核进度-生成错误标注文件失败: %v, 文件名: %s", err, pending.FileName)
+		http.Error(w, "生成错误标注文件失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionDownload,
+		EntityType: operationlog.EntityCheckpointTask,
+		Message:    fmt.Sprintf("下载卡口审核档案导入错误标注文件（%s，%d 处错误）", pending.FileName, len(pending.Problems)),
+	})
+
+	fileName := strings.TrimSuffix(pending.FileName, filepath.Ext(pending.FileName))
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_错误标注.xlsx\"", fileName))
+	f.Write(w)
+}
+
+// importRequest 一次导入的文件和表单
+type importRequest struct {
+	FileName     string
+	Data         []byte
+	Organization string
+	ArchiveType  string
+}
+
+// importArchive 校验并导入档案。token 为空时有任何错误都不导入，显示校验结果；
+// token 非空表示用户在校验结果页面选择了仅导入有效行，跳过有错误的行
+func importArchive(w http.ResponseWriter, r *http.Request, currentUser *auth.User, req importRequest, token string) {
+	validOnly := token != ""
+
 	// 去除扩展名，只保留文件名部分作为档案名称
-	fileNameWithoutExt := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	fileNameWithoutExt := strings.TrimSuffix(req.FileName, filepath.Ext(req.FileName))
+	organization := req.Organization
 
 	// 解析Excel文件
-	f, err := excelize.OpenReader(file)
+	f, err := excelize.OpenReader(bytes.NewReader(req.Data))
 	if err != nil {
-		logger.Errorf("卡口审核进度-Excel解析失败: %v, 文件名: %s", err, fileHeader.Filename)
+		logger.Errorf("卡口审核进度-Excel解析失败: %v, 文件名: %s", err, req.FileName)
 		http.Error(w, "Excel解析失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -446,7 +567,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := f.GetRows(sheetName)
 	if err != nil {
-		logger.Errorf("卡口审核进度-数据读取失败: %v, 文件名: %s", err, fileHeader.Filename)
+		logger.Errorf("卡口审核进度-数据读取失败: %v, 文件名: %s", err, req.FileName)
 		http.Error(w, "数据读取失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -456,8 +577,65 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验全部数据行，有错误时不写入任何数据
+	result, err := validateImportRows(rows)
+	if err != nil {
+		logger.Errorf("卡口审核进度-导入校验失败: %v, 文件名: %s", err, req.FileName)
+		http.Error(w, "导入校验失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !result.OK() && !validOnly {
+		token, err := importcheck.Save(&importcheck.Pending{
+			Owner:    currentUser.Username,
+			FileName: req.FileName,
+			Data:     req.Data,
+			Form: map[string]string{
+				"organization": req.Organization,
+				"archive_type": req.ArchiveType,
+			},
+			Problems: result.Problems,
+		})
+		if err != nil {
+			logger.Errorf("卡口审核进度-暂存导入文件失败: %v, 文件名: %s", err, req.FileName)
+			http.Error(w, "暂存导入文件失败", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Warnf("卡口审核进度-导入校验未通过: %d 行中 %d 行有错误（共 %d 处）, 文件名: %s", result.TotalRows, result.InvalidRows(), len(result.Problems), req.FileName)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionImport,
+			EntityType: operationlog.EntityCheckpointTask,
+			Outcome:    operationlog.OutcomeFailure,
+			Message:    fmt.Sprintf("导入 Excel（%s）校验未通过：%d 行中 %d 行有错误，未保存任何数据", req.FileName, result.TotalRows, result.InvalidRows()),
+		})
+
+		page := importcheck.NewReportPage(result)
+		page.Title = "卡口档案导入校验结果"
+		page.ActiveMenu = "audit"
+		page.SubMenu = "checkpoint_progress"
+		page.FileName = req.FileName
+		page.Organization = organization
+		page.Token = token
+		page.ErrorFileURL = "/checkpoint/progress/import/errors?token=" + token
+		page.ConfirmURL = "/checkpoint/progress/import/confirm"
+		page.BackURL = "/checkpoint/progress"
+		page.CSRFToken = auth.CSRFToken(r)
+		page.API = auth.HasBearerToken(r)
+		importcheck.RenderReport(w, page)
+		return
+	}
+	if result.ValidRows() == 0 {
+		http.Error(w, "没有校验通过的数据行，未导入任何数据", http.StatusBadRequest)
+		return
+	}
+	skippedCount := result.InvalidRows()
+
+	// 服务停止时等待导入完成，超时后中断并回滚
+	ctx, done := lifecycle.Track("卡口审核进度导入")
+	defer done()
+
 	// 开始事务
-	tx, err := db.DBInstance.Begin()
+	tx, err := db.DBInstance.BeginTx(ctx, nil)
 	if err != nil {
 		http.Error(w, "事务开始失败", http.StatusInternalServerError)
 		return
@@ -465,7 +643,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 1. 创建审核任务记录
 	insertTaskSQL := `INSERT INTO checkpoint_tasks (file_name, organization, import_time, audit_status, archive_type) VALUES (?, ?, ?, ?, ?)`
-	result, err := tx.Exec(insertTaskSQL, fileNameWithoutExt, organization, time.Now(), "未审核", archiveType)
+	insertResult, err := tx.Exec(insertTaskSQL, fileNameWithoutExt, organization, time.Now(), "未审核", req.ArchiveType)
 	if err != nil {
 		tx.Rollback()
 		logger.Errorf("卡口审核进度-创建审核任务失败: %v, SQL: %s", err, insertTaskSQL)
@@ -474,7 +652,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取插入的任务ID
-	taskID, err := result.LastInsertId()
+	taskID, err := insertResult.LastInsertId()
 	if err != nil {
 		tx.Rollback()
 		logger.Errorf("卡口审核进度-获取任务ID失败: %v", err)
@@ -484,24 +662,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 2. 导入Excel数据到checkpoint_details表
 	// Excel列顺序：序号(跳过), 卡口编号, 原卡口编号, 卡口名称, 卡口地址, 道路名称, 方向类型, 方向描述, 方向备注, 行政区划, 路段类型, 道路代码, 公里数/路口号, 道路米数, 立杆编号, 卡口点位类型, 卡口位置类型, 卡口应用类型, 具备拦截条件, 具备车辆测速功能, 具备实时视频功能, 具备人脸抓拍功能, 具备违章抓拍功能, 具备前端二次识别功能, 是否边界卡口, 邻界地域, 卡口经度, 卡口纬度, 卡口实景照片地址, 卡口状态, 抓拍触发类型, 抓拍方向类型, 车道总数, 全景球机设备编码, 沿线下一卡口编号, 对向下一卡口编号, 左转下一卡口编号, 右转下一卡口编号, 掉头下一卡口编号, 建设单位, 管理单位, 卡口所属部门, 管理员姓名, 管理员联系电话, 卡口承建单位, 卡口维护单位, 接警部门, 接警部门代码, 接警电话, 拦截部门, 拦截部门代码, 拦截部门联系电话, 终端编码, 终端IP地址, 终端端口, 终端用户名, 终端密码, 终端厂商, 卡口启用时间, 卡口撤销时间, 备注, 卡口设备类型, 抓拍摄像机总数, 中控机编码, 中控机IP地址, 中控机端口, 中控机用户名, 中控机密码, 中控机厂商, 卡口报废时间, 天线总数, 终端MAC地址, 采集区域类型, 集成指挥平台卡口编号, 更新时间
-	insertDetailFields := []string{
-		"task_id", "checkpoint_code", "original_checkpoint_code", "checkpoint_name", "checkpoint_address", "road_name",
-		"direction_type", "direction_description", "direction_notes", "division_code", "road_section_type", "road_code",
-		"kilometer_or_intersection_number", "road_meter", "pole_number", "checkpoint_point_type", "checkpoint_location_type",
-		"checkpoint_application_type", "has_interception_condition", "has_speed_measurement", "has_realtime_video", "has_face_capture",
-		"has_violation_capture", "has_frontend_secondary_recognition", "is_boundary_checkpoint", "adjacent_area",
-		"checkpoint_longitude", "checkpoint_latitude", "checkpoint_scene_photo_url", "checkpoint_status", "capture_trigger_type",
-		"capture_direction_type", "total_lanes", "panoramic_camera_device_code", "next_checkpoint_along_road",
-		"next_checkpoint_opposite", "next_checkpoint_left_turn", "next_checkpoint_right_turn", "next_checkpoint_u_turn",
-		"construction_unit", "management_unit", "checkpoint_department", "admin_name", "admin_contact",
-		"checkpoint_contractor", "checkpoint_maintain_unit", "alarm_receiving_department", "alarm_receiving_department_code",
-		"alarm_receiving_phone", "interception_department", "interception_department_code", "interception_department_contact",
-		"terminal_code", "terminal_ip_address", "terminal_port", "terminal_username", "terminal_password", "terminal_vendor",
-		"checkpoint_enabled_time", "checkpoint_revoked_time", "notes", "checkpoint_device_type", "total_capture_cameras",
-		"central_control_code", "central_control_ip_address", "central_control_port", "central_control_username",
-		"central_control_password", "central_control_vendor", "checkpoint_scrapped_time", "total_antennas",
-		"terminal_mac_address", "collection_area_type", "integrated_command_platform_checkpoint_code",
-	}
+	insertDetailFields := append([]string{"task_id"}, importFields...)
 
 	insertDetailSQL := fmt.Sprintf("INSERT INTO checkpoint_details (%s) VALUES (%s)",
 		strings.Join(insertDetailFields, ", "),
@@ -517,17 +678,24 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	defer stmt.Close()
 
 	importedCount := 0
-	const expectedCols = 75 // Excel总共75列（包括序号列）
 
-	// 跳过表头，从第2行开始
+	// 跳过表头，从第2行开始；跳过空行和校验未通过的行（仅导入有效行时）
 	for i, row := range rows {
-		if i == 0 {
-			continue // 跳过表头
+		if i == 0 || importcheck.IsBlankRow(row) || result.Invalid(i+1) {
+			continue
 		}
 
-		// 强制对齐到75列
-		for len(row) < expectedCols {
-			row = append(row, "")
+		if ctx.Err() != nil {
+			tx.Rollback()
+			logger.Warnf("卡口审核进度-服务停止，导入已中断并回滚（已处理 %d 行）, 文件名: %s", importedCount, req.FileName)
+			operationlog.Record(r, currentUser.Username, operationlog.Entry{
+				Action:     operationlog.ActionImport,
+				EntityType: operationlog.EntityCheckpointTask,
+				Outcome:    operationlog.OutcomeFailure,
+				Message:    fmt.Sprintf("服务停止，导入 Excel（%s）已中断并回滚，未保存任何数据", req.FileName),
+			})
+			http.Error(w, "服务正在停止，导入已中断，数据未保存，请稍后重新导入", http.StatusServiceUnavailable)
+			return
 		}
 
 		// 准备参数（task_id + 74个字段，跳过序号列）
@@ -551,7 +719,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 			// 检查是否是唯一约束错误（checkpoint_code字段）
 			isUniqueErr, fieldName, fieldValue := checkUniqueConstraintError(execErr, "checkpoint_code")
 			if isUniqueErr {
-				logger.Errorf("卡口审核进度-导入失败，第%d行数据违反唯一约束: checkpoint_code=%s, 文件名: %s", i+1, fieldValue, fileHeader.Filename)
+				logger.Errorf("卡口审核进度-导入失败，第%d行数据违反唯一约束: checkpoint_code=%s, 文件名: %s", i+1, fieldValue, req.FileName)
 				
 				// 返回JSON格式的错误信息，前端可以弹窗显示
 				w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -567,7 +735,7 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			
-			logger.Errorf("卡口审核进度-导入失败，第%d行数据错误: %v, 文件名: %s", i+1, execErr, fileHeader.Filename)
+			logger.Errorf("卡口审核进度-导入失败，第%d行数据错误: %v, 文件名: %s", i+1, execErr, req.FileName)
 			errMsg := fmt.Sprintf("导入失败：第 %d 行数据错误。详细信息: %v", i+1, execErr)
 			http.Error(w, errMsg, http.StatusInternalServerError)
 			return
@@ -587,15 +755,33 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 提交事务
 	if err = tx.Commit(); err != nil {
-		logger.Errorf("卡口审核进度-数据库提交失败: %v, 文件名: %s", err, fileHeader.Filename)
+		logger.Errorf("卡口审核进度-数据库提交失败: %v, 文件名: %s", err, req.FileName)
 		http.Error(w, "数据库提交失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// 新机构加入机构字典，便于在用户管理中分配
+	if err := auth.EnsureOrganization(organization); err != nil {
+		logger.Errorf("卡口审核进度-写入机构字典失败: %v, 机构: %s", err, organization)
+	}
+
+	// 仅导入有效行时删除暂存的文件
+	if validOnly {
+		importcheck.Delete(token)
+	}
+
 	// 记录导入操作日志
-	if currentUser := auth.GetCurrentUser(r); currentUser != nil {
+	if currentUser := auth.CurrentUser(r); currentUser != nil {
 		action := fmt.Sprintf("导入卡口审核档案 Excel（档案名称：%s，机构：%s，共 %d 条数据）", fileNameWithoutExt, organization, importedCount)
-		operationlog.Record(r, currentUser.Username, action)
+		if skippedCount > 0 {
+			action += fmt.Sprintf("，跳过 %d 行校验未通过的数据", skippedCount)
+		}
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionImport,
+			EntityType: operationlog.EntityCheckpointTask,
+			EntityID:   strconv.FormatInt(taskID, 10),
+			Message:    action,
+		})
 	}
 
 	http.Redirect(w, r, "/checkpoint/progress?message=ImportSuccess&count="+strconv.Itoa(importedCount), http.StatusSeeOther)
@@ -638,6 +824,7 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 			ActiveMenu string
 			SubMenu    string
 			Task       CheckpointTask
+			CSRFToken  string
 		}
 
 		data := EditPageData{
@@ -645,6 +832,7 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 			ActiveMenu: "audit",
 			SubMenu:    "checkpoint_progress",
 			Task:       task,
+			CSRFToken:  auth.CSRFToken(r),
 		}
 
 		tmpl, err := template.ParseFiles("templates/checkpointedit.html")
@@ -697,8 +885,24 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// 修改前的审核意见和状态（写入操作日志）
+		var oldComment sql.NullString
+		var oldStatus string
+		err = tx.QueryRow("SELECT audit_comment, audit_status FROM checkpoint_tasks WHERE id = ?", taskID).Scan(&oldComment, &oldStatus)
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			http.Error(w, "档案不存在", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			logger.Errorf("查询卡口审核意见失败: %v, taskID: %d", err, taskID)
+			http.Error(w, "查询审核意见失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// 保存审核意见历史记录（如果内容有变化）
-		currentUser := auth.GetCurrentUser(r)
+		currentUser := auth.CurrentUser(r)
 		err = SaveAuditHistory(tx, taskID, auditComment, auditStatus, currentUser)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -759,7 +963,14 @@ func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
 		// 记录操作日志
 		if currentUser != nil {
 			action := fmt.Sprintf("编辑卡口审核意见（档案ID：%d，状态：%s）", taskID, auditStatus)
-			operationlog.Record(r, currentUser.Username, action)
+			operationlog.Record(r, currentUser.Username, operationlog.Entry{
+				Action:     operationlog.ActionEditComment,
+				EntityType: operationlog.EntityCheckpointTask,
+				EntityID:   operationlog.ID(taskID),
+				Message:    action,
+				Before:     map[string]string{"audit_comment": oldComment.String, "audit_status": oldStatus},
+				After:      map[string]string{"audit_comment": auditComment, "audit_status": auditStatus},
+			})
 		}
 
 		http.Redirect(w, r, "/checkpoint/progress?message=EditSuccess", http.StatusSeeOther)
@@ -1150,11 +1361,11 @@ func DetailExportHandler(w http.ResponseWriter, r *http.Request) {
 			item.AlarmReceivingPhone.String, item.InterceptionDepartment.String,
 			item.InterceptionDepartmentCode.String, item.InterceptionDepartmentContact.String,
 			item.TerminalCode.String, item.TerminalIPAddress.String, item.TerminalPort.String,
-			item.TerminalUsername.String, item.TerminalPassword.String, item.TerminalVendor.String,
+			item.TerminalUsername.String, auth.MaskDevicePassword(auth.CurrentUser(r), item.TerminalPassword.String), item.TerminalVendor.String,
 			item.CheckpointEnabledTime.String, item.CheckpointRevokedTime.String, item.Notes.String,
 			item.CheckpointDeviceType.String, item.TotalCaptureCameras.String, item.CentralControlCode.String,
 			item.CentralControlIPAddress.String, item.CentralControlPort.String,
-			item.CentralControlUsername.String, item.CentralControlPassword.String,
+			item.CentralControlUsername.String, auth.MaskDevicePassword(auth.CurrentUser(r), item.CentralControlPassword.String),
 			item.CentralControlVendor.String, item.CheckpointScrappedTime.String, item.TotalAntennas.String,
 			item.TerminalMACAddress.String, item.CollectionAreaType.String,
 			item.IntegratedCommandPlatformCheckpointCode.String,
@@ -1173,10 +1384,15 @@ func DetailExportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 记录导出操作日志
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser != nil {
 		action := fmt.Sprintf("导出卡口审核档案明细 Excel（档案名称：%s）", task.FileName)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionExport,
+			EntityType: operationlog.EntityCheckpointTask,
+			EntityID:   operationlog.ID(taskID),
+			Message:    action,
+		})
 	}
 
 	// 输出文件（使用档案名称作为文件名）
@@ -1220,10 +1436,14 @@ func DownloadTemplateHandler(w http.ResponseWriter, r *http.Request) {
 	f.SetSheetRow(sheetName, "A1", &TemplateHeaders)
 
 	// 记录操作日志
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser != nil {
 		action := "下载卡口审核档案导入模板"
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDownload,
+			EntityType: operationlog.EntityCheckpointTask,
+			Message:    action,
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
@@ -1240,20 +1460,18 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查权限
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Error(w, "未登录", http.StatusUnauthorized)
 		return
 	}
 	
-	// 如果不是管理员，检查权限设置
-	if currentUser.RoleCode != 0 {
-		if !permission.CheckPermission(currentUser, "allow_checkpoint_audit_delete") {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte(`{"error": "权限不足，请联系管理员开通卡口审核进度档案删除权限"}`))
-			return
-		}
+	// 检查权限
+	if !currentUser.Can(auth.PermArchiveDelete) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": "权限不足，请联系管理员开通卡口审核进度档案删除权限"}`))
+		return
 	}
 
 	// 获取要删除的任务ID
@@ -1348,11 +1566,14 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 记录删除操作日志
-	currentUser2 := auth.GetCurrentUser(r)
-	if currentUser2 != nil {
-		action := fmt.Sprintf("删除卡口审核档案（档案名称：%s，机构：%s，包含 %d 条明细）", task.FileName, task.Organization, detailCount)
-		operationlog.Record(r, currentUser2.Username, action)
-	}
+	action := fmt.Sprintf("删除卡口审核档案（档案名称：%s，机构：%s，包含 %d 条明细）", task.FileName, task.Organization, detailCount)
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionDelete,
+		EntityType: operationlog.EntityCheckpointTask,
+		EntityID:   operationlog.ID(taskID),
+		Message:    action,
+		Before:     map[string]interface{}{"file_name": task.FileName, "organization": task.Organization, "detail_count": detailCount},
+	})
 
 	// 重定向回列表页（保留查询参数）
 	searchName := r.FormValue("file_name")
@@ -1381,7 +1602,7 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
@@ -1582,7 +1803,17 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 			action += fmt.Sprintf("，失败：%d", len(failedFiles))
 		}
 	}
-	operationlog.Record(r, currentUser.Username, action)
+	outcome := operationlog.OutcomeSuccess
+	if len(failedFiles) > 0 {
+		outcome = operationlog.OutcomeFailure
+	}
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionUpload,
+		EntityType: operationlog.EntityCheckpointTask,
+		EntityID:   operationlog.ID(taskID),
+		Outcome:    outcome,
+		Message:    action,
+	})
 
 	// 返回响应
 	w.WriteHeader(http.StatusOK)
@@ -1718,10 +1949,15 @@ func DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// 记录操作日志
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser != nil {
 		action := fmt.Sprintf("下载附件（档案：%s，文件名：%s）", archiveFileName, fileName)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDownload,
+			EntityType: operationlog.EntityCheckpointTask,
+			EntityID:   operationlog.ID(taskID),
+			Message:    action,
+		})
 	}
 }
 
@@ -1790,7 +2026,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 获取当前用户
-		currentUser := auth.GetCurrentUser(r)
+		currentUser := auth.CurrentUser(r)
 		if currentUser == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
@@ -1802,6 +2038,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 			SubMenu    string
 			Task       CheckpointTask
 			SampledBy  string
+			CSRFToken  string
 		}
 
 		data := SamplePageData{
@@ -1810,6 +2047,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 			SubMenu:    "checkpoint_progress",
 			Task:       task,
 			SampledBy:  currentUser.Username,
+			CSRFToken:  auth.CSRFToken(r),
 		}
 
 		tmpl, err := template.ParseFiles("templates/checkpointsample.html")
@@ -1862,7 +2100,7 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// 获取当前用户
-		currentUser := auth.GetCurrentUser(r)
+		currentUser := auth.CurrentUser(r)
 		if currentUser == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
@@ -1891,7 +2129,13 @@ func SampleHandler(w http.ResponseWriter, r *http.Request) {
 
 		// 记录操作日志
 		action := fmt.Sprintf("抽检卡口审核档案（档案ID：%d，结果：%s）", taskID, sampleResult)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionSample,
+			EntityType: operationlog.EntityCheckpointTask,
+			EntityID:   operationlog.ID(taskID),
+			Message:    action,
+			After:      map[string]string{"sample_result": sampleResult, "sample_comment": sampleComment},
+		})
 
 		http.Redirect(w, r, "/checkpoint/progress?message=SampleSuccess", http.StatusSeeOther)
 		return
@@ -0,0 +1,146 @@
+package checkpointprogress
+
+import (
+	"fmt"
+	"ops-web/internal/importcheck"
+	"strings"
+)
+
+// importFields 导入 checkpoint_details 的字段，顺序与导入模板一致：
+// Excel 第1列为序号，第 j+2 列（row[j+1]）对应 importFields[j]
+var importFields = []string{
+	"checkpoint_code", "original_checkpoint_code", "checkpoint_name", "checkpoint_address", "road_name",
+	"direction_type", "direction_description", "direction_notes", "division_code", "road_section_type", "road_code",
+	"kilometer_or_intersection_number", "road_meter", "pole_number", "checkpoint_point_type", "checkpoint_location_type",
+	"checkpoint_application_type", "has_interception_condition", "has_speed_measurement", "has_realtime_video", "has_face_capture",
+	"has_violation_capture", "has_frontend_secondary_recognition", "is_boundary_checkpoint", "adjacent_area",
+	"checkpoint_longitude", "checkpoint_latitude", "checkpoint_scene_photo_url", "checkpoint_status", "capture_trigger_type",
+	"capture_direction_type", "total_lanes", "panoramic_camera_device_code", "next_checkpoint_along_road",
+	"next_checkpoint_opposite", "next_checkpoint_left_turn", "next_checkpoint_right_turn", "next_checkpoint_u_turn",
+	"construction_unit", "management_unit", "checkpoint_department", "admin_name", "admin_contact",
+	"checkpoint_contractor", "checkpoint_maintain_unit", "alarm_receiving_department", "alarm_receiving_department_code",
+	"alarm_receiving_phone", "interception_department", "interception_department_code", "interception_department_contact",
+	"terminal_code", "terminal_ip_address", "terminal_port", "terminal_username", "terminal_password", "terminal_vendor",
+	"checkpoint_enabled_time", "checkpoint_revoked_time", "notes", "checkpoint_device_type", "total_capture_cameras",
+	"central_control_code", "central_control_ip_address", "central_control_port", "central_control_username",
+	"central_control_password", "central_control_vendor", "checkpoint_scrapped_time", "total_antennas",
+	"terminal_mac_address", "collection_area_type", "integrated_command_platform_checkpoint_code",
+}
+
+// fieldMaxLen checkpoint_details 各文本字段的长度
+var fieldMaxLen = map[string]int{
+	"checkpoint_code": 18, "original_checkpoint_code": 18, "checkpoint_name": 255, "checkpoint_address": 255,
+	"road_name": 255, "direction_type": 50, "direction_description": 2, "direction_notes": 255, "division_code": 8,
+	"road_section_type": 2, "road_code": 8, "kilometer_or_intersection_number": 6, "road_meter": 6, "pole_number": 30,
+	"checkpoint_point_type": 2, "checkpoint_location_type": 2, "checkpoint_application_type": 2,
+	"has_interception_condition": 2, "has_speed_measurement": 2, "has_realtime_video": 2, "has_face_capture": 2,
+	"has_violation_capture": 2, "has_frontend_secondary_recognition": 2, "is_boundary_checkpoint": 2, "adjacent_area": 20,
+	"checkpoint_longitude": 15, "checkpoint_latitude": 15, "checkpoint_scene_photo_url": 30, "checkpoint_status": 2,
+	"capture_trigger_type": 2, "capture_direction_type": 2, "total_lanes": 2, "panoramic_camera_device_code": 20,
+	"next_checkpoint_along_road": 18, "next_checkpoint_opposite": 18, "next_checkpoint_left_turn": 18,
+	"next_checkpoint_right_turn": 18, "next_checkpoint_u_turn": 18, "construction_unit": 30, "management_unit": 30,
+	"checkpoint_department": 2, "admin_name": 8, "admin_contact": 15, "checkpoint_contractor": 30,
+	"checkpoint_maintain_unit": 50, "alarm_receiving_department": 15, "alarm_receiving_department_code": 15,
+	"alarm_receiving_phone": 15, "interception_department": 15, "interception_department_code": 15,
+	"interception_department_contact": 15, "terminal_code": 20, "terminal_ip_address": 20, "terminal_port": 5,
+	"terminal_username": 10, "terminal_password": 20, "terminal_vendor": 50, "checkpoint_enabled_time": 30,
+	"checkpoint_revoked_time": 30, "notes": 20, "checkpoint_device_type": 2, "total_capture_cameras": 2,
+	"central_control_code": 20, "central_control_ip_address": 50, "central_control_port": 5,
+	"central_control_username": 20, "central_control_password": 20, "central_control_vendor": 50,
+	"checkpoint_scrapped_time": 20, "total_antennas": 20, "terminal_mac_address": 30, "collection_area_type": 20,
+	"integrated_command_platform_checkpoint_code": 20,
+}
+
+// portFields 端口字段
+var portFields = map[string]bool{
+	"terminal_port":        true,
+	"central_control_port": true,
+}
+
+// countFields 数量字段
+var countFields = map[string]bool{
+	"total_lanes":           true,
+	"total_capture_cameras": true,
+	"total_antennas":        true,
+}
+
+// fieldLabel 字段在导入模板中的表头，如“卡口编号（*）”
+func fieldLabel(j int) string {
+	if label, ok := TemplateHeaders[j+1].(string); ok {
+		return label
+	}
+	return importFields[j]
+}
+
+// validateImportRows 校验全部数据行（必填、格式、范围、卡口编号重复），收集所有错误；
+// 空行不计入数据行，导入时同样跳过
+func validateImportRows(rows [][]string) (*importcheck.Result, error) {
+	result := importcheck.NewResult()
+	codes := importcheck.UniqueChecker{}
+	var codeList []string
+
+	for i, row := range rows {
+		if i == 0 || importcheck.IsBlankRow(row) {
+			continue
+		}
+		result.TotalRows++
+		rowNum := i + 1
+
+		for j, field := range importFields {
+			value := strings.TrimSpace(getRowValue(row, j+1))
+			if msg := checkImportField(field, fieldLabel(j), value); msg != "" {
+				result.Add(rowNum, j+1, fieldLabel(j), value, msg)
+			}
+		}
+
+		code := strings.TrimSpace(getRowValue(row, 1))
+		if code == "" {
+			continue
+		}
+		if first, dup := codes.Seen(code, rowNum); dup {
+			result.Add(rowNum, 1, fieldLabel(0), code, fmt.Sprintf("卡口编号与第 %d 行重复", first))
+			continue
+		}
+		codeList = append(codeList, code)
+	}
+
+	// 已导入的档案中存在的卡口编号
+	existing, err := importcheck.ExistingValues("checkpoint_details", "checkpoint_code", codeList)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codeList {
+		if existing[code] {
+			result.Add(codes[code], 1, fieldLabel(0), code, "卡口编号已存在，不能重复导入")
+		}
+	}
+
+	result.Sort()
+	return result, nil
+}
+
+// checkImportField 校验单个字段，返回错误说明，通过时返回空字符串；表头带（*）的字段为必填
+func checkImportField(field, label, value string) string {
+	if value == "" {
+		if strings.Contains(label, "（*）") {
+			return "必填项不能为空"
+		}
+		return ""
+	}
+
+	var msg string
+	switch {
+	case field == "checkpoint_longitude":
+		msg = importcheck.CheckFloatRange(value, -180, 180)
+	case field == "checkpoint_latitude":
+		msg = importcheck.CheckFloatRange(value, -90, 90)
+	case portFields[field]:
+		msg = importcheck.CheckInt(value, 1, 65535)
+	case countFields[field]:
+		msg = importcheck.CheckInt(value, 0, 9999)
+	}
+	if msg != "" {
+		return msg
+	}
+	return importcheck.CheckLength(value, fieldMaxLen[field])
+}
@@ -0,0 +1,391 @@
+package db
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"ops-web/internal/logger"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DefaultConfigPath 未指定 -config 参数和 OPSWEB_CONFIG 环境变量时使用的配置文件
+const DefaultConfigPath = "config/config.json"
+
+// ConfigPath 配置文件路径，由 main 按 -config 参数设置
+var ConfigPath = DefaultConfigPath
+
+// 连接池默认值
+const (
+	defaultMaxOpenConns           = 20
+	defaultMaxIdleConns           = 5
+	defaultConnMaxLifetimeSeconds = 300
+	defaultConnectTimeoutSeconds  = 10
+	defaultShutdownTimeoutSeconds = 30
+	defaultTLSReloadSeconds       = 30
+)
+
+// envOverride 可以用环境变量覆盖的配置项
+type envOverride struct {
+	name  string
+	apply func(cfg *Config, value string) error
+}
+
+// envOverrides 环境变量覆盖表：容器和 systemd 部署时不必把密码写入配置文件
+var envOverrides = []envOverride{
+	{"OPSWEB_DB_HOST", func(c *Config, v string) error { c.DBHost = v; return nil }},
+	{"OPSWEB_DB_PORT", func(c *Config, v string) error { c.DBPort = v; return nil }},
+	{"OPSWEB_DB_USER", func(c *Config, v string) error { c.DBUser = v; return nil }},
+	{"OPSWEB_DB_PASS", func(c *Config, v string) error { c.DBPass = v; return nil }},
+	{"OPSWEB_DB_NAME", func(c *Config, v string) error { c.DBName = v; return nil }},
+	{"OPSWEB_SERVER_HOST", func(c *Config, v string) error { c.ServerHost = v; return nil }},
+	{"OPSWEB_SERVER_PORT", func(c *Config, v string) error { c.ServerPort = v; return nil }},
+	{"OPSWEB_SHUTDOWN_TIMEOUT_SECONDS", func(c *Config, v string) error { return parseIntEnv(v, &c.ShutdownTimeoutSeconds) }},
+	{"OPSWEB_AUTO_MIGRATE", func(c *Config, v string) error { return parseBoolEnv(v, &c.AutoMigrate) }},
+	{"OPSWEB_TRUSTED_PROXIES", func(c *Config, v string) error { c.TrustedProxies = splitList(v); return nil }},
+	{"OPSWEB_OPERATION_LOG_KEY", func(c *Config, v string) error { c.OperationLogKey = v; return nil }},
+	{"OPSWEB_DB_MAX_OPEN_CONNS", func(c *Config, v string) error { return parseIntEnv(v, &c.DBPool.MaxOpenConns) }},
+	{"OPSWEB_DB_MAX_IDLE_CONNS", func(c *Config, v string) error { return parseIntEnv(v, &c.DBPool.MaxIdleConns) }},
+	{"OPSWEB_DB_CONN_MAX_LIFETIME_SECONDS", func(c *Config, v string) error { return parseIntEnv(v, &c.DBPool.ConnMaxLifetimeSeconds) }},
+	{"OPSWEB_DB_CONN_MAX_IDLE_TIME_SECONDS", func(c *Config, v string) error { return parseIntEnv(v, &c.DBPool.ConnMaxIdleTimeSeconds) }},
+	{"OPSWEB_DB_CONNECT_TIMEOUT_SECONDS", func(c *Config, v string) error { return parseIntEnv(v, &c.DBPool.ConnectTimeoutSeconds) }},
+	{"OPSWEB_DB_READ_TIMEOUT_SECONDS", func(c *Config, v string) error { return parseIntEnv(v, &c.DBPool.ReadTimeoutSeconds) }},
+	{"OPSWEB_DB_WRITE_TIMEOUT_SECONDS", func(c *Config, v string) error { return parseIntEnv(v, &c.DBPool.WriteTimeoutSeconds) }},
+	{"OPSWEB_TLS_ENABLED", func(c *Config, v string) error { return parseBoolEnv(v, &c.TLS.Enabled) }},
+	{"OPSWEB_TLS_CERT_FILE", func(c *Config, v string) error { c.TLS.CertFile = v; return nil }},
+	{"OPSWEB_TLS_KEY_FILE", func(c *Config, v string) error { c.TLS.KeyFile = v; return nil }},
+	{"OPSWEB_TLS_MIN_VERSION", func(c *Config, v string) error { c.TLS.MinVersion = v; return nil }},
+	{"OPSWEB_TLS_HTTP_REDIRECT_PORT", func(c *Config, v string) error { c.TLS.HTTPRedirectPort = v; return nil }},
+	{"OPSWEB_LOG_LEVEL", func(c *Config, v string) error { c.Log.Level = v; return nil }},
+	{"OPSWEB_LOG_FORMAT", func(c *Config, v string) error { c.Log.Format = v; return nil }},
+	{"OPSWEB_LOG_DIR", func(c *Config, v string) error { c.Log.Dir = v; return nil }},
+	{"OPSWEB_LDAP_ENABLED", func(c *Config, v string) error { return parseBoolEnv(v, &c.LDAP.Enabled) }},
+	{"OPSWEB_LDAP_URL", func(c *Config, v string) error { c.LDAP.URL = v; return nil }},
+	{"OPSWEB_LDAP_BIND_DN", func(c *Config, v string) error { c.LDAP.BindDN = v; return nil }},
+	{"OPSWEB_LDAP_BIND_PASSWORD", func(c *Config, v string) error { c.LDAP.BindPassword = v; return nil }},
+	{"OPSWEB_LDAP_BASE_DN", func(c *Config, v string) error { c.LDAP.BaseDN = v; return nil }},
+}
+
+func parseIntEnv(value string, target *int) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("不是整数: %s", value)
+	}
+	*target = n
+	return nil
+}
+
+// splitList 拆分逗号分隔的环境变量值，空字符串为空列表
+func splitList(value string) []string {
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func parseBoolEnv(value string, target *bool) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("不是布尔值（true/false）: %s", value)
+	}
+	*target = b
+	return nil
+}
+
+// LoadConfig 读取配置文件，依次应用环境变量覆盖、解析加密或文件引用的密码、填充默认值并校验，
+// 校验通过后将密码等敏感值登记到日志脱敏
+func LoadConfig(path string) (Config, error) {
+	cfg := Config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("读取配置文件 %s 失败: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+
+	if err := checkUnknownFields(data); err != nil {
+		logger.Warnf("配置文件 %s 中有无法识别的配置项: %v", path, err)
+	}
+
+	var problems []string
+	for _, o := range envOverrides {
+		value, ok := os.LookupEnv(o.name)
+		if !ok {
+			continue
+		}
+		if err := o.apply(&cfg, value); err != nil {
+			problems = append(problems, fmt.Sprintf("环境变量 %s %v", o.name, err))
+		}
+	}
+
+	keyDir := configDir(path)
+	for _, s := range []struct {
+		name  string
+		value *string
+	}{
+		{"db_pass", &cfg.DBPass},
+		{"ldap.bind_password", &cfg.LDAP.BindPassword},
+		{"operation_log_key", &cfg.OperationLogKey},
+	} {
+		plain, err := resolveSecret(*s.value, keyDir)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.name, err))
+			continue
+		}
+		*s.value = plain
+	}
+
+	applyDefaults(&cfg)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, fmt.Errorf("配置文件 %s 校验失败：%s", path, strings.Join(problems, "；"))
+	}
+
+	logger.RegisterSecret(cfg.DBPass, cfg.LDAP.BindPassword, cfg.OperationLogKey)
+	return cfg, nil
+}
+
+// checkUnknownFields 检查无法识别的配置项（拼写错误的配置项会被静默忽略，需要单独提示）
+func checkUnknownFields(data []byte) error {
+	strict := json.NewDecoder(bytes.NewReader(data))
+	strict.DisallowUnknownFields()
+	return strict.Decode(&Config{})
+}
+
+// applyDefaults 填充未设置的配置项
+func applyDefaults(cfg *Config) {
+	if cfg.DBPort == "" {
+		cfg.DBPort = "3306"
+	}
+	if cfg.ServerHost == "" {
+		cfg.ServerHost = "127.0.0.1"
+	}
+	if cfg.ServerPort == "" {
+		cfg.ServerPort = "8080"
+	}
+	if cfg.TLS.ReloadIntervalSeconds == 0 {
+		cfg.TLS.ReloadIntervalSeconds = defaultTLSReloadSeconds
+	}
+	if cfg.TrustedProxies == nil {
+		cfg.TrustedProxies = []string{"127.0.0.1", "::1"}
+	}
+	if cfg.ShutdownTimeoutSeconds == 0 {
+		cfg.ShutdownTimeoutSeconds = defaultShutdownTimeoutSeconds
+	}
+	pool := &cfg.DBPool
+	if pool.MaxOpenConns == 0 {
+		pool.MaxOpenConns = defaultMaxOpenConns
+	}
+	if pool.MaxIdleConns == 0 {
+		pool.MaxIdleConns = defaultMaxIdleConns
+		if pool.MaxIdleConns > pool.MaxOpenConns {
+			pool.MaxIdleConns = pool.MaxOpenConns
+		}
+	}
+	if pool.ConnMaxLifetimeSeconds == 0 {
+		pool.ConnMaxLifetimeSeconds = defaultConnMaxLifetimeSeconds
+	}
+	if pool.ConnectTimeoutSeconds == 0 {
+		pool.ConnectTimeoutSeconds = defaultConnectTimeoutSeconds
+	}
+}
+
+// validate 检查必填项和取值范围，返回全部问题（一次列出，避免逐个修改重启）
+func (c Config) validate() []string {
+	var problems []string
+	for _, f := range []struct{ name, value string }{
+		{"db_host", c.DBHost},
+		{"db_user", c.DBUser},
+		{"db_name", c.DBName},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			problems = append(problems, f.name+" 不能为空")
+		}
+	}
+	for _, f := range []struct{ name, value string }{
+		{"db_port", c.DBPort},
+		{"server_port", c.ServerPort},
+	} {
+		if port, err := strconv.Atoi(f.value); err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("%s 不是有效端口: %s", f.name, f.value))
+		}
+	}
+
+	for _, p := range c.TrustedProxies {
+		p = strings.TrimSpace(p)
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				problems = append(problems, fmt.Sprintf("trusted_proxies 不是有效的 IP 或网段: %s", p))
+			}
+		}
+	}
+
+	if c.ShutdownTimeoutSeconds < 0 {
+		problems = append(problems, fmt.Sprintf("shutdown_timeout_seconds 不能为负数: %d", c.ShutdownTimeoutSeconds))
+	}
+
+	pool := c.DBPool
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"db_pool.max_open_conns", pool.MaxOpenConns},
+		{"db_pool.max_idle_conns", pool.MaxIdleConns},
+		{"db_pool.conn_max_lifetime_seconds", pool.ConnMaxLifetimeSeconds},
+		{"db_pool.conn_max_idle_time_seconds", pool.ConnMaxIdleTimeSeconds},
+		{"db_pool.connect_timeout_seconds", pool.ConnectTimeoutSeconds},
+		{"db_pool.read_timeout_seconds", pool.ReadTimeoutSeconds},
+		{"db_pool.write_timeout_seconds", pool.WriteTimeoutSeconds},
+	} {
+		if f.value < 0 {
+			problems = append(problems, fmt.Sprintf("%s 不能为负数: %d", f.name, f.value))
+		}
+	}
+	if pool.MaxIdleConns > pool.MaxOpenConns {
+		problems = append(problems, fmt.Sprintf("db_pool.max_idle_conns（%d）不能大于 max_open_conns（%d）", pool.MaxIdleConns, pool.MaxOpenConns))
+	}
+
+	if _, err := logger.ParseLevel(c.Log.Level); err != nil {
+		problems = append(problems, "log.level: "+err.Error())
+	}
+	if format := strings.ToLower(strings.TrimSpace(c.Log.Format)); format != "" && format != "text" && format != "json" {
+		problems = append(problems, fmt.Sprintf("log.format 只能为 text 或 json: %s", c.Log.Format))
+	}
+
+	if c.TLS.Enabled {
+		if strings.TrimSpace(c.TLS.CertFile) == "" || strings.TrimSpace(c.TLS.KeyFile) == "" {
+			problems = append(problems, "启用 HTTPS 时 tls.cert_file 和 tls.key_file 不能为空")
+		}
+		if _, err := c.TLS.TLSMinVersion(); err != nil {
+			problems = append(problems, "tls.min_version: "+err.Error())
+		}
+		if c.TLS.HTTPRedirectPort != "" {
+			if port, err := strconv.Atoi(c.TLS.HTTPRedirectPort); err != nil || port < 1 || port > 65535 {
+				problems = append(problems, fmt.Sprintf("tls.http_redirect_port 不是有效端口: %s", c.TLS.HTTPRedirectPort))
+			} else if c.TLS.HTTPRedirectPort == c.ServerPort {
+				problems = append(problems, "tls.http_redirect_port 不能与 server_port 相同")
+			}
+		}
+		if c.TLS.ReloadIntervalSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("tls.reload_interval_seconds 不能为负数: %d", c.TLS.ReloadIntervalSeconds))
+		}
+	}
+
+	if c.LDAP.Enabled {
+		if u, err := url.Parse(c.LDAP.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("ldap.url 应为 ldap://主机:端口 或 ldaps://主机:端口: %s", c.LDAP.URL))
+		}
+		if strings.TrimSpace(c.LDAP.BaseDN) == "" {
+			problems = append(problems, "启用 LDAP 时 ldap.base_dn 不能为空")
+		}
+	}
+	return problems
+}
+
+// TLSMinVersion 解析最低 TLS 版本，未设置时为 TLS 1.2
+func (c TLSConfig) TLSMinVersion() (uint16, error) {
+	switch strings.TrimSpace(c.MinVersion) {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("无效的 TLS 版本: %s（可选 1.0、1.1、1.2、1.3）", c.MinVersion)
+}
+
+// mysqlConfig 按配置生成 MySQL 驱动连接参数
+func (c Config) mysqlConfig() *mysql.Config {
+	mc := mysql.NewConfig()
+	mc.User = c.DBUser
+	mc.Passwd = c.DBPass
+	mc.Net = "tcp"
+	mc.Addr = c.DBHost + ":" + c.DBPort
+	mc.DBName = c.DBName
+	mc.Params = map[string]string{"charset": "utf8mb4"}
+	mc.ParseTime = true
+	mc.Loc = time.Local
+	mc.Timeout = time.Duration(c.DBPool.ConnectTimeoutSeconds) * time.Second
+	mc.ReadTimeout = time.Duration(c.DBPool.ReadTimeoutSeconds) * time.Second
+	mc.WriteTimeout = time.Duration(c.DBPool.WriteTimeoutSeconds) * time.Second
+	return mc
+}
+
+// DSN 数据库连接串（含密码，不要写入日志，记录日志使用 RedactedDSN）
+func (c Config) DSN() string {
+	return c.mysqlConfig().FormatDSN()
+}
+
+// RedactedDSN 密码替换为 ****** 的连接串，用于日志和诊断输出
+func (c Config) RedactedDSN() string {
+	mc := c.mysqlConfig()
+	if mc.Passwd != "" {
+		mc.Passwd = redactedValue
+	}
+	return mc.FormatDSN()
+}
+
+// redactedValue 脱敏后显示的内容
+const redactedValue = "******"
+
+// Redacted 返回敏感项（数据库密码、LDAP 服务账号密码、操作日志密钥）替换为 ****** 的副本
+func (c Config) Redacted() Config {
+	if c.DBPass != "" {
+		c.DBPass = redactedValue
+	}
+	if c.OperationLogKey != "" {
+		c.OperationLogKey = redactedValue
+	}
+	c.LDAP = c.LDAP.Redacted()
+	return c
+}
+
+// String 以 JSON 输出脱敏后的配置，以 %v、%+v 打印配置时不会泄露密码
+func (c Config) String() string {
+	data, err := json.MarshalIndent(c.Redacted(), "", "  ")
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// GoString 以 %#v 打印时同样输出脱敏后的配置
+func (c Config) GoString() string {
+	return c.String()
+}
+
+// Redacted 返回服务账号密码替换为 ****** 的副本
+func (c LDAPConfig) Redacted() LDAPConfig {
+	if c.BindPassword != "" {
+		c.BindPassword = redactedValue
+	}
+	return c
+}
+
+// String 以 JSON 输出脱敏后的 LDAP 配置
+func (c LDAPConfig) String() string {
+	data, err := json.Marshal(c.Redacted())
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// GoString 以 %#v 打印时同样输出脱敏后的配置
+func (c LDAPConfig) GoString() string {
+	return c.String()
+}
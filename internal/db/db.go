@@ -2,10 +2,7 @@ package db
 
 import (
 	"database/sql"
-	"encoding/json"
-	"fmt"
 	"ops-web/internal/logger"
-	"os"
 	"sync"
 	"time"
 
@@ -18,65 +15,111 @@ var (
 )
 
 type Config struct {
-	DBHost     string `json:"db_host"`
-	DBPort     string `json:"db_port"`
-	DBUser     string `json:"db_user"`
-	DBPass     string `json:"db_pass"`
-	DBName     string `json:"db_name"`
-	ServerHost string `json:"server_host"`
-	ServerPort string `json:"server_port"`
+	DBHost     string     `json:"db_host"`
+	DBPort     string     `json:"db_port"`
+	DBUser     string     `json:"db_user"`
+	DBPass     string     `json:"db_pass"` // 可写明文、enc:密文（ops-web config encrypt 生成）或 file:密码文件路径
+	DBName     string     `json:"db_name"`
+	ServerHost string     `json:"server_host"`
+	ServerPort string     `json:"server_port"`
+	LDAP       LDAPConfig `json:"ldap"`
+
+	// DBPool 数据库连接池和超时设置
+	DBPool PoolConfig `json:"db_pool"`
+
+	// TLS 内置 HTTPS 设置，启用后 server_port 为 HTTPS 端口
+	TLS TLSConfig `json:"tls"`
+
+	// ShutdownTimeoutSeconds 停止服务时等待进行中的请求和后台任务（备份、导入）完成的最长时间，默认30秒
+	ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds"`
+
+	// AutoMigrate 启动时自动执行待执行的数据库迁移；为 false 时有待执行的迁移则拒绝启动
+	AutoMigrate bool `json:"auto_migrate"`
+
+	// Log 日志级别、格式、切换和保留设置
+	Log logger.Config `json:"log"`
+
+	// TrustedProxies 可信反向代理的 IP 或网段（如 "127.0.0.1"、"10.0.0.0/24"），只有来自这些地址的请求
+	// 才采用 X-Forwarded-For 中的客户端地址（登录锁定、操作日志、API令牌IP限制均使用该地址）；
+	// 未设置时只信任本机（127.0.0.1、::1），设为 [] 则完全不采用 X-Forwarded-For
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// OperationLogKey 操作日志哈希链密钥，为空时使用配置文件所在目录的 operation_log.key（首次启动自动生成）
+	OperationLogKey string `json:"operation_log_key"`
+}
+
+// PoolConfig 数据库连接池和超时配置，未设置（为0）的项使用默认值
+type PoolConfig struct {
+	MaxOpenConns           int `json:"max_open_conns"`             // 最大连接数，默认20
+	MaxIdleConns           int `json:"max_idle_conns"`             // 最大空闲连接数，默认5，不能超过最大连接数
+	ConnMaxLifetimeSeconds int `json:"conn_max_lifetime_seconds"`  // 连接最长使用时间，默认300秒
+	ConnMaxIdleTimeSeconds int `json:"conn_max_idle_time_seconds"` // 空闲连接保留时间，默认不限制
+	ConnectTimeoutSeconds  int `json:"connect_timeout_seconds"`    // 建立连接超时，默认10秒
+	ReadTimeoutSeconds     int `json:"read_timeout_seconds"`       // 读超时，默认不限制（导出、归档等查询耗时较长）
+	WriteTimeoutSeconds    int `json:"write_timeout_seconds"`      // 写超时，默认不限制
+}
+
+// TLSConfig 内置 HTTPS 配置（前面没有 nginx 等反向代理时使用）
+type TLSConfig struct {
+	Enabled               bool   `json:"enabled"`
+	CertFile              string `json:"cert_file"`               // 证书文件（PEM，含中间证书）
+	KeyFile               string `json:"key_file"`                // 私钥文件（PEM）
+	MinVersion            string `json:"min_version"`             // 最低 TLS 版本：1.0、1.1、1.2（默认）、1.3
+	HTTPRedirectPort      string `json:"http_redirect_port"`      // 非空时在该端口监听 HTTP，将请求重定向到 HTTPS
+	ReloadIntervalSeconds int    `json:"reload_interval_seconds"` // 检查证书文件是否更新的间隔，默认30秒
+}
+
+// LDAPConfig LDAP/Active Directory 认证配置
+type LDAPConfig struct {
+	Enabled            bool           `json:"enabled"`
+	URL                string         `json:"url"`                  // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool           `json:"start_tls"`            // ldap:// 连接后升级为 TLS
+	InsecureSkipVerify bool           `json:"insecure_skip_verify"` // 跳过证书校验（仅测试环境使用）
+	TimeoutSeconds     int            `json:"timeout_seconds"`      // 连接超时，默认5秒
+	BindDN             string         `json:"bind_dn"`              // 查询用户使用的服务账号，为空时匿名查询
+	BindPassword       string         `json:"bind_password"`        // 同 db_pass，支持 enc: 和 file:
+	BaseDN             string         `json:"base_dn"`              // 用户查询起点
+	UserFilter         string         `json:"user_filter"`          // 用户查询条件，%s 替换为用户名，默认 (uid=%s)，AD 使用 (sAMAccountName=%s)
+	GroupAttribute     string         `json:"group_attribute"`      // 用户条目中记录所属组的属性，默认 memberOf
+	GroupBaseDN        string         `json:"group_base_dn"`        // 设置后改为按组查询，适用于没有 memberOf 的目录
+	GroupFilter        string         `json:"group_filter"`         // 组查询条件，%s 替换为用户DN，默认 (member=%s)
+	GroupRoleMap       map[string]int `json:"group_role_map"`       // 组DN -> 角色代码（user_role.role_code，0=管理员），属于多个组时拥有全部对应角色
+	DefaultRoleCode    *int           `json:"default_role_code"`    // 不属于任何映射组时的角色代码，为空则拒绝登录
+	DisableLocalLogin  bool           `json:"disable_local_login"`  // 禁止本地账号（users表密码）登录
 }
 
 var AppConfig Config
 
+// InitDB 读取配置文件（ConfigPath，可被 OPSWEB_* 环境变量覆盖）并连接数据库
 func InitDB() error {
 	var err error
 	once.Do(func() {
-		// 读取配置文件
-		file, e := os.Open("config/config.json")
+		cfg, e := LoadConfig(ConfigPath)
 		if e != nil {
-			logger.Errorf("数据库初始化-读取配置文件失败: %v", e)
-			err = e
-			return
-		}
-		defer file.Close()
-
-		decoder := json.NewDecoder(file)
-		cfg := Config{}
-		if e := decoder.Decode(&cfg); e != nil {
-			logger.Errorf("数据库初始化-解析配置文件失败: %v", e)
+			logger.Errorf("数据库初始化-加载配置失败: %v", e)
 			err = e
 			return
 		}
 
-		// 设置默认值
-		if cfg.ServerHost == "" {
-			cfg.ServerHost = "127.0.0.1"
-		}
-		if cfg.ServerPort == "" {
-			cfg.ServerPort = "8080"
-		}
-
 		// 保存到全局变量
 		AppConfig = cfg
 
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.DBUser, cfg.DBPass, cfg.DBHost, cfg.DBPort, cfg.DBName)
-
-		DBInstance, err = sql.Open("mysql", dsn)
+		DBInstance, err = sql.Open("mysql", cfg.DSN())
 		if err != nil {
-			logger.Errorf("数据库初始化-打开数据库连接失败: %v, DSN: %s", err, dsn)
+			logger.Errorf("数据库初始化-打开数据库连接失败: %v, DSN: %s", err, cfg.RedactedDSN())
 			return
 		}
 
-		DBInstance.SetMaxOpenConns(20)
-		DBInstance.SetMaxIdleConns(5)
-		DBInstance.SetConnMaxLifetime(time.Minute * 5)
+		pool := cfg.DBPool
+		DBInstance.SetMaxOpenConns(pool.MaxOpenConns)
+		DBInstance.SetMaxIdleConns(pool.MaxIdleConns)
+		DBInstance.SetConnMaxLifetime(time.Duration(pool.ConnMaxLifetimeSeconds) * time.Second)
+		DBInstance.SetConnMaxIdleTime(time.Duration(pool.ConnMaxIdleTimeSeconds) * time.Second)
 
 		err = DBInstance.Ping()
 		if err != nil {
-			logger.Errorf("数据库初始化-连接测试失败: %v, DSN: %s", err, dsn)
+			logger.Errorf("数据库初始化-连接测试失败: %v, DSN: %s", err, cfg.RedactedDSN())
 		}
 	})
 	return err
-}
\ No newline at end of file
+}
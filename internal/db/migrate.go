@@ -0,0 +1,403 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"ops-web/internal/logger"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 数据库迁移：表结构变更以编号的 SQL 文件保存在 migrations 目录并编译进程序，
+// 执行记录保存在 schema_migrations 表。文件名格式为 NNNN_说明.sql，按编号顺序执行，
+// 已执行的迁移文件不得再修改（校验和不一致时拒绝继续执行），需要调整时新增一个迁移。
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockName 多个实例同时启动时只允许一个执行迁移
+const migrationLockName = "ops_web_schema_migrations"
+
+// Migration 一个迁移文件
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string // 文件内容的 SHA-256（忽略换行符差异）
+}
+
+// MigrationStatus 迁移执行状态
+type MigrationStatus struct {
+	Migration
+	Applied         bool
+	Baseline        bool   // 通过 baseline 标记为已执行（未实际执行 SQL）
+	AppliedAt       string // 执行时间
+	AppliedChecksum string // 执行时记录的校验和
+}
+
+// ChecksumMismatch 已执行的迁移文件是否被修改过
+func (s MigrationStatus) ChecksumMismatch() bool {
+	return s.Applied && !s.Baseline && s.AppliedChecksum != s.Checksum
+}
+
+// LoadMigrations 读取程序内置的全部迁移（按版本号排序）
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base := strings.TrimSuffix(fileName, ".sql")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if len(parts) != 2 || err != nil || version <= 0 {
+			return nil, fmt.Errorf("迁移文件名格式错误（应为 NNNN_说明.sql）: %s", fileName)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("迁移版本号重复: %s 与 %s", other, fileName)
+		}
+		seen[version] = fileName
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+		content := strings.ReplaceAll(string(data), "\r\n", "\n")
+		sum := sha256.Sum256([]byte(content))
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     parts[1],
+			SQL:      content,
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureMigrationTable 创建迁移记录表
+func ensureMigrationTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version int NOT NULL COMMENT '迁移版本号',
+			name varchar(255) NOT NULL COMMENT '迁移说明（文件名）',
+			checksum char(64) NOT NULL COMMENT '迁移文件的 SHA-256',
+			baseline tinyint(1) NOT NULL DEFAULT 0 COMMENT '1=通过 baseline 标记，未实际执行',
+			execution_ms int NOT NULL DEFAULT 0 COMMENT '执行耗时（毫秒）',
+			applied_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '执行时间',
+			PRIMARY KEY (version)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='数据库迁移记录表'
+	`)
+	return err
+}
+
+// migrationStatuses 查询全部迁移的执行状态；unknown 为数据库中有记录但程序中没有的版本（程序版本低于数据库）
+func migrationStatuses(ctx context.Context, conn *sql.Conn) (statuses []MigrationStatus, unknown []int, err error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ensureMigrationTable(ctx, conn); err != nil {
+		return nil, nil, fmt.Errorf("创建迁移记录表失败: %v", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, baseline, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]MigrationStatus{}
+	for rows.Next() {
+		var s MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&s.Version, &s.AppliedChecksum, &s.Baseline, &appliedAt); err != nil {
+			return nil, nil, err
+		}
+		s.Applied = true
+		s.AppliedAt = appliedAt.Format("2006-01-02 15:04:05")
+		applied[s.Version] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, m := range migrations {
+		s := applied[m.Version]
+		s.Migration = m
+		statuses = append(statuses, s)
+		delete(applied, m.Version)
+	}
+	for version := range applied {
+		unknown = append(unknown, version)
+	}
+	sort.Ints(unknown)
+	return statuses, unknown, nil
+}
+
+// withMigrationLock 在同一个数据库连接上加锁后执行 fn（迁移中的 SET 语句只对当前连接有效）
+func withMigrationLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := DBInstance.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", migrationLockName).Scan(&locked); err != nil {
+		return fmt.Errorf("获取迁移锁失败: %v", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("获取迁移锁超时，可能有其他实例正在执行迁移")
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+
+	return fn(ctx, conn)
+}
+
+// hasExistingTables 数据库中是否已有业务表（未使用迁移部署的旧数据库）
+func hasExistingTables(ctx context.Context, conn *sql.Conn) (bool, error) {
+	var count int
+	err := conn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = 'users'
+	`).Scan(&count)
+	return count > 0, err
+}
+
+// pendingMigrations 检查迁移状态并返回待执行的迁移；已执行的迁移被修改、
+// 或旧数据库尚未标记基线时返回错误
+func pendingMigrations(ctx context.Context, conn *sql.Conn) ([]Migration, error) {
+	statuses, unknown, err := migrationStatuses(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) > 0 {
+		logger.Warnf("数据库迁移-数据库中存在程序未包含的迁移版本 %v，请确认程序版本是否过旧", unknown)
+	}
+
+	var pending []Migration
+	appliedCount := 0
+	for _, s := range statuses {
+		if s.ChecksumMismatch() {
+			return nil, fmt.Errorf("迁移 %04d_%s 执行后文件被修改（校验和不一致），请新增迁移而不是修改已执行的迁移", s.Version, s.Name)
+		}
+		if s.Applied {
+			appliedCount++
+		} else {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	if appliedCount == 0 && len(pending) > 0 {
+		existing, err := hasExistingTables(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if existing {
+			return nil, fmt.Errorf("数据库中已有数据表但没有迁移记录，请先执行 ops-web migrate baseline <版本号> 标记已执行到的版本")
+		}
+	}
+	return pending, nil
+}
+
+// applyMigration 逐条执行迁移中的 SQL 语句并记录
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) error {
+	start := time.Now()
+	for i, stmt := range SplitStatements(m.SQL) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("迁移 %04d_%s 第 %d 条语句执行失败: %v\nSQL: %s", m.Version, m.Name, i+1, err, stmt)
+		}
+	}
+	_, err := conn.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum, baseline, execution_ms) VALUES (?, ?, ?, 0, ?)",
+		m.Version, m.Name, m.Checksum, time.Since(start).Milliseconds())
+	if err != nil {
+		return fmt.Errorf("迁移 %04d_%s 已执行，但写入迁移记录失败: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// Migrate 按版本号顺序执行全部待执行的迁移，返回本次执行的迁移
+func Migrate() ([]Migration, error) {
+	var applied []Migration
+	err := withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		pending, err := pendingMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			if err := applyMigration(ctx, conn, m); err != nil {
+				return err
+			}
+			logger.Infof("数据库迁移-已执行 %04d_%s", m.Version, m.Name)
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Baseline 将版本号不大于 version 的迁移标记为已执行（不执行 SQL），用于接管按 deploy 目录脚本部署的旧数据库
+func Baseline(version int) ([]Migration, error) {
+	var marked []Migration
+	err := withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		statuses, _, err := migrationStatuses(ctx, conn)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, s := range statuses {
+			if s.Version == version {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("迁移版本 %d 不存在", version)
+		}
+
+		for _, s := range statuses {
+			if s.Version > version || s.Applied {
+				continue
+			}
+			_, err := conn.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name, checksum, baseline) VALUES (?, ?, ?, 1)",
+				s.Version, s.Name, s.Checksum)
+			if err != nil {
+				return err
+			}
+			marked = append(marked, s.Migration)
+		}
+		return nil
+	})
+	return marked, err
+}
+
+// MigrationStatuses 查询全部迁移的执行状态
+func MigrationStatuses() ([]MigrationStatus, []int, error) {
+	var statuses []MigrationStatus
+	var unknown []int
+	err := withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		var err error
+		statuses, unknown, err = migrationStatuses(ctx, conn)
+		return err
+	})
+	return statuses, unknown, err
+}
+
+// MigrateOnStartup 启动时检查数据库迁移：配置 auto_migrate 时自动执行，否则有待执行的迁移时返回错误
+func MigrateOnStartup() error {
+	if AppConfig.AutoMigrate {
+		applied, err := Migrate()
+		if len(applied) > 0 {
+			logger.Infof("数据库迁移-启动时执行了 %d 个迁移", len(applied))
+		}
+		return err
+	}
+
+	return withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		pending, err := pendingMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			names := make([]string, len(pending))
+			for i, m := range pending {
+				names[i] = fmt.Sprintf("%04d_%s", m.Version, m.Name)
+			}
+			return fmt.Errorf("有 %d 个数据库迁移未执行（%s），请执行 ops-web migrate up，或在 config.json 中设置 \"auto_migrate\": true",
+				len(pending), strings.Join(names, "、"))
+		}
+		return nil
+	})
+}
+
+// MigrateCommand 命令行迁移（ops-web migrate status|up|baseline <版本号>），返回进程退出码
+func MigrateCommand(args []string, out io.Writer) int {
+	usage := "用法: ops-web migrate status | up | baseline <版本号>"
+	if len(args) == 0 {
+		fmt.Fprintln(out, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, unknown, err := MigrationStatuses()
+		if err != nil {
+			fmt.Fprintf(out, "查询迁移状态失败: %v\n", err)
+			return 1
+		}
+		pending := 0
+		for _, s := range statuses {
+			state := "待执行"
+			switch {
+			case s.ChecksumMismatch():
+				state = "已执行，文件已被修改"
+			case s.Baseline:
+				state = "已标记（baseline） " + s.AppliedAt
+			case s.Applied:
+				state = "已执行 " + s.AppliedAt
+			default:
+				pending++
+			}
+			fmt.Fprintf(out, "%04d  %-36s %s\n", s.Version, s.Name, state)
+		}
+		for _, version := range unknown {
+			fmt.Fprintf(out, "%04d  %-36s %s\n", version, "?", "数据库中有记录，程序中不存在")
+		}
+		fmt.Fprintf(out, "共 %d 个迁移，待执行 %d 个\n", len(statuses), pending)
+		return 0
+
+	case "up":
+		applied, err := Migrate()
+		for _, m := range applied {
+			fmt.Fprintf(out, "已执行 %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "迁移失败: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "数据库已是最新版本")
+		}
+		return 0
+
+	case "baseline":
+		if len(args) < 2 {
+			fmt.Fprintln(out, usage)
+			return 2
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(out, "版本号无效: %s\n", args[1])
+			return 2
+		}
+		marked, err := Baseline(version)
+		if err != nil {
+			fmt.Fprintf(out, "标记失败: %v\n", err)
+			return 1
+		}
+		for _, m := range marked {
+			fmt.Fprintf(out, "已标记 %04d_%s\n", m.Version, m.Name)
+		}
+		fmt.Fprintf(out, "已将版本 %d 及之前的迁移标记为已执行（共 %d 个），之后的迁移可执行 ops-web migrate up\n", version, len(marked))
+		return 0
+	}
+
+	fmt.Fprintln(out, usage)
+	return 2
+}
@@ -0,0 +1,26 @@
+-- 来源：deploy/会话管理sql
+-- 创建用户会话表（会话持久化，服务重启后无需重新登录）
+CREATE TABLE IF NOT EXISTS `user_sessions` (
+  `session_id` varchar(128) NOT NULL COMMENT '会话ID',
+  `user_id` int(11) NOT NULL COMMENT '用户ID，关联users表',
+  `username` varchar(50) NOT NULL COMMENT '用户名',
+  `role_id` int(11) NOT NULL COMMENT '角色ID',
+  `role_code` tinyint(4) NOT NULL COMMENT '角色代码：0=管理员，1=普通用户',
+  `expire_at` datetime NOT NULL COMMENT '过期时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`session_id`),
+  KEY `idx_user_id` (`user_id`),
+  KEY `idx_expire_at` (`expire_at`),
+  CONSTRAINT `fk_user_sessions_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户会话表';
+
+-- 用户会话表增加登录IP、浏览器标识、最后活跃时间字段
+-- 会话ID改为保存令牌的SHA-256哈希，旧格式会话全部失效，需先清空
+DELETE FROM `user_sessions`;
+
+ALTER TABLE `user_sessions`
+  MODIFY COLUMN `session_id` char(64) NOT NULL COMMENT '会话ID（会话令牌的SHA-256哈希，不保存原始令牌）',
+  ADD COLUMN `ip` varchar(50) DEFAULT NULL COMMENT '登录IP' AFTER `role_code`,
+  ADD COLUMN `user_agent` varchar(512) DEFAULT NULL COMMENT '浏览器标识' AFTER `ip`,
+  ADD COLUMN `last_seen_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '最后活跃时间' AFTER `user_agent`,
+  MODIFY COLUMN `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '登录时间';
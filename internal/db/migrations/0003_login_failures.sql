@@ -0,0 +1,22 @@
+-- 来源：deploy/登录安全sql
+-- 创建登录失败记录表（用于登录防暴力破解：渐进延迟与临时锁定）
+CREATE TABLE IF NOT EXISTS `login_failures` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `scope` varchar(20) NOT NULL COMMENT '计数维度：username-用户名，ip-来源IP',
+  `scope_key` varchar(100) NOT NULL COMMENT '用户名或IP',
+  `fail_count` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口内连续失败次数',
+  `last_failed_at` datetime NOT NULL COMMENT '最后一次失败时间',
+  `locked_until` datetime DEFAULT NULL COMMENT '锁定截止时间，NULL表示未锁定',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_scope_key` (`scope`, `scope_key`),
+  KEY `idx_locked_until` (`locked_until`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='登录失败记录表';
+
+-- 插入默认参数（已存在时不覆盖）
+INSERT INTO `system_settings` (`param_key`, `param_value`) VALUES
+('login_delay_after_failures', '3'),
+('login_max_delay_seconds', '10'),
+('login_lockout_failures', '10'),
+('login_ip_lockout_failures', '30'),
+('login_lockout_minutes', '15')
+ON DUPLICATE KEY UPDATE `param_key` = `param_key`;
@@ -0,0 +1,28 @@
+-- 来源：deploy/双因素认证sql
+-- 创建用户双因素认证表（TOTP身份验证器绑定信息）
+CREATE TABLE IF NOT EXISTS `user_totp` (
+  `user_id` int(11) NOT NULL COMMENT '用户ID，关联users表',
+  `secret` varchar(64) NOT NULL COMMENT 'TOTP密钥（Base32编码）',
+  `enabled` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否启用：0=未启用，1=已启用',
+  `last_used_step` bigint(20) NOT NULL DEFAULT '0' COMMENT '最后一次使用的时间步长（防止验证码重放）',
+  `enabled_at` datetime DEFAULT NULL COMMENT '启用时间',
+  PRIMARY KEY (`user_id`),
+  CONSTRAINT `fk_user_totp_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户双因素认证表';
+
+-- 创建恢复码表（手机丢失时使用，每个恢复码只能使用一次）
+CREATE TABLE IF NOT EXISTS `user_recovery_codes` (
+  `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+  `user_id` int(11) NOT NULL COMMENT '用户ID，关联users表',
+  `code_hash` char(64) NOT NULL COMMENT '恢复码SHA-256哈希',
+  `used_at` datetime DEFAULT NULL COMMENT '使用时间，NULL表示未使用',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_user_code` (`user_id`, `code_hash`),
+  CONSTRAINT `fk_user_recovery_codes_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='双因素认证恢复码表';
+
+-- 插入默认参数（已存在时不覆盖）
+INSERT INTO `system_settings` (`param_key`, `param_value`) VALUES
+('require_admin_2fa', '0')
+ON DUPLICATE KEY UPDATE `param_key` = `param_key`;
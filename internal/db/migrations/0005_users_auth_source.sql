@@ -0,0 +1,5 @@
+-- 来源：deploy/LDAP认证sql
+-- 用户表增加账号来源字段（LDAP目录认证）
+-- local=本地账号（使用users表中的bcrypt密码登录），ldap=目录账号（首次通过LDAP登录时自动创建，密码为空）
+ALTER TABLE `users`
+  ADD COLUMN `auth_source` varchar(20) NOT NULL DEFAULT 'local' COMMENT '账号来源：local=本地账号，ldap=目录账号' AFTER `role_id`;
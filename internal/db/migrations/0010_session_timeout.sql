@@ -0,0 +1,10 @@
+-- 来源：deploy/会话超时sql
+-- 用户会话表增加最后活跃时间索引（定时清理空闲超时的会话）
+ALTER TABLE `user_sessions`
+  ADD KEY `idx_last_seen_at` (`last_seen_at`);
+
+-- 插入默认会话超时参数（已存在时不覆盖）
+INSERT INTO `system_settings` (`param_key`, `param_value`) VALUES
+('session_idle_minutes', '30'),
+('session_lifetime_hours', '24')
+ON DUPLICATE KEY UPDATE `param_key` = `param_key`;
@@ -0,0 +1,10 @@
+-- 来源：deploy/结构化操作日志sql
+-- 操作日志表增加操作类型、操作对象、操作结果和修改前后的值
+ALTER TABLE `operation_logs`
+  ADD COLUMN `action_code` varchar(50) NOT NULL DEFAULT '' COMMENT '操作类型代码（login、import、delete、edit_comment、sample、backup 等）' AFTER `action`,
+  ADD COLUMN `entity_type` varchar(50) NOT NULL DEFAULT '' COMMENT '操作对象类型（audit_task、audit_detail、user 等）' AFTER `action_code`,
+  ADD COLUMN `entity_id` varchar(255) NOT NULL DEFAULT '' COMMENT '操作对象ID（task_id、明细ID、用户ID等，多个以逗号分隔）' AFTER `entity_type`,
+  ADD COLUMN `outcome` varchar(20) NOT NULL DEFAULT 'success' COMMENT '操作结果：success=成功，failure=失败，denied=被拒绝' AFTER `entity_id`,
+  ADD COLUMN `detail` text DEFAULT NULL COMMENT '修改前后的值（JSON：{"before":...,"after":...}）' AFTER `outcome`,
+  ADD KEY `idx_action_code` (`action_code`),
+  ADD KEY `idx_entity` (`entity_type`, `entity_id`);
@@ -0,0 +1,5 @@
+-- 来源：deploy/操作日志查询sql
+-- 操作日志表增加查询索引（按用户、IP、时间查询日志）
+ALTER TABLE `operation_logs`
+  ADD KEY `idx_username_created_at` (`username`, `created_at`),
+  ADD KEY `idx_ip` (`ip`);
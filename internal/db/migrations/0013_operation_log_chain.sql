@@ -0,0 +1,27 @@
+-- 来源：deploy/日志哈希链sql
+-- 操作日志哈希链：每条日志保存前一条日志的哈希及本条日志的 HMAC-SHA256
+ALTER TABLE `operation_logs`
+  ADD COLUMN `prev_hash` char(64) DEFAULT NULL COMMENT '前一条日志的哈希（第一条或归档后的第一条为检查点哈希）' AFTER `detail`,
+  ADD COLUMN `hash` char(64) DEFAULT NULL COMMENT '本条日志的 HMAC-SHA256' AFTER `prev_hash`;
+
+-- 链头：最新一条日志的ID和哈希（只有一行，id=1），用于发现末尾日志被删除
+CREATE TABLE IF NOT EXISTS `operation_log_chain` (
+  `id` int NOT NULL COMMENT '固定为1',
+  `last_id` bigint NOT NULL DEFAULT '0' COMMENT '最新一条日志ID',
+  `last_hash` char(64) NOT NULL DEFAULT '' COMMENT '最新一条日志哈希',
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='操作日志哈希链头';
+
+-- 归档检查点：归档后从最近的检查点开始校验
+CREATE TABLE IF NOT EXISTS `operation_log_checkpoints` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `last_id` bigint NOT NULL COMMENT '已归档的最后一条日志ID',
+  `last_hash` char(64) NOT NULL COMMENT '已归档的最后一条日志哈希',
+  `archived_count` int NOT NULL DEFAULT '0' COMMENT '本次归档条数',
+  `archive_file` varchar(500) NOT NULL DEFAULT '' COMMENT '归档文件路径',
+  `created_by` varchar(50) NOT NULL DEFAULT '' COMMENT '操作人',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '归档时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_last_id` (`last_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='操作日志归档检查点';
@@ -0,0 +1,8 @@
+-- 来源：deploy/设备档案校验sql
+-- 插入设备档案经纬度允许范围的默认参数（已存在时不覆盖），默认为我国陆地和近海的外接矩形
+INSERT INTO `system_settings` (`param_key`, `param_value`) VALUES
+('geo_min_longitude', '73.5'),
+('geo_max_longitude', '135.1'),
+('geo_min_latitude', '3.8'),
+('geo_max_latitude', '53.6')
+ON DUPLICATE KEY UPDATE `param_key` = `param_key`;
@@ -0,0 +1,7 @@
+-- 来源：deploy/日志哈希链sql
+-- 链头和归档检查点增加 HMAC 校验值，防止直接在数据库中回退链头或伪造检查点掩盖删除的日志
+ALTER TABLE `operation_log_chain`
+  ADD COLUMN `mac` char(64) NOT NULL DEFAULT '' COMMENT '链头校验值 HMAC-SHA256(last_id, last_hash)' AFTER `last_hash`;
+
+ALTER TABLE `operation_log_checkpoints`
+  ADD COLUMN `mac` char(64) NOT NULL DEFAULT '' COMMENT '检查点校验值 HMAC-SHA256(检查点各字段)' AFTER `created_by`;
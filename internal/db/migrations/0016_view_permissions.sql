@@ -0,0 +1,13 @@
+-- 来源：deploy/角色权限sql
+-- 查看类权限：建档明细、审核进度、统计、用户列表原来登录即可访问，升级后需要角色拥有对应权限
+-- 非管理员角色全部授予，保持升级前的行为；管理员可在权限设置页面按角色取消
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+SELECT r.`id`, p.`permission`
+FROM `user_role` r
+JOIN (
+  SELECT 'device_view' AS `permission`
+  UNION ALL SELECT 'checkpoint_view'
+  UNION ALL SELECT 'stats_view'
+  UNION ALL SELECT 'user_view'
+) p
+WHERE r.`role_code` <> 0;
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 配置文件中密码的两种非明文写法：
+//   - enc:<密文>     用配置密钥加密的密码，由 ops-web config encrypt 生成
+//   - file:<路径>    从文件读取密码（如 Docker/Kubernetes secret 挂载的文件），去掉末尾换行
+const (
+	secretEncPrefix  = "enc:"
+	secretFilePrefix = "file:"
+)
+
+// ConfigKeyEnv 配置密钥环境变量；未设置时使用配置文件同目录下的 config.key
+const ConfigKeyEnv = "OPSWEB_CONFIG_KEY"
+
+// configKeyFileName 配置密钥文件名，首次执行 ops-web config encrypt 时自动生成
+const configKeyFileName = "config.key"
+
+// configDir 配置文件所在目录（配置密钥文件放在同一目录）
+func configDir(path string) string {
+	return filepath.Dir(path)
+}
+
+// resolveSecret 将 enc: 和 file: 写法的配置值解析为明文，其他值原样返回
+func resolveSecret(value, keyDir string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretFilePrefix):
+		path := strings.TrimSpace(strings.TrimPrefix(value, secretFilePrefix))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("读取密码文件失败: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(value, secretEncPrefix):
+		key, err := loadConfigKey(keyDir, false)
+		if err != nil {
+			return "", err
+		}
+		return decryptSecret(key, strings.TrimPrefix(value, secretEncPrefix))
+	}
+	return value, nil
+}
+
+// loadConfigKey 读取配置密钥：优先使用环境变量，其次读取密钥文件，create 为 true 时文件不存在则生成
+func loadConfigKey(dir string, create bool) ([]byte, error) {
+	if material := strings.TrimSpace(os.Getenv(ConfigKeyEnv)); material != "" {
+		return deriveConfigKey(material), nil
+	}
+
+	path := filepath.Join(dir, configKeyFileName)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		material := strings.TrimSpace(string(data))
+		if material == "" {
+			return nil, fmt.Errorf("配置密钥文件 %s 为空", path)
+		}
+		return deriveConfigKey(material), nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("读取配置密钥失败（设置环境变量 %s 或提供 %s）: %v", ConfigKeyEnv, path, err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("生成配置密钥失败: %v", err)
+	}
+	material := hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(material+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("保存配置密钥失败: %v", err)
+	}
+	return deriveConfigKey(material), nil
+}
+
+// deriveConfigKey 由密钥内容（密钥文件内容或环境变量值）得到 AES-256 密钥
+func deriveConfigKey(material string) []byte {
+	sum := sha256.Sum256([]byte(material))
+	return sum[:]
+}
+
+// encryptSecret 用 AES-256-GCM 加密，返回 base64(随机数+密文)
+func encryptSecret(key []byte, plain string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret 解密 encryptSecret 生成的密文
+func decryptSecret(key []byte, encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", errors.New("密文格式错误")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("密文格式错误")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("解密失败，配置密钥与加密时使用的不一致")
+	}
+	return string(plain), nil
+}
+
+// ConfigCommand 命令行配置工具（不连接数据库），返回进程退出码：
+//
+//	ops-web config check     加载并校验配置文件，输出脱敏后的生效配置
+//	ops-web config encrypt   从标准输入读取一行密码，输出可写入配置文件的 enc: 密文
+func ConfigCommand(args []string, in io.Reader, out io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(out, "用法: ops-web [-config 配置文件] config check | encrypt")
+		return 2
+	}
+
+	switch args[0] {
+	case "check":
+		cfg, err := LoadConfig(ConfigPath)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+		if data, err := os.ReadFile(ConfigPath); err == nil {
+			if err := checkUnknownFields(data); err != nil {
+				fmt.Fprintf(out, "警告: 配置文件中有无法识别的配置项: %v\n", err)
+			}
+		}
+		fmt.Fprintf(out, "配置文件: %s\n", ConfigPath)
+		fmt.Fprintf(out, "数据库: %s\n", cfg.RedactedDSN())
+		fmt.Fprintln(out, cfg)
+		return 0
+
+	case "encrypt":
+		key, err := loadConfigKey(configDir(ConfigPath), true)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+		fmt.Fprintln(out, "请输入要加密的密码（回车结束）:")
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			fmt.Fprintln(out, "读取输入失败:", err)
+			return 1
+		}
+		plain := strings.TrimRight(line, "\r\n")
+		if plain == "" {
+			fmt.Fprintln(out, "密码不能为空")
+			return 1
+		}
+		encrypted, err := encryptSecret(key, plain)
+		if err != nil {
+			fmt.Fprintln(out, "加密失败:", err)
+			return 1
+		}
+		fmt.Fprintln(out, secretEncPrefix+encrypted)
+		return 0
+	}
+
+	fmt.Fprintf(out, "未知的 config 子命令: %s\n", args[0])
+	return 2
+}
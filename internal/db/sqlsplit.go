@@ -0,0 +1,77 @@
+package db
+
+import "strings"
+
+// SplitStatements 将 SQL 脚本拆分为单条语句：按语句末尾的分号拆分，
+// 忽略字符串、反引号标识符和注释中的分号，并去掉注释（保留 /*! ... */ 版本注释）
+func SplitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// 字符串或标识符：原样保留到对应的结束引号（单双引号支持反斜杠转义和连续两个引号）
+			end := i + 1
+			for end < len(script) {
+				if script[end] == '\\' && c != '`' {
+					end += 2
+					continue
+				}
+				if script[end] == c {
+					if end+1 < len(script) && script[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end >= len(script) {
+				end = len(script) - 1
+			}
+			current.WriteString(script[i : end+1])
+			i = end
+
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "--") && (i+2 == len(script) || script[i+2] == ' ' || script[i+2] == '\t' || script[i+2] == '\n' || script[i+2] == '\r')):
+			// 单行注释
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end
+				current.WriteByte('\n')
+			}
+
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script)
+			} else {
+				end += i + 4
+			}
+			if strings.HasPrefix(script[i:], "/*!") {
+				current.WriteString(script[i:end])
+			} else {
+				current.WriteByte(' ')
+			}
+			i = end - 1
+
+		case c == ';':
+			flush()
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
@@ -0,0 +1,92 @@
+package devicevalidate
+
+import (
+	"fmt"
+	"math"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"strconv"
+)
+
+// Bounds 经纬度允许范围（WGS-84，经度东正西负，纬度北正南负）
+type Bounds struct {
+	MinLongitude float64
+	MaxLongitude float64
+	MinLatitude  float64
+	MaxLatitude  float64
+}
+
+// DefaultBounds 默认范围为我国陆地和近海的外接矩形，部署时可在系统参数中收窄到本辖区
+var DefaultBounds = Bounds{
+	MinLongitude: 73.5,
+	MaxLongitude: 135.1,
+	MinLatitude:  3.8,
+	MaxLatitude:  53.6,
+}
+
+// 经纬度范围的系统参数
+const (
+	settingMinLongitude = "geo_min_longitude"
+	settingMaxLongitude = "geo_max_longitude"
+	settingMinLatitude  = "geo_min_latitude"
+	settingMaxLatitude  = "geo_max_latitude"
+)
+
+// LoadBounds 从系统参数读取经纬度范围，参数不存在或无效时使用默认值
+func LoadBounds() Bounds {
+	b := Bounds{
+		MinLongitude: getSettingFloat(settingMinLongitude, DefaultBounds.MinLongitude),
+		MaxLongitude: getSettingFloat(settingMaxLongitude, DefaultBounds.MaxLongitude),
+		MinLatitude:  getSettingFloat(settingMinLatitude, DefaultBounds.MinLatitude),
+		MaxLatitude:  getSettingFloat(settingMaxLatitude, DefaultBounds.MaxLatitude),
+	}
+	if !b.valid() {
+		logger.Warnf("经纬度范围参数无效（经度 %g～%g，纬度 %g～%g），使用默认范围",
+			b.MinLongitude, b.MaxLongitude, b.MinLatitude, b.MaxLatitude)
+		return DefaultBounds
+	}
+	return b
+}
+
+// valid 范围在经纬度取值内且最小值小于最大值
+func (b Bounds) valid() bool {
+	return b.MinLongitude >= -180 && b.MaxLongitude <= 180 && b.MinLongitude < b.MaxLongitude &&
+		b.MinLatitude >= -90 && b.MaxLatitude <= 90 && b.MinLatitude < b.MaxLatitude
+}
+
+// Longitude 校验经度格式和范围
+func (b Bounds) Longitude(value string) string {
+	return checkRange("经度", value, b.MinLongitude, b.MaxLongitude)
+}
+
+// Latitude 校验纬度格式和范围
+func (b Bounds) Latitude(value string) string {
+	return checkRange("纬度", value, b.MinLatitude, b.MaxLatitude)
+}
+
+// checkRange 校验数值格式和范围
+func checkRange(name, value string, min, max float64) string {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Sprintf("%s不是有效的数字（十进制度数，如 120.153576）", name)
+	}
+	if v < min || v > max {
+		return fmt.Sprintf("%s超出允许范围（%g～%g）", name, min, max)
+	}
+	return ""
+}
+
+// getSettingFloat 获取小数类型参数值，参数不存在或无效时返回默认值
+func getSettingFloat(key string, defaultValue float64) float64 {
+	var value string
+	query := "SELECT param_value FROM system_settings WHERE param_key = ?"
+	err := db.DBInstance.QueryRow(query, key).Scan(&value)
+	if err != nil {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return f
+}
@@ -0,0 +1,84 @@
+package devicevalidate
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// GB/T 28181 设备编码为 20 位数字：
+//
+//	第 1～8 位   中心编码（省 2 位、市 2 位、区县 2 位、基层单位 2 位）
+//	第 9～10 位  行业编码（见 industryCodes）
+//	第 11～13 位 类型编码（111～130 前端主设备，131～199 前端外围设备，如 131 摄像机、132 网络摄像机）
+//	第 14 位     网络标识
+//	第 15～20 位 设备序号
+const deviceCodeLength = 20
+
+// 视频设备档案中的设备应为前端设备
+const (
+	minFrontEndType = 111
+	maxFrontEndType = 199
+)
+
+// provinceCodes 中心编码前 2 位：省级行政区划代码（GB/T 2260）
+var provinceCodes = map[string]bool{
+	"11": true, "12": true, "13": true, "14": true, "15": true,
+	"21": true, "22": true, "23": true,
+	"31": true, "32": true, "33": true, "34": true, "35": true, "36": true, "37": true,
+	"41": true, "42": true, "43": true, "44": true, "45": true, "46": true,
+	"50": true, "51": true, "52": true, "53": true, "54": true,
+	"61": true, "62": true, "63": true, "64": true, "65": true,
+	"71": true, "81": true, "82": true,
+}
+
+// industryCodes 行业编码（GB/T 28181 附录 D）：00～11 为公安和政府部门，40～55 为各类企业，
+// 12～39、56～99 为标准预留的扩展区间，尚未分配行业，设备编码中出现时视为填写错误
+var industryCodes = map[string]string{
+	"00": "社会治安路面接入", "01": "社会治安社区接入", "02": "社会治安内部接入", "03": "社会治安其他接入",
+	"04": "交通路面接入", "05": "交通卡口接入", "06": "交通内部接入", "07": "交通其他接入",
+	"08": "城市管理接入", "09": "卫生环保接入", "10": "商检海关接入", "11": "教育部门接入",
+	"40": "农林牧渔业接入", "41": "采矿企业接入", "42": "制造企业接入", "43": "冶金企业接入",
+	"44": "电力企业接入", "45": "燃气企业接入", "46": "建筑企业接入", "47": "物流企业接入",
+	"48": "邮政企业接入", "49": "信息企业接入", "50": "住宿和餐饮业接入", "51": "金融企业接入",
+	"52": "房地产业接入", "53": "商务服务业接入", "54": "水利企业接入", "55": "娱乐企业接入",
+}
+
+// DeviceCode 校验 20 位设备编码的结构：位数、中心编码的省级代码、行业编码、类型编码
+func DeviceCode(code string) string {
+	if len(code) != deviceCodeLength || !isDigits(code) {
+		return "设备编码应为20位数字（GB/T 28181）"
+	}
+	if !provinceCodes[code[0:2]] {
+		return fmt.Sprintf("设备编码第1～2位（%s）不是有效的省级行政区划代码", code[0:2])
+	}
+	if _, ok := industryCodes[code[8:10]]; !ok {
+		return fmt.Sprintf("设备编码第9～10位行业编码（%s）不是 GB/T 28181 规定的行业编码（00～11、40～55）", code[8:10])
+	}
+	typeCode, _ := strconv.Atoi(code[10:13])
+	if typeCode < minFrontEndType || typeCode > maxFrontEndType {
+		return fmt.Sprintf("设备编码第11～13位类型编码（%s）不是前端设备（%d～%d，摄像机为131或132）",
+			code[10:13], minFrontEndType, maxFrontEndType)
+	}
+	return ""
+}
+
+// DivisionCode 校验行政区划编码：6 位区县代码，或再加 1～2 位基层单位代码
+func DivisionCode(code string) string {
+	if len(code) < 6 || len(code) > 8 || !isDigits(code) {
+		return "行政区划编码应为6～8位数字"
+	}
+	if !provinceCodes[code[0:2]] {
+		return fmt.Sprintf("行政区划编码第1～2位（%s）不是有效的省级行政区划代码", code[0:2])
+	}
+	return ""
+}
+
+// isDigits 是否全部为 ASCII 数字
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,74 @@
+// Package devicevalidate 视频设备档案（audit_details）字段校验：
+// GB/T 28181 设备编码、行政区划编码、IP/MAC 地址、经纬度范围、联系电话和枚举字段。
+// 导入和编辑档案使用同一套校验，校验函数通过时返回空字符串，否则返回错误说明。
+package devicevalidate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validator 按字段名校验档案字段，经纬度范围在创建时从系统参数读取
+type Validator struct {
+	Bounds Bounds
+}
+
+// New 创建校验器，一次导入或保存使用同一个校验器
+func New() *Validator {
+	return &Validator{Bounds: LoadBounds()}
+}
+
+// FieldProblem 跨字段校验发现的错误，Field 为出错的字段
+type FieldProblem struct {
+	Field   string
+	Message string
+}
+
+// Field 按标准校验单个字段，没有标准校验的字段和空值直接通过（必填由调用方检查）
+func (v *Validator) Field(field, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	switch field {
+	case "device_code":
+		return DeviceCode(value)
+	case "division_code":
+		return DivisionCode(value)
+	case "ipv4_address":
+		return IPv4(value)
+	case "ipv6_address":
+		return IPv6(value)
+	case "mac_address":
+		return MAC(value)
+	case "longitude":
+		return v.Bounds.Longitude(value)
+	case "latitude":
+		return v.Bounds.Latitude(value)
+	case "admin_contact":
+		return Phone(value)
+	case "monitor_point_type":
+		return MonitorPointType(value)
+	case "camera_function_type":
+		return CameraFunctionType(value)
+	}
+	return ""
+}
+
+// Record 跨字段校验，get 返回字段值；目前检查行政区划编码与设备编码前几位一致。
+// 单个字段本身有误时不再做跨字段校验，避免同一问题重复报错
+func (v *Validator) Record(get func(field string) string) []FieldProblem {
+	var problems []FieldProblem
+	deviceCode := strings.TrimSpace(get("device_code"))
+	divisionCode := strings.TrimSpace(get("division_code"))
+	if deviceCode != "" && divisionCode != "" &&
+		DeviceCode(deviceCode) == "" && DivisionCode(divisionCode) == "" &&
+		!strings.HasPrefix(deviceCode, divisionCode) {
+		problems = append(problems, FieldProblem{
+			Field: "division_code",
+			Message: fmt.Sprintf("行政区划编码与设备编码前 %d 位（%s）不一致",
+				len(divisionCode), deviceCode[:len(divisionCode)]),
+		})
+	}
+	return problems
+}
@@ -0,0 +1,96 @@
+package devicevalidate
+
+import (
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// IPv4 校验 IPv4 地址（点分十进制，不含前导零和端口）
+func IPv4(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+		return "不是有效的IPv4地址（如 192.168.1.10）"
+	}
+	return ""
+}
+
+// IPv6 校验 IPv6 地址
+func IPv6(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil || !strings.Contains(value, ":") {
+		return "不是有效的IPv6地址（如 2001:db8::10）"
+	}
+	return ""
+}
+
+// MAC 校验 48 位 MAC 地址：00:1A:2B:3C:4D:5E、00-1A-2B-3C-4D-5E、001A.2B3C.4D5E 或 001A2B3C4D5E
+func MAC(value string) string {
+	if hw, err := net.ParseMAC(value); err == nil && len(hw) == 6 {
+		return ""
+	}
+	if b, err := hex.DecodeString(value); err == nil && len(b) == 6 {
+		return ""
+	}
+	return "不是有效的MAC地址（如 00:1A:2B:3C:4D:5E）"
+}
+
+var (
+	// mobilePattern 手机号码，可带 +86/86 前缀
+	mobilePattern = regexp.MustCompile(`^(\+?86)?1[3-9]\d{9}$`)
+	// landlinePattern 固定电话：区号可选，可带分机号，如 0571-87654321-123
+	landlinePattern = regexp.MustCompile(`^(0\d{2,3}-?)?[1-9]\d{6,7}(-\d{1,6})?$`)
+	// phoneSeparators 多个号码之间的分隔符
+	phoneSeparators = regexp.MustCompile(`[,，;；/、]`)
+)
+
+// Phone 校验联系电话：手机号码或固定电话，多个号码用逗号、分号、顿号或“/”分隔
+func Phone(value string) string {
+	for _, part := range phoneSeparators.Split(value, -1) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mobile := strings.NewReplacer(" ", "", "-", "").Replace(part)
+		if !mobilePattern.MatchString(mobile) && !landlinePattern.MatchString(part) {
+			return "联系电话格式不正确（手机号码如 13812345678，固定电话如 0571-87654321）"
+		}
+	}
+	return ""
+}
+
+// monitorPointTypes 监控点位类型，统计按编码 1～4 分类
+var monitorPointTypes = map[string]string{
+	"1": "一类点",
+	"2": "二类点",
+	"3": "三类点",
+	"4": "四类点",
+}
+
+// MonitorPointType 校验监控点位类型编码
+func MonitorPointType(value string) string {
+	if _, ok := monitorPointTypes[value]; !ok {
+		return "监控点位类型应为 1（一类点）、2（二类点）、3（三类点）或 4（四类点）"
+	}
+	return ""
+}
+
+// CameraFunctionType 校验摄像机功能类型：一个或多个数字编码，用英文逗号分隔（如“1,2”）。
+// 与表结构说明和审核统计一致，1=车辆，2=人脸，空或其他编码统计为视频，因此不限定编码取值；
+// 统计按英文逗号拆分，其他写法（中文逗号、文字等）会被误统计为视频
+func CameraFunctionType(value string) string {
+	const hint = "（1=车辆，2=人脸，其他编码为视频；多个用英文逗号分隔，如“1,2”）"
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if !isDigits(part) {
+			return "摄像机功能类型“" + part + "”不是数字编码" + hint
+		}
+		if seen[part] {
+			return "摄像机功能类型“" + part + "”重复"
+		}
+		seen[part] = true
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package diagnostics
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Version 程序版本，编译时注入：
+//
+//	go build -ldflags "-X ops-web/internal/diagnostics.Version=1.2.0" -o ops-web .
+var Version = "dev"
+
+// startedAt 进程启动时间
+var startedAt = time.Now()
+
+// BuildInfo 程序版本和编译信息
+type BuildInfo struct {
+	Version   string
+	GoVersion string
+	Revision  string // 编译时的 git 提交
+	BuildTime string // 该提交的时间
+	Modified  bool   // 编译时工作区有未提交的修改
+	StartedAt time.Time
+	Uptime    time.Duration
+}
+
+// ReadBuildInfo 读取版本和编译信息（git 信息由 go build 自动写入，不在 git 仓库中编译时为空）
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		StartedAt: startedAt,
+		Uptime:    time.Since(startedAt).Truncate(time.Second),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
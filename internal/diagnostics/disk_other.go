@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package diagnostics
+
+import "errors"
+
+// diskUsage 当前系统不支持查询磁盘空间
+func diskUsage(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("当前系统不支持查询磁盘空间")
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package diagnostics
+
+import "syscall"
+
+// diskUsage 返回路径所在磁盘的可用空间和总空间（字节）
+func diskUsage(path string) (free, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package diagnostics
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskUsage 返回路径所在磁盘的可用空间和总空间（字节）
+func diskUsage(path string) (free, total uint64, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var totalFree uint64
+	r, _, callErr := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if r == 0 {
+		return 0, 0, callErr
+	}
+	return free, total, nil
+}
@@ -0,0 +1,290 @@
+package diagnostics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"ops-web/internal/auditprogress"
+	"ops-web/internal/auth"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"ops-web/internal/taskconfig"
+	"ops-web/internal/tlsserver"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// lowDiskPercent 可用空间低于该比例时页面标红提示
+const lowDiskPercent = 10
+
+// PageData 页面数据
+type PageData struct {
+	Title      string
+	ActiveMenu string
+	SubMenu    string
+
+	Build BuildInfo
+
+	DBError      string // 数据库连接失败的原因，连接正常时为空
+	DBLatency    time.Duration
+	MySQLVersion string
+	DSN          string // 脱敏后的连接串
+	Pool         db.PoolConfig
+	PoolStats    sql.DBStats
+
+	MigrationsApplied int
+	MigrationsPending int
+	MigrationError    string
+
+	Disks []DiskInfo
+
+	MysqldumpPath  string // 为空表示未找到 mysqldump，数据库备份不可用
+	MysqldumpError string
+
+	Scheduler     SchedulerView
+	VideoReminder VideoReminderView
+
+	HTTPS HTTPSView
+
+	Goroutines int
+	MemAlloc   string
+}
+
+// HTTPSView 内置 HTTPS 状态和当前证书（时间已格式化）
+type HTTPSView struct {
+	Enabled      bool
+	MinVersion   string
+	RedirectPort string
+	CertFile     string
+	Subject      string
+	DNSNames     string
+	NotAfter     string
+	ExpiresSoon  bool // 证书30天内过期
+	LoadedAt     string
+}
+
+// DiskInfo 系统设置中各目录所在磁盘的空间
+type DiskInfo struct {
+	Name        string
+	SettingKey  string
+	Path        string
+	Free        string
+	Total       string
+	FreePercent int
+	Low         bool   // 可用空间不足 lowDiskPercent%
+	Error       string // 未设置目录或查询失败的原因
+}
+
+// SchedulerView 定时备份调度器状态（时间已格式化）
+type SchedulerView struct {
+	Running   bool
+	LastCheck string
+	NextCheck string
+	Backups   []BackupView
+}
+
+// BackupView 定时备份任务
+type BackupView struct {
+	Name        string
+	Enabled     bool
+	Description string
+	NextRun     string
+}
+
+// VideoReminderView 录像提醒定时任务状态（时间已格式化）
+type VideoReminderView struct {
+	Running   bool
+	Enabled   bool
+	Schedule  string
+	NextRun   string
+	LastRun   string
+	LastError string
+}
+
+// diagnosticDirs 诊断页面检查磁盘空间的目录（system_settings 中的参数）
+var diagnosticDirs = []struct{ name, key string }{
+	{"上传文件目录", "upload_file_path"},
+	{"备份路径", "backup_file_path"},
+	{"数据库备份路径", "database_backup_path"},
+}
+
+// Handler 系统诊断页面：数据库连接池、磁盘空间、备份工具、定时任务和版本信息
+func Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	data := PageData{
+		Title:      "系统诊断",
+		ActiveMenu: "settings",
+		SubMenu:    "task_config",
+		Build:      ReadBuildInfo(),
+		DSN:        db.AppConfig.RedactedDSN(),
+		Pool:       db.AppConfig.DBPool,
+		PoolStats:  db.DBInstance.Stats(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	data.MemAlloc = formatBytes(mem.Alloc)
+
+	// 数据库连接
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	start := time.Now()
+	err := db.DBInstance.QueryRowContext(ctx, "SELECT VERSION()").Scan(&data.MySQLVersion)
+	data.DBLatency = time.Since(start).Round(time.Millisecond)
+	cancel()
+	if err != nil {
+		logger.Errorf("系统诊断-数据库连接失败: %v", err)
+		data.DBError = err.Error()
+	}
+
+	// 数据库迁移
+	if statuses, _, err := db.MigrationStatuses(); err != nil {
+		data.MigrationError = err.Error()
+	} else {
+		for _, s := range statuses {
+			if s.Applied {
+				data.MigrationsApplied++
+			} else {
+				data.MigrationsPending++
+			}
+		}
+	}
+
+	// 磁盘空间
+	for _, dir := range diagnosticDirs {
+		data.Disks = append(data.Disks, checkDisk(dir.name, dir.key))
+	}
+
+	// 数据库备份工具
+	if path, err := exec.LookPath("mysqldump"); err != nil {
+		data.MysqldumpError = err.Error()
+	} else {
+		data.MysqldumpPath = path
+	}
+
+	// 定时任务
+	scheduler := taskconfig.Status()
+	data.Scheduler = SchedulerView{
+		Running:   scheduler.Running,
+		LastCheck: formatTime(scheduler.LastCheck),
+		NextCheck: formatTime(scheduler.NextCheck),
+	}
+	for _, b := range scheduler.Backups {
+		data.Scheduler.Backups = append(data.Scheduler.Backups, BackupView{
+			Name:        b.Name,
+			Enabled:     b.Enabled,
+			Description: b.Description,
+			NextRun:     formatTime(b.NextRun),
+		})
+	}
+	reminder := auditprogress.VideoReminderSchedulerStatus()
+	data.VideoReminder = VideoReminderView{
+		Running:   reminder.Running,
+		Enabled:   reminder.Enabled,
+		Schedule:  reminder.Schedule,
+		NextRun:   formatTime(reminder.NextRun),
+		LastRun:   formatTime(reminder.LastRun),
+		LastError: reminder.LastError,
+	}
+
+	// HTTPS
+	data.HTTPS = HTTPSView{
+		Enabled:      db.AppConfig.TLS.Enabled,
+		MinVersion:   db.AppConfig.TLS.MinVersion,
+		RedirectPort: db.AppConfig.TLS.HTTPRedirectPort,
+	}
+	if data.HTTPS.MinVersion == "" {
+		data.HTTPS.MinVersion = "1.2"
+	}
+	if cert, ok := tlsserver.ActiveStatus(); ok {
+		data.HTTPS.CertFile = cert.CertFile
+		data.HTTPS.Subject = cert.Subject
+		data.HTTPS.DNSNames = strings.Join(cert.DNSNames, ", ")
+		data.HTTPS.NotAfter = formatTime(cert.NotAfter)
+		data.HTTPS.ExpiresSoon = time.Until(cert.NotAfter) < 30*24*time.Hour
+		data.HTTPS.LoadedAt = formatTime(cert.LoadedAt)
+	}
+
+	tmpl, err := template.ParseFiles("templates/diagnostics.html")
+	if err != nil {
+		logger.Errorf("系统诊断-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		logger.Errorf("系统诊断-模板渲染失败: %v", err)
+		http.Error(w, "模板渲染失败: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// checkDisk 查询系统设置中目录所在磁盘的空间
+func checkDisk(name, key string) DiskInfo {
+	info := DiskInfo{Name: name, SettingKey: key, Path: getSetting(key)}
+	if info.Path == "" {
+		info.Error = "未设置"
+		return info
+	}
+	if _, err := os.Stat(info.Path); err != nil {
+		info.Error = "目录不可访问: " + err.Error()
+		return info
+	}
+	free, total, err := diskUsage(info.Path)
+	if err != nil {
+		info.Error = "查询磁盘空间失败: " + err.Error()
+		return info
+	}
+	info.Free = formatBytes(free)
+	info.Total = formatBytes(total)
+	if total > 0 {
+		info.FreePercent = int(free * 100 / total)
+		info.Low = info.FreePercent < lowDiskPercent
+	}
+	return info
+}
+
+// formatBytes 按 B/KB/MB/GB/TB 显示字节数
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
+
+// formatTime 格式化时间，零值显示为 -
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// getSetting 获取参数值
+func getSetting(key string) string {
+	var value string
+	query := "SELECT param_value FROM system_settings WHERE param_key = ?"
+	err := db.DBInstance.QueryRow(query, key).Scan(&value)
+	if err != nil {
+		// 参数不存在时返回空字符串
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,57 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"ops-web/internal/db"
+	"ops-web/internal/lifecycle"
+	"ops-web/internal/logger"
+	"time"
+)
+
+// readyTimeout 就绪检查中数据库 Ping 的超时时间
+const readyTimeout = 2 * time.Second
+
+// healthResponse 健康检查响应
+type healthResponse struct {
+	Status string            `json:"status"` // ok、unavailable 或 stopping
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// HealthzHandler 存活检查（无需登录）：进程能处理请求即返回 200
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// ReadyzHandler 就绪检查（无需登录）：数据库可连接时返回 200，否则返回 503；服务停止过程中返回 503。
+// 失败原因只写入日志，不返回给未登录的调用方
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if lifecycle.Stopping() {
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: "stopping"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	if err := db.DBInstance.PingContext(ctx); err != nil {
+		logger.Warnf("就绪检查-数据库连接失败: %v", err)
+		writeHealth(w, http.StatusServiceUnavailable, healthResponse{
+			Status: "unavailable",
+			Checks: map[string]string{"database": "unavailable"},
+		})
+		return
+	}
+	writeHealth(w, http.StatusOK, healthResponse{
+		Status: "ok",
+		Checks: map[string]string{"database": "ok"},
+	})
+}
+
+func writeHealth(w http.ResponseWriter, status int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -220,6 +220,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	whereSQL := " WHERE 1=1"
 	args := []interface{}{}
 
+	// 只显示当前用户可见机构的设备（未关联档案的设备按管理单位判断）
+	currentUser := auth.CurrentUser(r)
+	orgSQL, orgArgs := auth.OrganizationFilter(currentUser, "COALESCE(at.organization, ad.management_unit)")
+	whereSQL += orgSQL
+	args = append(args, orgArgs...)
+
 	if searchCode != "" {
 		whereSQL += " AND ad.device_code LIKE ?"
 		args = append(args, "%"+searchCode+"%")
@@ -344,7 +350,6 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	query := strings.Join(queryParams, "&")
 
 	// 记录查询操作日志（如果有查询条件）
-	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil && (searchCode != "" || searchName != "" || month != "" || auditStatus != "") {
 		action := "查询建档明细"
 		if searchCode != "" {
@@ -360,7 +365,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 			statusText := map[string]string{"0": "未审核未建档", "1": "已审核未建档", "2": "已建档"}
 			action += fmt.Sprintf("（建档状态：%s）", statusText[auditStatus])
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionQuery,
+			EntityType: operationlog.EntityAuditDetail,
+			Message:    action,
+		})
 	}
 
 	// 4. 准备数据并渲染模板
@@ -571,7 +580,11 @@ func ImportHandler(w http.ResponseWriter, r *http.Request) {
 	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil {
 		action := fmt.Sprintf("导入建档明细 Excel（共 %d 条数据）", importedCount)
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionImport,
+			EntityType: operationlog.EntityAuditDetail,
+			Message:    action,
+		})
 	}
 
 	http.Redirect(w, r, "/device/filelist?message=ImportSuccess&count="+strconv.Itoa(importedCount), http.StatusSeeOther)
@@ -624,6 +637,12 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 	whereSQL := " WHERE 1=1"
 	args := []interface{}{}
 
+	// 只导出当前用户可见机构的设备
+	currentUser := auth.CurrentUser(r)
+	orgSQL, orgArgs := auth.OrganizationFilter(currentUser, "COALESCE((SELECT t.organization FROM audit_tasks t WHERE t.id = audit_details.task_id), audit_details.management_unit)")
+	whereSQL += orgSQL
+	args = append(args, orgArgs...)
+
 	if searchCode != "" {
 		whereSQL += " AND device_code LIKE ?"
 		args = append(args, "%"+searchCode+"%")
@@ -744,7 +763,7 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 			item.ScenePicture.String, item.NetworkingProperty.String, item.AccessNetwork,
 			item.IPv4Address, item.IPv6Address.String, item.MACAddress,
 			item.AccessPort.String, item.AssociatedEncoder.String, item.DeviceUsername.String,
-			item.DevicePassword.String, item.ChannelNumber.String, item.ConnectionProtocol.String,
+			auth.MaskDevicePassword(auth.GetCurrentUser(r), item.DevicePassword.String), item.ChannelNumber.String, item.ConnectionProtocol.String,
 			item.EnabledTime.String, item.ScrappedTime.String, item.DeviceStatus,
 			item.InspectionStatus.String, item.VideoLoss.Int64, item.ColorDistortion.Int64,
 			item.VideoBlur.Int64, item.BrightnessException.Int64, item.VideoInterference.Int64,
@@ -761,7 +780,6 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 记录导出操作日志
-	currentUser := auth.GetCurrentUser(r)
 	if currentUser != nil {
 		action := "导出建档明细 Excel"
 		if searchCode != "" || searchName != "" {
@@ -774,7 +792,11 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			action += "）"
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionExport,
+			EntityType: operationlog.EntityAuditDetail,
+			Message:    action,
+		})
 	}
 
 	// 输出文件
@@ -910,7 +932,13 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		action += "）"
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDelete,
+			EntityType: operationlog.EntityAuditDetail,
+			EntityID:   strings.Join(ids, ","),
+			Message:    action,
+			Before:     map[string]interface{}{"device_codes": deviceCodes},
+		})
 	}
 
 	// 保留查询参数
@@ -0,0 +1,204 @@
+package importcheck
+
+import (
+	"fmt"
+	"ops-web/internal/db"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Problem 导入文件中一个单元格的错误
+type Problem struct {
+	Row     int    // Excel 行号（从1开始，表头为第1行）
+	Col     int    // 列索引（从0开始）
+	Column  string // 列名，如 B
+	Label   string // 表头
+	Value   string
+	Message string
+}
+
+// Cell 单元格坐标，如 B3
+func (p Problem) Cell() string {
+	cell, _ := excelize.CoordinatesToCellName(p.Col+1, p.Row)
+	return cell
+}
+
+// Result 导入文件的校验结果：全部数据行的错误，而不是第一个错误
+type Result struct {
+	TotalRows int // 数据行数（不含表头和空行）
+	Problems  []Problem
+	invalid   map[int]bool
+}
+
+// NewResult 创建校验结果
+func NewResult() *Result {
+	return &Result{invalid: map[int]bool{}}
+}
+
+// Add 记录一个错误，row 为 Excel 行号，col 为列索引
+func (r *Result) Add(row, col int, label, value, message string) {
+	column, _ := excelize.ColumnNumberToName(col + 1)
+	r.Problems = append(r.Problems, Problem{
+		Row:     row,
+		Col:     col,
+		Column:  column,
+		Label:   label,
+		Value:   value,
+		Message: message,
+	})
+	r.invalid[row] = true
+}
+
+// OK 全部数据行均通过校验
+func (r *Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Invalid 该行（Excel 行号）是否有错误
+func (r *Result) Invalid(row int) bool {
+	return r.invalid[row]
+}
+
+// InvalidRows 有错误的行数
+func (r *Result) InvalidRows() int {
+	return len(r.invalid)
+}
+
+// ValidRows 通过校验的行数
+func (r *Result) ValidRows() int {
+	return r.TotalRows - len(r.invalid)
+}
+
+// Sort 按行、列排序错误（数据库查重等后加入的错误排到对应行）
+func (r *Result) Sort() {
+	sort.SliceStable(r.Problems, func(i, j int) bool {
+		if r.Problems[i].Row != r.Problems[j].Row {
+			return r.Problems[i].Row < r.Problems[j].Row
+		}
+		return r.Problems[i].Col < r.Problems[j].Col
+	})
+}
+
+// IsBlankRow 整行为空（导入时跳过）
+func IsBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckLength 超过数据库字段长度（按字符计）时返回错误说明
+func CheckLength(value string, max int) string {
+	if max > 0 && utf8.RuneCountInString(value) > max {
+		return fmt.Sprintf("长度不能超过 %d 个字符（当前 %d 个）", max, utf8.RuneCountInString(value))
+	}
+	return ""
+}
+
+// CheckFloatRange 检查数值格式和范围
+func CheckFloatRange(value string, min, max float64) string {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "不是有效的数字"
+	}
+	if v < min || v > max {
+		return fmt.Sprintf("超出范围（%g ~ %g）", min, max)
+	}
+	return ""
+}
+
+// CheckInt 检查整数格式和范围
+func CheckInt(value string, min, max int) string {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return "不是有效的整数"
+	}
+	if v < min || v > max {
+		return fmt.Sprintf("超出范围（%d ~ %d）", min, max)
+	}
+	return ""
+}
+
+// dateLayouts 导入文件中常见的日期写法
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/1/2 15:04:05",
+	"2006/01/02",
+	"2006/1/2",
+	"2006.01.02",
+	"2006.1.2",
+	"20060102",
+	"01-02-06", // Excel 默认日期格式（月-日-年）
+	"1-2-06",
+}
+
+// ParseDate 解析日期，支持 2006-01-02、2006/1/2、RFC3339 等写法
+func ParseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// UniqueChecker 检查文件内的重复值
+type UniqueChecker map[string]int
+
+// Seen 记录 value 所在行，之前出现过时返回第一次出现的行号
+func (u UniqueChecker) Seen(value string, row int) (firstRow int, dup bool) {
+	if first, ok := u[value]; ok {
+		return first, true
+	}
+	u[value] = row
+	return 0, false
+}
+
+// existingBatch 查询已存在编码时每批的数量
+const existingBatch = 500
+
+// ExistingValues 查询 table.column 中已存在的值（用于检查编码是否已导入）
+func ExistingValues(table, column string, values []string) (map[string]bool, error) {
+	existing := map[string]bool{}
+	for start := 0; start < len(values); start += existingBatch {
+		end := start + existingBatch
+		if end > len(values) {
+			end = len(values)
+		}
+		batch := values[start:end]
+		args := make([]interface{}, len(batch))
+		for i, v := range batch {
+			args[i] = v
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)", column, table, column,
+			strings.TrimRight(strings.Repeat("?,", len(batch)), ","))
+		rows, err := db.DBInstance.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			existing[v] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
@@ -0,0 +1,120 @@
+package importcheck
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"ops-web/internal/logger"
+)
+
+// maxShownProblems 校验结果页面最多显示的错误数，全部错误见错误标注文件
+const maxShownProblems = 500
+
+// ReportPage 校验结果页面数据
+type ReportPage struct {
+	Title      string
+	ActiveMenu string
+	SubMenu    string
+
+	FileName     string
+	Organization string
+	TotalRows    int
+	ValidRows    int
+	InvalidRows  int
+	ProblemCount int
+	Problems     []Problem // 最多 maxShownProblems 条
+	MoreProblems int       // 未显示的错误数
+
+	Token        string
+	ErrorFileURL string // 下载错误标注文件
+	ConfirmURL   string // 仅导入有效行（POST）
+	BackURL      string // 返回列表页重新上传
+	CSRFToken    string
+
+	API bool // 通过 API 令牌导入：以 JSON 返回校验结果
+}
+
+// apiReport 通过 API 令牌导入时返回的校验结果
+type apiReport struct {
+	Error        string       `json:"error"`
+	FileName     string       `json:"file_name"`
+	TotalRows    int          `json:"total_rows"`
+	ValidRows    int          `json:"valid_rows"`
+	InvalidRows  int          `json:"invalid_rows"`
+	ProblemCount int          `json:"problem_count"`
+	Problems     []apiProblem `json:"problems"`
+	MoreProblems int          `json:"more_problems"`
+	Token        string       `json:"token"`          // 仅导入有效行时 POST 到 confirm_url 的 token
+	ErrorFileURL string       `json:"error_file_url"` // 下载错误标注文件
+	ConfirmURL   string       `json:"confirm_url"`
+}
+
+type apiProblem struct {
+	Row     int    `json:"row"`
+	Cell    string `json:"cell"`
+	Label   string `json:"label"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// NewReportPage 由校验结果生成页面数据，调用方再填写标题、链接等
+func NewReportPage(result *Result) ReportPage {
+	page := ReportPage{
+		TotalRows:    result.TotalRows,
+		ValidRows:    result.ValidRows(),
+		InvalidRows:  result.InvalidRows(),
+		ProblemCount: len(result.Problems),
+		Problems:     result.Problems,
+	}
+	if len(page.Problems) > maxShownProblems {
+		page.MoreProblems = len(page.Problems) - maxShownProblems
+		page.Problems = page.Problems[:maxShownProblems]
+	}
+	return page
+}
+
+// RenderReport 显示校验结果页面：错误列表、下载错误标注文件、仅导入有效行。
+// 校验未通过时返回 422，脚本不会把未导入的文件当作导入成功；API 令牌请求返回 JSON
+func RenderReport(w http.ResponseWriter, page ReportPage) {
+	if page.API {
+		renderAPIReport(w, page)
+		return
+	}
+
+	tmpl, err := template.ParseFiles("templates/import_check.html")
+	if err != nil {
+		logger.Errorf("导入校验-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := tmpl.Execute(w, page); err != nil {
+		logger.Errorf("导入校验-模板渲染失败: %v", err)
+	}
+}
+
+// renderAPIReport 以 JSON 返回校验结果
+func renderAPIReport(w http.ResponseWriter, page ReportPage) {
+	report := apiReport{
+		Error:        "导入校验未通过，未导入任何数据",
+		FileName:     page.FileName,
+		TotalRows:    page.TotalRows,
+		ValidRows:    page.ValidRows,
+		InvalidRows:  page.InvalidRows,
+		ProblemCount: page.ProblemCount,
+		Problems:     make([]apiProblem, 0, len(page.Problems)),
+		MoreProblems: page.MoreProblems,
+		Token:        page.Token,
+		ErrorFileURL: page.ErrorFileURL,
+		ConfirmURL:   page.ConfirmURL,
+	}
+	for _, p := range page.Problems {
+		report.Problems = append(report.Problems, apiProblem{Row: p.Row, Cell: p.Cell(), Label: p.Label, Value: p.Value, Message: p.Message})
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Errorf("导入校验-返回校验结果失败: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package importcheck
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// 校验未通过的上传文件暂存在内存中，供下载错误标注文件和“仅导入有效行”使用；
+// 服务重启或超过 pendingTTL 后需要重新上传
+const (
+	pendingTTL = 30 * time.Minute
+	maxPending = 50
+)
+
+// Pending 暂存的上传文件和导入表单
+type Pending struct {
+	Owner    string            // 上传用户，只有本人可以下载和继续导入
+	FileName string            // 上传的文件名
+	Data     []byte            // 文件内容
+	Form     map[string]string // 导入表单（机构、档案类型等）
+	Problems []Problem         // 校验发现的错误
+
+	created time.Time
+}
+
+var (
+	pendingMu sync.Mutex
+	pending   = map[string]*Pending{}
+)
+
+// Save 暂存上传文件，返回令牌
+func Save(p *Pending) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	p.created = time.Now()
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	prunePending()
+	pending[token] = p
+	return token, nil
+}
+
+// Load 按令牌取出暂存的文件，令牌不存在、已过期或不属于 owner 时返回 false
+func Load(token, owner string) (*Pending, bool) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	p, ok := pending[token]
+	if !ok || p.Owner != owner || time.Since(p.created) > pendingTTL {
+		return nil, false
+	}
+	return p, true
+}
+
+// Delete 导入完成后删除暂存的文件
+func Delete(token string) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	delete(pending, token)
+}
+
+// prunePending 删除过期的文件，数量超过 maxPending 时删除最早的（调用方持有锁）
+func prunePending() {
+	var oldestToken string
+	var oldest time.Time
+	for token, p := range pending {
+		if time.Since(p.created) > pendingTTL {
+			delete(pending, token)
+			continue
+		}
+		if oldestToken == "" || p.created.Before(oldest) {
+			oldestToken, oldest = token, p.created
+		}
+	}
+	if len(pending) >= maxPending && oldestToken != "" {
+		delete(pending, oldestToken)
+	}
+}
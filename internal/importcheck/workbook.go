@@ -0,0 +1,104 @@
+package importcheck
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 错误标注文件中出错单元格的底色和批注作者
+const (
+	errorFillColor = "FFC7CE"
+	commentAuthor  = "导入校验"
+)
+
+// ErrorWorkbook 在上传文件的副本中标出出错的单元格（红色底色 + 批注说明），
+// 并增加“错误汇总”工作表列出全部错误
+func ErrorWorkbook(data []byte, problems []Problem) (*excelize.File, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	sheet := f.GetSheetName(0)
+
+	// 同一单元格的多个错误合并到一条批注
+	messages := map[string][]string{}
+	var cells []string
+	for _, p := range problems {
+		cell := p.Cell()
+		if _, ok := messages[cell]; !ok {
+			cells = append(cells, cell)
+		}
+		messages[cell] = append(messages[cell], p.Message)
+	}
+
+	styles := map[int]int{} // 原样式 -> 加红色底色后的样式
+	for _, cell := range cells {
+		styleID, err := errorStyle(f, sheet, cell, styles)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.SetCellStyle(sheet, cell, cell, styleID); err != nil {
+			return nil, err
+		}
+		if err := f.AddComment(sheet, excelize.Comment{
+			Author: commentAuthor,
+			Cell:   cell,
+			Text:   strings.Join(messages[cell], "\n"),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := addSummarySheet(f, problems); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// errorStyle 在单元格原有样式（数字格式、边框等）基础上加红色底色
+func errorStyle(f *excelize.File, sheet, cell string, cache map[int]int) (int, error) {
+	base, err := f.GetCellStyle(sheet, cell)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := cache[base]; ok {
+		return id, nil
+	}
+
+	style, err := f.GetStyle(base)
+	if err != nil || style == nil {
+		style = &excelize.Style{}
+	}
+	style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{errorFillColor}}
+	id, err := f.NewStyle(style)
+	if err != nil {
+		return 0, err
+	}
+	cache[base] = id
+	return id, nil
+}
+
+// addSummarySheet 增加“错误汇总”工作表
+func addSummarySheet(f *excelize.File, problems []Problem) error {
+	const summary = "错误汇总"
+	if _, err := f.NewSheet(summary); err != nil {
+		return err
+	}
+	header := []interface{}{"行号", "列", "表头", "单元格内容", "错误说明"}
+	if err := f.SetSheetRow(summary, "A1", &header); err != nil {
+		return err
+	}
+	for i, p := range problems {
+		row := []interface{}{p.Row, p.Column, p.Label, p.Value, p.Message}
+		if err := f.SetSheetRow(summary, fmt.Sprintf("A%d", i+2), &row); err != nil {
+			return err
+		}
+	}
+	f.SetColWidth(summary, "C", "C", 28)
+	f.SetColWidth(summary, "D", "D", 30)
+	f.SetColWidth(summary, "E", "E", 50)
+	return nil
+}
@@ -0,0 +1,120 @@
+package lifecycle
+
+import (
+	"context"
+	"ops-web/internal/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 服务停止分两个阶段：
+//  1. Begin：不再接收新任务（定时任务循环退出，就绪检查返回 503），正在执行的任务继续运行；
+//  2. Wait 等待超时后中断：正在执行的任务收到取消信号，回滚事务、删除未写完的文件后退出。
+var (
+	stopCtx, stop   = context.WithCancel(context.Background())
+	abortCtx, abort = context.WithCancel(context.Background())
+
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	nextID  int
+	running = map[int]string{}
+)
+
+// Context 服务开始停止时取消，定时任务循环据此退出
+func Context() context.Context {
+	return stopCtx
+}
+
+// Stopping 服务是否正在停止
+func Stopping() bool {
+	return stopCtx.Err() != nil
+}
+
+// Go 启动后台任务（定时任务循环等），服务停止时等待其退出
+func Go(name string, fn func(ctx context.Context)) {
+	done := register(name)
+	go func() {
+		defer done()
+		fn(stopCtx)
+	}()
+}
+
+// Track 登记一个正在执行的任务（备份、导入等），服务停止时等待其完成；
+// 返回的 ctx 在等待超时后取消，任务应据此中断并清理，完成后调用 done
+func Track(name string) (ctx context.Context, done func()) {
+	return abortCtx, register(name)
+}
+
+func register(name string) func() {
+	mu.Lock()
+	nextID++
+	id := nextID
+	running[id] = name
+	wg.Add(1)
+	mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(running, id)
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+}
+
+// Running 正在执行的后台任务名称
+func Running() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(running))
+	for _, name := range running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Begin 开始停止服务：通知定时任务退出，不再启动新任务
+func Begin() {
+	stop()
+}
+
+// Wait 等待全部后台任务结束。ctx 到期时取消正在执行的任务，再等待 grace 供其清理，
+// 返回仍未结束的任务名称
+func Wait(ctx context.Context, grace time.Duration) []string {
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+	}
+
+	logger.Warnf("服务停止-等待超时，中断正在执行的任务: %v", Running())
+	abort()
+	select {
+	case <-finished:
+		return nil
+	case <-time.After(grace):
+		return Running()
+	}
+}
+
+// Sleep 等待 d 或 ctx 取消，ctx 取消时返回 false
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
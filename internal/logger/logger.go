@@ -1,73 +1,210 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 )
 
-var (
-	errorLog *log.Logger
-	logFile  *os.File
-)
-
-// InitLogger 初始化日志系统
-func InitLogger() error {
-	// 创建logs目录（如果不存在）
-	logsDir := "logs"
-	if err := os.MkdirAll(logsDir, 0755); err != nil {
-		return fmt.Errorf("创建logs目录失败: %v", err)
-	}
+// Level 日志级别
+type Level int
 
-	// 创建日志文件（按日期命名）
-	logFileName := fmt.Sprintf("ops-web-%s.log", time.Now().Format("2006-01-02"))
-	logFilePath := filepath.Join(logsDir, logFileName)
-
-	// 打开日志文件（追加模式）
-	file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return fmt.Errorf("打开日志文件失败: %v", err)
-	}
-
-	logFile = file
-	errorLog = log.New(file, "", log.LstdFlags|log.Lshortfile)
+const (
+	LevelDebug Level = iota // 调试信息，默认不输出
+	LevelInfo               // 运行信息：定时任务执行、数据处理结果等
+	LevelWarn               // 警告：配置缺失、数据不符合预期但可继续处理
+	LevelError              // 错误：操作失败
+)
 
-	return nil
+var levelNames = map[Level]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
 }
 
-// Error 记录错误日志
-func Error(format string, v ...interface{}) {
-	if errorLog != nil {
-		errorLog.Printf("[ERROR] "+format, v...)
-	}
+// String 级别名称（DEBUG/INFO/WARN/ERROR）
+func (l Level) String() string {
+	return levelNames[l]
 }
 
-// Errorf 记录错误日志（带格式化）
-func Errorf(format string, v ...interface{}) {
-	Error(format, v...)
+// ParseLevel 解析级别名称（不区分大小写），为空时为 info
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("无效的日志级别: %s（可选 debug、info、warn、error）", name)
 }
 
-// Close 关闭日志文件
-func Close() {
-	if logFile != nil {
-		logFile.Close()
-	}
+// Config 日志配置（config.json 的 log 节点）
+type Config struct {
+	Level     string `json:"level"`       // 最低输出级别：debug、info（默认）、warn、error
+	Format    string `json:"format"`      // 输出格式：text（默认）或 json（每行一个 JSON 对象）
+	Dir       string `json:"dir"`         // 日志目录，默认 logs
+	MaxSizeMB int    `json:"max_size_mb"` // 单个文件最大大小（MB），超过后切换新文件，默认 100
+	MaxFiles  int    `json:"max_files"`   // 保留的日志文件个数（含当前文件），默认 30，超出时删除最旧的
 }
 
+// 默认配置
+const (
+	defaultDir       = "logs"
+	defaultMaxSizeMB = 100
+	defaultMaxFiles  = 30
+)
 
+var (
+	mu       sync.Mutex
+	minLevel = LevelInfo
+	jsonMode bool
+	output   *rotateWriter
+)
 
+// InitLogger 按默认配置初始化日志系统（读取配置文件前调用，读取后再调用 Configure）
+func InitLogger() error {
+	return Configure(Config{})
+}
 
+// Configure 按配置重新初始化日志系统，配置无效时保持原有设置
+func Configure(cfg Config) error {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	format := strings.ToLower(strings.TrimSpace(cfg.Format))
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("无效的日志格式: %s（可选 text、json）", cfg.Format)
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = defaultDir
+	}
+	if cfg.MaxSizeMB <= 0 {
+		cfg.MaxSizeMB = defaultMaxSizeMB
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = defaultMaxFiles
+	}
 
+	// 创建logs目录（如果不存在）
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %v", err)
+	}
+	writer := &rotateWriter{
+		dir:      cfg.Dir,
+		maxSize:  int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxFiles: cfg.MaxFiles,
+	}
+	if err := writer.open(time.Now()); err != nil {
+		return err
+	}
 
+	mu.Lock()
+	defer mu.Unlock()
+	if output != nil {
+		output.close()
+	}
+	output = writer
+	minLevel = level
+	jsonMode = format == "json"
+	return nil
+}
 
+// Enabled 是否输出该级别的日志（拼接调试信息代价较大时先判断）
+func Enabled(level Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return output != nil && level >= minLevel
+}
 
+// Debugf 记录调试日志
+func Debugf(format string, v ...interface{}) {
+	write(LevelDebug, fmt.Sprintf(format, v...))
+}
 
+// Infof 记录运行信息
+func Infof(format string, v ...interface{}) {
+	write(LevelInfo, fmt.Sprintf(format, v...))
+}
 
+// Warnf 记录警告日志
+func Warnf(format string, v ...interface{}) {
+	write(LevelWarn, fmt.Sprintf(format, v...))
+}
 
+// Error 记录错误日志
+func Error(format string, v ...interface{}) {
+	write(LevelError, fmt.Sprintf(format, v...))
+}
 
+// Errorf 记录错误日志（带格式化）
+func Errorf(format string, v ...interface{}) {
+	write(LevelError, fmt.Sprintf(format, v...))
+}
 
+// jsonLine JSON 格式的单行日志
+type jsonLine struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Caller    string `json:"caller"`
+	RequestID string `json:"request_id,omitempty"`
+	Message   string `json:"msg"`
+}
 
+// write 输出一行日志，调用位置为 Debugf/Infof 等函数的调用方
+func write(level Level, message string) {
+	now := time.Now()
+	caller := "???:0"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
 
+	mu.Lock()
+	defer mu.Unlock()
+	if output == nil || level < minLevel {
+		return
+	}
+	requestID := currentRequestID()
+	message = redact(message)
+
+	var line []byte
+	if jsonMode {
+		line, _ = json.Marshal(jsonLine{
+			Time:      now.Format("2006-01-02T15:04:05.000Z07:00"),
+			Level:     strings.ToLower(level.String()),
+			Caller:    caller,
+			RequestID: requestID,
+			Message:   message,
+		})
+		line = append(line, '\n')
+	} else {
+		prefix := fmt.Sprintf("%s %s: [%s] ", now.Format("2006/01/02 15:04:05"), caller, level)
+		if requestID != "" {
+			prefix += "[" + requestID + "] "
+		}
+		line = []byte(prefix + strings.TrimRight(message, "\n") + "\n")
+	}
+	if err := output.write(now, line); err != nil {
+		fmt.Fprintf(os.Stderr, "写入日志失败: %v\n%s", err, line)
+	}
+}
 
+// Close 关闭日志文件
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if output != nil {
+		output.close()
+		output = nil
+	}
+}
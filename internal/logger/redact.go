@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedMask 日志中替换敏感值使用的掩码
+const redactedMask = "******"
+
+var (
+	secretMu       sync.RWMutex
+	secretValues   []string
+	secretReplacer *strings.Replacer
+)
+
+// RegisterSecret 登记不允许出现在日志中的敏感值（数据库密码、LDAP 服务账号密码等），
+// 之后写入的日志中出现这些值时替换为 ******。过短的值容易误伤正常内容，不予登记
+func RegisterSecret(values ...string) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+	for _, value := range values {
+		if len(value) < 4 {
+			continue
+		}
+		exists := false
+		for _, v := range secretValues {
+			if v == value {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			secretValues = append(secretValues, value)
+		}
+	}
+	if len(secretValues) == 0 {
+		return
+	}
+
+	// 较长的值优先替换，避免其中包含的较短敏感值先被替换后长值残留
+	sorted := append([]string(nil), secretValues...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	pairs := make([]string, 0, len(sorted)*2)
+	for _, v := range sorted {
+		pairs = append(pairs, v, redactedMask)
+	}
+	secretReplacer = strings.NewReplacer(pairs...)
+}
+
+// redact 替换日志内容中已登记的敏感值
+func redact(message string) string {
+	secretMu.RLock()
+	replacer := secretReplacer
+	secretMu.RUnlock()
+	if replacer == nil {
+		return message
+	}
+	return replacer.Replace(message)
+}
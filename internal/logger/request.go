@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// 请求ID与处理请求的 goroutine 绑定：net/http 在单独的 goroutine 中同步处理每个请求，
+// 绑定后该 goroutine 上的所有日志自动带上请求ID，各处调用 Errorf 等无需传入请求。
+// 处理函数另起的 goroutine 不继承请求ID
+var requestIDs sync.Map // goroutine ID -> 请求ID
+
+// BindRequestID 将请求ID绑定到当前 goroutine，返回的函数用于请求结束时解除绑定
+func BindRequestID(id string) func() {
+	gid := goroutineID()
+	if gid == 0 {
+		return func() {}
+	}
+	requestIDs.Store(gid, id)
+	return func() { requestIDs.Delete(gid) }
+}
+
+// currentRequestID 当前 goroutine 正在处理的请求ID，不在请求中时返回空字符串
+func currentRequestID() string {
+	id, ok := requestIDs.Load(goroutineID())
+	if !ok {
+		return ""
+	}
+	return id.(string)
+}
+
+// goroutineID 从调用栈首行（goroutine 123 [running]:）读取当前 goroutine ID，失败时返回 0
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	field := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(field, ' '); i > 0 {
+		field = field[:i]
+	}
+	id, err := strconv.ParseUint(string(field), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
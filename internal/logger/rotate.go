@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rotateWriter 按日期和大小切换的日志文件
+// 当前文件为 ops-web-YYYY-MM-DD.log；跨天时切换到新日期的文件，
+// 超过大小上限时将当前文件重命名为 ops-web-YYYY-MM-DD.N.log（N 递增）后重新创建。
+// 每次切换后只保留最新的 maxFiles 个日志文件。调用方负责加锁
+type rotateWriter struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	date string // 当前文件的日期
+	size int64  // 当前文件大小
+}
+
+// fileName 某日期的当前日志文件路径
+func (w *rotateWriter) fileName(date string) string {
+	return filepath.Join(w.dir, "ops-web-"+date+".log")
+}
+
+// open 打开（追加）指定时间对应日期的日志文件
+func (w *rotateWriter) open(now time.Time) error {
+	date := now.Format("2006-01-02")
+	file, err := os.OpenFile(w.fileName(date), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件信息失败: %v", err)
+	}
+
+	w.close()
+	w.file = file
+	w.date = date
+	w.size = info.Size()
+	return nil
+}
+
+// write 写入一行日志，必要时先切换文件
+func (w *rotateWriter) write(now time.Time, line []byte) error {
+	if w.file == nil || now.Format("2006-01-02") != w.date {
+		if err := w.open(now); err != nil {
+			return err
+		}
+		w.cleanup()
+	} else if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotateBySize(now); err != nil {
+			return err
+		}
+		w.cleanup()
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// rotateBySize 当前文件超过大小上限：重命名为当天的下一个序号后重新创建
+func (w *rotateWriter) rotateBySize(now time.Time) error {
+	next := 1
+	matches, _ := filepath.Glob(filepath.Join(w.dir, "ops-web-"+w.date+".*.log"))
+	for _, path := range matches {
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(filepath.Base(path), "ops-web-"+w.date+"."), "%d.log", &n); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+
+	w.close()
+	archived := filepath.Join(w.dir, fmt.Sprintf("ops-web-%s.%d.log", w.date, next))
+	if err := os.Rename(w.fileName(w.date), archived); err != nil {
+		return fmt.Errorf("重命名日志文件失败: %v", err)
+	}
+	return w.open(now)
+}
+
+// cleanup 删除超出保留个数的旧日志文件（按修改时间，保留最新的）
+func (w *rotateWriter) cleanup() {
+	matches, err := filepath.Glob(filepath.Join(w.dir, "ops-web-*.log"))
+	if err != nil || len(matches) <= w.maxFiles {
+		return
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	current := w.fileName(w.date)
+	for _, path := range matches {
+		if path == current {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{path, info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime.Equal(files[j].modTime) {
+			return strings.Compare(files[i].path, files[j].path) > 0
+		}
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	// 当前文件占一个名额
+	for i := w.maxFiles - 1; i < len(files); i++ {
+		os.Remove(files[i].path)
+	}
+}
+
+// close 关闭当前文件
+func (w *rotateWriter) close() {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+}
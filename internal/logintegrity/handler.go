@@ -0,0 +1,168 @@
+package logintegrity
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"ops-web/internal/auth"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strings"
+	"time"
+)
+
+// PageData 页面数据
+type PageData struct {
+	Title       string
+	ActiveMenu  string
+	SubMenu     string
+	HeadID      int64                     // 链头记录的最新日志ID
+	Checkpoints []operationlog.Checkpoint // 归档检查点
+	Result      *operationlog.VerifyResult
+	CanArchive  bool   // 是否有归档权限（系统设置权限）
+	ArchivePath string // 归档目录（备份路径下的 operation_logs）
+	Message     string
+	MessageType string // success, error
+	CSRFToken   string
+}
+
+// Handler 操作日志完整性校验页面，run=1 时执行哈希链校验
+func Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	checkpoints, err := operationlog.ListCheckpoints()
+	if err != nil {
+		logger.Errorf("日志校验-查询归档检查点失败: %v", err)
+		http.Error(w, "查询归档检查点失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:       "操作日志完整性校验",
+		ActiveMenu:  "settings",
+		SubMenu:     "logs",
+		Checkpoints: checkpoints,
+		CanArchive:  currentUser.Can(auth.PermSystemManage),
+		Message:     r.URL.Query().Get("message"),
+		MessageType: r.URL.Query().Get("type"),
+		CSRFToken:   auth.CSRFToken(r),
+	}
+	if backupPath := getSetting("backup_file_path"); backupPath != "" {
+		data.ArchivePath = strings.TrimRight(backupPath, "/\\") + "/operation_logs"
+	}
+	if err := db.DBInstance.QueryRow("SELECT last_id FROM operation_log_chain WHERE id = 1").Scan(&data.HeadID); err != nil {
+		logger.Errorf("日志校验-查询链头失败: %v", err)
+	}
+
+	if r.URL.Query().Get("run") == "1" {
+		result, err := operationlog.VerifyChain()
+		if err != nil {
+			logger.Errorf("日志校验-校验失败: %v, 用户: %s", err, currentUser.Username)
+			http.Error(w, "校验失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Result = result
+
+		entry := operationlog.Entry{
+			Action:     operationlog.ActionVerify,
+			EntityType: operationlog.EntityOperationLog,
+			Message:    fmt.Sprintf("校验操作日志哈希链（已校验 %d 条，链完整）", result.Checked),
+		}
+		if !result.OK() {
+			logger.Errorf("日志校验-哈希链断开: 记录ID %d, %s", result.BrokenID, result.Reason)
+			entry.EntityID = fmt.Sprint(result.BrokenID)
+			entry.Outcome = operationlog.OutcomeFailure
+			entry.Message = fmt.Sprintf("校验操作日志哈希链（记录 ID %d 处断链：%s）", result.BrokenID, result.Reason)
+		}
+		operationlog.Record(r, currentUser.Username, entry)
+	}
+
+	tmpl, err := template.ParseFiles("templates/logverify.html")
+	if err != nil {
+		logger.Errorf("日志校验-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		logger.Errorf("日志校验-模板渲染失败: %v", err)
+	}
+}
+
+// ArchiveHandler 归档指定日期之前的操作日志（需要系统设置权限）
+func ArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.GetCurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	before, err := time.ParseInLocation("2006-01-02", r.FormValue("before"), time.Local)
+	if err != nil {
+		redirectWithMessage(w, r, "请选择归档截止日期", "error")
+		return
+	}
+	if !before.Before(time.Now()) {
+		redirectWithMessage(w, r, "归档截止日期须早于今天", "error")
+		return
+	}
+
+	backupPath := getSetting("backup_file_path")
+	if backupPath == "" {
+		redirectWithMessage(w, r, "请先在任务配置中设置备份路径", "error")
+		return
+	}
+
+	checkpoint, err := operationlog.Archive(before, backupPath, currentUser.Username)
+	if err != nil {
+		logger.Errorf("日志归档-归档失败: %v, 用户: %s", err, currentUser.Username)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionArchive,
+			EntityType: operationlog.EntityOperationLog,
+			Outcome:    operationlog.OutcomeFailure,
+			Message:    "归档 " + before.Format("2006-01-02") + " 之前的操作日志失败：" + err.Error(),
+		})
+		redirectWithMessage(w, r, "归档失败："+err.Error(), "error")
+		return
+	}
+
+	message := fmt.Sprintf("归档 %s 之前的操作日志 %d 条（至记录 ID %d，归档文件：%s）",
+		before.Format("2006-01-02"), checkpoint.ArchivedCount, checkpoint.LastID, checkpoint.ArchiveFile)
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionArchive,
+		EntityType: operationlog.EntityOperationLog,
+		EntityID:   fmt.Sprint(checkpoint.LastID),
+		Message:    message,
+		After:      checkpoint,
+	})
+
+	redirectWithMessage(w, r, message, "success")
+}
+
+// redirectWithMessage 返回校验页面并显示提示消息
+func redirectWithMessage(w http.ResponseWriter, r *http.Request, message, messageType string) {
+	http.Redirect(w, r, "/logs/verify?message="+url.QueryEscape(message)+"&type="+messageType, http.StatusFound)
+}
+
+// getSetting 获取参数值
+func getSetting(key string) string {
+	var value string
+	query := "SELECT param_value FROM system_settings WHERE param_key = ?"
+	err := db.DBInstance.QueryRow(query, key).Scan(&value)
+	if err != nil {
+		// 参数不存在时返回空字符串
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,673 @@
+package operationlog
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 操作日志哈希链：每条日志保存前一条日志的哈希（prev_hash）及本条日志的 HMAC-SHA256（hash），
+// 密钥只保存在服务器上（config.json 的 operation_log_key 或配置文件所在目录的 operation_log.key 文件），
+// 直接在数据库中修改、删除或插入日志都会导致校验不通过。
+// operation_log_chain 表保存链头（最新一条日志的ID和哈希），用于发现末尾日志被删除；
+// 归档旧日志时在 operation_log_checkpoints 表记录检查点，之后从检查点开始校验。
+// 链头和检查点同样带 HMAC 校验值（mac），直接回退链头或伪造检查点也会导致校验不通过。
+
+// chainKeyFileName 未在 config.json 中配置密钥时使用的密钥文件，放在配置文件所在目录，首次启动时自动生成
+const chainKeyFileName = "operation_log.key"
+
+// legacyChainKeyFile 早期版本相对工作目录保存的密钥文件，配置文件目录中没有密钥文件时仍读取
+const legacyChainKeyFile = "config/operation_log.key"
+
+var (
+	chainKey []byte
+	chainMu  sync.Mutex // 保证同一进程内日志按顺序写入哈希链
+)
+
+// chainRow 参与哈希计算的日志字段
+type chainRow struct {
+	ID         int64
+	Username   string
+	Action     string
+	IP         string
+	CreatedAt  time.Time
+	ActionCode string
+	EntityType string
+	EntityID   string
+	Outcome    string
+	Detail     string
+	PrevHash   string
+	Hash       string
+}
+
+// computeHash 计算日志哈希：HMAC-SHA256(密钥, 前一条哈希 + 本条各字段)
+func (row chainRow) computeHash(key []byte, prevHash string) string {
+	return hmacFields(key,
+		prevHash,
+		strconv.FormatInt(row.ID, 10),
+		row.Username,
+		row.Action,
+		row.IP,
+		row.CreatedAt.Format("2006-01-02 15:04:05"),
+		row.ActionCode,
+		row.EntityType,
+		row.EntityID,
+		row.Outcome,
+		row.Detail,
+	)
+}
+
+// headMAC 链头校验值：HMAC-SHA256(密钥, 最新日志ID + 哈希)
+func headMAC(key []byte, lastID int64, lastHash string) string {
+	return hmacFields(key, "operation_log_chain", strconv.FormatInt(lastID, 10), lastHash)
+}
+
+// computeMAC 检查点校验值：HMAC-SHA256(密钥, 检查点各字段)
+func (cp Checkpoint) computeMAC(key []byte) string {
+	return hmacFields(key, "operation_log_checkpoint", strconv.FormatInt(cp.LastID, 10), cp.LastHash,
+		strconv.Itoa(cp.ArchivedCount), cp.ArchiveFile, cp.CreatedBy)
+}
+
+// hmacFields 各字段加引号后以 | 连接，计算 HMAC-SHA256
+func hmacFields(key []byte, fields ...string) string {
+	for i, field := range fields {
+		fields[i] = strconv.Quote(field)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validMAC 校验值是否正确（为空视为不正确）
+func validMAC(got, want string) bool {
+	return got != "" && hmac.Equal([]byte(got), []byte(want))
+}
+
+// chainKeyFile 密钥文件路径：与配置文件（-config 参数或 OPSWEB_CONFIG 环境变量指定）在同一目录，
+// 不随启动时的工作目录变化
+func chainKeyFile() string {
+	return filepath.Join(filepath.Dir(db.ConfigPath), chainKeyFileName)
+}
+
+// readKeyFile 读取密钥文件
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return nil, fmt.Errorf("密钥文件 %s 为空", path)
+	}
+	return []byte(key), nil
+}
+
+// loadChainKey 读取哈希链密钥，create 为 true 且未配置密钥时生成密钥文件
+func loadChainKey(create bool) ([]byte, error) {
+	if key := strings.TrimSpace(db.AppConfig.OperationLogKey); key != "" {
+		return []byte(key), nil
+	}
+
+	keyFile := chainKeyFile()
+	key, err := readKeyFile(keyFile)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取密钥文件 %s 失败: %v", keyFile, err)
+	}
+
+	// 早期版本的密钥文件在工作目录下的 config 目录，配置文件不在该目录时继续使用旧文件，避免生成新密钥后已有日志全部校验失败
+	if legacy, err := filepath.Abs(legacyChainKeyFile); err == nil {
+		if current, err := filepath.Abs(keyFile); err == nil && legacy != current {
+			if key, err := readKeyFile(legacy); err == nil {
+				logger.Warnf("操作日志哈希链-使用旧位置的密钥文件 %s，请将其移动到配置文件所在目录: %s", legacy, keyFile)
+				return key, nil
+			}
+		}
+	}
+	if !create {
+		return nil, fmt.Errorf("读取密钥文件 %s 失败: %v", keyFile, err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	newKey := hex.EncodeToString(buf)
+	if err := os.WriteFile(keyFile, []byte(newKey+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("生成密钥文件 %s 失败: %v", keyFile, err)
+	}
+	logger.Infof("操作日志哈希链-已生成密钥文件: %s", keyFile)
+	return []byte(newKey), nil
+}
+
+// currentKey 服务进程中使用已加载的密钥，命令行校验时读取密钥（不生成）
+func currentKey() ([]byte, error) {
+	if chainKey != nil {
+		return chainKey, nil
+	}
+	return loadChainKey(false)
+}
+
+// InitChain 加载哈希链密钥；首次启用时为已有日志依次计算哈希并建立链头
+func InitChain() error {
+	key, err := loadChainKey(true)
+	if err != nil {
+		return err
+	}
+	chainKey = key
+
+	chainMu.Lock()
+	defer chainMu.Unlock()
+
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT IGNORE INTO operation_log_chain (id, last_id, last_hash) VALUES (1, 0, '')")
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		// 链头已存在，不再为新出现的无哈希记录补算（这些记录只可能是直接写入数据库的）
+		return nil
+	}
+
+	// 已有带哈希的日志却没有链头：链头被删除，重新计算会掩盖对历史日志的篡改，
+	// 保留没有校验值的空链头，校验时报告，核查后执行 ops-web verify-log seal
+	var hashed int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM operation_logs WHERE hash IS NOT NULL").Scan(&hashed); err != nil {
+		return err
+	}
+	if hashed > 0 {
+		logger.Errorf("操作日志哈希链-链头记录丢失（已有 %d 条日志带哈希），不重新计算哈希，请执行 ops-web verify-log 核查", hashed)
+		return tx.Commit()
+	}
+
+	// 首次启用：按ID顺序为升级前的历史日志计算哈希
+	rows, err := tx.Query("SELECT " + chainColumns + " FROM operation_logs ORDER BY id")
+	if err != nil {
+		return err
+	}
+	var history []chainRow
+	for rows.Next() {
+		row, err := scanChainRow(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		history = append(history, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var lastID int64
+	lastHash := ""
+	for _, row := range history {
+		hash := row.computeHash(chainKey, lastHash)
+		if _, err := tx.Exec("UPDATE operation_logs SET prev_hash = ?, hash = ? WHERE id = ?", lastHash, hash, row.ID); err != nil {
+			return err
+		}
+		lastID, lastHash = row.ID, hash
+	}
+	if _, err := tx.Exec("UPDATE operation_log_chain SET last_id = ?, last_hash = ?, mac = ? WHERE id = 1",
+		lastID, lastHash, headMAC(chainKey, lastID, lastHash)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertChained 写入一条日志并接到哈希链末尾
+func insertChained(row chainRow) error {
+	if chainKey == nil {
+		return errors.New("哈希链密钥未加载")
+	}
+
+	chainMu.Lock()
+	defer chainMu.Unlock()
+
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 锁定链头，多个进程同时写入时也按顺序接链
+	var lastID int64
+	var lastHash, lastMAC string
+	err = tx.QueryRow("SELECT last_id, last_hash, mac FROM operation_log_chain WHERE id = 1 FOR UPDATE").Scan(&lastID, &lastHash, &lastMAC)
+	if err != nil {
+		return fmt.Errorf("读取链头失败: %v", err)
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO operation_logs (username, action, ip, created_at, action_code, entity_type, entity_id, outcome, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		row.Username, row.Action, row.IP, row.CreatedAt, row.ActionCode, row.EntityType, row.EntityID, row.Outcome,
+		sql.NullString{String: row.Detail, Valid: row.Detail != ""},
+	)
+	if err != nil {
+		return err
+	}
+	if row.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	hash := row.computeHash(chainKey, lastHash)
+	if _, err := tx.Exec("UPDATE operation_logs SET prev_hash = ?, hash = ? WHERE id = ?", lastHash, hash, row.ID); err != nil {
+		return err
+	}
+
+	// 链头校验值不正确（链头被直接修改或清空）时新链头也不签名，否则下一条日志就会掩盖篡改
+	mac := ""
+	if validMAC(lastMAC, headMAC(chainKey, lastID, lastHash)) {
+		mac = headMAC(chainKey, row.ID, hash)
+	} else {
+		logger.Errorf("操作日志哈希链-链头校验值不正确，链头可能被篡改，请执行 ops-web verify-log 核查")
+	}
+	if _, err := tx.Exec("UPDATE operation_log_chain SET last_id = ?, last_hash = ?, mac = ? WHERE id = 1", row.ID, hash, mac); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// chainColumns 校验哈希链需要读取的字段
+const chainColumns = "id, username, action, ip, created_at, action_code, entity_type, entity_id, outcome, detail, prev_hash, hash"
+
+// rowScanner 由 *sql.Rows 和 *sql.Row 实现
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanChainRow 读取一行日志的哈希链字段
+func scanChainRow(rows rowScanner) (chainRow, error) {
+	var row chainRow
+	var ip, detail, prevHash, hash sql.NullString
+	err := rows.Scan(&row.ID, &row.Username, &row.Action, &ip, &row.CreatedAt,
+		&row.ActionCode, &row.EntityType, &row.EntityID, &row.Outcome, &detail, &prevHash, &hash)
+	row.IP = ip.String
+	row.Detail = detail.String
+	row.PrevHash = prevHash.String
+	row.Hash = hash.String
+	return row, err
+}
+
+// Checkpoint 归档检查点：归档后从该检查点开始校验哈希链
+type Checkpoint struct {
+	ID            int
+	LastID        int64  // 已归档的最后一条日志ID
+	LastHash      string // 已归档的最后一条日志哈希
+	ArchivedCount int    // 本次归档的日志条数
+	ArchiveFile   string // 归档文件路径
+	CreatedBy     string
+	CreatedAt     string
+
+	mac string // 检查点校验值
+}
+
+// ListCheckpoints 查询全部归档检查点（最新的在前）
+func ListCheckpoints() ([]Checkpoint, error) {
+	rows, err := db.DBInstance.Query(`
+		SELECT id, last_id, last_hash, archived_count, archive_file, created_by, created_at, mac
+		FROM operation_log_checkpoints ORDER BY last_id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []Checkpoint
+	for rows.Next() {
+		var cp Checkpoint
+		var createdAt time.Time
+		if err := rows.Scan(&cp.ID, &cp.LastID, &cp.LastHash, &cp.ArchivedCount, &cp.ArchiveFile, &cp.CreatedBy, &createdAt, &cp.mac); err != nil {
+			return nil, err
+		}
+		cp.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
+
+// VerifyResult 哈希链校验结果
+type VerifyResult struct {
+	Checkpoint *Checkpoint // 校验起点（为空时从第一条日志开始）
+	Checked    int         // 已校验的日志条数
+	HeadID     int64       // 链头记录的最新日志ID
+	BrokenID   int64       // 第一处断链的日志ID，链完整时为 0
+	Reason     string      // 断链原因
+}
+
+// OK 哈希链是否完整
+func (v *VerifyResult) OK() bool {
+	return v.Reason == ""
+}
+
+// VerifyChain 校验全部归档检查点的校验值，再从最近的检查点开始逐条校验哈希链，最后校验链头，返回第一处断链
+func VerifyChain() (*VerifyResult, error) {
+	key, err := currentKey()
+	if err != nil {
+		return nil, err
+	}
+	return verifyChain(key, true)
+}
+
+// verifyChain 校验哈希链，checkMACs 为 false 时不校验链头和检查点的校验值（seal 签名前使用）
+func verifyChain(key []byte, checkMACs bool) (*VerifyResult, error) {
+	var headID int64
+	var headHash, headSeal string
+	err := db.DBInstance.QueryRow("SELECT last_id, last_hash, mac FROM operation_log_chain WHERE id = 1").Scan(&headID, &headHash, &headSeal)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("哈希链尚未初始化，请先启动一次服务")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := ListCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	result := &VerifyResult{HeadID: headID}
+	if len(checkpoints) > 0 {
+		result.Checkpoint = &checkpoints[0]
+	}
+
+	// 伪造的检查点可以让校验跳过被删除的旧日志，须先确认全部检查点都由系统写入
+	if checkMACs {
+		for _, c := range checkpoints {
+			if validMAC(c.mac, c.computeMAC(key)) {
+				continue
+			}
+			result.BrokenID = c.LastID
+			if c.mac == "" {
+				result.Reason = fmt.Sprintf("归档检查点（记录 ID %d，%s 归档）没有校验值，可能是直接在数据库中插入的（升级后请先执行 ops-web verify-log seal）", c.LastID, c.CreatedAt)
+			} else {
+				result.Reason = fmt.Sprintf("归档检查点（记录 ID %d，%s 归档）校验值不正确，检查点可能被伪造或修改", c.LastID, c.CreatedAt)
+			}
+			return result, nil
+		}
+	}
+	cp := result.Checkpoint
+
+	var lastID int64
+	lastHash := ""
+	if cp != nil {
+		lastID, lastHash = cp.LastID, cp.LastHash
+	}
+
+	rows, err := db.DBInstance.Query("SELECT "+chainColumns+" FROM operation_logs WHERE id > ? ORDER BY id", lastID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scanChainRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case row.Hash == "":
+			result.BrokenID, result.Reason = row.ID, "记录没有哈希，不是由系统写入的（可能在数据库中直接插入）"
+		case row.PrevHash != lastHash:
+			result.BrokenID, result.Reason = row.ID, fmt.Sprintf("与前一条记录（ID %d）的哈希不衔接，两者之间的记录可能被删除或插入", lastID)
+		case !hmac.Equal([]byte(row.computeHash(key, lastHash)), []byte(row.Hash)):
+			result.BrokenID, result.Reason = row.ID, "哈希校验失败，记录内容可能被修改"
+		}
+		if result.BrokenID != 0 {
+			return result, nil
+		}
+		result.Checked++
+		lastID, lastHash = row.ID, row.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case lastID != headID || lastHash != headHash:
+		result.BrokenID = headID
+		result.Reason = fmt.Sprintf("最后一条记录（ID %d）与链头记录的最新日志（ID %d）不一致，末尾的记录可能被删除", lastID, headID)
+	case checkMACs && headSeal == "":
+		result.BrokenID = headID
+		result.Reason = "链头没有校验值，链头可能被清空或删除（升级后请先执行 ops-web verify-log seal）"
+	case checkMACs && !validMAC(headSeal, headMAC(key, headID, headHash)):
+		result.BrokenID = headID
+		result.Reason = "链头校验值不正确，链头可能被直接修改（如删除末尾日志后回退链头）"
+	}
+	return result, nil
+}
+
+// Seal 为链头和没有校验值的归档检查点计算校验值：升级到带校验值的版本后执行一次，
+// 或核查链头被篡改的原因后重新签名。哈希链本身断开或存在校验值不正确的检查点时拒绝执行
+func Seal(out io.Writer) error {
+	key, err := currentKey()
+	if err != nil {
+		return err
+	}
+	result, err := verifyChain(key, false)
+	if err != nil {
+		return err
+	}
+	if !result.OK() {
+		return fmt.Errorf("哈希链在记录 ID %d 处断开（%s），请先核查", result.BrokenID, result.Reason)
+	}
+
+	checkpoints, err := ListCheckpoints()
+	if err != nil {
+		return err
+	}
+	var unsigned []Checkpoint
+	for _, c := range checkpoints {
+		switch {
+		case c.mac == "":
+			unsigned = append(unsigned, c)
+		case !validMAC(c.mac, c.computeMAC(key)):
+			return fmt.Errorf("归档检查点（ID %d，记录 ID %d）校验值不正确，请核查后删除伪造的检查点", c.ID, c.LastID)
+		}
+	}
+
+	chainMu.Lock()
+	defer chainMu.Unlock()
+
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var headID int64
+	var headHash string
+	if err := tx.QueryRow("SELECT last_id, last_hash FROM operation_log_chain WHERE id = 1 FOR UPDATE").Scan(&headID, &headHash); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE operation_log_chain SET mac = ? WHERE id = 1", headMAC(key, headID, headHash)); err != nil {
+		return err
+	}
+	for _, c := range unsigned {
+		if _, err := tx.Exec("UPDATE operation_log_checkpoints SET mac = ? WHERE id = ?", c.computeMAC(key), c.ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "链头: 记录 ID %d\n", headID)
+	for _, c := range unsigned {
+		fmt.Fprintf(out, "归档检查点: 记录 ID %d（%s 由 %s 归档，%d 条，%s）\n", c.LastID, c.CreatedAt, c.CreatedBy, c.ArchivedCount, c.ArchiveFile)
+	}
+	return nil
+}
+
+// archiveHeaders 归档文件的表头（保留哈希，归档后仍可离线核对）
+var archiveHeaders = []string{"ID", "时间", "用户", "IP", "操作", "类型", "对象类型", "对象ID", "结果", "修改前后", "前一条哈希", "哈希"}
+
+// Archive 将 before 之前的日志导出到 dir 下的 CSV 文件并从数据库删除，同时记录检查点；
+// 哈希链在归档范围内不完整时拒绝归档，避免被篡改的记录随归档一起消失
+func Archive(before time.Time, dir, username string) (*Checkpoint, error) {
+	result, err := VerifyChain()
+	if err != nil {
+		return nil, err
+	}
+
+	var fromID int64
+	if result.Checkpoint != nil {
+		fromID = result.Checkpoint.LastID
+	}
+	var lastID sql.NullInt64
+	err = db.DBInstance.QueryRow("SELECT MAX(id) FROM operation_logs WHERE id > ? AND created_at < ?", fromID, before).Scan(&lastID)
+	if err != nil {
+		return nil, err
+	}
+	if !lastID.Valid {
+		return nil, fmt.Errorf("%s 之前没有需要归档的日志", before.Format("2006-01-02"))
+	}
+	if !result.OK() && result.BrokenID <= lastID.Int64 {
+		return nil, fmt.Errorf("哈希链在记录 ID %d 处断开（%s），请先核查后再归档", result.BrokenID, result.Reason)
+	}
+
+	rows, err := db.DBInstance.Query("SELECT "+chainColumns+" FROM operation_logs WHERE id > ? AND id <= ? ORDER BY id", fromID, lastID.Int64)
+	if err != nil {
+		return nil, err
+	}
+	var archived []chainRow
+	for rows.Next() {
+		row, err := scanChainRow(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		archived = append(archived, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 1. 写入归档文件
+	archiveDir := filepath.Join(dir, "operation_logs")
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %v", err)
+	}
+	fileName := filepath.Join(archiveDir, fmt.Sprintf("operation_logs_%d-%d_%s.csv",
+		archived[0].ID, lastID.Int64, time.Now().Format("20060102_150405")))
+	if err := writeArchive(fileName, archived); err != nil {
+		return nil, fmt.Errorf("写入归档文件失败: %v", err)
+	}
+
+	// 2. 记录检查点（带校验值）并删除已归档的日志
+	last := archived[len(archived)-1]
+	cp := &Checkpoint{
+		LastID:        last.ID,
+		LastHash:      last.Hash,
+		ArchivedCount: len(archived),
+		ArchiveFile:   fileName,
+		CreatedBy:     username,
+	}
+	key, err := currentKey()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.DBInstance.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO operation_log_checkpoints (last_id, last_hash, archived_count, archive_file, created_by, mac)
+		VALUES (?, ?, ?, ?, ?, ?)`, cp.LastID, cp.LastHash, cp.ArchivedCount, cp.ArchiveFile, cp.CreatedBy, cp.computeMAC(key))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM operation_logs WHERE id > ? AND id <= ?", fromID, last.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	cp.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+	return cp, nil
+}
+
+// writeArchive 将日志写入 CSV 文件（带 UTF-8 BOM，Excel 可直接打开）
+func writeArchive(fileName string, rows []chainRow) error {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	file.Write([]byte("\xEF\xBB\xBF"))
+	writer := csv.NewWriter(file)
+	writer.Write(archiveHeaders)
+	for _, row := range rows {
+		writer.Write([]string{
+			strconv.FormatInt(row.ID, 10), row.CreatedAt.Format("2006-01-02 15:04:05"), row.Username, row.IP,
+			row.Action, row.ActionCode, row.EntityType, row.EntityID, row.Outcome, row.Detail, row.PrevHash, row.Hash,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// VerifyCommand 命令行校验哈希链（ops-web verify-log），返回进程退出码：0=完整，1=断链，2=校验出错；
+// ops-web verify-log seal 为链头和没有校验值的检查点计算校验值（0=成功，2=失败）
+func VerifyCommand(args []string, out io.Writer) int {
+	if len(args) > 0 {
+		if args[0] != "seal" {
+			fmt.Fprintln(out, "用法: ops-web verify-log [seal]")
+			return 2
+		}
+		if err := Seal(out); err != nil {
+			fmt.Fprintf(out, "签名失败: %v\n", err)
+			return 2
+		}
+		fmt.Fprintln(out, "结果: 已为以上链头和检查点计算校验值")
+		return 0
+	}
+
+	result, err := VerifyChain()
+	if err != nil {
+		fmt.Fprintf(out, "校验失败: %v\n", err)
+		return 2
+	}
+
+	if result.Checkpoint != nil {
+		fmt.Fprintf(out, "校验起点: 归档检查点（记录 ID %d，%s 归档）\n", result.Checkpoint.LastID, result.Checkpoint.CreatedAt)
+	} else {
+		fmt.Fprintln(out, "校验起点: 第一条记录")
+	}
+	fmt.Fprintf(out, "链头记录: ID %d\n", result.HeadID)
+	fmt.Fprintf(out, "已校验: %d 条\n", result.Checked)
+	if !result.OK() {
+		fmt.Fprintf(out, "结果: 哈希链断开，第一处断链位于记录 ID %d：%s\n", result.BrokenID, result.Reason)
+		return 1
+	}
+	fmt.Fprintln(out, "结果: 哈希链完整")
+	return 0
+}
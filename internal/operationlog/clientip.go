@@ -0,0 +1,106 @@
+package operationlog
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedProxiesMu sync.RWMutex
+	trustedProxies   []*net.IPNet
+)
+
+// SetTrustedProxies 设置可信反向代理（IP 或网段），只有来自这些地址的请求才采用 X-Forwarded-For
+func SetTrustedProxies(proxies []string) error {
+	nets, err := ParseProxies(proxies)
+	if err != nil {
+		return err
+	}
+	trustedProxiesMu.Lock()
+	trustedProxies = nets
+	trustedProxiesMu.Unlock()
+	return nil
+}
+
+// ParseProxies 解析可信代理列表，单个 IP 视为 /32（IPv6 为 /128）
+func ParseProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("不是有效的 IP 或网段: %s", p)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("不是有效的 IP 或网段: %s", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// isTrustedProxy 地址是否为可信代理
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP 直接连接的对端地址（不看请求头）
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ClientIP 获取客户端 IP：对端不是可信代理时直接使用对端地址（客户端可以任意伪造 X-Forwarded-For）；
+// 对端是可信代理时从 X-Forwarded-For 末尾向前跳过可信代理，取第一个不可信的地址
+func ClientIP(r *http.Request) string {
+	remote := remoteIP(r)
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+	xff := r.Header.Values("X-Forwarded-For")
+	var hops []string
+	for _, v := range xff {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return client
+}
@@ -1,26 +1,212 @@
 package operationlog
 
 import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"ops-web/internal/db"
 	"ops-web/internal/logger"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/xuri/excelize/v2"
 )
 
+// 每页显示的日志条数
+const logPageSize = 30
+
 // PageData 页面数据
 type PageData struct {
-	Title      string
-	ActiveMenu string
-	SubMenu    string
-	Logs       []LogEntry
+	Title       string
+	ActiveMenu  string
+	SubMenu     string
+	Logs        []LogEntry
+	Filter      LogFilter
+	ActionTypes []Option
+	Outcomes    []Option
+	CurrentPage int
+	TotalPages  int
+	TotalCount  int // 总记录数
+	StartRecord int // 当前页起始记录号
+	EndRecord   int // 当前页结束记录号
+	HasPrev     bool
+	HasNext     bool
+	PrevPage    int
+	NextPage    int
+	FirstPage   int
+	LastPage    int
+	Query       string // 查询条件（用于分页和导出链接）
+}
+
+// LogFilter 操作日志查询条件
+type LogFilter struct {
+	Username   string // 用户名（模糊匹配）
+	StartDate  string // 开始日期 YYYY-MM-DD（含）
+	EndDate    string // 结束日期 YYYY-MM-DD（含）
+	IP         string // IP（前缀匹配）
+	Keyword    string // 操作内容关键字
+	ActionCode string // 操作类型
+	Outcome    string // 操作结果
+}
+
+// parseLogFilter 从请求参数读取查询条件，日期格式无效时忽略
+func parseLogFilter(r *http.Request) LogFilter {
+	q := r.URL.Query()
+	filter := LogFilter{
+		Username:   strings.TrimSpace(q.Get("username")),
+		StartDate:  strings.TrimSpace(q.Get("start_date")),
+		EndDate:    strings.TrimSpace(q.Get("end_date")),
+		IP:         strings.TrimSpace(q.Get("ip")),
+		Keyword:    strings.TrimSpace(q.Get("keyword")),
+		ActionCode: q.Get("action_code"),
+		Outcome:    q.Get("outcome"),
+	}
+	if _, err := time.Parse("2006-01-02", filter.StartDate); err != nil {
+		filter.StartDate = ""
+	}
+	if _, err := time.Parse("2006-01-02", filter.EndDate); err != nil {
+		filter.EndDate = ""
+	}
+	return filter
+}
+
+// where 生成查询条件 SQL（以 " WHERE 1=1" 开头）及参数
+func (f LogFilter) where() (string, []interface{}) {
+	whereSQL := " WHERE 1=1"
+	args := []interface{}{}
+	if f.Username != "" {
+		whereSQL += " AND username LIKE ?"
+		args = append(args, "%"+f.Username+"%")
+	}
+	if f.StartDate != "" {
+		whereSQL += " AND created_at >= ?"
+		args = append(args, f.StartDate+" 00:00:00")
+	}
+	if f.EndDate != "" {
+		end, _ := time.Parse("2006-01-02", f.EndDate)
+		whereSQL += " AND created_at < ?"
+		args = append(args, end.AddDate(0, 0, 1).Format("2006-01-02")+" 00:00:00")
+	}
+	if f.IP != "" {
+		whereSQL += " AND ip LIKE ?"
+		args = append(args, f.IP+"%")
+	}
+	if f.Keyword != "" {
+		whereSQL += " AND action LIKE ?"
+		args = append(args, "%"+f.Keyword+"%")
+	}
+	if f.ActionCode != "" {
+		whereSQL += " AND action_code = ?"
+		args = append(args, f.ActionCode)
+	}
+	if f.Outcome != "" {
+		whereSQL += " AND outcome = ?"
+		args = append(args, f.Outcome)
+	}
+	return whereSQL, args
+}
+
+// query 查询条件的 URL 参数（不含分页）
+func (f LogFilter) query() string {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"username":    f.Username,
+		"start_date":  f.StartDate,
+		"end_date":    f.EndDate,
+		"ip":          f.IP,
+		"keyword":     f.Keyword,
+		"action_code": f.ActionCode,
+		"outcome":     f.Outcome,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values.Encode()
 }
 
-// Handler 日志列表
+// describe 查询条件说明（写入导出操作日志）
+func (f LogFilter) describe() string {
+	var conditions []string
+	if f.Username != "" {
+		conditions = append(conditions, "用户："+f.Username)
+	}
+	if f.StartDate != "" || f.EndDate != "" {
+		conditions = append(conditions, "日期："+f.StartDate+"~"+f.EndDate)
+	}
+	if f.IP != "" {
+		conditions = append(conditions, "IP："+f.IP)
+	}
+	if f.Keyword != "" {
+		conditions = append(conditions, "关键字："+f.Keyword)
+	}
+	if f.ActionCode != "" {
+		conditions = append(conditions, "类型："+optionName(ActionTypes, f.ActionCode))
+	}
+	if f.Outcome != "" {
+		conditions = append(conditions, "结果："+optionName(Outcomes, f.Outcome))
+	}
+	return strings.Join(conditions, "，")
+}
+
+// logColumns 查询日志列表的字段
+const logColumns = "id, username, action, ip, created_at, action_code, entity_type, entity_id, outcome, detail"
+
+// scanLogEntry 读取一行日志
+func scanLogEntry(rows *sql.Rows) (LogEntry, error) {
+	var item LogEntry
+	var createdAtRaw string
+	var ip, detail sql.NullString
+	err := rows.Scan(&item.ID, &item.Username, &item.Action, &ip, &createdAtRaw,
+		&item.ActionCode, &item.EntityType, &item.EntityID, &item.Outcome, &detail)
+	if err != nil {
+		return item, err
+	}
+	item.IP = ip.String
+	item.Detail = detail.String
+	item.CreatedAt = formatDateTime(createdAtRaw)
+	return item, nil
+}
+
+// Handler 日志列表（支持按用户、日期、IP、关键字、操作类型查询，分页显示）
 func Handler(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.DBInstance.Query(`SELECT id, username, action, ip, created_at FROM operation_logs ORDER BY id DESC LIMIT 200`)
+	filter := parseLogFilter(r)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	whereSQL, args := filter.where()
+
+	// 1. 查询总记录数
+	var totalCount int
+	countSQL := "SELECT COUNT(*) FROM operation_logs" + whereSQL
+	if err := db.DBInstance.QueryRow(countSQL, args...).Scan(&totalCount); err != nil {
+		logger.Errorf("操作日志-查询总数失败: %v, SQL: %s, Args: %v", err, countSQL, args)
+		http.Error(w, "查询总数失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 2. 分页计算
+	totalPages := (totalCount + logPageSize - 1) / logPageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	offset := (page - 1) * logPageSize
+
+	// 3. 查询当前页数据
+	querySQL := "SELECT " + logColumns + " FROM operation_logs" + whereSQL + " ORDER BY id DESC LIMIT ? OFFSET ?"
+	queryArgs := append(args, logPageSize, offset)
+	rows, err := db.DBInstance.Query(querySQL, queryArgs...)
 	if err != nil {
-		logger.Errorf("操作日志-查询失败: %v", err)
+		logger.Errorf("操作日志-查询失败: %v, SQL: %s, Args: %v", err, querySQL, queryArgs)
 		http.Error(w, "查询操作日志失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -28,20 +214,50 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 	var logs []LogEntry
 	for rows.Next() {
-		var item LogEntry
-		var createdAtRaw string
-		if err := rows.Scan(&item.ID, &item.Username, &item.Action, &item.IP, &createdAtRaw); err != nil {
+		item, err := scanLogEntry(rows)
+		if err != nil {
+			logger.Errorf("操作日志-读取记录失败: %v", err)
 			continue
 		}
-		item.CreatedAt = formatDateTime(createdAtRaw)
 		logs = append(logs, item)
 	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("操作日志-数据遍历失败: %v", err)
+		http.Error(w, "数据遍历失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 计算当前页记录范围
+	startRecord := offset + 1
+	endRecord := page * logPageSize
+	if endRecord > totalCount {
+		endRecord = totalCount
+	}
+	if totalCount == 0 {
+		startRecord = 0
+		endRecord = 0
+	}
 
 	data := PageData{
-		Title:      "操作日志",
-		ActiveMenu: "settings",
-		SubMenu:    "logs",
-		Logs:       logs,
+		Title:       "操作日志",
+		ActiveMenu:  "settings",
+		SubMenu:     "logs",
+		Logs:        logs,
+		Filter:      filter,
+		ActionTypes: ActionTypes,
+		Outcomes:    Outcomes,
+		CurrentPage: page,
+		TotalPages:  totalPages,
+		TotalCount:  totalCount,
+		StartRecord: startRecord,
+		EndRecord:   endRecord,
+		HasPrev:     page > 1,
+		HasNext:     page < totalPages,
+		PrevPage:    page - 1,
+		NextPage:    page + 1,
+		FirstPage:   1,
+		LastPage:    totalPages,
+		Query:       filter.query(),
 	}
 
 	tmpl, err := template.ParseFiles("templates/logs.html")
@@ -51,7 +267,88 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = tmpl.Execute(w, data)
+	if err := tmpl.Execute(w, data); err != nil {
+		logger.Errorf("操作日志-模板渲染失败: %v", err)
+	}
+}
+
+// exportHeaders 导出文件的表头
+var exportHeaders = []string{"ID", "时间", "用户", "IP", "类型", "对象类型", "对象ID", "结果", "操作", "修改前后"}
+
+// ExportHandler 按当前查询条件导出操作日志（format=csv 时导出 CSV，否则导出 Excel）
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	filter := parseLogFilter(r)
+	whereSQL, args := filter.where()
+
+	querySQL := "SELECT " + logColumns + " FROM operation_logs" + whereSQL + " ORDER BY id DESC"
+	rows, err := db.DBInstance.Query(querySQL, args...)
+	if err != nil {
+		logger.Errorf("操作日志-导出查询失败: %v, SQL: %s, Args: %v", err, querySQL, args)
+		http.Error(w, "数据库查询失败", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var records [][]string
+	for rows.Next() {
+		item, err := scanLogEntry(rows)
+		if err != nil {
+			logger.Errorf("操作日志-导出读取记录失败: %v", err)
+			continue
+		}
+		records = append(records, []string{
+			strconv.FormatInt(item.ID, 10), item.CreatedAt, item.Username, item.IP,
+			item.ActionName(), entityNames[item.EntityType], item.EntityID, item.OutcomeName(),
+			item.Action, item.Detail,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("操作日志-导出数据遍历失败: %v", err)
+		http.Error(w, "数据遍历失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	format := "Excel"
+	if r.URL.Query().Get("format") == "csv" {
+		format = "CSV"
+	}
+
+	// 记录导出操作日志（导出本身也要留痕）
+	action := fmt.Sprintf("导出操作日志 %s（共 %d 条", format, len(records))
+	if conditions := filter.describe(); conditions != "" {
+		action += "，" + conditions
+	}
+	action += "）"
+	Record(r, Username(r), Entry{
+		Action:     ActionExport,
+		EntityType: EntityOperationLog,
+		Message:    action,
+	})
+
+	fileName := "操作日志_" + time.Now().Format("20060102_150405")
+	if format == "CSV" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", fileName))
+		// 写入 UTF-8 BOM，避免 Excel 打开时中文乱码
+		w.Write([]byte("\xEF\xBB\xBF"))
+		writer := csv.NewWriter(w)
+		writer.Write(exportHeaders)
+		writer.WriteAll(records)
+		return
+	}
+
+	f := excelize.NewFile()
+	sheetName := "操作日志"
+	f.SetSheetName("Sheet1", sheetName)
+	f.SetSheetRow(sheetName, "A1", &exportHeaders)
+	for i, record := range records {
+		cellName, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow(sheetName, cellName, &record)
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.xlsx\"", fileName))
+	f.Write(w)
 }
 
 // formatDateTime 格式化时间字符串为 YYYY-MM-DD HH:mm 格式
@@ -1,71 +1,237 @@
 package operationlog
 
 import (
-	"net"
+	"context"
+	"encoding/json"
 	"net/http"
-	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// LogEntry 单条日志
-type LogEntry struct {
-	ID        int64
-	Username  string
-	Action    string
-	IP        string
-	CreatedAt string
-}
-
-// Record 写入一条操作日志
-func Record(r *http.Request, username, action string) {
-	ip := clientIP(r)
-	_, _ = db.DBInstance.Exec(
-		"INSERT INTO operation_logs (username, action, ip) VALUES (?, ?, ?)",
-		username, action, ip,
-	)
-}
-
-// 获取客户端 IP，优先 X-Forwarded-For
-func clientIP(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
-	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
-}
-
-
-
-
-
-
-
-
-
+// 操作类型代码
+const (
+	ActionLogin          = "login"           // 登录
+	ActionLogout         = "logout"          // 退出登录
+	ActionLockout        = "lockout"         // 登录失败次数过多被锁定
+	ActionUnlock         = "unlock"          // 解除登录锁定
+	ActionChangePassword = "change_password" // 修改密码
+	ActionEnable2FA      = "enable_2fa"      // 启用双因素认证
+	ActionDisable2FA     = "disable_2fa"     // 关闭双因素认证
+	ActionReset2FA       = "reset_2fa"       // 重置双因素认证
+	ActionRevokeSession  = "revoke_session"  // 强制下线会话
+	ActionCreateToken    = "create_token"    // 创建API令牌
+	ActionRevokeToken    = "revoke_token"    // 吊销API令牌
+	ActionAPIAccess      = "api_access"      // 通过API令牌访问
+	ActionAccess         = "access"          // 访问（权限、机构、CSRF校验不通过时记录）
+	ActionQuery          = "query"           // 查询
+	ActionCreate         = "create"          // 新增
+	ActionEdit           = "edit"            // 编辑
+	ActionEditComment    = "edit_comment"    // 编辑审核意见
+	ActionDelete         = "delete"          // 删除
+	ActionImport         = "import"          // 导入
+	ActionExport         = "export"          // 导出
+	ActionUpload         = "upload"          // 上传附件
+	ActionDownload       = "download"        // 下载附件、导入模板
+	ActionSample         = "sample"          // 抽检
+	ActionComplete       = "complete"        // 标记完成
+	ActionSaveSettings   = "save_settings"   // 保存系统设置
+	ActionBackup         = "backup"          // 数据库备份
+	ActionVerify         = "verify"          // 校验操作日志哈希链
+	ActionArchive        = "archive"         // 归档操作日志
+)
 
+// 操作对象类型
+const (
+	EntityUser             = "user"              // 用户，ID为用户ID
+	EntityRole             = "role"              // 角色，ID为角色ID
+	EntitySession          = "session"           // 登录会话，ID为会话所属用户ID
+	EntityAPIToken         = "api_token"         // API令牌，ID为令牌ID
+	EntityLoginLock        = "login_lock"        // 登录锁定记录，ID为用户名或IP
+	EntityAuditTask        = "audit_task"        // 设备档案，ID为task_id
+	EntityAuditDetail      = "audit_detail"      // 设备档案明细，ID为明细ID
+	EntityCheckpointTask   = "checkpoint_task"   // 卡口档案，ID为task_id
+	EntityCheckpointDetail = "checkpoint_detail" // 卡口档案明细，ID为明细ID
+	EntityVideoReminder    = "video_reminder"    // 录像天数不足提醒，ID为提醒ID
+	EntityStatistics       = "statistics"        // 统计数据
+	EntitySettings         = "settings"          // 系统设置，ID为设置页面
+	EntityTaskConfig       = "task_config"       // 定时任务配置
+	EntityRequest          = "request"           // HTTP 请求（访问校验不通过时）
+	EntityOperationLog     = "operation_log"     // 操作日志
+)
 
+// 操作结果
+const (
+	OutcomeSuccess = "success" // 成功
+	OutcomeFailure = "failure" // 失败
+	OutcomeDenied  = "denied"  // 被拒绝（无权限、校验不通过等）
+)
 
+// LogEntry 单条日志
+type LogEntry struct {
+	ID         int64
+	Username   string
+	Action     string
+	IP         string
+	CreatedAt  string
+	ActionCode string
+	EntityType string
+	EntityID   string
+	Outcome    string
+	Detail     string // 修改前后的值（JSON）
+}
 
+// Option 操作类型、操作结果等代码及其显示名称
+type Option struct {
+	Code string
+	Name string
+}
 
+// ActionTypes 全部操作类型（按日志查询页面下拉框的顺序）
+var ActionTypes = []Option{
+	{ActionLogin, "登录"}, {ActionLogout, "退出登录"}, {ActionLockout, "登录锁定"}, {ActionUnlock, "解除锁定"},
+	{ActionChangePassword, "修改密码"}, {ActionEnable2FA, "启用双因素认证"}, {ActionDisable2FA, "关闭双因素认证"},
+	{ActionReset2FA, "重置双因素认证"}, {ActionRevokeSession, "强制下线"}, {ActionCreateToken, "创建API令牌"},
+	{ActionRevokeToken, "吊销API令牌"}, {ActionAPIAccess, "API访问"}, {ActionAccess, "访问"}, {ActionQuery, "查询"},
+	{ActionCreate, "新增"}, {ActionEdit, "编辑"}, {ActionEditComment, "编辑审核意见"}, {ActionDelete, "删除"},
+	{ActionImport, "导入"}, {ActionExport, "导出"}, {ActionUpload, "上传"}, {ActionDownload, "下载"},
+	{ActionSample, "抽检"}, {ActionComplete, "标记完成"}, {ActionSaveSettings, "保存设置"}, {ActionBackup, "备份"},
+	{ActionVerify, "日志校验"}, {ActionArchive, "日志归档"},
+}
 
+// Outcomes 全部操作结果
+var Outcomes = []Option{
+	{OutcomeSuccess, "成功"}, {OutcomeFailure, "失败"}, {OutcomeDenied, "拒绝"},
+}
 
+var entityNames = map[string]string{
+	EntityUser: "用户", EntityRole: "角色", EntitySession: "会话", EntityAPIToken: "API令牌",
+	EntityLoginLock: "登录锁定", EntityAuditTask: "设备档案", EntityAuditDetail: "设备档案明细",
+	EntityCheckpointTask: "卡口档案", EntityCheckpointDetail: "卡口档案明细", EntityVideoReminder: "录像提醒",
+	EntityStatistics: "统计数据", EntitySettings: "系统设置", EntityTaskConfig: "任务配置", EntityRequest: "请求",
+	EntityOperationLog: "操作日志",
+}
 
+// ActionName 操作类型显示名称（未知代码原样返回）
+func (e LogEntry) ActionName() string {
+	return optionName(ActionTypes, e.ActionCode)
+}
 
+// EntityName 操作对象显示名称，有对象ID时附加ID
+func (e LogEntry) EntityName() string {
+	name, ok := entityNames[e.EntityType]
+	if !ok {
+		name = e.EntityType
+	}
+	if e.EntityID != "" {
+		name += " #" + e.EntityID
+	}
+	return name
+}
 
+// OutcomeName 操作结果显示名称
+func (e LogEntry) OutcomeName() string {
+	return optionName(Outcomes, e.Outcome)
+}
 
+// optionName 查找代码对应的显示名称，未知代码原样返回
+func optionName(options []Option, code string) string {
+	for _, option := range options {
+		if option.Code == code {
+			return option.Name
+		}
+	}
+	return code
+}
 
+// Entry 待写入的结构化操作日志
+type Entry struct {
+	Action     string      // 操作类型代码（Action* 常量）
+	EntityType string      // 操作对象类型（Entity* 常量）
+	EntityID   string      // 操作对象ID，多个以逗号分隔
+	Outcome    string      // 操作结果（Outcome* 常量），为空时为成功
+	Message    string      // 操作说明，显示在操作日志列表中
+	Before     interface{} // 修改前的值（可选，以 JSON 保存）
+	After      interface{} // 修改后的值（可选，以 JSON 保存）
+}
 
+// ID 将整数ID转换为 Entry.EntityID
+func ID(id int) string {
+	return strconv.Itoa(id)
+}
 
+// IDs 将多个整数ID转换为以逗号分隔的 Entry.EntityID
+func IDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
 
+type apiAccessKey struct{}
 
+type usernameKey struct{}
 
+// WithUsername 记录本次请求的当前用户名（由认证中间件设置，供本包的处理函数写入操作日志）
+func WithUsername(r *http.Request, username string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), usernameKey{}, username))
+}
 
+// Username 获取认证中间件设置的当前用户名，未登录时返回空字符串
+func Username(r *http.Request) string {
+	username, _ := r.Context().Value(usernameKey{}).(string)
+	return username
+}
 
+// WithAPIAccess 标记请求为 API 令牌访问，之后该请求写入的操作日志都带 [API] 标记
+func WithAPIAccess(r *http.Request, tokenName string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), apiAccessKey{}, tokenName))
+}
 
+// Record 写入一条操作日志，写入失败时记录错误日志
+func Record(r *http.Request, username string, entry Entry) {
+	message := entry.Message
+	if tokenName, ok := r.Context().Value(apiAccessKey{}).(string); ok {
+		message = "[API:" + tokenName + "] " + message
+	}
+	outcome := entry.Outcome
+	if outcome == "" {
+		outcome = OutcomeSuccess
+	}
+	detail, err := entryDetail(entry)
+	if err != nil {
+		logger.Errorf("操作日志-序列化修改前后的值失败: %v, 用户: %s, 操作: %s", err, username, message)
+	}
 
+	row := chainRow{
+		Username:   username,
+		Action:     message,
+		IP:         ClientIP(r),
+		CreatedAt:  time.Now().Truncate(time.Second),
+		ActionCode: entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Outcome:    outcome,
+		Detail:     detail,
+	}
+	if err := insertChained(row); err != nil {
+		logger.Errorf("操作日志-写入失败: %v, 用户: %s, IP: %s, 操作: %s", err, username, row.IP, message)
+	}
+}
 
+// entryDetail 将修改前后的值序列化为 JSON，均为空时返回空字符串
+func entryDetail(entry Entry) (string, error) {
+	if entry.Before == nil && entry.After == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"before": entry.Before,
+		"after":  entry.After,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -3,10 +3,14 @@ package permission
 import (
 	"html/template"
 	"net/http"
+	"net/url"
 	"ops-web/internal/auth"
 	"ops-web/internal/db"
 	"ops-web/internal/logger"
 	"ops-web/internal/operationlog"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // PageData 页面数据
@@ -17,33 +21,33 @@ type PageData struct {
 	Message       string
 	MessageType   string // success, error
 	CurrentUser   *auth.User
-	// 权限配置
-	AllowDeviceAuditImport    bool
-	AllowDeviceAuditDelete    bool
-	AllowCheckpointAuditImport bool
-	AllowCheckpointAuditDelete bool
+	CSRFToken     string
+	// 角色权限配置
+	Roles       []auth.Role
+	Permissions []auth.Permission
+	// 安全配置
+	RequireAdmin2FA bool
+	PasswordPolicy  auth.PasswordPolicy
+	SessionPolicy   auth.SessionPolicy
 }
 
 // Handler 权限设置页面
 func Handler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
-		http.Error(w, "权限不足", http.StatusForbidden)
+	// 获取角色及权限配置
+	roles, err := auth.ListRoles()
+	if err != nil {
+		logger.Errorf("权限设置-查询角色失败: %v", err)
+		http.Error(w, "查询角色失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	// 获取权限配置
-	allowDeviceAuditImport := getSettingBool("allow_device_audit_import")
-	allowDeviceAuditDelete := getSettingBool("allow_device_audit_delete")
-	allowCheckpointAuditImport := getSettingBool("allow_checkpoint_audit_import")
-	allowCheckpointAuditDelete := getSettingBool("allow_checkpoint_audit_delete")
+	requireAdmin2FA := getSettingBool("require_admin_2fa")
 
 	// 获取消息参数（用于显示保存成功/失败消息）
 	message := r.URL.Query().Get("message")
@@ -56,10 +60,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		Message:       message,
 		MessageType:   messageType,
 		CurrentUser:   currentUser,
-		AllowDeviceAuditImport:    allowDeviceAuditImport,
-		AllowDeviceAuditDelete:    allowDeviceAuditDelete,
-		AllowCheckpointAuditImport: allowCheckpointAuditImport,
-		AllowCheckpointAuditDelete: allowCheckpointAuditDelete,
+		CSRFToken:     auth.CSRFToken(r),
+		Roles:                      roles,
+		Permissions:                auth.Permissions,
+		RequireAdmin2FA:            requireAdmin2FA,
+		PasswordPolicy:             auth.LoadPasswordPolicy(),
+		SessionPolicy:              auth.LoadSessionPolicy(),
 	}
 
 	// 渲染模板
@@ -81,43 +87,237 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 // SaveHandler 保存权限设置
 func SaveHandler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
-		http.Error(w, "权限不足", http.StatusForbidden)
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, "/permission?message="+url.QueryEscape("表单解析失败")+"&type=error", http.StatusFound)
 		return
 	}
+	requireAdmin2FA := r.FormValue("require_admin_2fa") == "on"
 
-	// 获取权限配置
-	allowDeviceAuditImport := r.FormValue("allow_device_audit_import") == "on"
-	allowDeviceAuditDelete := r.FormValue("allow_device_audit_delete") == "on"
-	allowCheckpointAuditImport := r.FormValue("allow_checkpoint_audit_import") == "on"
-	allowCheckpointAuditDelete := r.FormValue("allow_checkpoint_audit_delete") == "on"
+	// 获取密码策略
+	policy := auth.PasswordPolicy{
+		MinLength:     formInt(r, "password_min_length"),
+		RequireUpper:  r.FormValue("password_require_upper") == "on",
+		RequireLower:  r.FormValue("password_require_lower") == "on",
+		RequireDigit:  r.FormValue("password_require_digit") == "on",
+		RequireSymbol: r.FormValue("password_require_symbol") == "on",
+		HistoryCount:  formInt(r, "password_history_count"),
+		MaxAgeDays:    formInt(r, "password_max_age_days"),
+	}
+	if err := policy.Check(); err != nil {
+		http.Redirect(w, r, "/permission?message="+url.QueryEscape(err.Error())+"&type=error", http.StatusFound)
+		return
+	}
 
-	// 保存权限配置
-	saveSettingBool("allow_device_audit_import", allowDeviceAuditImport)
-	saveSettingBool("allow_device_audit_delete", allowDeviceAuditDelete)
-	saveSettingBool("allow_checkpoint_audit_import", allowCheckpointAuditImport)
-	saveSettingBool("allow_checkpoint_audit_delete", allowCheckpointAuditDelete)
+	// 获取会话超时策略
+	sessionPolicy := auth.SessionPolicy{
+		IdleMinutes:   formInt(r, "session_idle_minutes"),
+		LifetimeHours: formInt(r, "session_lifetime_hours"),
+	}
+	if err := sessionPolicy.Check(); err != nil {
+		http.Redirect(w, r, "/permission?message="+url.QueryEscape(err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	// 保存各角色权限（管理员角色固定拥有全部权限，不保存）
+	roles, err := auth.ListRoles()
+	if err != nil {
+		logger.Errorf("权限设置-查询角色失败: %v", err)
+		http.Redirect(w, r, "/permission?message="+url.QueryEscape("查询角色失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+	before := securitySettings{
+		RequireAdmin2FA: getSettingBool("require_admin_2fa"),
+		PasswordPolicy:  auth.LoadPasswordPolicy(),
+		SessionPolicy:   auth.LoadSessionPolicy(),
+		RolePermissions: make(map[string][]string),
+	}
+	after := securitySettings{
+		RequireAdmin2FA: requireAdmin2FA,
+		PasswordPolicy:  policy,
+		SessionPolicy:   sessionPolicy,
+		RolePermissions: make(map[string][]string),
+	}
+	var changes []string
+	for _, role := range roles {
+		if role.IsAdmin() {
+			continue
+		}
+		perms := r.Form["role_"+strconv.Itoa(role.ID)]
+		before.RolePermissions[role.Name] = permissionCodes(role.Permissions)
+		after.RolePermissions[role.Name] = perms
+		if err := auth.SaveRolePermissions(role.ID, perms); err != nil {
+			logger.Errorf("权限设置-保存角色权限失败: %v, 角色: %s", err, role.Name)
+			http.Redirect(w, r, "/permission?message="+url.QueryEscape("保存角色权限失败: "+err.Error())+"&type=error", http.StatusFound)
+			return
+		}
+		if diff := permissionDiff(role.Permissions, perms); diff != "" {
+			changes = append(changes, role.Name+"："+diff)
+		}
+	}
+	saveSettingBool("require_admin_2fa", requireAdmin2FA)
+	saveSetting("password_min_length", strconv.Itoa(policy.MinLength))
+	saveSettingBool("password_require_upper", policy.RequireUpper)
+	saveSettingBool("password_require_lower", policy.RequireLower)
+	saveSettingBool("password_require_digit", policy.RequireDigit)
+	saveSettingBool("password_require_symbol", policy.RequireSymbol)
+	saveSetting("password_history_count", strconv.Itoa(policy.HistoryCount))
+	saveSetting("password_max_age_days", strconv.Itoa(policy.MaxAgeDays))
+	saveSetting("session_idle_minutes", strconv.Itoa(sessionPolicy.IdleMinutes))
+	saveSetting("session_lifetime_hours", strconv.Itoa(sessionPolicy.LifetimeHours))
 
 	// 记录操作日志
 	action := "保存权限设置"
-	operationlog.Record(r, currentUser.Username, action)
+	if len(changes) > 0 {
+		action += "（角色权限变更：" + strings.Join(changes, "；") + "）"
+	}
+	if requireAdmin2FA {
+		action += "（要求管理员启用双因素认证）"
+	}
+	action += "（密码策略：" + policy.Description() + "）"
+	action += "（会话：" + sessionPolicy.Description() + "）"
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionSaveSettings,
+		EntityType: operationlog.EntitySettings,
+		EntityID:   "permission",
+		Message:    action,
+		Before:     before,
+		After:      after,
+	})
 
 	// 重定向到权限设置页面，显示成功消息
 	http.Redirect(w, r, "/permission?message=保存成功&type=success", http.StatusFound)
 }
 
+// RoleAddHandler 新建角色
+func RoleAddHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	role, err := auth.CreateRole(r.FormValue("role_name"))
+	if err != nil {
+		logger.Errorf("权限设置-新建角色失败: %v", err)
+		http.Redirect(w, r, "/permission?message="+url.QueryEscape("新建角色失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionCreate,
+		EntityType: operationlog.EntityRole,
+		EntityID:   operationlog.ID(role.ID),
+		Message:    "新建角色（" + role.Name + "）",
+	})
+	http.Redirect(w, r, "/permission?message="+url.QueryEscape("角色已创建，请为其勾选权限后保存")+"&type=success", http.StatusFound)
+}
+
+// RoleDeleteHandler 删除角色
+func RoleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	roleID, err := strconv.Atoi(r.FormValue("role_id"))
+	if err != nil || roleID <= 0 {
+		http.Redirect(w, r, "/permission?message="+url.QueryEscape("角色ID无效")+"&type=error", http.StatusFound)
+		return
+	}
+
+	role, err := auth.DeleteRole(roleID)
+	if err != nil {
+		logger.Errorf("权限设置-删除角色失败: %v, 角色ID: %d", err, roleID)
+		http.Redirect(w, r, "/permission?message="+url.QueryEscape("删除角色失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionDelete,
+		EntityType: operationlog.EntityRole,
+		EntityID:   operationlog.ID(role.ID),
+		Message:    "删除角色（" + role.Name + "）",
+		Before:     map[string]interface{}{"name": role.Name, "code": role.Code},
+	})
+	http.Redirect(w, r, "/permission?message="+url.QueryEscape("角色已删除")+"&type=success", http.StatusFound)
+}
+
+// securitySettings 操作日志中记录的权限设置（修改前后对比）
+type securitySettings struct {
+	RequireAdmin2FA bool                `json:"require_admin_2fa"`
+	PasswordPolicy  auth.PasswordPolicy `json:"password_policy"`
+	SessionPolicy   auth.SessionPolicy  `json:"session_policy"`
+	RolePermissions map[string][]string `json:"role_permissions"` // 角色名称 -> 权限代码
+}
+
+// permissionCodes 已授予的权限代码（排序后）
+func permissionCodes(perms map[string]bool) []string {
+	codes := []string{}
+	for code, granted := range perms {
+		if granted {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// permissionDiff 描述角色权限的增减，无变化时返回空字符串
+func permissionDiff(old map[string]bool, selected []string) string {
+	now := make(map[string]bool)
+	for _, p := range selected {
+		now[p] = true
+	}
+	var added, removed []string
+	for _, p := range auth.Permissions {
+		switch {
+		case now[p.Code] && !old[p.Code]:
+			added = append(added, p.Name)
+		case !now[p.Code] && old[p.Code]:
+			removed = append(removed, p.Name)
+		}
+	}
+	var parts []string
+	if len(added) > 0 {
+		parts = append(parts, "新增"+strings.Join(added, "、"))
+	}
+	if len(removed) > 0 {
+		parts = append(parts, "移除"+strings.Join(removed, "、"))
+	}
+	return strings.Join(parts, "，")
+}
+
+// formInt 读取整数类型表单值，无效时返回 -1（由策略校验报错）
+func formInt(r *http.Request, key string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(r.FormValue(key)))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
 // getSettingBool 获取布尔类型参数值
 func getSettingBool(key string) bool {
 	value := getSetting(key)
@@ -158,15 +358,3 @@ func saveSetting(key, value string) error {
 	_, err := db.DBInstance.Exec(query, key, value, value)
 	return err
 }
-
-// CheckPermission 检查用户是否有权限执行某个操作
-// 管理员始终有权限，普通用户需要检查系统设置
-func CheckPermission(user *auth.User, permissionKey string) bool {
-	// 管理员始终有权限
-	if user != nil && user.RoleCode == 0 {
-		return true
-	}
-	// 普通用户检查系统设置
-	return getSettingBool(permissionKey)
-}
-
@@ -18,6 +18,7 @@ type PageData struct {
 	Message       string
 	MessageType   string // success, error
 	CurrentUser   *auth.User
+	CSRFToken     string
 }
 
 // Handler 参数设置页面
@@ -29,8 +30,8 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
+	// 需要系统设置权限
+	if !currentUser.Can(auth.PermSystemManage) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -50,6 +51,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		Message:       message,
 		MessageType:   messageType,
 		CurrentUser:   currentUser,
+		CSRFToken:     auth.CSRFToken(r),
 	}
 
 	// 渲染模板
@@ -77,8 +79,8 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
+	// 需要系统设置权限
+	if !currentUser.Can(auth.PermSystemManage) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -92,6 +94,7 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 	uploadFilePath := r.FormValue("upload_file_path")
 
 	// 保存参数
+	oldUploadFilePath := getSetting("upload_file_path")
 	err := saveSetting("upload_file_path", uploadFilePath)
 	if err != nil {
 		logger.Errorf("参数设置-保存失败: %v", err)
@@ -101,7 +104,14 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 记录操作日志
 	action := "保存参数设置（上传文件目录：" + uploadFilePath + "）"
-	operationlog.Record(r, currentUser.Username, action)
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionSaveSettings,
+		EntityType: operationlog.EntitySettings,
+		EntityID:   "settings",
+		Message:    action,
+		Before:     map[string]string{"upload_file_path": oldUploadFilePath},
+		After:      map[string]string{"upload_file_path": uploadFilePath},
+	})
 
 	// 重定向到参数设置页面，显示成功消息
 	http.Redirect(w, r, "/settings?message=保存成功&type=success", http.StatusFound)
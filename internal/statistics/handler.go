@@ -67,7 +67,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	stats, summary := getStatisticsByDateRange(startDate, endDate, auditStatus, hasDateFilter)
+	stats, summary := getStatisticsByDateRange(auth.CurrentUser(r), startDate, endDate, auditStatus, hasDateFilter)
 
 	// 构建查询参数字符串
 	queryParams := []string{}
@@ -103,7 +103,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 			action += fmt.Sprintf("，建档状态：%s", statusText[auditStatus])
 		}
 		action += "）"
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionQuery,
+			EntityType: operationlog.EntityStatistics,
+			Message:    action,
+		})
 	}
 
 	renderTemplate(w, data)
@@ -111,7 +115,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 
 // 按日期范围获取统计数据（从audit_details表读取，只统计新增档案）
-func getStatisticsByDateRange(startTime, endTime time.Time, auditStatus string, hasDateFilter bool) ([]StatRow, StatRow) {
+func getStatisticsByDateRange(user *auth.User, startTime, endTime time.Time, auditStatus string, hasDateFilter bool) ([]StatRow, StatRow) {
 	// 构建 SQL 查询（关联audit_tasks表，只统计archive_type为'新增'的记录）
 	query := `
 		SELECT 
@@ -126,6 +130,11 @@ func getStatisticsByDateRange(startTime, endTime time.Time, auditStatus string,
 
 	args := []interface{}{}
 
+	// 只统计当前用户可见机构的档案
+	orgSQL, orgArgs := auth.OrganizationFilter(user, "at.organization")
+	query += orgSQL
+	args = append(args, orgArgs...)
+
 	// 如果需要过滤时间（按日期范围统计）
 	if hasDateFilter && !startTime.IsZero() && !endTime.IsZero() {
 		// 使用 DATE() 函数提取日期部分进行比较
@@ -275,7 +284,7 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取统计数据（应用筛选条件）
-	stats, summary := getStatisticsByDateRange(startDate, endDate, auditStatus, hasDateFilter)
+	stats, summary := getStatisticsByDateRange(auth.CurrentUser(r), startDate, endDate, auditStatus, hasDateFilter)
 
 	// 创建Excel文件
 	f := excelize.NewFile()
@@ -355,7 +364,11 @@ func ExportHandler(w http.ResponseWriter, r *http.Request) {
 			}
 			action += "）"
 		}
-		operationlog.Record(r, currentUser.Username, action)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionExport,
+			EntityType: operationlog.EntityStatistics,
+			Message:    action,
+		})
 	}
 
 	// 输出文件
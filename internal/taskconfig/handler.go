@@ -1,15 +1,17 @@
 package taskconfig
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"ops-web/internal/auth"
 	"ops-web/internal/db"
+	"ops-web/internal/lifecycle"
 	"ops-web/internal/logger"
 	"ops-web/internal/operationlog"
 	"os"
@@ -37,19 +39,20 @@ type PageData struct {
 	Message                  string
 	MessageType              string // success, error
 	CurrentUser              *auth.User
+	CSRFToken                string
 }
 
 // Handler 任务配置页面
 func Handler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
+	// 需要系统设置权限
+	if !currentUser.Can(auth.PermSystemManage) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -106,6 +109,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		Message:             message,
 		MessageType:         messageType,
 		CurrentUser:         currentUser,
+		CSRFToken:           auth.CSRFToken(r),
 	}
 
 	// 渲染模板
@@ -124,17 +128,24 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// taskConfigKeys 任务配置页面保存的参数
+var taskConfigKeys = []string{
+	"upload_file_path", "backup_file_path", "database_backup_path",
+	"db_backup_enabled", "db_backup_frequency", "db_backup_hour",
+	"file_backup_enabled", "file_backup_frequency", "file_backup_hour",
+}
+
 // SaveHandler 保存任务配置
 func SaveHandler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
+	// 需要系统设置权限
+	if !currentUser.Can(auth.PermSystemManage) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -157,6 +168,12 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 	fileBackupFrequency := r.FormValue("file_backup_frequency")
 	fileBackupHour := r.FormValue("file_backup_hour")
 
+	// 修改前的配置（写入操作日志）
+	before := make(map[string]string)
+	for _, key := range taskConfigKeys {
+		before[key] = getSetting(key)
+	}
+
 	// 保存参数
 	err := saveSetting("upload_file_path", uploadFilePath)
 	if err != nil {
@@ -226,7 +243,18 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 		uploadFilePath, backupFilePath, databaseBackupPath,
 		dbBackupEnabled, dbBackupFrequency, dbBackupHour,
 		fileBackupEnabled, fileBackupFrequency, fileBackupHour)
-	operationlog.Record(r, currentUser.Username, action)
+	after := make(map[string]string)
+	for _, key := range taskConfigKeys {
+		after[key] = r.FormValue(key)
+	}
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionSaveSettings,
+		EntityType: operationlog.EntityTaskConfig,
+		EntityID:   "taskconfig",
+		Message:    action,
+		Before:     before,
+		After:      after,
+	})
 
 	// 重定向到任务配置页面，显示成功消息
 	http.Redirect(w, r, "/taskconfig?message="+url.QueryEscape("保存成功")+"&type=success", http.StatusFound)
@@ -259,14 +287,14 @@ func saveSetting(key, value string) error {
 // BackupDatabaseHandler 数据库备份处理
 func BackupDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
+	// 需要系统设置权限
+	if !currentUser.Can(auth.PermSystemManage) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -278,22 +306,12 @@ func BackupDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 读取配置文件获取数据库信息
-	configFile, err := os.Open("config/config.json")
-	if err != nil {
-		logger.Errorf("数据库备份-读取配置文件失败: %v", err)
-		http.Redirect(w, r, "/taskconfig?message="+url.QueryEscape("读取配置文件失败")+"&type=error", http.StatusFound)
-		return
-	}
-	defer configFile.Close()
+	// 数据库连接信息使用启动时加载的配置（已应用环境变量覆盖并解密密码）
+	config := db.AppConfig
 
-	var config db.Config
-	decoder := json.NewDecoder(configFile)
-	if err := decoder.Decode(&config); err != nil {
-		logger.Errorf("数据库备份-解析配置文件失败: %v", err)
-		http.Redirect(w, r, "/taskconfig?message="+url.QueryEscape("解析配置文件失败")+"&type=error", http.StatusFound)
-		return
-	}
+	// 服务停止时等待备份完成，超时后中断
+	ctx, done := lifecycle.Track("数据库备份")
+	defer done()
 
 	// 创建当天日期目录（YYYY-MM-DD格式）
 	today := time.Now().Format("2006-01-02")
@@ -317,8 +335,12 @@ func BackupDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	// 备份每个表
 	backupCount := 0
 	for _, table := range tables {
+		if ctx.Err() != nil {
+			logger.Warnf("数据库备份-服务停止，备份已中断（已备份表数：%d/%d）", backupCount, len(tables))
+			break
+		}
 		outputFile := filepath.Join(backupDir, table+".sql")
-		if err := backupTable(config, table, outputFile); err != nil {
+		if err := backupTable(ctx, config, table, outputFile); err != nil {
 			logger.Errorf("数据库备份-备份表 %s 失败: %v", table, err)
 			continue
 		}
@@ -333,7 +355,17 @@ func BackupDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 记录操作日志
 	action := fmt.Sprintf("数据库备份（备份路径：%s，备份表数：%d/%d）", backupDir, backupCount, len(tables))
-	operationlog.Record(r, currentUser.Username, action)
+	outcome := operationlog.OutcomeSuccess
+	if backupCount < len(tables) {
+		outcome = operationlog.OutcomeFailure
+	}
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionBackup,
+		EntityType: operationlog.EntityTaskConfig,
+		EntityID:   "database",
+		Outcome:    outcome,
+		Message:    action,
+	})
 
 	// 重定向到任务配置页面，显示成功消息
 	message := fmt.Sprintf("备份成功！共备份 %d/%d 个表到 %s", backupCount, len(tables), backupDir)
@@ -360,33 +392,42 @@ func getAllTables(dbName string) ([]string, error) {
 	return tables, rows.Err()
 }
 
-// backupTable 备份单个表
-func backupTable(config db.Config, tableName, outputFile string) error {
+// backupTable 备份单个表，失败或 ctx 取消（mysqldump 被终止）时删除未写完的文件
+func backupTable(ctx context.Context, config db.Config, tableName, outputFile string) (err error) {
 	// 构建mysqldump命令
-	// mysqldump -h host -P port -u user -ppassword database table > output.sql
-	cmd := exec.Command("mysqldump",
+	// mysqldump -h host -P port -u user database table > output.sql
+	// 密码通过 MYSQL_PWD 环境变量传递，不出现在命令行参数（ps 可见）和错误信息中
+	cmd := exec.CommandContext(ctx, "mysqldump",
 		"-h", config.DBHost,
 		"-P", config.DBPort,
 		"-u", config.DBUser,
-		"-p"+config.DBPass,
 		config.DBName,
 		tableName,
 	)
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+config.DBPass)
 
 	// 创建输出文件
 	outFile, err := os.Create(outputFile)
 	if err != nil {
 		return fmt.Errorf("创建输出文件失败: %v", err)
 	}
-	defer outFile.Close()
+	defer func() {
+		outFile.Close()
+		if err != nil {
+			os.Remove(outputFile)
+		}
+	}()
 
 	// 设置输出
 	cmd.Stdout = outFile
 	cmd.Stderr = os.Stderr
 
 	// 执行命令
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("执行mysqldump失败: %v", err)
+	if runErr := cmd.Run(); runErr != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("服务停止，mysqldump已中断")
+		}
+		return fmt.Errorf("执行mysqldump失败: %v", runErr)
 	}
 
 	return nil
@@ -423,7 +464,7 @@ func cleanOldBackups(backupPath string, keepDays int) error {
 				logger.Errorf("数据库备份-删除旧备份目录失败: %v, 路径: %s", err, dirPath)
 				continue
 			}
-			log.Printf("数据库备份-已删除旧备份目录: %s", dirPath)
+			logger.Infof("数据库备份-已删除旧备份目录: %s", dirPath)
 		}
 	}
 
@@ -433,14 +474,14 @@ func cleanOldBackups(backupPath string, keepDays int) error {
 // BackupFileHandler 文件备份处理
 func BackupFileHandler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
 
-	// 只允许管理员访问
-	if currentUser.RoleCode != 0 {
+	// 需要系统设置权限
+	if !currentUser.Can(auth.PermSystemManage) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -472,25 +513,32 @@ func BackupFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 复制文件
-	copiedCount, err := copyDirectory(uploadPath, backupPath)
+	// 复制文件（服务停止时等待复制完成，超时后中断）
+	ctx, done := lifecycle.Track("文件备份")
+	defer done()
+	copiedCount, err := copyDirectory(ctx, uploadPath, backupPath)
 	if err != nil {
-		logger.Errorf("文件备份-复制文件失败: %v", err)
+		logger.Errorf("文件备份-复制文件失败: %v（已复制文件数：%d）", err, copiedCount)
 		http.Redirect(w, r, "/taskconfig?message="+url.QueryEscape("文件备份失败: "+err.Error())+"&type=error", http.StatusFound)
 		return
 	}
 
 	// 记录操作日志
 	action := fmt.Sprintf("文件备份（上传路径：%s，备份路径：%s，复制文件数：%d）", uploadPath, backupPath, copiedCount)
-	operationlog.Record(r, currentUser.Username, action)
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionBackup,
+		EntityType: operationlog.EntityTaskConfig,
+		EntityID:   "file",
+		Message:    action,
+	})
 
 	// 重定向到任务配置页面，显示成功消息
 	message := fmt.Sprintf("文件备份成功！共复制 %d 个文件到 %s", copiedCount, backupPath)
 	http.Redirect(w, r, "/taskconfig?message="+url.QueryEscape(message)+"&type=success", http.StatusFound)
 }
 
-// copyDirectory 复制目录下的所有文件（递归）
-func copyDirectory(srcDir, dstDir string) (int, error) {
+// copyDirectory 复制目录下的所有文件（递归），ctx 取消时停止复制并删除未写完的文件
+func copyDirectory(ctx context.Context, srcDir, dstDir string) (int, error) {
 	copiedCount := 0
 
 	// 遍历源目录
@@ -498,6 +546,9 @@ func copyDirectory(srcDir, dstDir string) (int, error) {
 		if err != nil {
 			return err
 		}
+		if ctx.Err() != nil {
+			return errors.New("服务停止，复制已中断")
+		}
 
 		// 计算相对路径
 		relPath, err := filepath.Rel(srcDir, srcPath)
@@ -513,35 +564,57 @@ func copyDirectory(srcDir, dstDir string) (int, error) {
 			return os.MkdirAll(dstPath, info.Mode())
 		}
 
-		// 如果是文件，复制文件
-		srcFile, err := os.Open(srcPath)
-		if err != nil {
+		if err := copyFile(ctx, srcPath, dstPath); err != nil {
 			return err
 		}
-		defer srcFile.Close()
+		copiedCount++
+		return nil
+	})
 
-		// 创建目标文件的目录（如果不存在）
-		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
-			return err
-		}
+	return copiedCount, err
+}
 
-		// 创建目标文件
-		dstFile, err := os.Create(dstPath)
-		if err != nil {
-			return err
-		}
-		defer dstFile.Close()
+// copyFile 复制单个文件，失败时删除未写完的目标文件
+func copyFile(ctx context.Context, srcPath, dstPath string) (err error) {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	// 创建目标文件的目录（如果不存在）
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return err
+	}
 
-		// 复制文件内容
-		_, err = dstFile.ReadFrom(srcFile)
+	// 创建目标文件
+	dstFile, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dstFile.Close(); err == nil {
+			err = closeErr
+		}
 		if err != nil {
-			return err
+			os.Remove(dstPath)
 		}
+	}()
 
-		copiedCount++
-		return nil
-	})
+	// 复制文件内容（大文件复制过程中也能响应服务停止）
+	_, err = io.Copy(dstFile, contextReader{ctx: ctx, r: srcFile})
+	return err
+}
 
-	return copiedCount, err
+// contextReader ctx 取消后读取返回错误，用于中断大文件复制
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
 }
 
+func (cr contextReader) Read(p []byte) (int, error) {
+	if cr.ctx.Err() != nil {
+		return 0, errors.New("服务停止，复制已中断")
+	}
+	return cr.r.Read(p)
+}
@@ -1,9 +1,10 @@
 package taskconfig
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"fmt"
 	"ops-web/internal/db"
+	"ops-web/internal/lifecycle"
 	"ops-web/internal/logger"
 	"os"
 	"path/filepath"
@@ -14,36 +15,115 @@ import (
 
 // 定时任务调度器相关变量
 var (
-	schedulerStopChan chan bool
-	schedulerRunning  bool
-	schedulerMutex    sync.Mutex
+	schedulerCancel  context.CancelFunc // 停止当前调度器
+	schedulerRunning bool
+	schedulerMutex   sync.Mutex
+
+	// 调度器最近一次检查时间（供诊断页面计算下次执行时间）
+	statusMutex        sync.Mutex
+	schedulerLastCheck time.Time
 )
 
-// ReloadScheduler 重新加载定时任务配置
-func ReloadScheduler() {
+// 调度器检查间隔
+const schedulerInterval = time.Hour
+
+// BackupSchedule 定时备份任务的配置和下次执行时间
+type BackupSchedule struct {
+	Name        string    // 数据库备份、文件备份
+	Enabled     bool
+	Description string    // 如"每天 2 点"
+	NextRun     time.Time // 为零值时表示按当前配置不会执行
+}
+
+// SchedulerStatus 定时任务调度器状态
+type SchedulerStatus struct {
+	Running   bool
+	LastCheck time.Time
+	NextCheck time.Time
+	Backups   []BackupSchedule
+}
+
+// Status 返回调度器状态和各备份任务的下次执行时间
+func Status() SchedulerStatus {
 	schedulerMutex.Lock()
-	defer schedulerMutex.Unlock()
+	running := schedulerRunning
+	schedulerMutex.Unlock()
+
+	statusMutex.Lock()
+	lastCheck := schedulerLastCheck
+	statusMutex.Unlock()
+
+	status := SchedulerStatus{Running: running, LastCheck: lastCheck}
+	if running && !lastCheck.IsZero() {
+		status.NextCheck = lastCheck.Add(schedulerInterval)
+	}
+	for _, task := range []struct{ name, prefix string }{
+		{"数据库备份", "db_backup"},
+		{"文件备份", "file_backup"},
+	} {
+		status.Backups = append(status.Backups, backupSchedule(task.name, task.prefix, status.NextCheck))
+	}
+	return status
+}
 
-	// 停止现有调度器
-	if schedulerRunning {
-		schedulerStopChan <- true
-		schedulerRunning = false
-		// 等待调度器停止
-		time.Sleep(100 * time.Millisecond)
+// backupSchedule 按任务配置推算下次执行时间：调度器每小时检查一次，检查时所在小时与配置的小时一致时执行
+func backupSchedule(name, prefix string, nextCheck time.Time) BackupSchedule {
+	schedule := BackupSchedule{Name: name, Enabled: getSetting(prefix+"_enabled") == "1"}
+	frequency := getSetting(prefix + "_frequency")
+	hour, err := strconv.Atoi(getSetting(prefix + "_hour"))
+	if err != nil || hour < 1 || hour > 24 {
+		schedule.Description = "未配置执行时间"
+		return schedule
+	}
+	switch frequency {
+	case "daily":
+		schedule.Description = fmt.Sprintf("每天 %d 点", hour)
+	case "weekly":
+		schedule.Description = fmt.Sprintf("每周一 %d 点", hour)
+	default:
+		schedule.Description = "未配置执行频率"
+		return schedule
+	}
+	if !schedule.Enabled || nextCheck.IsZero() {
+		return schedule
 	}
 
-	// 启动新的调度器
-	go startScheduler()
+	for check := nextCheck; check.Before(nextCheck.Add(8 * 24 * time.Hour)); check = check.Add(schedulerInterval) {
+		if check.Hour() == hour && (frequency == "daily" || check.Weekday() == time.Monday) {
+			schedule.NextRun = check
+			break
+		}
+	}
+	return schedule
 }
 
-// startScheduler 启动定时任务调度器
-func startScheduler() {
+// ReloadScheduler 重新加载定时任务配置：停止现有调度器后按新配置启动
+func ReloadScheduler() {
 	schedulerMutex.Lock()
+	defer schedulerMutex.Unlock()
+
+	// 停止现有调度器（已开始执行的备份不受影响）
+	if schedulerCancel != nil {
+		schedulerCancel()
+		schedulerCancel = nil
+	}
+	schedulerRunning = false
+	if lifecycle.Stopping() {
+		return
+	}
+
+	// 启动新的调度器，服务停止或再次重新加载时退出
+	ctx, cancel := context.WithCancel(lifecycle.Context())
+	schedulerCancel = cancel
 	schedulerRunning = true
-	schedulerStopChan = make(chan bool)
-	schedulerMutex.Unlock()
+	lifecycle.Go("定时任务调度器", func(context.Context) {
+		startScheduler(ctx)
+	})
+}
 
-	ticker := time.NewTicker(1 * time.Hour) // 每小时检查一次
+// startScheduler 运行定时任务调度器，直到 ctx 取消
+func startScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval) // 每小时检查一次
 	defer ticker.Stop()
 
 	// 立即检查一次（启动时）
@@ -51,7 +131,13 @@ func startScheduler() {
 
 	for {
 		select {
-		case <-schedulerStopChan:
+		case <-ctx.Done():
+			if lifecycle.Stopping() {
+				schedulerMutex.Lock()
+				schedulerRunning = false
+				schedulerMutex.Unlock()
+				logger.Infof("定时任务调度器已停止")
+			}
 			return
 		case <-ticker.C:
 			checkAndRunScheduledTasks()
@@ -59,9 +145,25 @@ func startScheduler() {
 	}
 }
 
+// startBackupJob 在后台执行备份任务，服务停止时等待其完成（超时后中断）
+func startBackupJob(name string, run func(ctx context.Context)) {
+	if lifecycle.Stopping() {
+		return
+	}
+	ctx, done := lifecycle.Track(name)
+	go func() {
+		defer done()
+		run(ctx)
+	}()
+}
+
 // checkAndRunScheduledTasks 检查并执行定时任务
 func checkAndRunScheduledTasks() {
 	now := time.Now()
+	statusMutex.Lock()
+	schedulerLastCheck = now
+	statusMutex.Unlock()
+
 	currentHour := now.Hour()
 	currentWeekday := now.Weekday()
 
@@ -83,7 +185,7 @@ func checkAndRunScheduledTasks() {
 			}
 
 			if shouldRun {
-				go runDatabaseBackup()
+				startBackupJob("定时数据库备份", runDatabaseBackup)
 			}
 		}
 	}
@@ -106,35 +208,23 @@ func checkAndRunScheduledTasks() {
 			}
 
 			if shouldRun {
-				go runFileBackup()
+				startBackupJob("定时文件备份", runFileBackup)
 			}
 		}
 	}
 }
 
-// runDatabaseBackup 执行数据库备份（定时任务调用）
-func runDatabaseBackup() {
+// runDatabaseBackup 执行数据库备份（定时任务调用），ctx 取消时中断并删除未写完的备份文件
+func runDatabaseBackup(ctx context.Context) {
 	// 获取数据库备份路径
 	backupPath := getSetting("database_backup_path")
 	if backupPath == "" {
-		logger.Errorf("定时任务-数据库备份路径未设置")
+		logger.Warnf("定时任务-数据库备份路径未设置")
 		return
 	}
 
-	// 读取配置文件获取数据库信息
-	configFile, err := os.Open("config/config.json")
-	if err != nil {
-		logger.Errorf("定时任务-数据库备份-读取配置文件失败: %v", err)
-		return
-	}
-	defer configFile.Close()
-
-	var config db.Config
-	decoder := json.NewDecoder(configFile)
-	if err := decoder.Decode(&config); err != nil {
-		logger.Errorf("定时任务-数据库备份-解析配置文件失败: %v", err)
-		return
-	}
+	// 数据库连接信息使用启动时加载的配置
+	config := db.AppConfig
 
 	// 创建当天日期目录
 	today := time.Now().Format("2006-01-02")
@@ -156,8 +246,12 @@ func runDatabaseBackup() {
 	// 备份每个表
 	backupCount := 0
 	for _, table := range tables {
+		if ctx.Err() != nil {
+			logger.Warnf("定时任务-数据库备份-服务停止，备份已中断（已备份表数：%d/%d）", backupCount, len(tables))
+			return
+		}
 		outputFile := filepath.Join(backupDir, table+".sql")
-		if err := backupTable(config, table, outputFile); err != nil {
+		if err := backupTable(ctx, config, table, outputFile); err != nil {
 			logger.Errorf("定时任务-数据库备份-备份表 %s 失败: %v", table, err)
 			continue
 		}
@@ -169,23 +263,23 @@ func runDatabaseBackup() {
 		logger.Errorf("定时任务-数据库备份-清理旧备份失败: %v", err)
 	}
 
-	log.Printf("定时任务-数据库备份完成（备份表数：%d/%d）", backupCount, len(tables))
+	logger.Infof("定时任务-数据库备份完成（备份表数：%d/%d）", backupCount, len(tables))
 }
 
-// runFileBackup 执行文件备份（定时任务调用）
-func runFileBackup() {
+// runFileBackup 执行文件备份（定时任务调用），ctx 取消时中断并删除未写完的文件
+func runFileBackup(ctx context.Context) {
 	// 获取文件上传路径和备份路径
 	uploadPath := getSetting("upload_file_path")
 	backupPath := getSetting("backup_file_path")
 
 	if uploadPath == "" || backupPath == "" {
-		logger.Errorf("定时任务-文件备份-路径未设置")
+		logger.Warnf("定时任务-文件备份-路径未设置")
 		return
 	}
 
 	// 检查上传路径是否存在
 	if _, err := os.Stat(uploadPath); os.IsNotExist(err) {
-		logger.Errorf("定时任务-文件备份-上传路径不存在: %s", uploadPath)
+		logger.Warnf("定时任务-文件备份-上传路径不存在: %s", uploadPath)
 		return
 	}
 
@@ -196,13 +290,17 @@ func runFileBackup() {
 	}
 
 	// 复制文件
-	copiedCount, err := copyDirectory(uploadPath, backupPath)
+	copiedCount, err := copyDirectory(ctx, uploadPath, backupPath)
 	if err != nil {
+		if ctx.Err() != nil {
+			logger.Warnf("定时任务-文件备份-服务停止，备份已中断（已复制文件数：%d）", copiedCount)
+			return
+		}
 		logger.Errorf("定时任务-文件备份-复制文件失败: %v", err)
 		return
 	}
 
-	log.Printf("定时任务-文件备份完成（复制文件数：%d）", copiedCount)
+	logger.Infof("定时任务-文件备份完成（复制文件数：%d）", copiedCount)
 }
 
 // InitScheduler 初始化定时任务调度器（在main.go中调用）
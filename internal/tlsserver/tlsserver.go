@@ -0,0 +1,194 @@
+package tlsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"ops-web/internal/logger"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// expiryWarning 证书剩余有效期不足该时长时记录警告
+const expiryWarning = 30 * 24 * time.Hour
+
+// CertReloader 持有当前使用的证书，证书文件更新或收到 SIGHUP 时重新加载，
+// 新证书加载失败时继续使用原证书
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certMod  time.Time
+	keyMod   time.Time
+	loadedAt time.Time
+}
+
+// active 服务使用的证书（未启用 HTTPS 时为 nil），诊断页面据此显示证书信息
+var active *CertReloader
+
+// NewCertReloader 加载证书和私钥，失败时返回错误（启动时证书无效则不启动服务）
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	active = r
+	return r, nil
+}
+
+// Reload 重新读取证书和私钥
+func (r *CertReloader) Reload() error {
+	certMod, keyMod := modTime(r.certFile), modTime(r.keyFile)
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书失败: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("解析证书失败: %v", err)
+	}
+	cert.Leaf = leaf
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+
+	logger.Infof("HTTPS-已加载证书: %s，有效期至 %s", leaf.Subject.CommonName, leaf.NotAfter.Format("2006-01-02 15:04:05"))
+	if remaining := time.Until(leaf.NotAfter); remaining < expiryWarning {
+		logger.Warnf("HTTPS-证书将于 %s 过期（剩余 %d 天），请及时更换", leaf.NotAfter.Format("2006-01-02"), int(remaining.Hours()/24))
+	}
+	return nil
+}
+
+// GetCertificate 供 tls.Config 使用，返回当前证书
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch 每隔 interval 检查证书文件的修改时间，文件变化或收到 SIGHUP 时重新加载，ctx 取消时退出
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logger.Infof("HTTPS-收到 SIGHUP，重新加载证书")
+			if err := r.Reload(); err != nil {
+				logger.Errorf("HTTPS-重新加载证书失败，继续使用原证书: %v", err)
+			}
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			logger.Infof("HTTPS-证书文件已更新，重新加载证书")
+			if err := r.Reload(); err != nil {
+				// 证书和私钥可能还没有全部写完，下次检查时再试
+				logger.Errorf("HTTPS-重新加载证书失败，继续使用原证书: %v", err)
+			}
+		}
+	}
+}
+
+// changed 证书或私钥文件的修改时间是否与上次加载时不同
+func (r *CertReloader) changed() bool {
+	certMod, keyMod := modTime(r.certFile), modTime(r.keyFile)
+	if certMod.IsZero() || keyMod.IsZero() {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+}
+
+// CertStatus 当前证书信息（诊断页面显示）
+type CertStatus struct {
+	CertFile string
+	Subject  string
+	DNSNames []string
+	NotAfter time.Time
+	LoadedAt time.Time
+}
+
+// Status 返回当前证书信息
+func (r *CertReloader) Status() CertStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	status := CertStatus{CertFile: r.certFile, LoadedAt: r.loadedAt}
+	if r.cert != nil && r.cert.Leaf != nil {
+		status.Subject = r.cert.Leaf.Subject.CommonName
+		status.DNSNames = r.cert.Leaf.DNSNames
+		status.NotAfter = r.cert.Leaf.NotAfter
+	}
+	return status
+}
+
+// ActiveStatus 返回服务当前使用的证书信息，未启用 HTTPS 时 ok 为 false
+func ActiveStatus() (status CertStatus, ok bool) {
+	if active == nil {
+		return CertStatus{}, false
+	}
+	return active.Status(), true
+}
+
+// ServerConfig 生成 HTTPS 服务的 TLS 配置，证书由 reloader 提供
+func ServerConfig(minVersion uint16, reloader *CertReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+}
+
+// RedirectHandler 将 HTTP 请求重定向到 httpsPort 端口的 HTTPS 地址，保留主机名、路径和查询参数
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 地址
+		}
+		target := "https://" + host + r.URL.RequestURI()
+
+		// GET/HEAD 永久重定向；其他方法用 308 保留请求方法和请求体
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target, code)
+	})
+}
+
+// modTime 文件修改时间，文件不存在时返回零值
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,277 @@
+package user
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"ops-web/internal/auth"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenInfo 令牌信息（页面展示用）
+type TokenInfo struct {
+	ID         int
+	Username   string
+	Name       string
+	Prefix     string
+	Scopes     string
+	CreatedBy  string
+	CreatedAt  string
+	LastUsedAt string
+	LastUsedIP string
+	ExpiresAt  string
+	Status     string // 有效、已过期、已吊销
+	Active     bool
+}
+
+// ScopeOption 权限范围选项
+type ScopeOption struct {
+	Code string
+	Name string
+}
+
+// TokenPageData API令牌管理页面数据
+type TokenPageData struct {
+	Title       string
+	ActiveMenu  string
+	SubMenu     string
+	Tokens      []TokenInfo
+	Users       []UserInfo
+	Scopes      []ScopeOption
+	NewToken    string // 刚创建的原始令牌，只显示一次
+	NewTokenFor string
+	Message     string
+	MessageType string // success, error
+	CurrentUser *auth.User
+	CSRFToken   string
+}
+
+// TokensHandler API令牌列表页面
+func TokensHandler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	data := TokenPageData{
+		Message:     r.URL.Query().Get("message"),
+		MessageType: r.URL.Query().Get("type"),
+	}
+	renderTokensPage(w, r, currentUser, data)
+}
+
+// CreateTokenHandler 为用户创建API令牌，创建成功后直接在页面上显示一次原始令牌
+func CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/users/tokens", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userID <= 0 {
+		http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("请选择令牌所属用户")+"&type=error", http.StatusFound)
+		return
+	}
+
+	// 令牌以所属用户的身份访问，只有管理员可以为其他用户创建，否则“用户管理”权限可借管理员的令牌提升权限
+	if userID != currentUser.ID && !currentUser.IsAdmin() {
+		rejectAdminTarget(w, r, currentUser, operationlog.ActionCreateToken, userID, "创建API令牌",
+			"只有管理员可以为其他用户创建令牌", "/users/tokens?")
+		return
+	}
+
+	// 过期日期当天有效，留空表示永不过期
+	var expiresAt time.Time
+	if expires := strings.TrimSpace(r.FormValue("expires_at")); expires != "" {
+		day, err := time.ParseInLocation("2006-01-02", expires, time.Local)
+		if err != nil {
+			http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("过期日期格式无效")+"&type=error", http.StatusFound)
+			return
+		}
+		expiresAt = day.AddDate(0, 0, 1)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("表单解析失败")+"&type=error", http.StatusFound)
+		return
+	}
+	raw, token, err := auth.CreateAPIToken(userID, r.FormValue("name"), r.Form["scopes"], expiresAt, currentUser.Username)
+	if err != nil {
+		logger.Errorf("API令牌-创建令牌失败: %v, 用户ID: %d", err, userID)
+		http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("创建令牌失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	action := fmt.Sprintf("创建API令牌（名称：%s，所属用户：%s，权限：%s）", token.Name, token.Username, strings.Join(token.Scopes, ","))
+	operationlog.Record(r, currentUser.Username, operationlog.Entry{
+		Action:     operationlog.ActionCreateToken,
+		EntityType: operationlog.EntityAPIToken,
+		EntityID:   operationlog.ID(token.ID),
+		Message:    action,
+		After:      map[string]interface{}{"name": token.Name, "user_id": token.UserID, "scopes": token.Scopes},
+	})
+
+	data := TokenPageData{
+		NewToken:    raw,
+		NewTokenFor: token.Username,
+		Message:     "令牌创建成功，请立即复制保存，关闭页面后将无法再次查看",
+		MessageType: "success",
+	}
+	renderTokensPage(w, r, currentUser, data)
+}
+
+// RevokeTokenHandler 吊销API令牌
+func RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/users/tokens", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+
+	tokenID, err := strconv.Atoi(r.FormValue("token_id"))
+	if err != nil || tokenID <= 0 {
+		http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("令牌ID无效")+"&type=error", http.StatusFound)
+		return
+	}
+
+	token, err := auth.GetAPIToken(tokenID)
+	if err != nil {
+		logger.Errorf("API令牌-查询令牌失败: %v, 令牌ID: %d", err, tokenID)
+		http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("查询令牌失败")+"&type=error", http.StatusFound)
+		return
+	}
+	if token != nil {
+		if reason := checkAdminTarget(currentUser, token.UserID, nil); reason != "" {
+			rejectAdminTarget(w, r, currentUser, operationlog.ActionRevokeToken, token.UserID, "吊销API令牌（名称："+token.Name+"）", reason, "/users/tokens?")
+			return
+		}
+	}
+
+	token, err = auth.RevokeAPIToken(tokenID)
+	if err != nil {
+		logger.Errorf("API令牌-吊销令牌失败: %v, 令牌ID: %d", err, tokenID)
+		http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("吊销令牌失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+	if token == nil {
+		http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("令牌不存在")+"&type=error", http.StatusFound)
+		return
+	}
+
+	if currentUser != nil {
+		action := fmt.Sprintf("吊销API令牌（名称：%s，所属用户：%s）", token.Name, token.Username)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionRevokeToken,
+			EntityType: operationlog.EntityAPIToken,
+			EntityID:   operationlog.ID(token.ID),
+			Message:    action,
+		})
+	}
+
+	http.Redirect(w, r, "/users/tokens?message="+url.QueryEscape("令牌已吊销")+"&type=success", http.StatusFound)
+}
+
+// renderTokensPage 查询令牌和用户列表并渲染页面
+func renderTokensPage(w http.ResponseWriter, r *http.Request, currentUser *auth.User, data TokenPageData) {
+	tokens, err := auth.ListAPITokens()
+	if err != nil {
+		logger.Errorf("API令牌-查询令牌失败: %v", err)
+		http.Error(w, "查询令牌失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	scopeNames := make(map[string]string)
+	for _, s := range auth.APIScopes {
+		scopeNames[s.Code] = s.Name
+		data.Scopes = append(data.Scopes, ScopeOption{Code: s.Code, Name: s.Name})
+	}
+
+	now := time.Now()
+	for _, t := range tokens {
+		var names []string
+		for _, s := range t.Scopes {
+			names = append(names, scopeNames[s])
+		}
+		info := TokenInfo{
+			ID:         t.ID,
+			Username:   t.Username,
+			Name:       t.Name,
+			Prefix:     t.Prefix,
+			Scopes:     strings.Join(names, "、"),
+			CreatedBy:  t.CreatedBy,
+			CreatedAt:  t.CreatedAt.Format("2006-01-02 15:04"),
+			LastUsedAt: "从未使用",
+			LastUsedIP: t.LastUsedIP,
+			ExpiresAt:  "永不过期",
+			Status:     "有效",
+			Active:     t.Active(),
+		}
+		if !t.LastUsedAt.IsZero() {
+			info.LastUsedAt = t.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		if !t.ExpiresAt.IsZero() {
+			info.ExpiresAt = t.ExpiresAt.Format("2006-01-02 15:04")
+		}
+		switch {
+		case !t.RevokedAt.IsZero():
+			info.Status = "已吊销"
+		case !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt):
+			info.Status = "已过期"
+		}
+		data.Tokens = append(data.Tokens, info)
+	}
+
+	users, err := listUsers()
+	if err != nil {
+		logger.Errorf("API令牌-查询用户失败: %v", err)
+		http.Error(w, "查询用户失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 非管理员只能为自己创建令牌
+	if !currentUser.IsAdmin() {
+		var self []UserInfo
+		for _, u := range users {
+			if u.ID == currentUser.ID {
+				self = append(self, u)
+			}
+		}
+		users = self
+	}
+
+	data.Title = "API令牌"
+	data.ActiveMenu = "settings"
+	data.SubMenu = "users"
+	data.Users = users
+	data.CurrentUser = currentUser
+	data.CSRFToken = auth.CSRFToken(r)
+
+	// 页面可能包含原始令牌，禁止缓存
+	w.Header().Set("Cache-Control", "no-store")
+
+	tmpl, err := template.ParseFiles("templates/usertokens.html")
+	if err != nil {
+		logger.Errorf("API令牌-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		logger.Errorf("API令牌-模板渲染失败: %v", err)
+		http.Error(w, "模板渲染失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,24 @@
+package user
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestCreateTokenOnlyForSelf(t *testing.T) {
+	// 非管理员不能为其他用户（尤其是管理员）创建令牌
+	for _, userID := range []int{testAdminUserID, testNormalUserID} {
+		t.Run("用户"+strconv.Itoa(userID), func(t *testing.T) {
+			store := useFakeUserDB(t)
+			form := url.Values{"user_id": {strconv.Itoa(userID)}, "name": {"script"}, "scopes": {"read"}}
+			message, messageType := postAs(t, CreateTokenHandler, userManager(), form)
+			if messageType != "error" || message != "只有管理员可以为其他用户创建令牌" {
+				t.Errorf("提示 = %q（%s），期望拒绝", message, messageType)
+			}
+			if len(store.unexpected) > 0 {
+				t.Errorf("被拒绝的请求执行了其他语句: %v", store.unexpected)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package user
 
 import (
+	"database/sql"
 	"html/template"
 	"net/http"
+	"net/url"
 	"ops-web/internal/auth"
 	"ops-web/internal/db"
 	"ops-web/internal/logger"
@@ -13,11 +15,16 @@ import (
 
 // UserInfo 用户信息结构
 type UserInfo struct {
-	ID       int
-	Username string
-	RoleID   int
-	RoleCode int
-	RoleName string
+	ID               int
+	Username         string
+	RoleID           int   // 主角色（角色代码最小的角色）
+	RoleIDs          []int // 全部角色
+	RoleCode         int
+	RoleName         string // 全部角色名称，以“、”分隔
+	OrganizationIDs  []int  // 关联的机构
+	Organizations    string // 关联的机构名称，以“、”分隔
+	AuthSource       string // 账号来源：local=本地账号，ldap=目录账号
+	TwoFactorEnabled bool   // 是否已启用双因素认证
 }
 
 // RoleInfo 角色信息结构
@@ -34,43 +41,38 @@ type PageData struct {
 	SubMenu    string
 	Users      []UserInfo
 	Roles      []RoleInfo
+	Organizations []auth.Organization // 可分配的机构
+	Locks      []LockInfo // 因登录失败被锁定的用户名/IP
 	Message    string
 	MessageType string // success, error
 	CurrentUser *auth.User
+	CSRFToken   string
 }
 
 // Handler 用户列表页面
 func Handler(w http.ResponseWriter, r *http.Request) {
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser == nil {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
 
 	// 查询所有用户
-	query := `
-		SELECT u.id, u.username, u.role_id, ur.role_code, ur.role_name
-		FROM users u
-		LEFT JOIN user_role ur ON u.role_id = ur.id
-		ORDER BY u.id DESC
-	`
-	rows, err := db.DBInstance.Query(query)
+	users, err := listUsers()
 	if err != nil {
-		logger.Errorf("用户管理-查询用户失败: %v, SQL: %s", err, query)
+		logger.Errorf("用户管理-查询用户失败: %v", err)
 		http.Error(w, "查询用户失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var users []UserInfo
-	for rows.Next() {
-		var user UserInfo
-		err := rows.Scan(&user.ID, &user.Username, &user.RoleID, &user.RoleCode, &user.RoleName)
-		if err != nil {
-			continue
-		}
-		users = append(users, user)
+	// 填充双因素认证状态
+	twoFactorUsers, err := auth.GetTwoFactorEnabledUsers()
+	if err != nil {
+		logger.Errorf("用户管理-查询双因素认证状态失败: %v", err)
+	}
+	for i := range users {
+		users[i].TwoFactorEnabled = twoFactorUsers[users[i].ID]
 	}
 
 	// 查询所有角色
@@ -81,6 +83,23 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 查询所有机构
+	organizations, err := auth.ListOrganizations()
+	if err != nil {
+		logger.Errorf("用户管理-查询机构失败: %v", err)
+		http.Error(w, "查询机构失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 管理员可查看登录锁定记录（查询失败不影响用户列表显示）
+	var locks []LockInfo
+	if currentUser.Can(auth.PermUserManage) {
+		locks, err = getLoginLocks()
+		if err != nil {
+			logger.Errorf("用户管理-查询登录锁定记录失败: %v", err)
+		}
+	}
+
 	// 获取消息参数
 	message := r.URL.Query().Get("message")
 	messageType := r.URL.Query().Get("type")
@@ -91,9 +110,12 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		SubMenu:     "users",
 		Users:       users,
 		Roles:       roles,
+		Organizations: organizations,
+		Locks:       locks,
 		Message:     message,
 		MessageType: messageType,
 		CurrentUser: currentUser,
+		CSRFToken:   auth.CSRFToken(r),
 	}
 
 	renderTemplate(w, data)
@@ -106,20 +128,29 @@ func AddHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 
 	username := strings.TrimSpace(r.FormValue("username"))
 	password := r.FormValue("password")
-	roleIDStr := r.FormValue("role_id")
+	roleIDs, err := formIntValues(r, "role_ids")
+	if err != nil {
+		http.Redirect(w, r, "/users?message=角色ID无效&type=error", http.StatusFound)
+		return
+	}
 
-	if username == "" || password == "" || roleIDStr == "" {
+	orgIDs, err := formIntValues(r, "organization_ids")
+	if err != nil {
+		http.Redirect(w, r, "/users?message=机构ID无效&type=error", http.StatusFound)
+		return
+	}
+
+	if username == "" || password == "" || len(roleIDs) == 0 {
 		http.Redirect(w, r, "/users?message=用户名、密码和角色不能为空&type=error", http.StatusFound)
 		return
 	}
 
-	roleID, err := strconv.Atoi(roleIDStr)
-	if err != nil {
-		http.Redirect(w, r, "/users?message=角色ID无效&type=error", http.StatusFound)
+	if reason := checkAdminTarget(currentUser, 0, roleIDs); reason != "" {
+		rejectAdminTarget(w, r, currentUser, operationlog.ActionCreate, 0, "添加用户:"+username, reason, "/users?")
 		return
 	}
 
@@ -135,6 +166,12 @@ func AddHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 校验密码策略
+	if err := auth.CheckNewPassword(0, password); err != nil {
+		http.Redirect(w, r, "/users?message="+url.QueryEscape(err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
 	// 加密密码
 	hashedPassword, err := auth.HashPassword(password)
 	if err != nil {
@@ -144,14 +181,39 @@ func AddHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 插入用户
 	insertSQL := "INSERT INTO users (username, password, role_id) VALUES (?, ?, ?)"
-	_, err = db.DBInstance.Exec(insertSQL, username, hashedPassword, roleID)
+	result, err := db.DBInstance.Exec(insertSQL, username, hashedPassword, roleIDs[0])
 	if err != nil {
 		http.Redirect(w, r, "/users?message=添加用户失败: "+err.Error()+"&type=error", http.StatusFound)
 		return
 	}
 
+	newID, err := result.LastInsertId()
+	if err == nil {
+		// 写入全部角色（同时把 users.role_id 修正为主角色）
+		if err := auth.SetUserRoles(int(newID), roleIDs); err != nil {
+			logger.Errorf("用户管理-设置用户角色失败: %v, 用户名: %s", err, username)
+			http.Redirect(w, r, "/users?message="+url.QueryEscape("用户已添加，但设置角色失败: "+err.Error())+"&type=error", http.StatusFound)
+			return
+		}
+		if err := auth.SetUserOrganizations(int(newID), orgIDs); err != nil {
+			logger.Errorf("用户管理-设置用户机构失败: %v, 用户名: %s", err, username)
+			http.Redirect(w, r, "/users?message="+url.QueryEscape("用户已添加，但设置机构失败: "+err.Error())+"&type=error", http.StatusFound)
+			return
+		}
+		// 记录历史密码（用于禁止重复使用）
+		if err := auth.RecordPasswordHistory(int(newID), hashedPassword); err != nil {
+			logger.Errorf("用户管理-%v, 用户名: %s", err, username)
+		}
+	}
+
 	if currentUser != nil {
-		operationlog.Record(r, currentUser.Username, "添加用户:"+username)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionCreate,
+			EntityType: operationlog.EntityUser,
+			EntityID:   strconv.FormatInt(newID, 10),
+			Message:    "添加用户:" + username,
+			After:      userSnapshot{Username: username, RoleIDs: roleIDs, OrganizationIDs: orgIDs},
+		})
 	}
 
 	http.Redirect(w, r, "/users?message=用户添加成功&type=success", http.StatusFound)
@@ -164,14 +226,24 @@ func EditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 
 	userIDStr := r.FormValue("user_id")
 	username := strings.TrimSpace(r.FormValue("username"))
 	password := r.FormValue("password")
-	roleIDStr := r.FormValue("role_id")
+	roleIDs, err := formIntValues(r, "role_ids")
+	if err != nil {
+		http.Redirect(w, r, "/users?message=角色ID无效&type=error", http.StatusFound)
+		return
+	}
 
-	if userIDStr == "" || username == "" || roleIDStr == "" {
+	orgIDs, err := formIntValues(r, "organization_ids")
+	if err != nil {
+		http.Redirect(w, r, "/users?message=机构ID无效&type=error", http.StatusFound)
+		return
+	}
+
+	if userIDStr == "" || username == "" || len(roleIDs) == 0 {
 		http.Redirect(w, r, "/users?message=用户ID、用户名和角色不能为空&type=error", http.StatusFound)
 		return
 	}
@@ -182,12 +254,20 @@ func EditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roleID, err := strconv.Atoi(roleIDStr)
-	if err != nil {
-		http.Redirect(w, r, "/users?message=角色ID无效&type=error", http.StatusFound)
+	if reason := checkAdminTarget(currentUser, userID, roleIDs); reason != "" {
+		rejectAdminTarget(w, r, currentUser, operationlog.ActionEdit, userID, "编辑用户:"+username, reason, "/users?")
 		return
 	}
 
+	// 防止管理员误操作移除自己的管理员角色后无人可管理系统
+	if currentUser != nil && currentUser.ID == userID && currentUser.IsAdmin() {
+		keepsAdmin, err := auth.ContainsAdminRole(roleIDs)
+		if err != nil || !keepsAdmin {
+			http.Redirect(w, r, "/users?message="+url.QueryEscape("不能移除当前登录用户的管理员角色")+"&type=error", http.StatusFound)
+			return
+		}
+	}
+
 	// 检查用户名是否被其他用户使用
 	var count int
 	err = db.DBInstance.QueryRow("SELECT COUNT(*) FROM users WHERE username = ? AND id != ?", username, userID).Scan(&count)
@@ -200,31 +280,66 @@ func EditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 修改前的用户信息（写入操作日志）
+	before, err := getUserSnapshot(userID)
+	if err != nil {
+		logger.Errorf("用户管理-查询用户信息失败: %v, 用户ID: %d", err, userID)
+		http.Redirect(w, r, "/users?message=数据库查询失败&type=error", http.StatusFound)
+		return
+	}
+
 	// 如果提供了新密码，则更新密码
 	if password != "" {
+		if err := auth.CheckNewPassword(userID, password); err != nil {
+			http.Redirect(w, r, "/users?message="+url.QueryEscape(err.Error())+"&type=error", http.StatusFound)
+			return
+		}
 		hashedPassword, err := auth.HashPassword(password)
 		if err != nil {
 			http.Redirect(w, r, "/users?message=密码加密失败&type=error", http.StatusFound)
 			return
 		}
-		updateSQL := "UPDATE users SET username = ?, password = ?, role_id = ? WHERE id = ?"
-		_, err = db.DBInstance.Exec(updateSQL, username, hashedPassword, roleID, userID)
+		updateSQL := "UPDATE users SET username = ?, password = ?, password_changed_at = NOW() WHERE id = ?"
+		_, err = db.DBInstance.Exec(updateSQL, username, hashedPassword, userID)
 		if err != nil {
 			http.Redirect(w, r, "/users?message=更新用户失败: "+err.Error()+"&type=error", http.StatusFound)
 			return
 		}
+		if err := auth.RecordPasswordHistory(userID, hashedPassword); err != nil {
+			logger.Errorf("用户管理-%v, 用户ID: %d", err, userID)
+		}
 	} else {
 		// 不更新密码
-		updateSQL := "UPDATE users SET username = ?, role_id = ? WHERE id = ?"
-		_, err = db.DBInstance.Exec(updateSQL, username, roleID, userID)
+		updateSQL := "UPDATE users SET username = ? WHERE id = ?"
+		_, err = db.DBInstance.Exec(updateSQL, username, userID)
 		if err != nil {
 			http.Redirect(w, r, "/users?message=更新用户失败: "+err.Error()+"&type=error", http.StatusFound)
 			return
 		}
 	}
 
+	// 更新角色（users.role_id 同步为主角色）
+	if err := auth.SetUserRoles(userID, roleIDs); err != nil {
+		logger.Errorf("用户管理-设置用户角色失败: %v, 用户ID: %d", err, userID)
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("更新用户角色失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+	if err := auth.SetUserOrganizations(userID, orgIDs); err != nil {
+		logger.Errorf("用户管理-设置用户机构失败: %v, 用户ID: %d", err, userID)
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("更新用户机构失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
 	if currentUser != nil {
-		operationlog.Record(r, currentUser.Username, "编辑用户:"+username)
+		after := userSnapshot{Username: username, RoleIDs: roleIDs, OrganizationIDs: orgIDs, PasswordChanged: password != ""}
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionEdit,
+			EntityType: operationlog.EntityUser,
+			EntityID:   operationlog.ID(userID),
+			Message:    "编辑用户:" + username,
+			Before:     before,
+			After:      after,
+		})
 	}
 
 	http.Redirect(w, r, "/users?message=用户更新成功&type=success", http.StatusFound)
@@ -250,11 +365,23 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取当前用户
-	currentUser := auth.GetCurrentUser(r)
+	currentUser := auth.CurrentUser(r)
 	if currentUser != nil && currentUser.ID == userID {
 		http.Redirect(w, r, "/users?message=不能删除当前登录用户&type=error", http.StatusFound)
 		return
 	}
+	if reason := checkAdminTarget(currentUser, userID, nil); reason != "" {
+		rejectAdminTarget(w, r, currentUser, operationlog.ActionDelete, userID, "删除用户ID:"+strconv.Itoa(userID), reason, "/users?")
+		return
+	}
+
+	// 删除前的用户信息（写入操作日志）
+	before, err := getUserSnapshot(userID)
+	if err != nil {
+		logger.Errorf("用户管理-查询用户信息失败: %v, 用户ID: %d", err, userID)
+		http.Redirect(w, r, "/users?message=数据库查询失败&type=error", http.StatusFound)
+		return
+	}
 
 	// 删除用户
 	_, err = db.DBInstance.Exec("DELETE FROM users WHERE id = ?", userID)
@@ -264,12 +391,188 @@ func DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if currentUser != nil {
-		operationlog.Record(r, currentUser.Username, "删除用户ID:"+strconv.Itoa(userID))
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionDelete,
+			EntityType: operationlog.EntityUser,
+			EntityID:   operationlog.ID(userID),
+			Message:    "删除用户ID:" + strconv.Itoa(userID),
+			Before:     before,
+		})
 	}
 
 	http.Redirect(w, r, "/users?message=用户删除成功&type=success", http.StatusFound)
 }
 
+// listUsers 获取所有用户
+func listUsers() ([]UserInfo, error) {
+	query := `
+		SELECT u.id, u.username, u.role_id, ur.role_code, ur.role_name, u.auth_source
+		FROM users u
+		LEFT JOIN user_role ur ON u.role_id = ur.id
+		ORDER BY u.id DESC
+	`
+	rows, err := db.DBInstance.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserInfo
+	for rows.Next() {
+		var user UserInfo
+		err := rows.Scan(&user.ID, &user.Username, &user.RoleID, &user.RoleCode, &user.RoleName, &user.AuthSource)
+		if err != nil {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	// 填充全部角色，没有角色成员记录的用户沿用 users.role_id
+	roles, err := getRoles()
+	if err != nil {
+		return nil, err
+	}
+	roleNames := make(map[int]string)
+	for _, role := range roles {
+		roleNames[role.ID] = role.RoleName
+	}
+	userRoles, err := auth.UserRoleIDs()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		ids, ok := userRoles[users[i].ID]
+		if !ok {
+			users[i].RoleIDs = []int{users[i].RoleID}
+			continue
+		}
+		var names []string
+		for _, id := range ids {
+			names = append(names, roleNames[id])
+		}
+		users[i].RoleIDs = ids
+		users[i].RoleName = strings.Join(names, "、")
+	}
+
+	// 填充关联的机构
+	orgs, err := auth.ListOrganizations()
+	if err != nil {
+		return nil, err
+	}
+	orgNames := make(map[int]string)
+	for _, org := range orgs {
+		orgNames[org.ID] = org.Name
+	}
+	userOrgs, err := auth.UserOrganizationIDs()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		ids := userOrgs[users[i].ID]
+		var names []string
+		for _, id := range ids {
+			names = append(names, orgNames[id])
+		}
+		users[i].OrganizationIDs = append([]int{}, ids...)
+		users[i].Organizations = strings.Join(names, "、")
+	}
+	return users, nil
+}
+
+// userSnapshot 操作日志中记录的用户信息（修改前后对比）
+type userSnapshot struct {
+	Username        string `json:"username"`
+	RoleIDs         []int  `json:"role_ids"`
+	OrganizationIDs []int  `json:"organization_ids"`
+	PasswordChanged bool   `json:"password_changed,omitempty"`
+}
+
+// getUserSnapshot 查询用户当前的用户名、角色和机构，用户不存在时返回 nil
+func getUserSnapshot(userID int) (*userSnapshot, error) {
+	var snapshot userSnapshot
+	err := db.DBInstance.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&snapshot.Username)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	roleIDs, err := auth.UserRoleIDs()
+	if err != nil {
+		return nil, err
+	}
+	orgIDs, err := auth.UserOrganizationIDs()
+	if err != nil {
+		return nil, err
+	}
+	snapshot.RoleIDs = roleIDs[userID]
+	snapshot.OrganizationIDs = orgIDs[userID]
+	return &snapshot, nil
+}
+
+// formIntValues 读取表单中勾选的多个ID（角色、机构）
+func formIntValues(r *http.Request, key string) ([]int, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, v := range r.Form[key] {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// checkAdminTarget 管理员账号和管理员角色只能由管理员操作：拥有“用户管理”权限的非管理员不能授予管理员角色，
+// 也不能修改、删除管理员账号，重置其双因素认证或强制其下线，否则“用户管理”权限就等同于管理员。
+// targetUserID 为 0 表示新建用户；返回拒绝原因，允许时返回空字符串，查询失败时拒绝
+func checkAdminTarget(currentUser *auth.User, targetUserID int, roleIDs []int) string {
+	if currentUser == nil {
+		return "未登录"
+	}
+	if currentUser.IsAdmin() {
+		return ""
+	}
+
+	grantsAdmin, err := auth.ContainsAdminRole(roleIDs)
+	if err != nil {
+		logger.Errorf("用户管理-查询角色失败: %v", err)
+		return "查询角色失败"
+	}
+	if grantsAdmin {
+		return "只有管理员可以授予管理员角色"
+	}
+	if targetUserID > 0 {
+		targetIsAdmin, err := auth.IsAdminUser(targetUserID)
+		if err != nil {
+			logger.Errorf("用户管理-查询用户角色失败: %v, 用户ID: %d", err, targetUserID)
+			return "查询用户角色失败"
+		}
+		if targetIsAdmin {
+			return "只有管理员可以管理管理员账号"
+		}
+	}
+	return ""
+}
+
+// rejectAdminTarget 拒绝 checkAdminTarget 不允许的操作，写入操作日志后返回 redirectBase 指向的页面
+func rejectAdminTarget(w http.ResponseWriter, r *http.Request, currentUser *auth.User, action string, userID int, operation, reason, redirectBase string) {
+	if currentUser != nil {
+		logger.Warnf("用户管理-操作被拒绝: %s（%s）, 用户: %s", operation, reason, currentUser.Username)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     action,
+			EntityType: operationlog.EntityUser,
+			EntityID:   operationlog.ID(userID),
+			Outcome:    operationlog.OutcomeDenied,
+			Message:    operation + "，被拒绝：" + reason,
+		})
+	}
+	http.Redirect(w, r, redirectBase+"message="+url.QueryEscape(reason)+"&type=error", http.StatusFound)
+}
+
 // getRoles 获取所有角色
 func getRoles() ([]RoleInfo, error) {
 	rows, err := db.DBInstance.Query("SELECT id, role_name, role_code FROM user_role ORDER BY role_code")
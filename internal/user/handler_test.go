@@ -0,0 +1,229 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"ops-web/internal/auth"
+	"ops-web/internal/db"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试用的内存用户库：只实现管理员账号校验（checkAdminTarget）及其之前用到的查询，
+// 其他语句一律记录并返回错误，用于确认被拒绝的请求没有写入任何数据
+
+const (
+	testAdminRoleID   = 1
+	testManageRoleID  = 2
+	testAdminUserID   = 1
+	testManagerUserID = 2
+	testNormalUserID  = 3
+)
+
+// testRoleCodes 角色ID -> 角色代码
+var testRoleCodes = map[int64]int64{testAdminRoleID: auth.AdminRoleCode, testManageRoleID: 1}
+
+// testUsers 用户ID -> 用户名、角色
+var testUsers = map[int64]struct {
+	Username string
+	RoleID   int64
+}{
+	testAdminUserID:   {"admin", testAdminRoleID},
+	testManagerUserID: {"manager", testManageRoleID},
+	testNormalUserID:  {"zhangsan", testManageRoleID},
+}
+
+type fakeUserDB struct {
+	mu         sync.Mutex
+	unexpected []string
+}
+
+var (
+	fakeUserDBOnce    sync.Once
+	fakeUserDBCurrent *fakeUserDB
+	spaces            = regexp.MustCompile(`\s+`)
+)
+
+// useFakeUserDB 以内存用户库替换 db.DBInstance，测试结束后恢复
+func useFakeUserDB(t *testing.T) *fakeUserDB {
+	t.Helper()
+	fakeUserDBOnce.Do(func() { sql.Register("usertest", fakeDriver{}) })
+
+	store := &fakeUserDB{}
+	fakeUserDBCurrent = store
+	conn, err := sql.Open("usertest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.DBInstance
+	db.DBInstance = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db.DBInstance = previous
+	})
+	return store
+}
+
+func (s *fakeUserDB) query(q string, args []driver.Value) ([]string, [][]driver.Value, error) {
+	switch {
+	case strings.HasPrefix(q, "SELECT COUNT(*) FROM user_role WHERE role_code = ? AND id IN ("):
+		count := int64(0)
+		for _, id := range args[1:] {
+			if code, ok := testRoleCodes[id.(int64)]; ok && code == args[0].(int64) {
+				count++
+			}
+		}
+		return []string{"count"}, [][]driver.Value{{count}}, nil
+
+	case strings.HasPrefix(q, "SELECT COUNT(*) FROM user_role WHERE role_code = ? AND (id = (SELECT role_id FROM users WHERE id = ?)"):
+		count := int64(0)
+		if u, ok := testUsers[args[1].(int64)]; ok && testRoleCodes[u.RoleID] == args[0].(int64) {
+			count = 1
+		}
+		return []string{"count"}, [][]driver.Value{{count}}, nil
+
+	case strings.HasPrefix(q, "SELECT username FROM users WHERE id = ?"):
+		u, ok := testUsers[args[0].(int64)]
+		if !ok {
+			return []string{"username"}, nil, nil
+		}
+		return []string{"username"}, [][]driver.Value{{u.Username}}, nil
+	}
+	return nil, nil, s.reject(q)
+}
+
+func (s *fakeUserDB) reject(q string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unexpected = append(s.unexpected, q)
+	return fmt.Errorf("测试用户库未实现的语句: %s", q)
+}
+
+// fakeDriver 把查询转给当前的内存用户库
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(q string) (driver.Stmt, error) { return nil, fakeUserDBCurrent.reject(q) }
+func (fakeConn) Close() error                          { return nil }
+func (fakeConn) Begin() (driver.Tx, error)             { return nil, fakeUserDBCurrent.reject("BEGIN") }
+
+func (fakeConn) QueryContext(_ context.Context, q string, args []driver.NamedValue) (driver.Rows, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	columns, rows, err := fakeUserDBCurrent.query(strings.TrimSpace(spaces.ReplaceAllString(q, " ")), values)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func (fakeConn) ExecContext(_ context.Context, q string, _ []driver.NamedValue) (driver.Result, error) {
+	return nil, fakeUserDBCurrent.reject(strings.TrimSpace(spaces.ReplaceAllString(q, " ")))
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// userManager 只有“用户管理”权限的非管理员
+func userManager() *auth.User {
+	return &auth.User{
+		ID:          testManagerUserID,
+		Username:    "manager",
+		RoleID:      testManageRoleID,
+		RoleCode:    1,
+		Permissions: map[string]bool{auth.PermUserManage: true},
+	}
+}
+
+// postAs 以指定用户提交表单，返回重定向地址中的提示信息和类型
+func postAs(t *testing.T, handler http.HandlerFunc, user *auth.User, form url.Values) (message, messageType string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r = auth.WithCurrentUser(r, user)
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || err != nil {
+		t.Fatalf("响应 %d, Location = %q, 期望重定向", w.Code, w.Header().Get("Location"))
+	}
+	return location.Query().Get("message"), location.Query().Get("type")
+}
+
+func TestUserManagerCannotTouchAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		form    url.Values
+		want    string
+	}{
+		{"添加管理员", AddHandler, url.Values{
+			"username": {"newadmin"}, "password": {"Passw0rd!2024"}, "role_ids": {"1"},
+		}, "只有管理员可以授予管理员角色"},
+		{"给自己加管理员角色", EditHandler, url.Values{
+			"user_id": {"2"}, "username": {"manager"}, "role_ids": {"2", "1"},
+		}, "只有管理员可以授予管理员角色"},
+		{"修改管理员密码", EditHandler, url.Values{
+			"user_id": {"1"}, "username": {"admin"}, "password": {"Passw0rd!2024"}, "role_ids": {"2"},
+		}, "只有管理员可以管理管理员账号"},
+		{"删除管理员", DeleteHandler, url.Values{"user_id": {"1"}}, "只有管理员可以管理管理员账号"},
+		{"重置管理员双因素认证", ResetTwoFactorHandler, url.Values{"user_id": {"1"}}, "只有管理员可以管理管理员账号"},
+		{"强制管理员下线", RevokeUserSessionsHandler, url.Values{"user_id": {"1"}, "username": {"admin"}}, "只有管理员可以管理管理员账号"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useFakeUserDB(t)
+			message, messageType := postAs(t, tt.handler, userManager(), tt.form)
+			if messageType != "error" || message != tt.want {
+				t.Errorf("提示 = %q（%s），期望 %q", message, messageType, tt.want)
+			}
+			if len(store.unexpected) > 0 {
+				t.Errorf("被拒绝的请求执行了其他语句: %v", store.unexpected)
+			}
+		})
+	}
+}
+
+func TestCheckAdminTarget(t *testing.T) {
+	useFakeUserDB(t)
+	admin := &auth.User{ID: testAdminUserID, Username: "admin", RoleCode: auth.AdminRoleCode}
+
+	if reason := checkAdminTarget(admin, testAdminUserID, []int{testAdminRoleID}); reason != "" {
+		t.Errorf("管理员操作被拒绝: %s", reason)
+	}
+	if reason := checkAdminTarget(userManager(), testNormalUserID, []int{testManageRoleID}); reason != "" {
+		t.Errorf("用户管理员管理普通用户被拒绝: %s", reason)
+	}
+	if reason := checkAdminTarget(nil, testNormalUserID, nil); reason == "" {
+		t.Error("未登录时应拒绝")
+	}
+}
@@ -0,0 +1,91 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"ops-web/internal/auth"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strconv"
+)
+
+// LockInfo 登录锁定信息（页面展示用）
+type LockInfo struct {
+	ID           int
+	ScopeText    string // 用户名 / IP
+	ScopeKey     string
+	FailCount    int
+	LastFailedAt string
+	LockedUntil  string
+}
+
+// getLoginLocks 查询当前处于锁定状态的用户名和IP
+func getLoginLocks() ([]LockInfo, error) {
+	locks, err := auth.ListLoginLocks()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []LockInfo
+	for _, lock := range locks {
+		scopeText := "用户名"
+		if lock.Scope == auth.LockScopeIP {
+			scopeText = "IP"
+		}
+		list = append(list, LockInfo{
+			ID:           lock.ID,
+			ScopeText:    scopeText,
+			ScopeKey:     lock.ScopeKey,
+			FailCount:    lock.FailCount,
+			LastFailedAt: lock.LastFailedAt.Format("2006-01-02 15:04"),
+			LockedUntil:  lock.LockedUntil.Time.Format("2006-01-02 15:04"),
+		})
+	}
+	return list, nil
+}
+
+// UnlockHandler 解除登录锁定
+func UnlockHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/users", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+
+	lockID, err := strconv.Atoi(r.FormValue("lock_id"))
+	if err != nil || lockID <= 0 {
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("锁定记录ID无效")+"&type=error", http.StatusFound)
+		return
+	}
+
+	lock, err := auth.UnlockLogin(lockID)
+	if err == sql.ErrNoRows {
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("锁定记录不存在或已解除")+"&type=error", http.StatusFound)
+		return
+	}
+	if err != nil {
+		logger.Errorf("用户管理-解除登录锁定失败: %v, 锁定记录ID: %d", err, lockID)
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("解除锁定失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	if currentUser != nil {
+		var action string
+		if lock.Scope == auth.LockScopeIP {
+			action = fmt.Sprintf("解除登录锁定（IP：%s，失败次数：%d）", lock.ScopeKey, lock.FailCount)
+		} else {
+			action = fmt.Sprintf("解除登录锁定（用户名：%s，失败次数：%d）", lock.ScopeKey, lock.FailCount)
+		}
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionUnlock,
+			EntityType: operationlog.EntityLoginLock,
+			EntityID:   lock.ScopeKey,
+			Message:    action,
+		})
+	}
+
+	http.Redirect(w, r, "/users?message="+url.QueryEscape("已解除锁定")+"&type=success", http.StatusFound)
+}
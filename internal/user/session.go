@@ -0,0 +1,216 @@
+package user
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"ops-web/internal/auth"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strconv"
+)
+
+// SessionInfo 会话信息（页面展示用）
+type SessionInfo struct {
+	ID         string
+	UserID     int
+	Username   string
+	IP         string
+	UserAgent  string
+	CreatedAt  string
+	LastSeenAt string
+	ExpireAt   string
+	IsCurrent  bool // 是否为当前管理员正在使用的会话
+}
+
+// SessionPageData 会话管理页面数据
+type SessionPageData struct {
+	Title        string
+	ActiveMenu   string
+	SubMenu      string
+	Sessions     []SessionInfo
+	FilterUserID int
+	FilterName   string
+	Message      string
+	MessageType  string // success, error
+	CurrentUser  *auth.User
+	CSRFToken    string
+}
+
+// SessionsHandler 在线会话列表页面
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.CurrentUser(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	filterUserID, _ := strconv.Atoi(r.URL.Query().Get("user_id"))
+
+	sessions, err := auth.ListSessions(filterUserID)
+	if err != nil {
+		logger.Errorf("会话管理-查询会话失败: %v", err)
+		http.Error(w, "查询会话失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	currentSessionID := auth.CurrentSessionID(r)
+	var list []SessionInfo
+	filterName := ""
+	policy := auth.LoadSessionPolicy()
+	for _, s := range sessions {
+		list = append(list, SessionInfo{
+			ID:         s.ID,
+			UserID:     s.UserID,
+			Username:   s.Username,
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt.Format("2006-01-02 15:04"),
+			LastSeenAt: s.LastSeenAt.Format("2006-01-02 15:04"),
+			ExpireAt:   policy.ExpiresAt(s).Format("2006-01-02 15:04"), // 无操作时的到期时间
+			IsCurrent:  s.ID == currentSessionID,
+		})
+		if filterUserID != 0 {
+			filterName = s.Username
+		}
+	}
+
+	data := SessionPageData{
+		Title:        "在线会话",
+		ActiveMenu:   "settings",
+		SubMenu:      "users",
+		Sessions:     list,
+		FilterUserID: filterUserID,
+		FilterName:   filterName,
+		Message:      r.URL.Query().Get("message"),
+		MessageType:  r.URL.Query().Get("type"),
+		CurrentUser:  currentUser,
+		CSRFToken:    auth.CSRFToken(r),
+	}
+
+	tmpl, err := template.ParseFiles("templates/usersessions.html")
+	if err != nil {
+		logger.Errorf("会话管理-模板解析失败: %v", err)
+		http.Error(w, "模板解析失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		logger.Errorf("会话管理-模板渲染失败: %v", err)
+		http.Error(w, "模板渲染失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// RevokeSessionHandler 强制下线单个会话
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/users/sessions", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+	redirectBase := sessionsRedirectBase(r.FormValue("filter_user_id"))
+
+	sessionID := r.FormValue("session_id")
+	if sessionID == "" {
+		http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("会话ID不能为空")+"&type=error", http.StatusFound)
+		return
+	}
+	if sessionID == auth.CurrentSessionID(r) {
+		http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("不能强制下线当前会话，请使用退出登录")+"&type=error", http.StatusFound)
+		return
+	}
+
+	session, err := auth.GetSessionByID(sessionID)
+	if err != nil {
+		logger.Errorf("会话管理-查询会话失败: %v", err)
+		http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("查询会话失败")+"&type=error", http.StatusFound)
+		return
+	}
+	if session == nil {
+		http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("会话不存在或已下线")+"&type=error", http.StatusFound)
+		return
+	}
+
+	if reason := checkAdminTarget(currentUser, session.UserID, nil); reason != "" {
+		rejectAdminTarget(w, r, currentUser, operationlog.ActionRevokeSession, session.UserID, "强制下线会话（用户："+session.Username+"）", reason, redirectBase)
+		return
+	}
+
+	if err := auth.RevokeSession(sessionID); err != nil {
+		logger.Errorf("会话管理-强制下线失败: %v", err)
+		http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("强制下线失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	if currentUser != nil {
+		action := fmt.Sprintf("强制下线会话（用户：%s，IP：%s）", session.Username, session.IP)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionRevokeSession,
+			EntityType: operationlog.EntitySession,
+			EntityID:   operationlog.ID(session.UserID),
+			Message:    action,
+		})
+	}
+
+	http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("会话已强制下线")+"&type=success", http.StatusFound)
+}
+
+// RevokeUserSessionsHandler 强制下线某个用户的全部会话
+func RevokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/users/sessions", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+	redirectBase := sessionsRedirectBase(r.FormValue("filter_user_id"))
+
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userID <= 0 {
+		http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("用户ID无效")+"&type=error", http.StatusFound)
+		return
+	}
+
+	username := r.FormValue("username")
+	if reason := checkAdminTarget(currentUser, userID, nil); reason != "" {
+		rejectAdminTarget(w, r, currentUser, operationlog.ActionRevokeSession, userID, "强制下线用户全部会话（用户："+username+"）", reason, redirectBase)
+		return
+	}
+	count, err := auth.RevokeUserSessions(userID)
+	if err != nil {
+		logger.Errorf("会话管理-强制下线用户全部会话失败: %v, 用户ID: %d", err, userID)
+		http.Redirect(w, r, redirectBase+"message="+url.QueryEscape("强制下线失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	if currentUser != nil {
+		action := fmt.Sprintf("强制下线用户全部会话（用户：%s，用户ID：%d，共 %d 个会话）", username, userID, count)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionRevokeSession,
+			EntityType: operationlog.EntitySession,
+			EntityID:   operationlog.ID(userID),
+			Message:    action,
+		})
+	}
+
+	// 下线的是自己，当前会话已失效
+	if currentUser != nil && currentUser.ID == userID {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	message := fmt.Sprintf("已强制下线 %d 个会话", count)
+	http.Redirect(w, r, redirectBase+"message="+url.QueryEscape(message)+"&type=success", http.StatusFound)
+}
+
+// sessionsRedirectBase 构造返回会话列表的地址（保留用户筛选条件）
+func sessionsRedirectBase(filterUserID string) string {
+	if id, err := strconv.Atoi(filterUserID); err == nil && id > 0 {
+		return "/users/sessions?user_id=" + strconv.Itoa(id) + "&"
+	}
+	return "/users/sessions?"
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"ops-web/internal/auth"
+	"ops-web/internal/db"
+	"ops-web/internal/logger"
+	"ops-web/internal/operationlog"
+	"strconv"
+)
+
+// ResetTwoFactorHandler 重置用户的双因素认证（用户更换或丢失手机时由管理员操作）
+func ResetTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/users", http.StatusFound)
+		return
+	}
+
+	currentUser := auth.CurrentUser(r)
+
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userID <= 0 {
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("用户ID无效")+"&type=error", http.StatusFound)
+		return
+	}
+
+	var username string
+	err = db.DBInstance.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("用户不存在")+"&type=error", http.StatusFound)
+		return
+	}
+	if err != nil {
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("数据库查询失败")+"&type=error", http.StatusFound)
+		return
+	}
+
+	if reason := checkAdminTarget(currentUser, userID, nil); reason != "" {
+		rejectAdminTarget(w, r, currentUser, operationlog.ActionReset2FA, userID, "重置双因素认证（用户："+username+"）", reason, "/users?")
+		return
+	}
+
+	if err := auth.ResetTwoFactor(userID); err != nil {
+		logger.Errorf("用户管理-重置双因素认证失败: %v, 用户ID: %d", err, userID)
+		http.Redirect(w, r, "/users?message="+url.QueryEscape("重置双因素认证失败: "+err.Error())+"&type=error", http.StatusFound)
+		return
+	}
+
+	if currentUser != nil {
+		action := fmt.Sprintf("重置双因素认证（用户：%s，用户ID：%d）", username, userID)
+		operationlog.Record(r, currentUser.Username, operationlog.Entry{
+			Action:     operationlog.ActionReset2FA,
+			EntityType: operationlog.EntityUser,
+			EntityID:   operationlog.ID(userID),
+			Message:    action,
+		})
+	}
+
+	http.Redirect(w, r, "/users?message="+url.QueryEscape("已重置用户 "+username+" 的双因素认证")+"&type=success", http.StatusFound)
+}
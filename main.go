@@ -1,25 +1,52 @@
 package main
 
 import (
+    "context"
+    "flag"
     "fmt"
     "log"
     "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+    "ops-web/internal/accesslog"
+    "ops-web/internal/account"
     "ops-web/internal/auth"
     "ops-web/internal/auditprogress"
     "ops-web/internal/auditstatistics"
     "ops-web/internal/checkpointfilelist"
     "ops-web/internal/checkpointprogress"
     "ops-web/internal/db"
+    "ops-web/internal/diagnostics"
     "ops-web/internal/filelist"
+    "ops-web/internal/lifecycle"
     "ops-web/internal/logger"
+    "ops-web/internal/logintegrity"
     "ops-web/internal/operationlog"
     "ops-web/internal/statistics"
     "ops-web/internal/taskconfig"
+    "ops-web/internal/tlsserver"
     "ops-web/internal/user"
     "ops-web/internal/permission"
 )
 
 func main() {
+    // 命令行参数：ops-web [-config 配置文件] [子命令 ...]，未指定时使用 OPSWEB_CONFIG 环境变量或 config/config.json
+    defaultConfig := os.Getenv("OPSWEB_CONFIG")
+    if defaultConfig == "" {
+        defaultConfig = db.DefaultConfigPath
+    }
+    configPath := flag.String("config", defaultConfig, "配置文件路径（也可通过环境变量 OPSWEB_CONFIG 指定）")
+    flag.Parse()
+    db.ConfigPath = *configPath
+    args := flag.Args()
+
+    // 命令行配置工具（不连接数据库）：ops-web config check | encrypt
+    if len(args) > 0 && args[0] == "config" {
+        os.Exit(db.ConfigCommand(args[1:], os.Stdin, os.Stdout))
+    }
+
     // 0. 初始化日志系统
     if err := logger.InitLogger(); err != nil {
         log.Printf("警告: 初始化日志系统失败: %v", err)
@@ -32,31 +59,80 @@ func main() {
         log.Fatal("Failed to connect to database:", err)
     }
     defer db.DBInstance.Close()
-    
-    // 注意：DDL依赖已关闭，请手动执行SQL脚本创建数据库表
-    // 1.1. 初始化审核相关的数据库表（已禁用，请手动执行SQL）
-    // if err := db.InitAuditTables(); err != nil {
-    //     logger.Errorf("初始化审核表失败: %v", err)
-    //     log.Printf("警告: 初始化审核表失败: %v", err)
-    // }
-    
-    // 1.2. 初始化卡口审核相关的数据库表（已禁用，请手动执行SQL）
-    // if err := db.InitCheckpointTables(); err != nil {
-    //     logger.Errorf("初始化卡口审核表失败: %v", err)
-    //     log.Printf("警告: 初始化卡口审核表失败: %v", err)
-    // }
 
-    // 2. 注册路由
+    // 1.1. 按配置文件中的 log 节点重新设置日志级别、格式和文件切换
+    if err := logger.Configure(db.AppConfig.Log); err != nil {
+        logger.Errorf("日志配置无效，使用默认配置: %v", err)
+        log.Printf("警告: 日志配置无效，使用默认配置: %v", err)
+    }
+
+    // 1.1.1. 可信反向代理：只有来自这些地址的请求才采用 X-Forwarded-For 中的客户端地址
+    if err := operationlog.SetTrustedProxies(db.AppConfig.TrustedProxies); err != nil {
+        logger.Errorf("可信代理配置无效: %v", err)
+        log.Fatal("Invalid trusted_proxies: ", err)
+    }
+
+    // 命令行数据库迁移：ops-web migrate status | up | baseline <版本号>
+    if len(args) > 0 && args[0] == "migrate" {
+        code := db.MigrateCommand(args[1:], os.Stdout)
+        db.DBInstance.Close()
+        logger.Close()
+        os.Exit(code)
+    }
+
+    // 命令行校验操作日志哈希链：ops-web verify-log [seal]（0=完整，1=断链，2=校验出错）
+    if len(args) > 0 && args[0] == "verify-log" {
+        code := operationlog.VerifyCommand(args[1:], os.Stdout)
+        db.DBInstance.Close()
+        logger.Close()
+        os.Exit(code)
+    }
+
+    // 1.2. 检查数据库迁移（配置 auto_migrate 时自动执行待执行的迁移，否则有未执行的迁移时拒绝启动）
+    if err := db.MigrateOnStartup(); err != nil {
+        logger.Errorf("数据库迁移检查失败: %v", err)
+        log.Fatal("Database migration check failed: ", err)
+    }
+
+    // 1.3. 初始化会话存储（会话保存在 user_sessions 表，重启后无需重新登录）
+    auth.InitSessionStore()
+
+    // 1.4. 初始化登录认证后端（config.json 中启用 ldap 时使用目录认证）
+    auth.InitAuthenticators()
+
+    // 1.5. 初始化操作日志哈希链（加载密钥，首次启用时为已有日志计算哈希）
+    if err := operationlog.InitChain(); err != nil {
+        logger.Errorf("初始化操作日志哈希链失败: %v", err)
+        log.Fatal("Failed to initialize operation log chain:", err)
+    }
+    
+    // 2. 注册路由（RequirePermission 声明访问所需的权限，AllowAnyUser 表示登录即可访问）
     
     // ===== 认证路由（不需要登录） =====
     http.HandleFunc("/login", auth.LoginHandler)
+    http.HandleFunc("/login/2fa", auth.TwoFactorHandler)
+    http.HandleFunc("/login/change-password", auth.ExpiredPasswordHandler)
     http.HandleFunc("/logout", auth.LogoutHandler)
     
+    // ===== 会话超时提示（自行判断会话，查询状态不延长会话） =====
+    http.HandleFunc("/session/status", auth.SessionStatusHandler)
+    http.HandleFunc("/session/refresh", auth.SessionRefreshHandler)
+    http.HandleFunc("/session/timeout.js", auth.SessionScriptHandler)
+    
+    // ===== 健康检查（不需要登录，供负载均衡和监控探测） =====
+    http.HandleFunc("/healthz", diagnostics.HealthzHandler)
+    http.HandleFunc("/readyz", diagnostics.ReadyzHandler)
+    
     // ===== 根路径重定向 =====
     http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
         if r.URL.Path == "/" {
-            if auth.IsAuthenticated(r) {
-                http.Redirect(w, r, "/device/filelist", http.StatusFound)
+            if user := auth.GetCurrentUser(r); user != nil {
+                // 没有查看设备档案权限的用户进入“我的账号”，避免登录后直接看到权限不足提示
+                if user.Can(auth.PermDeviceView) {
+                    http.Redirect(w, r, "/device/filelist", http.StatusFound)
+                } else {
+                    http.Redirect(w, r, "/account", http.StatusFound)
+                }
             } else {
                 http.Redirect(w, r, "/login", http.StatusFound)
             }
@@ -65,19 +141,19 @@ func main() {
         http.NotFound(w, r)
     })
     
-    // ===== 统计信息路由（需要登录） =====
-    http.HandleFunc("/stats", auth.RequireAuth(statistics.Handler))               // 统计信息页面
-    http.HandleFunc("/stats/export", auth.RequireAuth(statistics.ExportHandler))  // 导出统计信息
+    // ===== 统计信息路由（需要查看统计权限） =====
+    http.HandleFunc("/stats", auth.RequirePermission(auth.PermStatsView, statistics.Handler))               // 统计信息页面
+    http.HandleFunc("/stats/export", auth.RequirePermission(auth.PermDataExport, statistics.ExportHandler))  // 导出统计信息
     
-    // ===== 建档明细路由（需要登录） =====
+    // ===== 建档明细路由（需要查看设备/卡口档案权限） =====
     // 设备建档明细（原/filelist路由）
-    http.HandleFunc("/device/filelist", auth.RequireAuth(filelist.Handler))
-    http.HandleFunc("/device/filelist/export", auth.RequireAuth(filelist.ExportHandler))
+    http.HandleFunc("/device/filelist", auth.RequirePermission(auth.PermDeviceView, filelist.Handler))
+    http.HandleFunc("/device/filelist/export", auth.RequirePermission(auth.PermDataExport, filelist.ExportHandler))
     // 兼容旧路由，重定向到新路由
-    http.HandleFunc("/filelist", auth.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+    http.HandleFunc("/filelist", auth.RequirePermission(auth.PermDeviceView, func(w http.ResponseWriter, r *http.Request) {
         http.Redirect(w, r, "/device/filelist", http.StatusFound)
     }))
-    http.HandleFunc("/filelist/export", auth.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+    http.HandleFunc("/filelist/export", auth.RequirePermission(auth.PermDataExport, func(w http.ResponseWriter, r *http.Request) {
         // 重定向到新的导出路由
         newURL := "/device/filelist/export" + r.URL.RawQuery
         if newURL != "" {
@@ -87,65 +163,89 @@ func main() {
     }))
     
     // 卡口建档明细
-    http.HandleFunc("/checkpoint/filelist", auth.RequireAuth(checkpointfilelist.Handler))
-    http.HandleFunc("/checkpoint/filelist/export", auth.RequireAuth(checkpointfilelist.ExportHandler))
+    http.HandleFunc("/checkpoint/filelist", auth.RequirePermission(auth.PermCheckpointView, checkpointfilelist.Handler))
+    http.HandleFunc("/checkpoint/filelist/export", auth.RequirePermission(auth.PermDataExport, checkpointfilelist.ExportHandler))
 
-    // ===== 审核进度路由（需要登录） =====
+    // ===== 审核进度路由（需要查看设备档案权限） =====
     // 注意：必须先注册子路由，再注册父路由
-    http.HandleFunc("/audit/progress", auth.RequireAuth(auditprogress.Handler))
-    http.HandleFunc("/audit/progress/import", auth.RequireAuth(auditprogress.ImportHandler))
-    http.HandleFunc("/audit/progress/detail", auth.RequireAuth(auditprogress.DetailHandler))
-    http.HandleFunc("/audit/progress/detail/export", auth.RequireAuth(auditprogress.DetailExportHandler))
-    http.HandleFunc("/audit/progress/edit", auth.RequireAuth(auditprogress.EditCommentHandler))
-    http.HandleFunc("/audit/progress/history", auth.RequireAuth(auditprogress.AuditHistoryHandler))
-    http.HandleFunc("/audit/progress/sample", auth.RequireAuth(auditprogress.SampleHandler))
-    http.HandleFunc("/audit/progress/sample/history", auth.RequireAuth(auditprogress.SampleHistoryHandler))
-    http.HandleFunc("/audit/progress/delete", auth.RequireAuth(auditprogress.DeleteHandler))
-    http.HandleFunc("/audit/progress/download-template", auth.RequireAuth(auditprogress.DownloadTemplateHandler))
-    http.HandleFunc("/audit/progress/upload", auth.RequireAuth(auditprogress.UploadHandler))
-    http.HandleFunc("/audit/progress/download", auth.RequireAuth(auditprogress.DownloadHandler))
-    http.HandleFunc("/audit/progress/video-reminders", auth.RequireAuth(auditprogress.VideoReminderHandler))
-    http.HandleFunc("/audit/progress/video-reminders/complete", auth.RequireAuth(auditprogress.CompleteReminderHandler))
-    http.HandleFunc("/audit/progress/video-reminders/delete", auth.RequireAuth(auditprogress.DeleteReminderHandler))
-    http.HandleFunc("/audit/progress/video-reminders/schedule", auth.RequireAuth(auditprogress.ScheduleConfigHandler))
-    http.HandleFunc("/audit/statistics", auth.RequireAuth(auditstatistics.Handler))
-    http.HandleFunc("/audit/statistics/export", auth.RequireAuth(auditstatistics.ExportHandler))
-    http.HandleFunc("/audit", auth.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+    http.HandleFunc("/audit/progress", auth.RequirePermission(auth.PermDeviceView, auditprogress.Handler))
+    http.HandleFunc("/audit/progress/import", auth.RequirePermission(auth.PermDeviceImport, auditprogress.ImportHandler))
+    http.HandleFunc("/audit/progress/import/confirm", auth.RequirePermission(auth.PermDeviceImport, auditprogress.ImportConfirmHandler))
+    http.HandleFunc("/audit/progress/import/errors", auth.RequirePermission(auth.PermDeviceImport, auditprogress.ImportErrorFileHandler))
+    http.HandleFunc("/audit/progress/detail", auth.RequirePermission(auth.PermDeviceView, auth.RequireTaskOrganization("audit_tasks", auditprogress.DetailHandler)))
+    http.HandleFunc("/audit/progress/detail/export", auth.RequirePermission(auth.PermDataExport, auth.RequireTaskOrganization("audit_tasks", auditprogress.DetailExportHandler)))
+    http.HandleFunc("/audit/progress/edit", auth.RequirePermission(auth.PermAuditEdit, auth.RequireTaskOrganization("audit_tasks", auditprogress.EditCommentHandler)))
+    http.HandleFunc("/audit/progress/history", auth.RequirePermission(auth.PermDeviceView, auth.RequireTaskOrganization("audit_tasks", auditprogress.AuditHistoryHandler)))
+    http.HandleFunc("/audit/progress/sample", auth.RequirePermission(auth.PermAuditSample, auth.RequireTaskOrganization("audit_tasks", auditprogress.SampleHandler)))
+    http.HandleFunc("/audit/progress/sample/history", auth.RequirePermission(auth.PermDeviceView, auth.RequireTaskOrganization("audit_tasks", auditprogress.SampleHistoryHandler)))
+    http.HandleFunc("/audit/progress/delete", auth.RequirePermission(auth.PermArchiveDelete, auth.RequireTaskOrganization("audit_tasks", auditprogress.DeleteHandler)))
+    http.HandleFunc("/audit/progress/download-template", auth.RequirePermission(auth.PermDeviceImport, auditprogress.DownloadTemplateHandler))
+    http.HandleFunc("/audit/progress/upload", auth.RequirePermission(auth.PermAttachmentUpload, auth.RequireTaskOrganization("audit_tasks", auditprogress.UploadHandler)))
+    http.HandleFunc("/audit/progress/download", auth.RequirePermission(auth.PermAttachmentDownload, auth.RequireTaskOrganization("audit_tasks", auditprogress.DownloadHandler)))
+    http.HandleFunc("/audit/progress/video-reminders", auth.RequirePermission(auth.PermDeviceView, auditprogress.VideoReminderHandler))
+    http.HandleFunc("/audit/progress/video-reminders/complete", auth.RequirePermission(auth.PermReminderManage, auditprogress.CompleteReminderHandler))
+    http.HandleFunc("/audit/progress/video-reminders/delete", auth.RequirePermission(auth.PermReminderManage, auditprogress.DeleteReminderHandler))
+    http.HandleFunc("/audit/progress/video-reminders/schedule", auth.RequirePermission(auth.PermReminderManage, auditprogress.ScheduleConfigHandler))
+    http.HandleFunc("/audit/statistics", auth.RequirePermission(auth.PermStatsView, auditstatistics.Handler))
+    http.HandleFunc("/audit/statistics/export", auth.RequirePermission(auth.PermDataExport, auditstatistics.ExportHandler))
+    http.HandleFunc("/audit", auth.RequirePermission(auth.PermDeviceView, func(w http.ResponseWriter, r *http.Request) {
         http.Redirect(w, r, "/audit/progress", http.StatusFound)
     }))
     
-    // ===== 卡口审核进度路由（需要登录） =====
-    http.HandleFunc("/checkpoint/progress", auth.RequireAuth(checkpointprogress.Handler))
-    http.HandleFunc("/checkpoint/progress/import", auth.RequireAuth(checkpointprogress.ImportHandler))
-    http.HandleFunc("/checkpoint/progress/detail", auth.RequireAuth(checkpointprogress.DetailHandler))
-    http.HandleFunc("/checkpoint/progress/detail/export", auth.RequireAuth(checkpointprogress.DetailExportHandler))
-    http.HandleFunc("/checkpoint/progress/edit", auth.RequireAuth(checkpointprogress.EditCommentHandler))
-    http.HandleFunc("/checkpoint/progress/history", auth.RequireAuth(checkpointprogress.AuditHistoryHandler))
-    http.HandleFunc("/checkpoint/progress/sample", auth.RequireAuth(checkpointprogress.SampleHandler))
-    http.HandleFunc("/checkpoint/progress/sample/history", auth.RequireAuth(checkpointprogress.SampleHistoryHandler))
-    http.HandleFunc("/checkpoint/progress/delete", auth.RequireAuth(checkpointprogress.DeleteHandler))
-    http.HandleFunc("/checkpoint/progress/download-template", auth.RequireAuth(checkpointprogress.DownloadTemplateHandler))
-    http.HandleFunc("/checkpoint/progress/upload", auth.RequireAuth(checkpointprogress.UploadHandler))
-    http.HandleFunc("/checkpoint/progress/download", auth.RequireAuth(checkpointprogress.DownloadHandler))
-
-    // ===== 用户管理路由（需要管理员权限） =====
-    http.HandleFunc("/users", auth.RequireAuth(user.Handler))
-    http.HandleFunc("/users/add", auth.RequireAdmin(user.AddHandler))
-    http.HandleFunc("/users/edit", auth.RequireAdmin(user.EditHandler))
-    http.HandleFunc("/users/delete", auth.RequireAdmin(user.DeleteHandler))
-
-    // ===== 操作日志（需要管理员权限） =====
-    http.HandleFunc("/logs", auth.RequireAdmin(operationlog.Handler))
-
-    // ===== 任务配置（需要管理员权限） =====
-    http.HandleFunc("/taskconfig", auth.RequireAdmin(taskconfig.Handler))
-    http.HandleFunc("/taskconfig/save", auth.RequireAdmin(taskconfig.SaveHandler))
-    http.HandleFunc("/taskconfig/backup-database", auth.RequireAdmin(taskconfig.BackupDatabaseHandler))
-    http.HandleFunc("/taskconfig/backup-files", auth.RequireAdmin(taskconfig.BackupFileHandler))
-
-    // ===== 权限设置（需要管理员权限） =====
-    http.HandleFunc("/permission", auth.RequireAdmin(permission.Handler))
-    http.HandleFunc("/permission/save", auth.RequireAdmin(permission.SaveHandler))
+    // ===== 卡口审核进度路由（需要查看卡口档案权限） =====
+    http.HandleFunc("/checkpoint/progress", auth.RequirePermission(auth.PermCheckpointView, checkpointprogress.Handler))
+    http.HandleFunc("/checkpoint/progress/import", auth.RequirePermission(auth.PermCheckpointImport, checkpointprogress.ImportHandler))
+    http.HandleFunc("/checkpoint/progress/import/confirm", auth.RequirePermission(auth.PermCheckpointImport, checkpointprogress.ImportConfirmHandler))
+    http.HandleFunc("/checkpoint/progress/import/errors", auth.RequirePermission(auth.PermCheckpointImport, checkpointprogress.ImportErrorFileHandler))
+    http.HandleFunc("/checkpoint/progress/detail", auth.RequirePermission(auth.PermCheckpointView, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.DetailHandler)))
+    http.HandleFunc("/checkpoint/progress/detail/export", auth.RequirePermission(auth.PermDataExport, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.DetailExportHandler)))
+    http.HandleFunc("/checkpoint/progress/edit", auth.RequirePermission(auth.PermAuditEdit, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.EditCommentHandler)))
+    http.HandleFunc("/checkpoint/progress/history", auth.RequirePermission(auth.PermCheckpointView, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.AuditHistoryHandler)))
+    http.HandleFunc("/checkpoint/progress/sample", auth.RequirePermission(auth.PermAuditSample, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.SampleHandler)))
+    http.HandleFunc("/checkpoint/progress/sample/history", auth.RequirePermission(auth.PermCheckpointView, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.SampleHistoryHandler)))
+    http.HandleFunc("/checkpoint/progress/delete", auth.RequirePermission(auth.PermArchiveDelete, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.DeleteHandler)))
+    http.HandleFunc("/checkpoint/progress/download-template", auth.RequirePermission(auth.PermCheckpointImport, checkpointprogress.DownloadTemplateHandler))
+    http.HandleFunc("/checkpoint/progress/upload", auth.RequirePermission(auth.PermAttachmentUpload, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.UploadHandler)))
+    http.HandleFunc("/checkpoint/progress/download", auth.RequirePermission(auth.PermAttachmentDownload, auth.RequireTaskOrganization("checkpoint_tasks", checkpointprogress.DownloadHandler)))
+
+    // ===== 用户管理路由（用户列表需要查看用户列表权限，其余需要用户管理权限） =====
+    http.HandleFunc("/users", auth.RequirePermission(auth.PermUserView, user.Handler))
+    http.HandleFunc("/users/add", auth.RequirePermission(auth.PermUserManage, user.AddHandler))
+    http.HandleFunc("/users/edit", auth.RequirePermission(auth.PermUserManage, user.EditHandler))
+    http.HandleFunc("/users/delete", auth.RequirePermission(auth.PermUserManage, user.DeleteHandler))
+    http.HandleFunc("/users/unlock", auth.RequirePermission(auth.PermUserManage, user.UnlockHandler))
+    http.HandleFunc("/users/reset-2fa", auth.RequirePermission(auth.PermUserManage, user.ResetTwoFactorHandler))
+    http.HandleFunc("/users/sessions", auth.RequirePermission(auth.PermUserManage, user.SessionsHandler))
+    http.HandleFunc("/users/sessions/revoke", auth.RequirePermission(auth.PermUserManage, user.RevokeSessionHandler))
+    http.HandleFunc("/users/sessions/revoke-user", auth.RequirePermission(auth.PermUserManage, user.RevokeUserSessionsHandler))
+    http.HandleFunc("/users/tokens", auth.RequirePermission(auth.PermUserManage, user.TokensHandler))
+    http.HandleFunc("/users/tokens/create", auth.RequirePermission(auth.PermUserManage, user.CreateTokenHandler))
+    http.HandleFunc("/users/tokens/revoke", auth.RequirePermission(auth.PermUserManage, user.RevokeTokenHandler))
+
+    // ===== 我的账号（登录即可访问） =====
+    http.HandleFunc("/account", auth.AllowAnyUser(account.Handler))
+    http.HandleFunc("/account/password", auth.AllowAnyUser(account.ChangePasswordHandler))
+    http.HandleFunc("/account/2fa/enable", auth.AllowAnyUser(account.EnableTwoFactorHandler))
+    http.HandleFunc("/account/2fa/disable", auth.AllowAnyUser(account.DisableTwoFactorHandler))
+
+    // ===== 操作日志（需要查看操作日志权限） =====
+    http.HandleFunc("/logs", auth.RequirePermission(auth.PermLogView, operationlog.Handler))
+    http.HandleFunc("/logs/export", auth.RequirePermission(auth.PermLogView, operationlog.ExportHandler))
+    http.HandleFunc("/logs/verify", auth.RequirePermission(auth.PermLogView, logintegrity.Handler))
+    http.HandleFunc("/logs/archive", auth.RequirePermission(auth.PermSystemManage, logintegrity.ArchiveHandler))
+
+    // ===== 任务配置（需要系统设置权限） =====
+    http.HandleFunc("/taskconfig", auth.RequirePermission(auth.PermSystemManage, taskconfig.Handler))
+    http.HandleFunc("/taskconfig/save", auth.RequirePermission(auth.PermSystemManage, taskconfig.SaveHandler))
+    http.HandleFunc("/taskconfig/backup-database", auth.RequirePermission(auth.PermSystemManage, taskconfig.BackupDatabaseHandler))
+    http.HandleFunc("/taskconfig/backup-files", auth.RequirePermission(auth.PermSystemManage, taskconfig.BackupFileHandler))
+    http.HandleFunc("/diagnostics", auth.RequirePermission(auth.PermSystemManage, diagnostics.Handler))
+
+    // ===== 权限设置（需要系统设置权限） =====
+    http.HandleFunc("/permission", auth.RequirePermission(auth.PermSystemManage, permission.Handler))
+    http.HandleFunc("/permission/save", auth.RequirePermission(auth.PermSystemManage, permission.SaveHandler))
+    http.HandleFunc("/permission/roles/add", auth.RequirePermission(auth.PermSystemManage, permission.RoleAddHandler))
+    http.HandleFunc("/permission/roles/delete", auth.RequirePermission(auth.PermSystemManage, permission.RoleDeleteHandler))
 
     // 2.1. 初始化定时任务调度器
     taskconfig.InitScheduler()
@@ -155,12 +255,93 @@ func main() {
 
     // 3. 启动服务
     serverAddr := ":" + db.AppConfig.ServerPort
-    baseURL := fmt.Sprintf("http://%s:%s", db.AppConfig.ServerHost, db.AppConfig.ServerPort)
+    scheme := "http"
+    if db.AppConfig.TLS.Enabled {
+        scheme = "https"
+    }
+    baseURL := fmt.Sprintf("%s://%s:%s", scheme, db.AppConfig.ServerHost, db.AppConfig.ServerPort)
     
     log.Printf("Server starting on %s", baseURL)
+    logger.Infof("服务启动: %s（版本 %s）", baseURL, diagnostics.Version)
     
-    if err := http.ListenAndServe(serverAddr, nil); err != nil {
+    // 所有会修改数据的请求都须携带 CSRF 令牌；最外层分配请求ID并记录访问日志
+    handler := accesslog.Middleware(auth.CSRFProtect(http.DefaultServeMux))
+
+    server := &http.Server{
+        Addr:    serverAddr,
+        Handler: handler,
+    }
+
+    // 3.1. 内置 HTTPS：证书文件更新或收到 SIGHUP 时重新加载证书，Cookie 只通过 HTTPS 发送
+    var redirectServer *http.Server
+    if db.AppConfig.TLS.Enabled {
+        minVersion, _ := db.AppConfig.TLS.TLSMinVersion()
+        reloader, err := tlsserver.NewCertReloader(db.AppConfig.TLS.CertFile, db.AppConfig.TLS.KeyFile)
+        if err != nil {
+            logger.Errorf("HTTPS启动失败: %v", err)
+            log.Fatal(err)
+        }
+        server.TLSConfig = tlsserver.ServerConfig(minVersion, reloader)
+        auth.SetSecureCookies(true)
+
+        interval := time.Duration(db.AppConfig.TLS.ReloadIntervalSeconds) * time.Second
+        lifecycle.Go("证书热加载", func(ctx context.Context) {
+            reloader.Watch(ctx, interval)
+        })
+
+        // 3.2. HTTP 端口重定向到 HTTPS
+        if port := db.AppConfig.TLS.HTTPRedirectPort; port != "" {
+            redirectServer = &http.Server{
+                Addr:    ":" + port,
+                Handler: tlsserver.RedirectHandler(db.AppConfig.ServerPort),
+            }
+            go func() {
+                if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+                    logger.Errorf("HTTP重定向服务启动失败: %v", err)
+                }
+            }()
+            logger.Infof("HTTP重定向服务启动: 端口 %s 的请求重定向到 HTTPS", port)
+        }
+    }
+
+    // 收到 SIGINT/SIGTERM 后停止接收新请求，等待进行中的请求和后台任务完成
+    stopSignal, stopNotify := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stopNotify()
+
+    serverErr := make(chan error, 1)
+    go func() {
+        if server.TLSConfig != nil {
+            // 证书由 TLSConfig.GetCertificate 提供
+            serverErr <- server.ListenAndServeTLS("", "")
+            return
+        }
+        serverErr <- server.ListenAndServe()
+    }()
+
+    select {
+    case err := <-serverErr:
         logger.Errorf("HTTP服务启动失败: %v", err)
         log.Fatal(err)
+    case <-stopSignal.Done():
+    }
+    // 恢复默认信号处理：等待期间再次按 Ctrl+C 立即退出
+    stopNotify()
+
+    // 4. 停止服务：定时任务不再启动新任务，等待进行中的请求、备份和导入完成，超时后中断（导入回滚、删除未写完的备份文件）
+    timeout := time.Duration(db.AppConfig.ShutdownTimeoutSeconds) * time.Second
+    logger.Infof("服务停止-收到停止信号，等待进行中的请求和后台任务完成（最长 %v）", timeout)
+    lifecycle.Begin()
+
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    if redirectServer != nil {
+        redirectServer.Shutdown(ctx)
+    }
+    if err := server.Shutdown(ctx); err != nil {
+        logger.Warnf("服务停止-等待请求结束超时: %v", err)
+    }
+    if remaining := lifecycle.Wait(ctx, 10*time.Second); len(remaining) > 0 {
+        logger.Errorf("服务停止-以下任务未能在限定时间内结束: %v", remaining)
     }
+    logger.Infof("服务已停止")
 }
\ No newline at end of file